@@ -0,0 +1,169 @@
+// Command keyring manage signing keys of access and id tokens kept in JWT_KEYRING_FILE,
+// or in database if JWT_MASTER_KEY is set.
+//
+//	keyring list
+//	keyring introduce [-alg EdDSA] [-key private.pem]
+//	keyring promote <kid>
+//	keyring retire <kid>
+//
+// Rotation is: introduce new key, so verifiers fetch it from jwks in advance, promote it,
+// then retire old key when access tokens signed with it are expired.
+// Keyring in database is rotated by instances on schedule, command is for manual rotation, e.g. when key is compromised.
+// Instances reload keyring every JWT_KEYRING_RELOAD_INTERVAL, so restart isn't needed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"medods/config"
+	"medods/internal/repository"
+	"medods/internal/service"
+	"medods/internal/service/jwt"
+	"medods/pkg/logger"
+	"medods/pkg/postgres"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage:
+	keyring list
+	keyring introduce [-alg HS512|RS512|ES512|EdDSA] [-key private.pem]
+	keyring promote <kid>
+	keyring retire <kid>`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cfg, err := config.MustLoad()
+	if err != nil {
+		return err
+	}
+	if !cfg.JWT.Keyring() {
+		return fmt.Errorf("neither JWT_KEYRING_FILE nor JWT_MASTER_KEY is set")
+	}
+	l := logger.New(cfg.Log.Level, false)
+
+	repo := &repository.Manager{}
+	if cfg.JWT.MasterKey != "" {
+		pg, err := postgres.New(&postgres.Config{DSN: cfg.PG.DSN, MigrationURL: cfg.PG.MigrationURL})
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+		repo = repository.New(pg.Conn)
+	}
+	store, err := service.NewKeyStore(cfg, repo)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cmd, args := args[0], args[1:]
+
+	var op func(keyring *jwt.Keyring) (string, error)
+	switch cmd {
+	case "list":
+		keys, err := store.Load(ctx)
+		if err != nil {
+			return err
+		}
+		keyring, err := jwt.NewKeyring(keys, cfg.Auth.MaxTokenAge())
+		if err != nil {
+			return err
+		}
+		return list(keyring)
+	case "introduce":
+		op = func(keyring *jwt.Keyring) (string, error) {
+			return introduce(keyring, args)
+		}
+	case "promote":
+		op = withKID(args, (*jwt.Keyring).Promote)
+	case "retire":
+		op = withKID(args, (*jwt.Keyring).Retire)
+	default:
+		return errors.New(usage)
+	}
+
+	var keyring *jwt.Keyring
+	var kid string
+	err = store.Update(ctx, func(keys []jwt.Key) ([]jwt.Key, error) {
+		parsed, err := jwt.NewKeyring(keys, cfg.Auth.MaxTokenAge())
+		if err != nil {
+			return nil, err
+		}
+		changed, err := op(parsed)
+		if err != nil {
+			return nil, err
+		}
+		keyring, kid = parsed, changed
+		return parsed.Keys(), nil
+	})
+	if err != nil {
+		return err
+	}
+	l.Warn("[SECURITY] signing keyring changed by command: %s kid[%s]", cmd, kid)
+	return list(keyring)
+}
+
+func list(keyring *jwt.Keyring) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATE\tCREATED\tDEMOTED")
+	for _, key := range keyring.Keys() {
+		demoted := "-"
+		if key.DemotedAt != 0 {
+			demoted = time.Unix(key.DemotedAt, 0).Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, key.State,
+			time.Unix(key.CreatedAt, 0).Format(time.RFC3339), demoted)
+	}
+	return w.Flush()
+}
+
+// introduce generate new key or import PEM of existing one, return kid of introduced key
+func introduce(keyring *jwt.Keyring, args []string) (string, error) {
+	flags := flag.NewFlagSet("introduce", flag.ContinueOnError)
+	alg := flags.String("alg", "EdDSA", "signing method of key")
+	path := flags.String("key", "", "PEM of private key, new key is generated if empty")
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+
+	var key jwt.Key
+	var err error
+	if *path == "" {
+		key, err = jwt.GenerateKey(*alg, time.Now())
+	} else {
+		var data []byte
+		data, err = os.ReadFile(*path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key: %w", err)
+		}
+		key, err = jwt.NewKey(*alg, data, time.Now())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return key.ID, keyring.Introduce(key)
+}
+
+func withKID(args []string, op func(keyring *jwt.Keyring, kid string) error) func(keyring *jwt.Keyring) (string, error) {
+	return func(keyring *jwt.Keyring) (string, error) {
+		if len(args) != 1 {
+			return "", errors.New(usage)
+		}
+		return args[0], op(keyring, args[0])
+	}
+}
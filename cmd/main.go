@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
 	"medods/config"
 	"medods/internal/repository"
 	"medods/internal/service"
+	"medods/internal/service/denylist"
+	"medods/internal/service/jwt"
 	router "medods/internal/transport/http"
+	"medods/pkg/geoip"
 	httpserver "medods/pkg/httpServer"
 	"medods/pkg/logger"
 	"medods/pkg/postgres"
@@ -59,15 +63,33 @@ func main() {
 		From:     config.SMTP.FROM,
 	})
 
-	service := service.New(config, repo, smtp, logger)
+	service, err := service.New(config, repo, smtp, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	go denylist.RunPruner(jobCtx, service.Denylist, denylist.PruneInterval, logger)
+	go service.RunPruner(jobCtx, logger)
+	if service.KeyRotator != nil {
+		go jwt.RunRotation(jobCtx, service.KeyRotator, config.JWT.KeyringReloadInterval, logger)
+	}
+	if service.GeoIP != nil {
+		go geoip.RunReload(jobCtx, service.GeoIP, config.GeoIP.ReloadInterval, logger)
+	}
 
-	router := router.NewRouter(service, logger)
+	router := router.NewRouter(service, &router.Config{
+		DevMode:       config.App.DevMode,
+		Issuer:        config.OAuth.Issuer,
+		SecureCookies: config.MagicLink.Secure(),
+	}, logger)
 
 	server := httpserver.New(router, &httpserver.Config{
 		Port: config.HTTP.Port,
 	})
 
 	gracefullShutdown(func() {
+		stopJobs()
 		if err := server.Shutdown(); err != nil {
 			log.Fatalf("Gracefull shutdown is failed: %s", err.Error())
 		}
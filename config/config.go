@@ -1,23 +1,195 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type (
 	// Config -.
 	Config struct {
+		App  `yaml:"app"`
 		HTTP `yaml:"http"`
 		Log  `yaml:"logger"`
 		PG
 		JWT
-		SMTP `yaml:"smtp"`
+		Auth              `yaml:"auth"`
+		Password          `yaml:"password"`
+		EmailVerification `yaml:"email_verification"`
+		PasswordReset     `yaml:"password_reset"`
+		MagicLink         `yaml:"magic_link"`
+		OneTimeToken      `yaml:"one_time_token"`
+		MFA               `yaml:"mfa"`
+		WebAuthn          `yaml:"webauthn"`
+		OAuth             `yaml:"oauth"`
+		IPPolicy          `yaml:"ip_policy"`
+		GeoIP             `yaml:"geoip"`
+		SMTP              `yaml:"smtp"`
+		Introspection     `yaml:"introspection"`
+	}
+
+	App struct {
+		// enables routes for testing, e.g. login by user id without password
+		DevMode bool `yaml:"dev_mode" env:"DEV_MODE" env-default:"false"`
 	}
 
 	JWT struct {
 		SecretKey string `env-required:"true" env:"SECRET_KEY"`
+		// HS512 signs with secret key, RS512, ES512 and EdDSA with private key and publish public one in jwks
+		SigningMethod string `env:"JWT_SIGNING_METHOD" env-default:"HS512"`
+		// path to PEM private key, required for asymmetric signing methods
+		PrivateKeyFile string `env:"JWT_PRIVATE_KEY_FILE"`
+		// path to keyring managed by cmd/keyring, signing method and private key are ignored if it's set
+		KeyringFile string `env:"JWT_KEYRING_FILE"`
+		// base64 of 32 bytes, if it's set keyring is kept in database encrypted with it and rotated on schedule,
+		// keys of signing method are generated, so private key is ignored
+		MasterKey string `env:"JWT_MASTER_KEY"`
+		// active key of keyring in database is replaced when it has been signing this long, 0 disables rotation
+		RotationInterval time.Duration `env:"JWT_ROTATION_INTERVAL" env-default:"720h"`
+		// next key is published in jwks this long before it starts signing
+		KeyPrePublish time.Duration `env:"JWT_KEY_PREPUBLISH" env-default:"1h"`
+		// how often instances check keyring for changes made by other instances or by cmd/keyring
+		KeyringReloadInterval time.Duration `env:"JWT_KEYRING_RELOAD_INTERVAL" env-default:"1m"`
+		// RFC 3339 time until which HS512 tokens are still accepted after switch to asymmetric method,
+		// refresh needs old access token, so window should cover refresh token lifetime
+		HS512AcceptedUntil time.Time `env:"JWT_HS512_ACCEPTED_UNTIL" env-layout:"2006-01-02T15:04:05Z07:00"`
+	}
+
+	Auth struct {
+		ATokenLifetime time.Duration `yaml:"access_token_lifetime" env:"ACCESS_TOKEN_LIFETIME" env-default:"30m"`
+		RTokenLifetime time.Duration `yaml:"refresh_token_lifetime" env:"REFRESH_TOKEN_LIFETIME" env-default:"720h"`
+		// count of random bytes in refresh token, before base64 encoding
+		RTokenBytes int `yaml:"refresh_token_bytes" env:"REFRESH_TOKEN_BYTES" env-default:"52"`
+		// hash of refresh token: bcrypt or hmac-sha256, hashes of the other format are replaced on refresh
+		RTokenHasher string `yaml:"refresh_token_hasher" env:"REFRESH_TOKEN_HASHER" env-default:"bcrypt"`
+		// cost of bcrypt hash of refresh token
+		BcryptCost int `yaml:"bcrypt_cost" env:"BCRYPT_COST" env-default:"10"`
+		// base64 key of hmac-sha256 hash of refresh token, changing it invalidates refresh tokens hashed with it.
+		// Keep it after switch back to bcrypt, so refresh tokens hashed with hmac-sha256 stay valid.
+		RTokenHMACKey string `yaml:"refresh_token_hmac_key" env:"REFRESH_TOKEN_HMAC_KEY"`
+		// allowed clock skew between services when checking exp, iat and nbf
+		Leeway time.Duration `yaml:"leeway" env:"TOKEN_LEEWAY" env-default:"0s"`
+	}
+
+	// argon2id parameters of password hash, hashes with other parameters are upgraded on login
+	Password struct {
+		// memory in KiB
+		Memory      uint32 `yaml:"argon2_memory" env:"ARGON2_MEMORY" env-default:"65536"`
+		Time        uint32 `yaml:"argon2_time" env:"ARGON2_TIME" env-default:"3"`
+		Parallelism uint8  `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM" env-default:"2"`
+	}
+
+	EmailVerification struct {
+		// link sent to new users, token is added as query parameter
+		URL           string        `yaml:"url" env:"EMAIL_VERIFICATION_URL" env-default:"http://localhost:8080/api/v1/user/verify"`
+		TokenLifetime time.Duration `yaml:"token_lifetime" env:"EMAIL_VERIFICATION_TOKEN_LIFETIME" env-default:"24h"`
+		// refuse to open sessions for users with unverified email
+		Required bool `yaml:"required" env:"EMAIL_VERIFICATION_REQUIRED" env-default:"false"`
+	}
+
+	PasswordReset struct {
+		// page of client app which posts token and new password to reset endpoint, token is added as query parameter
+		URL           string        `yaml:"url" env:"PASSWORD_RESET_URL" env-default:"http://localhost:8080/password/reset"`
+		TokenLifetime time.Duration `yaml:"token_lifetime" env:"PASSWORD_RESET_TOKEN_LIFETIME" env-default:"15m"`
+	}
+
+	MagicLink struct {
+		// endpoint which consumes login link, token is added as query parameter
+		URL           string        `yaml:"url" env:"MAGIC_LINK_URL" env-default:"http://localhost:8080/api/v1/auth/magic-link/consume"`
+		TokenLifetime time.Duration `yaml:"token_lifetime" env:"MAGIC_LINK_TOKEN_LIFETIME" env-default:"10m"`
+	}
+
+	// tokens of email verification, password reset and magic link
+	OneTimeToken struct {
+		// base64 of 32 bytes, signs tokens, changing it invalidates links which are already sent
+		SecretKey string `env-required:"true" env:"ONETIME_TOKEN_SECRET_KEY"`
+	}
+
+	MFA struct {
+		// base64 of 32 bytes, encrypts totp secrets in database, secrets can't be read with other key
+		EncryptionKey string `env-required:"true" env:"MFA_ENCRYPTION_KEY"`
+		// name of service shown by authenticator app
+		Issuer string `yaml:"issuer" env:"MFA_ISSUER" env-default:"medods"`
+		// time given to enter code of second factor after password is verified
+		ChallengeLifetime time.Duration `yaml:"challenge_lifetime" env:"MFA_CHALLENGE_LIFETIME" env-default:"5m"`
+	}
+
+	WebAuthn struct {
+		// relying party id, registrable domain of site, passkeys are bound to it and stop working if it's changed
+		RPID string `yaml:"rp_id" env:"WEBAUTHN_RP_ID" env-default:"localhost"`
+		// name of site shown by authenticator
+		RPName string `yaml:"rp_name" env:"WEBAUTHN_RP_NAME" env-default:"medods"`
+		// origins of pages which run ceremonies, hosts must be rp id or its subdomains
+		Origins []string `yaml:"origins" env:"WEBAUTHN_ORIGINS" env-separator:"," env-default:"http://localhost:8080"`
+		// time given to finish registration or login
+		ChallengeLifetime time.Duration `yaml:"challenge_lifetime" env:"WEBAUTHN_CHALLENGE_LIFETIME" env-default:"5m"`
+		// base64 of 32 bytes, fake passkeys of unknown emails are derived from it, must be the same on every instance
+		SecretKey string `env-required:"true" env:"WEBAUTHN_SECRET_KEY"`
+	}
+
+	OAuth struct {
+		// url of OpenID Connect provider, discovery document is served at <issuer>/.well-known/openid-configuration
+		Issuer string `yaml:"issuer" env:"OAUTH_ISSUER" env-default:"http://localhost:8080"`
+		// time given to client to exchange authorization code for tokens
+		CodeLifetime time.Duration `yaml:"code_lifetime" env:"OAUTH_CODE_LIFETIME" env-default:"1m"`
+		// registered clients, set only in config file
+		Clients []OAuthClient `yaml:"clients"`
+	}
+
+	OAuthClient struct {
+		ID string `yaml:"id"`
+		// empty for public clients, e.g. SPA and mobile apps
+		Secret string `yaml:"secret"`
+		// exact uris where user could be redirected with authorization code
+		RedirectURIs []string `yaml:"redirect_uris"`
+		// scopes which client may request
+		Scopes []string `yaml:"scopes"`
+	}
+
+	// outcome of refresh from other ip than tokens were issued to: allow, notify, step-up or deny.
+	// The most lenient outcome of matched trusted situations is taken, rapid change makes it stricter.
+	IPPolicy struct {
+		// CIDRs, e.g. egress of office VPN, refresh from them is always allowed without notification
+		TrustedNetworks []string `yaml:"trusted_networks" env:"IP_POLICY_TRUSTED_NETWORKS" env-separator:","`
+		// ip matches no trusted situation, outcomes of trusted situations only lower it
+		Default string `yaml:"default" env:"IP_POLICY_DEFAULT" env-default:"notify"`
+		// ip is in the same subnet as ip of session, mobile carriers give addresses of one range
+		SameSubnet   string `yaml:"same_subnet" env:"IP_POLICY_SAME_SUBNET" env-default:"allow"`
+		SubnetBitsV4 int    `yaml:"subnet_bits_v4" env:"IP_POLICY_SUBNET_BITS_V4" env-default:"24"`
+		SubnetBitsV6 int    `yaml:"subnet_bits_v6" env:"IP_POLICY_SUBNET_BITS_V6" env-default:"56"`
+		// ip is in the same country as ip of session, needs geoip database
+		SameCountry string `yaml:"same_country" env:"IP_POLICY_SAME_COUNTRY" env-default:"notify"`
+		// other active session of user is refreshed from ip
+		KnownIP string `yaml:"known_ip" env:"IP_POLICY_KNOWN_IP" env-default:"notify"`
+		// ip changed sooner than window after last refresh, zero disables rule
+		RapidChangeWindow time.Duration `yaml:"rapid_change_window" env:"IP_POLICY_RAPID_CHANGE_WINDOW" env-default:"0s"`
+		RapidChange       string        `yaml:"rapid_change" env:"IP_POLICY_RAPID_CHANGE" env-default:"step-up"`
+		// km/h, location of ip is farther from location of session ip than it's possible to get since last refresh,
+		// airliners fly about 900 km/h, needs geoip database with coordinates, zero disables rule
+		MaxTravelSpeed float64 `yaml:"max_travel_speed" env:"IP_POLICY_MAX_TRAVEL_SPEED" env-default:"1000"`
+		// step-up or deny, user is emailed either way
+		ImpossibleTravel string `yaml:"impossible_travel" env:"IP_POLICY_IMPOSSIBLE_TRAVEL" env-default:"step-up"`
+	}
+
+	GeoIP struct {
+		// path to MaxMind DB file, e.g. GeoLite2-City.mmdb, empty disables geo of sessions and same country rule
+		DBPath string `yaml:"db_path" env:"GEOIP_DB_PATH"`
+		// how often file is checked for changes, replaced file is loaded without restart
+		ReloadInterval time.Duration `yaml:"reload_interval" env:"GEOIP_RELOAD_INTERVAL" env-default:"1m"`
+	}
+
+	Introspection struct {
+		// client_id:client_secret pairs of services allowed to introspect tokens
+		Clients map[string]string `yaml:"clients" env:"INTROSPECTION_CLIENTS" env-separator:","`
 	}
 
 	HTTP struct {
@@ -55,5 +227,370 @@ func MustLoad() (*Config, error) {
 		return nil, fmt.Errorf("read env error: %w", err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return cfg, nil
 }
+
+// refresh token is base64 encoded and bcrypt uses only first 72 bytes of input
+const (
+	minRTokenBytes = 32
+	maxRTokenBytes = 54
+)
+
+// reset and login links give full access to account, so they must not live long
+const (
+	maxPasswordResetTokenLifetime = time.Hour
+	maxMagicLinkTokenLifetime     = 15 * time.Minute
+	maxMFAChallengeLifetime       = 15 * time.Minute
+	maxWebAuthnChallengeLifetime  = 15 * time.Minute
+	// RFC 6749 section 4.1.2
+	maxOAuthCodeLifetime = 10 * time.Minute
+)
+
+// Key return decoded encryption key of totp secrets
+func (m MFA) Key() ([]byte, error) {
+	return decodeKey("mfa encryption key", m.EncryptionKey)
+}
+
+// Key return decoded key of fake passkeys
+func (w WebAuthn) Key() ([]byte, error) {
+	return decodeKey("webauthn secret key", w.SecretKey)
+}
+
+// Secure is true if login link is https, nonce cookie is sent only over https then
+func (m MagicLink) Secure() bool {
+	u, err := url.Parse(m.URL)
+	return err == nil && u.Scheme == "https"
+}
+
+// Key return decoded signing key of one-time tokens
+func (o OneTimeToken) Key() ([]byte, error) {
+	return decodeKey("one-time token secret key", o.SecretKey)
+}
+
+// decodeKey decode base64 key of 32 bytes, name is used in errors
+func decodeKey(name, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be base64: %w", name, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes, got %d", name, len(key))
+	}
+	return key, nil
+}
+
+// hashers of refresh token
+const (
+	RTokenHasherBcrypt = "bcrypt"
+	RTokenHasherHMAC   = "hmac-sha256"
+)
+
+// HMACKey return decoded key of hmac-sha256 hash of refresh token, it's as long as hash at least
+func (a Auth) HMACKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(a.RTokenHMACKey)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token hmac key must be base64: %w", err)
+	}
+	if len(key) < 32 {
+		return nil, fmt.Errorf("refresh token hmac key must be at least 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Networks return parsed trusted networks of ip policy
+func (p IPPolicy) Networks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(p.TrustedNetworks))
+	for _, cidr := range p.TrustedNetworks {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("ip policy trusted network must be CIDR, got %q", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// MaxTokenAge is time after issue when access token may be still accepted,
+// signing key is retired only after it passed since key was demoted
+func (a Auth) MaxTokenAge() time.Duration {
+	return a.ATokenLifetime + a.Leeway
+}
+
+// Keyring report whether keys are kept in keyring file or database instead of config
+func (j JWT) Keyring() bool {
+	return j.KeyringFile != "" || j.MasterKey != ""
+}
+
+// Master return decoded master key of keyring in database
+func (j JWT) Master() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(j.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("jwt master key must be base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("jwt master key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Asymmetric report whether tokens could be verified without secret key, keyring file may have keys of any method
+func (j JWT) Asymmetric() bool {
+	return j.KeyringFile != "" || j.SigningMethod != jwtHS512
+}
+
+// PrivateKey return PEM of private key of asymmetric signing method, nil for HS512 and keyring
+func (j JWT) PrivateKey() ([]byte, error) {
+	if j.SigningMethod == jwtHS512 || j.Keyring() {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(j.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt private key: %w", err)
+	}
+	return data, nil
+}
+
+const jwtHS512 = "HS512"
+
+var jwtSigningMethods = []string{jwtHS512, "RS512", "ES512", "EdDSA"}
+
+// scope of OAuth 2.0 client which gets ID tokens
+const oauthScopeOpenID = "openid"
+
+var ipPolicyOutcomes = []string{"allow", "notify", "step-up", "deny"}
+
+// outcomes which refuse refresh
+var ipPolicyRefusals = []string{"step-up", "deny"}
+
+func (c *Config) Validate() error {
+	if !slices.Contains(jwtSigningMethods, c.JWT.SigningMethod) {
+		return fmt.Errorf("jwt signing method must be one of %v, got %q", jwtSigningMethods, c.JWT.SigningMethod)
+	}
+	if c.JWT.SigningMethod != jwtHS512 && c.JWT.PrivateKeyFile == "" && !c.JWT.Keyring() {
+		return fmt.Errorf("jwt private key file is required for %s", c.JWT.SigningMethod)
+	}
+	if c.JWT.KeyringFile != "" && c.JWT.MasterKey != "" {
+		return fmt.Errorf("jwt keyring must be either in file or in database, not both")
+	}
+	if c.JWT.MasterKey != "" {
+		if _, err := c.JWT.Master(); err != nil {
+			return err
+		}
+		if c.JWT.RotationInterval < 0 {
+			return fmt.Errorf("jwt rotation interval must not be negative, got %s", c.JWT.RotationInterval)
+		}
+		if c.JWT.RotationInterval > 0 && (c.JWT.KeyPrePublish < 0 || c.JWT.KeyPrePublish >= c.JWT.RotationInterval) {
+			return fmt.Errorf("jwt key pre-publish must be in [0, rotation interval), got %s", c.JWT.KeyPrePublish)
+		}
+	}
+	if c.JWT.Keyring() && c.JWT.KeyringReloadInterval <= 0 {
+		return fmt.Errorf("jwt keyring reload interval must be positive, got %s", c.JWT.KeyringReloadInterval)
+	}
+
+	if c.Auth.ATokenLifetime <= 0 {
+		return fmt.Errorf("access token lifetime must be positive, got %s", c.Auth.ATokenLifetime)
+	}
+	if c.Auth.RTokenLifetime <= c.Auth.ATokenLifetime {
+		return fmt.Errorf("refresh token lifetime %s must be longer than access token lifetime %s",
+			c.Auth.RTokenLifetime, c.Auth.ATokenLifetime)
+	}
+	if c.Auth.RTokenBytes < minRTokenBytes || c.Auth.RTokenBytes > maxRTokenBytes {
+		return fmt.Errorf("refresh token bytes must be in [%d, %d], got %d",
+			minRTokenBytes, maxRTokenBytes, c.Auth.RTokenBytes)
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be in [%d, %d], got %d",
+			bcrypt.MinCost, bcrypt.MaxCost, c.Auth.BcryptCost)
+	}
+	switch c.Auth.RTokenHasher {
+	case RTokenHasherBcrypt:
+		// key of hashes made before rollback from hmac-sha256
+		if c.Auth.RTokenHMACKey != "" {
+			if _, err := c.Auth.HMACKey(); err != nil {
+				return err
+			}
+		}
+	case RTokenHasherHMAC:
+		if _, err := c.Auth.HMACKey(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("refresh token hasher must be one of [%s %s], got %q",
+			RTokenHasherBcrypt, RTokenHasherHMAC, c.Auth.RTokenHasher)
+	}
+	if c.Auth.Leeway < 0 || c.Auth.Leeway >= c.Auth.ATokenLifetime {
+		return fmt.Errorf("leeway must be in [0, access token lifetime), got %s", c.Auth.Leeway)
+	}
+
+	if c.Password.Time < 1 {
+		return fmt.Errorf("argon2 time must be positive, got %d", c.Password.Time)
+	}
+	if c.Password.Parallelism < 1 {
+		return fmt.Errorf("argon2 parallelism must be positive, got %d", c.Password.Parallelism)
+	}
+	if c.Password.Memory < 8*uint32(c.Password.Parallelism) {
+		return fmt.Errorf("argon2 memory must be at least 8*parallelism KiB, got %d", c.Password.Memory)
+	}
+
+	if c.EmailVerification.TokenLifetime <= 0 {
+		return fmt.Errorf("email verification token lifetime must be positive, got %s", c.EmailVerification.TokenLifetime)
+	}
+	if u, err := url.Parse(c.EmailVerification.URL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("email verification url must be absolute, got %q", c.EmailVerification.URL)
+	}
+
+	if c.PasswordReset.TokenLifetime <= 0 || c.PasswordReset.TokenLifetime > maxPasswordResetTokenLifetime {
+		return fmt.Errorf("password reset token lifetime must be in (0, %s], got %s",
+			maxPasswordResetTokenLifetime, c.PasswordReset.TokenLifetime)
+	}
+	if u, err := url.Parse(c.PasswordReset.URL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("password reset url must be absolute, got %q", c.PasswordReset.URL)
+	}
+
+	if c.MagicLink.TokenLifetime <= 0 || c.MagicLink.TokenLifetime > maxMagicLinkTokenLifetime {
+		return fmt.Errorf("magic link token lifetime must be in (0, %s], got %s",
+			maxMagicLinkTokenLifetime, c.MagicLink.TokenLifetime)
+	}
+	if u, err := url.Parse(c.MagicLink.URL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("magic link url must be absolute, got %q", c.MagicLink.URL)
+	}
+
+	if _, err := c.OneTimeToken.Key(); err != nil {
+		return err
+	}
+
+	if _, err := c.MFA.Key(); err != nil {
+		return err
+	}
+	// issuer is a part of label in otpauth uri, where colon separates it from account
+	if c.MFA.Issuer == "" || strings.Contains(c.MFA.Issuer, ":") {
+		return fmt.Errorf("mfa issuer must be non-empty and without colon, got %q", c.MFA.Issuer)
+	}
+	if c.MFA.ChallengeLifetime <= 0 || c.MFA.ChallengeLifetime > maxMFAChallengeLifetime {
+		return fmt.Errorf("mfa challenge lifetime must be in (0, %s], got %s",
+			maxMFAChallengeLifetime, c.MFA.ChallengeLifetime)
+	}
+
+	// rp id is a domain, without scheme and port
+	if c.WebAuthn.RPID == "" || strings.ContainsAny(c.WebAuthn.RPID, ":/") {
+		return fmt.Errorf("webauthn rp id must be a domain, got %q", c.WebAuthn.RPID)
+	}
+	if len(c.WebAuthn.Origins) == 0 {
+		return fmt.Errorf("webauthn origins must be set")
+	}
+	for _, origin := range c.WebAuthn.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || !u.IsAbs() || u.Path != "" {
+			return fmt.Errorf("webauthn origin must be scheme and host, got %q", origin)
+		}
+		if host := u.Hostname(); host != c.WebAuthn.RPID && !strings.HasSuffix(host, "."+c.WebAuthn.RPID) {
+			return fmt.Errorf("webauthn origin %q is not within rp id %q", origin, c.WebAuthn.RPID)
+		}
+	}
+	if c.WebAuthn.ChallengeLifetime <= 0 || c.WebAuthn.ChallengeLifetime > maxWebAuthnChallengeLifetime {
+		return fmt.Errorf("webauthn challenge lifetime must be in (0, %s], got %s",
+			maxWebAuthnChallengeLifetime, c.WebAuthn.ChallengeLifetime)
+	}
+	if _, err := c.WebAuthn.Key(); err != nil {
+		return err
+	}
+
+	for name, outcome := range map[string]string{
+		"default":      c.IPPolicy.Default,
+		"same subnet":  c.IPPolicy.SameSubnet,
+		"same country": c.IPPolicy.SameCountry,
+		"known ip":     c.IPPolicy.KnownIP,
+		"rapid change": c.IPPolicy.RapidChange,
+	} {
+		if !slices.Contains(ipPolicyOutcomes, outcome) {
+			return fmt.Errorf("ip policy %s outcome must be one of %v, got %q", name, ipPolicyOutcomes, outcome)
+		}
+	}
+	if c.IPPolicy.SubnetBitsV4 < 0 || c.IPPolicy.SubnetBitsV4 > 32 {
+		return fmt.Errorf("ip policy ipv4 subnet bits must be in [0, 32], got %d", c.IPPolicy.SubnetBitsV4)
+	}
+	if c.IPPolicy.SubnetBitsV6 < 0 || c.IPPolicy.SubnetBitsV6 > 128 {
+		return fmt.Errorf("ip policy ipv6 subnet bits must be in [0, 128], got %d", c.IPPolicy.SubnetBitsV6)
+	}
+	if _, err := c.IPPolicy.Networks(); err != nil {
+		return err
+	}
+	if c.IPPolicy.RapidChangeWindow < 0 {
+		return fmt.Errorf("ip policy rapid change window must not be negative, got %s", c.IPPolicy.RapidChangeWindow)
+	}
+	if c.IPPolicy.MaxTravelSpeed < 0 {
+		return fmt.Errorf("ip policy max travel speed must not be negative, got %g", c.IPPolicy.MaxTravelSpeed)
+	}
+	if !slices.Contains(ipPolicyRefusals, c.IPPolicy.ImpossibleTravel) {
+		return fmt.Errorf("ip policy impossible travel outcome must be one of %v, got %q", ipPolicyRefusals, c.IPPolicy.ImpossibleTravel)
+	}
+	if c.GeoIP.DBPath != "" && c.GeoIP.ReloadInterval <= 0 {
+		return fmt.Errorf("geoip reload interval must be positive, got %s", c.GeoIP.ReloadInterval)
+	}
+
+	if err := validateIssuer(c.OAuth.Issuer); err != nil {
+		return err
+	}
+	if c.OAuth.CodeLifetime <= 0 || c.OAuth.CodeLifetime > maxOAuthCodeLifetime {
+		return fmt.Errorf("oauth code lifetime must be in (0, %s], got %s",
+			maxOAuthCodeLifetime, c.OAuth.CodeLifetime)
+	}
+	clientIDs := make(map[string]bool, len(c.OAuth.Clients))
+	for _, client := range c.OAuth.Clients {
+		if client.ID == "" || clientIDs[client.ID] {
+			return fmt.Errorf("oauth client id must be set and unique, got %q", client.ID)
+		}
+		clientIDs[client.ID] = true
+
+		if len(client.RedirectURIs) == 0 {
+			return fmt.Errorf("oauth client %q has no redirect uris", client.ID)
+		}
+		for _, uri := range client.RedirectURIs {
+			if err := validateRedirectURI(uri); err != nil {
+				return fmt.Errorf("oauth client %q: %w", client.ID, err)
+			}
+		}
+		// client verifies ID token itself, HMAC would give it key which signs access tokens of every user
+		if slices.Contains(client.Scopes, oauthScopeOpenID) && !c.JWT.Asymmetric() {
+			return fmt.Errorf("oauth client %q with %s scope requires asymmetric jwt signing method or keyring file, got %s",
+				client.ID, oauthScopeOpenID, c.JWT.SigningMethod)
+		}
+	}
+
+	return nil
+}
+
+// validateRedirectURI allow absolute uris without fragment (RFC 6749 section 3.1.2),
+// plain http only for loopback, custom schemes of native apps are allowed (RFC 8252)
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return fmt.Errorf("redirect uri must be absolute and without fragment, got %q", uri)
+	}
+	if u.Scheme == "http" && !isLoopback(u.Hostname()) {
+		return fmt.Errorf("redirect uri must use https, got %q", uri)
+	}
+	return nil
+}
+
+// validateIssuer allow https url without query and fragment (OpenID Connect discovery section 3),
+// plain http only for loopback
+func validateIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(u.Path, "/") {
+		return fmt.Errorf("oauth issuer must be url without query, fragment and trailing slash, got %q", issuer)
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !isLoopback(u.Hostname())) {
+		return fmt.Errorf("oauth issuer must use https, got %q", issuer)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
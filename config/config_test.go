@@ -0,0 +1,1449 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultJWT = JWT{
+	SecretKey:     "secret",
+	SigningMethod: "HS512",
+}
+
+var defaultEmailVerification = EmailVerification{
+	URL:           "http://localhost:8080/api/v1/user/verify",
+	TokenLifetime: 24 * time.Hour,
+}
+
+var defaultPasswordReset = PasswordReset{
+	URL:           "http://localhost:8080/password/reset",
+	TokenLifetime: 15 * time.Minute,
+}
+
+var defaultMagicLink = MagicLink{
+	URL:           "http://localhost:8080/api/v1/auth/magic-link/consume",
+	TokenLifetime: 10 * time.Minute,
+}
+
+var defaultOneTimeToken = OneTimeToken{
+	SecretKey: "AwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwM=",
+}
+
+var defaultMFA = MFA{
+	EncryptionKey:     "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=",
+	Issuer:            "medods",
+	ChallengeLifetime: 5 * time.Minute,
+}
+
+var defaultIPPolicy = IPPolicy{
+	Default:          "notify",
+	SameSubnet:       "allow",
+	SubnetBitsV4:     24,
+	SubnetBitsV6:     56,
+	SameCountry:      "notify",
+	KnownIP:          "notify",
+	RapidChange:      "step-up",
+	MaxTravelSpeed:   1000,
+	ImpossibleTravel: "step-up",
+}
+
+var defaultWebAuthn = WebAuthn{
+	RPID:              "localhost",
+	RPName:            "medods",
+	Origins:           []string{"http://localhost:8080"},
+	ChallengeLifetime: 5 * time.Minute,
+	SecretKey:         "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=",
+}
+
+var defaultOAuth = OAuth{
+	Issuer:       "http://localhost:8080",
+	CodeLifetime: time.Minute,
+}
+
+func TestConfigValidate(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+		Leeway:         5 * time.Second,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(a Auth) Auth
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(a Auth) Auth { return a },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK short access token for staging",
+			input: func(a Auth) Auth {
+				a.ATokenLifetime = 2 * time.Minute
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error access token lifetime not positive",
+			input: func(a Auth) Auth {
+				a.ATokenLifetime = 0
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error refresh token shorter than access token",
+			input: func(a Auth) Auth {
+				a.RTokenLifetime = a.ATokenLifetime
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error refresh token too short",
+			input: func(a Auth) Auth {
+				a.RTokenBytes = 16
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error refresh token exceeds bcrypt input",
+			input: func(a Auth) Auth {
+				a.RTokenBytes = 64
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error bcrypt cost",
+			input: func(a Auth) Auth {
+				a.BcryptCost = 2
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "OK hmac hasher",
+			input: func(a Auth) Auth {
+				a.RTokenHasher = RTokenHasherHMAC
+				a.RTokenHMACKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error hmac hasher without key",
+			input: func(a Auth) Auth {
+				a.RTokenHasher = RTokenHasherHMAC // note
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error hmac key too short",
+			input: func(a Auth) Auth {
+				a.RTokenHasher = RTokenHasherHMAC
+				a.RTokenHMACKey = "AQEBAQEBAQEBAQEBAQEBAQ==" // note: 16 bytes
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "OK bcrypt hasher with hmac key",
+			input: func(a Auth) Auth {
+				a.RTokenHMACKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=" // note: rolled back from hmac
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error bcrypt hasher with short hmac key",
+			input: func(a Auth) Auth {
+				a.RTokenHMACKey = "AQEBAQEBAQEBAQEBAQEBAQ==" // note: 16 bytes
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error unknown hasher",
+			input: func(a Auth) Auth {
+				a.RTokenHasher = "sha256"
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error negative leeway",
+			input: func(a Auth) Auth {
+				a.Leeway = -time.Second
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error leeway longer than access token",
+			input: func(a Auth) Auth {
+				a.Leeway = a.ATokenLifetime
+				return a
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{JWT: defaultJWT, Auth: test.input(defaultAuth), Password: defaultPassword, EmailVerification: defaultEmailVerification, PasswordReset: defaultPasswordReset, MagicLink: defaultMagicLink, MFA: defaultMFA, WebAuthn: defaultWebAuthn, OneTimeToken: defaultOneTimeToken, OAuth: defaultOAuth, IPPolicy: defaultIPPolicy}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidatePassword(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	tc := []struct {
+		name        string
+		input       Password
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: Password{Memory: 64 * 1024, Time: 3, Parallelism: 2},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "error zero time",
+			input: Password{Memory: 64 * 1024, Time: 0, Parallelism: 2},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:  "error zero parallelism",
+			input: Password{Memory: 64 * 1024, Time: 3, Parallelism: 0},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:  "error too little memory",
+			input: Password{Memory: 8, Time: 3, Parallelism: 2},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{JWT: defaultJWT, Auth: defaultAuth, Password: test.input, EmailVerification: defaultEmailVerification, PasswordReset: defaultPasswordReset, MagicLink: defaultMagicLink, MFA: defaultMFA, WebAuthn: defaultWebAuthn, OneTimeToken: defaultOneTimeToken, OAuth: defaultOAuth, IPPolicy: defaultIPPolicy}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateEmailVerification(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(e EmailVerification) EmailVerification
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(e EmailVerification) EmailVerification { return e },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error token lifetime not positive",
+			input: func(e EmailVerification) EmailVerification {
+				e.TokenLifetime = 0
+				return e
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error relative url",
+			input: func(e EmailVerification) EmailVerification {
+				e.URL = "/api/v1/user/verify"
+				return e
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{JWT: defaultJWT, Auth: defaultAuth, Password: defaultPassword, EmailVerification: test.input(defaultEmailVerification), PasswordReset: defaultPasswordReset, MagicLink: defaultMagicLink, MFA: defaultMFA, WebAuthn: defaultWebAuthn, OneTimeToken: defaultOneTimeToken, OAuth: defaultOAuth, IPPolicy: defaultIPPolicy}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidatePasswordReset(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(r PasswordReset) PasswordReset
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(r PasswordReset) PasswordReset { return r },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error token lifetime not positive",
+			input: func(r PasswordReset) PasswordReset {
+				r.TokenLifetime = 0
+				return r
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error token lives too long",
+			input: func(r PasswordReset) PasswordReset {
+				r.TokenLifetime = 24 * time.Hour
+				return r
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error relative url",
+			input: func(r PasswordReset) PasswordReset {
+				r.URL = "/password/reset"
+				return r
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{JWT: defaultJWT, Auth: defaultAuth, Password: defaultPassword, EmailVerification: defaultEmailVerification, PasswordReset: test.input(defaultPasswordReset), MagicLink: defaultMagicLink, MFA: defaultMFA, WebAuthn: defaultWebAuthn, OneTimeToken: defaultOneTimeToken, OAuth: defaultOAuth, IPPolicy: defaultIPPolicy}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateMagicLink(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(m MagicLink) MagicLink
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(m MagicLink) MagicLink { return m },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error token lifetime not positive",
+			input: func(m MagicLink) MagicLink {
+				m.TokenLifetime = 0
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error token lives longer than minutes",
+			input: func(m MagicLink) MagicLink {
+				m.TokenLifetime = time.Hour
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error relative url",
+			input: func(m MagicLink) MagicLink {
+				m.URL = "/api/v1/auth/magic-link/consume"
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         test.input(defaultMagicLink),
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateOneTimeToken(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(o OneTimeToken) OneTimeToken
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(o OneTimeToken) OneTimeToken { return o },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error no secret key",
+			input: func(o OneTimeToken) OneTimeToken {
+				o.SecretKey = ""
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error short secret key",
+			input: func(o OneTimeToken) OneTimeToken {
+				o.SecretKey = "AwMDAwMDAwMDAwMDAwMDAw==" // note: 16 bytes
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      test.input(defaultOneTimeToken),
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateMFA(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(m MFA) MFA
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(m MFA) MFA { return m },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error key is not base64",
+			input: func(m MFA) MFA {
+				m.EncryptionKey = "not base64!"
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error short key",
+			input: func(m MFA) MFA {
+				m.EncryptionKey = "AQEBAQEBAQEBAQEBAQEBAQ==" // note: 16 bytes
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error issuer with colon",
+			input: func(m MFA) MFA {
+				m.Issuer = "medods:auth"
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error challenge lives too long",
+			input: func(m MFA) MFA {
+				m.ChallengeLifetime = time.Hour
+				return m
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               test.input(defaultMFA),
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateWebAuthn(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(w WebAuthn) WebAuthn
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(w WebAuthn) WebAuthn { return w },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK subdomain origin",
+			input: func(w WebAuthn) WebAuthn {
+				w.RPID = "example.com"
+				w.Origins = []string{"https://example.com", "https://login.example.com"}
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error rp id with scheme",
+			input: func(w WebAuthn) WebAuthn {
+				w.RPID = "https://localhost"
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error no origins",
+			input: func(w WebAuthn) WebAuthn {
+				w.Origins = nil
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error origin of other domain",
+			input: func(w WebAuthn) WebAuthn {
+				w.RPID = "example.com"
+				w.Origins = []string{"https://notexample.com"}
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error origin with path",
+			input: func(w WebAuthn) WebAuthn {
+				w.Origins = []string{"http://localhost:8080/login"}
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error challenge lives too long",
+			input: func(w WebAuthn) WebAuthn {
+				w.ChallengeLifetime = time.Hour
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error no secret key",
+			input: func(w WebAuthn) WebAuthn {
+				w.SecretKey = ""
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error short secret key",
+			input: func(w WebAuthn) WebAuthn {
+				w.SecretKey = "AgICAgICAgICAgICAgICAg==" // note: 16 bytes
+				return w
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          test.input(defaultWebAuthn),
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateOAuth(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	defaultClient := OAuthClient{
+		ID:           "spa",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"openid"},
+	}
+
+	// ID tokens are signed with private key
+	asymmetricJWT := JWT{SecretKey: "secret", SigningMethod: "EdDSA", PrivateKeyFile: "private.pem"}
+
+	tc := []struct {
+		name        string
+		input       func(o OAuth) OAuth
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK without clients",
+			input: func(o OAuth) OAuth { return o },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK clients",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{defaultClient, {
+					ID:           "mobile",
+					RedirectURIs: []string{"com.example.app:/callback", "http://127.0.0.1:8000/callback", "http://localhost/callback"},
+				}}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error duplicated client id",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{defaultClient, defaultClient}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error client without redirect uris",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{{ID: "spa"}}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error relative redirect uri",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{{ID: "spa", RedirectURIs: []string{"/callback"}}}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error redirect uri with fragment",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{{ID: "spa", RedirectURIs: []string{"https://app.example.com/callback#x"}}}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error plain http redirect uri",
+			input: func(o OAuth) OAuth {
+				o.Clients = []OAuthClient{{ID: "spa", RedirectURIs: []string{"http://app.example.com/callback"}}}
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "OK https issuer with path",
+			input: func(o OAuth) OAuth {
+				o.Issuer = "https://auth.example.com/medods"
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error plain http issuer",
+			input: func(o OAuth) OAuth {
+				o.Issuer = "http://auth.example.com"
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error issuer with trailing slash",
+			input: func(o OAuth) OAuth {
+				o.Issuer = "https://auth.example.com/"
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error code lives too long",
+			input: func(o OAuth) OAuth {
+				o.CodeLifetime = time.Hour
+				return o
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               asymmetricJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             test.input(defaultOAuth),
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateIDTokenSigning(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(j JWT) JWT
+		scopes      []string
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK asymmetric",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "ES512"
+				j.PrivateKeyFile = "/etc/medods/jwt.pem"
+				return j
+			},
+			scopes: []string{"openid", "email"},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK keyring",
+			input: func(j JWT) JWT {
+				j.KeyringFile = "/etc/medods/keyring.json"
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			scopes: []string{"openid"},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "OK HS512 without openid scope",
+			input:  func(j JWT) JWT { return j },
+			scopes: []string{"email"}, // note: client gets no ID token
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "error HS512",
+			input:  func(j JWT) JWT { return j },
+			scopes: []string{"openid"},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "asymmetric")
+			},
+		},
+		{
+			name: "error HS512 keyring in database",
+			input: func(j JWT) JWT {
+				j.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=" // note: keys of signing method are generated
+				j.RotationInterval = 720 * time.Hour
+				j.KeyPrePublish = time.Hour
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			scopes: []string{"openid"},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "asymmetric")
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			oauth := defaultOAuth
+			oauth.Clients = []OAuthClient{{
+				ID:           "spa",
+				RedirectURIs: []string{"https://app.example.com/callback"},
+				Scopes:       test.scopes,
+			}}
+
+			cfg := &Config{
+				JWT:               test.input(defaultJWT),
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             oauth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateJWT(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(j JWT) JWT
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(j JWT) JWT { return j },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK asymmetric",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "EdDSA"
+				j.PrivateKeyFile = "/etc/medods/jwt.pem"
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK keyring",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "RS512"
+				j.KeyringFile = "/etc/medods/keyring.json" // note: private key is in keyring
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK keyring in database",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "EdDSA"
+				j.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+				j.RotationInterval = 720 * time.Hour
+				j.KeyPrePublish = time.Hour
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK rotation disabled",
+			input: func(j JWT) JWT {
+				j.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+				j.RotationInterval = 0 // note: pre-publish is ignored
+				j.KeyPrePublish = time.Hour
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error short master key",
+			input: func(j JWT) JWT {
+				j.MasterKey = "AQEBAQ==" // note
+				j.RotationInterval = 720 * time.Hour
+				j.KeyPrePublish = time.Hour
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error keyring in file and database",
+			input: func(j JWT) JWT {
+				j.KeyringFile = "/etc/medods/keyring.json" // note
+				j.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+				j.RotationInterval = 720 * time.Hour
+				j.KeyPrePublish = time.Hour
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error pre-publish longer than rotation interval",
+			input: func(j JWT) JWT {
+				j.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+				j.RotationInterval = time.Hour
+				j.KeyPrePublish = time.Hour // note
+				j.KeyringReloadInterval = time.Minute
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error keyring without reload interval",
+			input: func(j JWT) JWT {
+				j.KeyringFile = "/etc/medods/keyring.json" // note: reload interval is zero
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error unsupported signing method",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "HS256"
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error asymmetric without key",
+			input: func(j JWT) JWT {
+				j.SigningMethod = "RS512" // note: no private key file
+				return j
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               test.input(defaultJWT),
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateIPPolicy(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(p IPPolicy) IPPolicy
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(p IPPolicy) IPPolicy { return p },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK strict policy",
+			input: func(p IPPolicy) IPPolicy {
+				p.Default = "deny"
+				p.SameSubnet = "allow"
+				p.KnownIP = "step-up"
+				p.RapidChangeWindow = time.Minute
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error unknown outcome",
+			input: func(p IPPolicy) IPPolicy {
+				p.KnownIP = "block"
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "known ip")
+			},
+		},
+		{
+			name: "error ipv4 subnet bits",
+			input: func(p IPPolicy) IPPolicy {
+				p.SubnetBitsV4 = 33
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error ipv6 subnet bits",
+			input: func(p IPPolicy) IPPolicy {
+				p.SubnetBitsV6 = -1
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "OK trusted networks",
+			input: func(p IPPolicy) IPPolicy {
+				p.TrustedNetworks = []string{"203.0.113.0/24", " 2001:db8::/32"}
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error trusted network without prefix",
+			input: func(p IPPolicy) IPPolicy {
+				p.TrustedNetworks = []string{"203.0.113.1"} // note
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "OK deny impossible travel",
+			input: func(p IPPolicy) IPPolicy {
+				p.ImpossibleTravel = "deny" // note
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error impossible travel isn't refused",
+			input: func(p IPPolicy) IPPolicy {
+				p.ImpossibleTravel = "notify" // note
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "impossible travel")
+			},
+		},
+		{
+			name: "error negative max travel speed",
+			input: func(p IPPolicy) IPPolicy {
+				p.MaxTravelSpeed = -1 // note
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name: "error negative rapid change window",
+			input: func(p IPPolicy) IPPolicy {
+				p.RapidChangeWindow = -time.Second
+				return p
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          test.input(defaultIPPolicy),
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
+
+func TestConfigValidateGeoIP(t *testing.T) {
+	defaultAuth := Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 720 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+		RTokenHasher:   RTokenHasherBcrypt,
+	}
+
+	defaultPassword := Password{
+		Memory:      64 * 1024,
+		Time:        3,
+		Parallelism: 2,
+	}
+
+	defaultGeoIP := GeoIP{
+		DBPath:         "GeoLite2-City.mmdb",
+		ReloadInterval: time.Minute,
+	}
+
+	tc := []struct {
+		name        string
+		input       func(g GeoIP) GeoIP
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: func(g GeoIP) GeoIP { return g },
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK disabled",
+			input: func(g GeoIP) GeoIP {
+				g.DBPath = "" // note
+				g.ReloadInterval = 0
+				return g
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error reload interval",
+			input: func(g GeoIP) GeoIP {
+				g.ReloadInterval = 0 // note
+				return g
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "geoip reload interval")
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{
+				JWT:               defaultJWT,
+				Auth:              defaultAuth,
+				Password:          defaultPassword,
+				EmailVerification: defaultEmailVerification,
+				PasswordReset:     defaultPasswordReset,
+				MagicLink:         defaultMagicLink,
+				MFA:               defaultMFA,
+				WebAuthn:          defaultWebAuthn,
+				OneTimeToken:      defaultOneTimeToken,
+				OAuth:             defaultOAuth,
+				IPPolicy:          defaultIPPolicy,
+				GeoIP:             test.input(defaultGeoIP),
+			}
+			test.checkResult(t, cfg.Validate())
+		})
+	}
+}
@@ -18,28 +18,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login/{user_id}": {
-            "get": {
-                "description": "Create session and return new pair access and refresh tokens.",
+        "/auth/introspect": {
+            "post": {
+                "description": "Report state of access or refresh token by RFC 7662, caller authenticates with client credentials (basic auth or form).",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "auth"
                 ],
-                "summary": "Create session",
+                "summary": "Introspect token",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "user id",
-                        "name": "user_id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "token to introspect",
+                        "name": "token",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "access_token or refresh_token",
+                        "name": "token_type_hint",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "client id, if basic auth is not used",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "client secret, if basic auth is not used",
+                        "name": "client_secret",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Introspection"
+                        }
                     },
                     "400": {
                         "description": "Invalid request parameters",
@@ -47,8 +71,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.errMsg"
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "401": {
+                        "description": "Unauthorized - invalid client credentials",
                         "schema": {
                             "$ref": "#/definitions/http.errMsg"
                         }
@@ -62,14 +86,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/refresh": {
+        "/auth/login": {
             "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Refresh session and return new pair access and refresh tokens.",
+                "description": "Check email and password, create session and return new pair access and refresh tokens.\nIf user has second factor enabled, returns 202 with challenge token for /auth/login/mfa instead.",
                 "consumes": [
                     "application/json"
                 ],
@@ -79,21 +98,30 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Refresh session",
+                "summary": "Login",
                 "parameters": [
                     {
-                        "description": "refresh token for refresh session",
-                        "name": "refresh_token",
+                        "description": "email and password of user",
+                        "name": "login_request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/http.refreshRequest"
+                            "$ref": "#/definitions/http.loginRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.loginResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Second factor is required",
+                        "schema": {
+                            "$ref": "#/definitions/http.mfaRequiredResponse"
+                        }
                     },
                     "400": {
                         "description": "Invalid request parameters",
@@ -102,7 +130,13 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid tokens",
+                        "description": "Invalid email or password",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Email is not verified",
                         "schema": {
                             "$ref": "#/definitions/http.errMsg"
                         }
@@ -116,19 +150,48 @@ const docTemplate = `{
                 }
             }
         },
-        "/session/list": {
-            "get": {
-                "description": "Show rows of session table from database.",
+        "/auth/login/mfa": {
+            "post": {
+                "description": "Check code of authenticator app or recovery code for challenge returned by login, create session and return new pair access and refresh tokens.\nChallenge works once, after wrong code login must be repeated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "test"
+                    "auth"
+                ],
+                "summary": "Login with second factor",
+                "parameters": [
+                    {
+                        "description": "challenge token and code",
+                        "name": "mfa_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.verifyMFARequest"
+                        }
+                    }
                 ],
-                "summary": "List session",
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.loginResponse"
+                        }
                     },
-                    "204": {
-                        "description": "No Content"
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired or used challenge or wrong code",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
                     },
                     "500": {
                         "description": "Internal server error",
@@ -139,33 +202,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/session/update": {
-            "post": {
-                "description": "Update session in database. Needed for testing.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/auth/login/{user_id}": {
+            "get": {
+                "description": "Create session and return new pair access and refresh tokens. Available only in dev mode.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "test"
                 ],
-                "summary": "Update session",
+                "summary": "Create session by user id",
                 "parameters": [
                     {
-                        "description": "update session request, find session by id and version and update",
-                        "name": "update_request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.updateSessionRequest"
-                        }
+                        "type": "integer",
+                        "description": "user id",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK"
+                    "201": {
+                        "description": "Created"
                     },
                     "400": {
                         "description": "Invalid request parameters",
@@ -173,8 +231,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/http.errMsg"
                         }
                     },
+                    "403": {
+                        "description": "Email is not verified",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
                     "404": {
-                        "description": "Not found",
+                        "description": "User not found",
                         "schema": {
                             "$ref": "#/definitions/http.errMsg"
                         }
@@ -188,34 +252,104 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/create": {
+        "/auth/logout": {
             "post": {
-                "description": "Create user, needed for testing with few users",
-                "consumes": [
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke session of access token, refresh of it will fail after that.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Logout",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout-all": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke all sessions of user which access token belongs to.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "test"
+                    "auth"
                 ],
-                "summary": "Create user",
+                "summary": "Logout everywhere",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/magic-link": {
+            "post": {
+                "description": "Send single-use login link to email if account exists and set nonce cookie, link works only in the same browser. Response is the same for unknown emails.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Send login link",
                 "parameters": [
                     {
-                        "format": "email",
-                        "description": "create user request, email of user",
-                        "name": "create_request",
+                        "description": "email of user",
+                        "name": "magic_link_request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/http.createUserRequest"
+                            "$ref": "#/definitions/http.magicLinkRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "202": {
+                        "description": "Accepted"
                     },
                     "400": {
                         "description": "Invalid request parameters",
@@ -232,19 +366,49 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/list": {
+        "/auth/magic-link/consume": {
             "get": {
-                "description": "Show rows of users table from database.",
+                "description": "Check login link and nonce cookie set when link was requested, create session and return new pair access and refresh tokens.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "test"
+                    "auth"
+                ],
+                "summary": "Login by link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "token from login link",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
-                "summary": "List user",
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.loginResponse"
+                        }
                     },
-                    "204": {
-                        "description": "No Content"
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired or already used link, or link opened in other browser",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Email is not verified",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
                     },
                     "500": {
                         "description": "Internal server error",
@@ -254,33 +418,1274 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "http.createUserRequest": {
-            "type": "object",
-            "required": [
-                "email"
-            ],
-            "properties": {
-                "email": {
-                    "type": "string",
-                    "example": "mock@gmail.com"
-                }
-            }
         },
-        "http.errMsg": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
+        "/auth/password/forgot": {
+            "post": {
+                "description": "Send password reset link to email if account exists. Response is the same for unknown emails.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Forgot password",
+                "parameters": [
+                    {
+                        "description": "email of user",
+                        "name": "forgot_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.forgotPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
                 }
             }
         },
-        "http.refreshRequest": {
-            "type": "object",
-            "required": [
-                "refresh_token"
+        "/auth/password/reset": {
+            "post": {
+                "description": "Set new password by token from reset link and close all sessions of user, link could be used only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "token from reset link and new password",
+                        "name": "reset_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.resetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters or invalid, expired or already used token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refresh session and return new pair access and refresh tokens.\nAccess token may be omitted, it's required only for refresh tokens issued before format selector.secret.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh session",
+                "parameters": [
+                    {
+                        "description": "refresh token for refresh session",
+                        "name": "refresh_token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.refreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid tokens, or new IP address requires to log in again",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Refresh from new IP address is denied",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/begin": {
+            "post": {
+                "description": "Return options of navigator.credentials.get() with passkeys of user.\nUnknown email gets the same response, so it can't be used to check whether user exists.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Start login with passkey",
+                "parameters": [
+                    {
+                        "description": "email of user",
+                        "name": "login_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.beginPasskeyLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.requestOptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/finish": {
+            "post": {
+                "description": "Verify assertion of passkey, create session and return new pair access and refresh tokens.\nAuthenticator verifies user itself, so second factor is not asked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Login with passkey",
+                "parameters": [
+                    {
+                        "description": "PublicKeyCredential returned by navigator.credentials.get(), binary fields are base64url",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webauthn.AssertionResponse"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.loginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired or used challenge or invalid passkey",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Email is not verified",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/register/begin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return options of navigator.credentials.create() for user which access token belongs to.\nChallenge works once, registration must be finished before it expires.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Start passkey registration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.creationOptionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/register/finish": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verify credential created by authenticator and save it as passkey of user which access token belongs to.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey registration",
+                "parameters": [
+                    {
+                        "description": "PublicKeyCredential returned by navigator.credentials.create(), binary fields are base64url",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webauthn.AttestationResponse"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebAuthnCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters, challenge or credential",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "409": {
+                        "description": "Passkey is already registered",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/authorize": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue authorization code to client on behalf of user which access token belongs to (RFC 6749 section 4.1).\nPKCE with S256 is required. Parameters are taken from query or form.\nErrors of request are sent to redirect uri, unknown client or redirect uri get 400 without redirect.\nBrowser doesn't send bearer token on navigation, so client sends user to login page of first-party frontend\nwith these parameters, and the page calls this endpoint by XHR with Accept: application/json\nand navigates to redirect_uri of response. Request without Accept: application/json gets 302.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OAuth 2.0 authorization endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "registered client id",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "registered redirect uri, may be omitted if client has only one",
+                        "name": "redirect_uri",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "space-delimited scopes, all scopes of client by default",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "opaque value returned to client",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "base64url of sha256 of code verifier",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OpenID Connect nonce, returned in id token",
+                        "name": "nonce",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Accept: application/json",
+                        "schema": {
+                            "$ref": "#/definitions/http.authorizeResponse"
+                        }
+                    },
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Unknown client or redirect uri",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue authorization code to client on behalf of user which access token belongs to (RFC 6749 section 4.1).\nPKCE with S256 is required. Parameters are taken from query or form.\nErrors of request are sent to redirect uri, unknown client or redirect uri get 400 without redirect.\nBrowser doesn't send bearer token on navigation, so client sends user to login page of first-party frontend\nwith these parameters, and the page calls this endpoint by XHR with Accept: application/json\nand navigates to redirect_uri of response. Request without Accept: application/json gets 302.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OAuth 2.0 authorization endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "registered client id",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "registered redirect uri, may be omitted if client has only one",
+                        "name": "redirect_uri",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "space-delimited scopes, all scopes of client by default",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "opaque value returned to client",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "base64url of sha256 of code verifier",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OpenID Connect nonce, returned in id token",
+                        "name": "nonce",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Accept: application/json",
+                        "schema": {
+                            "$ref": "#/definitions/http.authorizeResponse"
+                        }
+                    },
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Unknown client or redirect uri",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "Exchange authorization code or refresh token for tokens (RFC 6749 section 4.1.3 and 6).\nConfidential client authenticates with basic auth or form, public client sends only client_id.\nRefresh token is bound to client, refresh rotates it the same way as /auth/refresh.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OAuth 2.0 token endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "authorization_code or refresh_token",
+                        "name": "grant_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "client id, if basic auth is not used",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "secret of confidential client, if basic auth is not used",
+                        "name": "client_secret",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "authorization code",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "the same as in authorization request",
+                        "name": "redirect_uri",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code verifier",
+                        "name": "code_verifier",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "refresh token",
+                        "name": "refresh_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "scope of refresh, it can't exceed granted one",
+                        "name": "scope",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or grant",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid client credentials",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/userinfo": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return claims of user which access token was issued to client with openid scope.\nEmail claims are returned only if email scope is granted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OpenID Connect userinfo endpoint",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token has no openid scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Return claims of user which access token was issued to client with openid scope.\nEmail claims are returned only if email scope is granted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OpenID Connect userinfo endpoint",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token has no openid scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/list": {
+            "get": {
+                "description": "Show rows of session table from database. Available only in dev mode.",
+                "tags": [
+                    "test"
+                ],
+                "summary": "List session",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/update": {
+            "post": {
+                "description": "Update session in database. Needed for testing. Available only in dev mode.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "test"
+                ],
+                "summary": "Update session",
+                "parameters": [
+                    {
+                        "description": "update session request, find session by id and version and update",
+                        "name": "update_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.updateSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Enable second factor of user which access token belongs to, if code of pending secret is valid.\nReturns recovery codes, previous recovery codes stop working.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Confirm two-factor enrollment",
+                "parameters": [
+                    {
+                        "description": "code of authenticator app",
+                        "name": "confirm_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.confirmTOTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.confirmTOTPResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request parameters or wrong code",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "409": {
+                        "description": "Enrollment is not started or second factor is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/enroll": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Generate secret of authenticator app for user which access token belongs to.\nSecond factor is enabled only after confirmation with first code, repeated call replaces pending secret.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Start two-factor enrollment",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.enrollTOTPResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "409": {
+                        "description": "Second factor is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/create": {
+            "post": {
+                "description": "Create user with unverified email and send verification link to it, needed for testing with few users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "test"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "create user request, email and optional password of user",
+                        "name": "create_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.createUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/list": {
+            "get": {
+                "description": "Show rows of users table from database. Available only in dev mode.",
+                "tags": [
+                    "test"
+                ],
+                "summary": "List user",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/password": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change password of user which access token belongs to, old password is required.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "old and new password",
+                        "name": "change_request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.changePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid request parameters",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid old password or token of OAuth client",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/verify": {
+            "get": {
+                "description": "Mark email of user verified by token from link sent on creation, link could be used only once.",
+                "tags": [
+                    "user"
+                ],
+                "summary": "Verify email",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "token from verification link",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid, expired or already used token",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/http.errMsg"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "http.authorizeResponse": {
+            "type": "object",
+            "properties": {
+                "redirect_uri": {
+                    "description": "uri of client with code or error, page navigates to it",
+                    "type": "string",
+                    "example": "https://app.example.com/callback?code=code\u0026state=xyz"
+                }
+            }
+        },
+        "http.beginPasskeyLoginRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@gmail.com"
+                }
+            }
+        },
+        "http.changePasswordRequest": {
+            "type": "object",
+            "required": [
+                "new_password",
+                "old_password"
+            ],
+            "properties": {
+                "new_password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "new_password"
+                },
+                "old_password": {
+                    "type": "string",
+                    "example": "password"
+                }
+            }
+        },
+        "http.confirmTOTPRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "http.confirmTOTPResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "description": "single-use codes for login without authenticator app, they are shown only once",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.createUserRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "mock@gmail.com"
+                },
+                "password": {
+                    "description": "optional, without password user can't login by email",
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "password"
+                }
+            }
+        },
+        "http.creationOptionsResponse": {
+            "type": "object",
+            "properties": {
+                "publicKey": {
+                    "$ref": "#/definitions/webauthn.CreationOptions"
+                }
+            }
+        },
+        "http.enrollTOTPResponse": {
+            "type": "object",
+            "properties": {
+                "otpauth_uri": {
+                    "description": "key uri, usually shown as QR code",
+                    "type": "string",
+                    "example": "otpauth://totp/medods:user@gmail.com?secret=JBSWY3DPEHPK3PXP\u0026issuer=medods"
+                },
+                "secret": {
+                    "description": "base32, for manual entry in authenticator app",
+                    "type": "string",
+                    "example": "JBSWY3DPEHPK3PXP"
+                }
+            }
+        },
+        "http.errMsg": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.forgotPasswordRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@gmail.com"
+                }
+            }
+        },
+        "http.loginRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@gmail.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "password"
+                }
+            }
+        },
+        "http.loginResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.magicLinkRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@gmail.com"
+                }
+            }
+        },
+        "http.mfaRequiredResponse": {
+            "type": "object",
+            "properties": {
+                "mfa_token": {
+                    "description": "single-use, must be posted to /auth/login/mfa together with code of second factor",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "mfa_required"
+                }
+            }
+        },
+        "http.refreshRequest": {
+            "type": "object",
+            "required": [
+                "refresh_token"
             ],
             "properties": {
                 "refresh_token": {
@@ -288,6 +1693,32 @@ const docTemplate = `{
                 }
             }
         },
+        "http.requestOptionsResponse": {
+            "type": "object",
+            "properties": {
+                "publicKey": {
+                    "$ref": "#/definitions/webauthn.RequestOptions"
+                }
+            }
+        },
+        "http.resetPasswordRequest": {
+            "type": "object",
+            "required": [
+                "new_password",
+                "token"
+            ],
+            "properties": {
+                "new_password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8,
+                    "example": "new_password"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "http.updateSessionRequest": {
             "type": "object",
             "required": [
@@ -316,6 +1747,319 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "http.verifyMFARequest": {
+            "type": "object",
+            "required": [
+                "code",
+                "mfa_token"
+            ],
+            "properties": {
+                "code": {
+                    "description": "code of authenticator app or one of recovery codes",
+                    "type": "string",
+                    "example": "123456"
+                },
+                "mfa_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Introspection": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "exp": {
+                    "type": "integer"
+                },
+                "iat": {
+                    "type": "integer"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "jti": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UserInfo": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@gmail.com"
+                },
+                "email_verified": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "sub": {
+                    "type": "string",
+                    "example": "1"
+                }
+            }
+        },
+        "model.WebAuthnCredential": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "credential_id": {
+                    "description": "base64url of credential id given by authenticator",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "integer"
+                },
+                "sign_count": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "oauth.Error": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "invalid_request"
+                },
+                "error_description": {
+                    "type": "string",
+                    "example": "code_challenge is required"
+                }
+            }
+        },
+        "oauth.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "type": "integer",
+                    "example": 900
+                },
+                "id_token": {
+                    "description": "issued when openid scope is granted",
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "openid email"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "webauthn.AssertionResponse": {
+            "type": "object",
+            "required": [
+                "rawId",
+                "type"
+            ],
+            "properties": {
+                "rawId": {
+                    "type": "string"
+                },
+                "response": {
+                    "$ref": "#/definitions/webauthn.AuthenticatorAssertionResponse"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.AttestationResponse": {
+            "type": "object",
+            "required": [
+                "rawId",
+                "type"
+            ],
+            "properties": {
+                "rawId": {
+                    "type": "string"
+                },
+                "response": {
+                    "$ref": "#/definitions/webauthn.AuthenticatorAttestationResponse"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.AuthenticatorAssertionResponse": {
+            "type": "object",
+            "required": [
+                "authenticatorData",
+                "clientDataJSON",
+                "signature"
+            ],
+            "properties": {
+                "authenticatorData": {
+                    "type": "string"
+                },
+                "clientDataJSON": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                },
+                "userHandle": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.AuthenticatorAttestationResponse": {
+            "type": "object",
+            "required": [
+                "attestationObject",
+                "clientDataJSON"
+            ],
+            "properties": {
+                "attestationObject": {
+                    "type": "string"
+                },
+                "clientDataJSON": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.AuthenticatorSelection": {
+            "type": "object",
+            "properties": {
+                "residentKey": {
+                    "type": "string"
+                },
+                "userVerification": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.CreationOptions": {
+            "type": "object",
+            "properties": {
+                "attestation": {
+                    "type": "string"
+                },
+                "authenticatorSelection": {
+                    "$ref": "#/definitions/webauthn.AuthenticatorSelection"
+                },
+                "challenge": {
+                    "type": "string"
+                },
+                "excludeCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "pubKeyCredParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialParameter"
+                    }
+                },
+                "rp": {
+                    "$ref": "#/definitions/webauthn.RelyingParty"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "user": {
+                    "$ref": "#/definitions/webauthn.UserEntity"
+                }
+            }
+        },
+        "webauthn.CredentialDescriptor": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.CredentialParameter": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.RelyingParty": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.RequestOptions": {
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "challenge": {
+                    "type": "string"
+                },
+                "rpId": {
+                    "type": "string"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "userVerification": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.UserEntity": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
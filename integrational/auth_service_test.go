@@ -4,15 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"medods/config"
+	"medods/internal/model"
 	"medods/internal/repository"
 	"medods/internal/service"
 	"medods/internal/service/auth"
+	"medods/internal/service/jwt"
+	"medods/internal/service/mfa"
+	"medods/internal/service/oauth"
+	"medods/internal/service/passkey"
+	userService "medods/internal/service/user"
 	"medods/pkg/logger"
 	"medods/pkg/postgres"
 	"medods/pkg/smtp"
+	"medods/pkg/totp"
+	"medods/pkg/webauthn/webauthntest"
+	"mime/quotedprintable"
 	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -23,7 +35,64 @@ import (
 
 var defaultConfig *config.Config = &config.Config{
 	JWT: config.JWT{
-		SecretKey: "123",
+		SecretKey:     "123",
+		SigningMethod: "HS512",
+	},
+	Auth: config.Auth{
+		ATokenLifetime: 30 * time.Minute,
+		RTokenLifetime: 30 * 24 * time.Hour,
+		RTokenBytes:    52,
+		BcryptCost:     10,
+	},
+	Password: config.Password{
+		Memory:      64 * 1024,
+		Time:        1,
+		Parallelism: 2,
+	},
+	EmailVerification: config.EmailVerification{
+		URL:           "http://localhost:8080/api/v1/user/verify",
+		TokenLifetime: 24 * time.Hour,
+	},
+	PasswordReset: config.PasswordReset{
+		URL:           "http://localhost:8080/password/reset",
+		TokenLifetime: 15 * time.Minute,
+	},
+	MagicLink: config.MagicLink{
+		URL:           "http://localhost:8080/api/v1/auth/magic-link/consume",
+		TokenLifetime: 10 * time.Minute,
+	},
+	OneTimeToken: config.OneTimeToken{
+		SecretKey: "AwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwM=",
+	},
+	MFA: config.MFA{
+		EncryptionKey:     "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=",
+		Issuer:            "medods",
+		ChallengeLifetime: 5 * time.Minute,
+	},
+	WebAuthn: config.WebAuthn{
+		RPID:              "localhost",
+		RPName:            "medods",
+		Origins:           []string{"http://localhost:8080"},
+		ChallengeLifetime: 5 * time.Minute,
+		SecretKey:         "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=",
+	},
+	OAuth: config.OAuth{
+		Issuer:       "http://localhost:8080",
+		CodeLifetime: time.Minute,
+		Clients: []config.OAuthClient{{
+			ID:           "spa",
+			RedirectURIs: []string{"http://localhost:3000/callback"},
+			Scopes:       []string{"openid", "email"},
+		}},
+	},
+	IPPolicy: config.IPPolicy{
+		Default:      "notify",
+		SameSubnet:   "notify",
+		SubnetBitsV4: 24,
+		SubnetBitsV6: 64,
+		SameCountry:  "notify",
+		KnownIP:      "notify",
+		RapidChange:  "step-up",
 	},
 }
 
@@ -60,7 +129,8 @@ func setupService(t *testing.T) (s *service.Manager, close func(), smtpEndpoint,
 	assert.NoError(t, err)
 
 	repo := repository.New(psg.Conn)
-	service := service.New(defaultConfig, repo, smtp, logger.New("debug", true))
+	service, err := service.New(defaultConfig, repo, smtp, logger.New("debug", true))
+	assert.NoError(t, err)
 
 	return service, func() {
 		smtpClose()
@@ -86,22 +156,25 @@ func TestAuth_CreateSession(t *testing.T) {
 	assert.NotEmpty(t, aT1)
 	assert.NotEmpty(t, rT1)
 
-	s1, err := service.Session.GetByUserID(ctx, user.ID)
+	_, p1, err := service.JWT.VerifyToken(ctx, aT1)
 	assert.NoError(t, err)
 
-	_, p1, err := service.JWT.VerifyToken(aT1)
+	s1, err := service.Session.GetByID(ctx, p1.SessionID)
 	assert.NoError(t, err)
 
 	assert.Equal(t, s1.UserID, p1.UserID)
 	assert.Equal(t, s1.ATokenID, p1.ID)
 	assert.Equal(t, s1.CreatedAt, p1.IssuedAt.Time.Unix())
-	assert.True(t, auth.CompareHash(s1.RTokenHash, rT1))
+	selector, secret, ok := strings.Cut(rT1, ".")
+	assert.True(t, ok)
+	assert.Equal(t, s1.RTokenSelector, selector)
+	assert.True(t, auth.NewBcryptTokenHasher(defaultConfig.Auth.BcryptCost, nil).Compare(s1.RTokenHash, secret))
 
-	expTime := time.Now().Add(auth.ATokenLifetime) // if processing of container more than 5 minute mb flucky
+	expTime := time.Now().Add(defaultConfig.Auth.ATokenLifetime) // if processing of container more than 5 minute mb flucky
 	assert.True(t, expTime.Add(-5*time.Minute).Before(p1.ExpiresAt.Time))
 	assert.True(t, expTime.Add(5*time.Minute).After(p1.ExpiresAt.Time))
 
-	// Create new session
+	// Create session from other device
 	IP2 := "::2"
 	aT2, rT2, err := service.Auth.CreateSession(context.Background(), user.ID, IP2)
 	assert.NoError(t, err)
@@ -110,13 +183,24 @@ func TestAuth_CreateSession(t *testing.T) {
 	assert.NotEqual(t, aT1, aT2)
 	assert.NotEqual(t, rT1, rT2)
 
-	s2, err := service.Session.GetByUserID(ctx, user.ID)
+	_, p2, err := service.JWT.VerifyToken(ctx, aT2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, p1.SessionID, p2.SessionID)
+
+	// first session is still alive
+	s1After, err := service.Session.GetByID(ctx, p1.SessionID)
+	assert.NoError(t, err)
+	assert.Equal(t, s1, s1After)
+
+	sessions, err := service.Session.ListByUserID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	// both sessions can be refreshed independently
+	_, _, err = service.Auth.RefreshSession(ctx, aT1, rT1, IP1)
+	assert.NoError(t, err)
+	_, _, err = service.Auth.RefreshSession(ctx, aT2, rT2, IP2)
 	assert.NoError(t, err)
-	assert.Equal(t, s1.ID, s2.ID)
-	assert.Equal(t, s1.UserID, s2.UserID)
-	assert.Equal(t, s1.Version+1, s2.Version)
-	assert.NotEqual(t, s1.ATokenID, s2.ATokenID)
-	assert.NotEqual(t, s1.RTokenHash, s2.RTokenHash)
 }
 
 func TestAuth_RefreshSession(t *testing.T) {
@@ -137,10 +221,10 @@ func TestAuth_RefreshSession(t *testing.T) {
 	assert.NotEmpty(t, aT1)
 	assert.NotEmpty(t, rT1)
 
-	s1, err := service.Session.GetByUserID(ctx, user.ID)
+	_, p1, err := service.JWT.VerifyToken(ctx, aT1)
 	assert.NoError(t, err)
 
-	_, p1, err := service.JWT.VerifyToken(aT1)
+	s1, err := service.Session.GetByID(ctx, p1.SessionID)
 	assert.NoError(t, err)
 
 	// Refresh session
@@ -151,7 +235,7 @@ func TestAuth_RefreshSession(t *testing.T) {
 	assert.NotEqual(t, aT1, aT2)
 	assert.NotEqual(t, rT1, rT2)
 
-	s2, err := service.Session.GetByUserID(ctx, user.ID)
+	s2, err := service.Session.GetByID(ctx, p1.SessionID)
 	assert.NoError(t, err)
 	assert.Equal(t, s1.ID, s2.ID)
 	assert.Equal(t, s1.UserID, s2.UserID)
@@ -159,30 +243,19 @@ func TestAuth_RefreshSession(t *testing.T) {
 	assert.NotEqual(t, s1.ATokenID, s2.ATokenID)
 	assert.NotEqual(t, s1.RTokenHash, s2.RTokenHash)
 
-	_, p2, err := service.JWT.VerifyToken(aT2)
+	_, p2, err := service.JWT.VerifyToken(ctx, aT2)
 	assert.NoError(t, err)
 	assert.Equal(t, p1.IP, p2.IP)
 	assert.Equal(t, p1.UserID, p2.UserID)
+	assert.Equal(t, p1.SessionID, p2.SessionID)
 	assert.NotEqual(t, p1.ID, p2.ID)
 
-	// Try refrsh with old aToken and old rToken
-	aT3, rT3, err := service.Auth.RefreshSession(ctx, aT1, rT1, IP1)
-	assert.Error(t, err)
-	assert.Empty(t, aT3)
-	assert.Empty(t, rT3)
-
-	// Try refrsh with new aToken and old rToken
-	// check that i can't refresh session even i have new aT
-	aT3, rT3, err = service.Auth.RefreshSession(ctx, aT1, rT2, IP1)
-	assert.Error(t, err)
-	assert.Empty(t, aT3)
-	assert.Empty(t, rT3)
-
-	// Try refresh with old Token and new rToken
-	// aToken also valid, so we need check strong link between at and rt
-	// we check that i can't use valid aT1 with valid rT2
-	aT3, rT3, err = service.Auth.RefreshSession(ctx, aT1, rT2, IP1)
-	assert.Error(t, err)
+	// Try refresh with selector of new rToken and forged secret
+	// session is found by selector, but secret must match too
+	selector, _, _ := strings.Cut(rT2, ".")
+	aT3, rT3, err := service.Auth.RefreshSession(ctx, aT2, selector+".forged", IP1)
+	assert.ErrorIs(t, err, auth.ErrValidationFailed)
+	assert.NotErrorIs(t, err, auth.ErrTokenReused)
 	assert.Empty(t, aT3)
 	assert.Empty(t, rT3)
 
@@ -206,8 +279,9 @@ func TestAuth_RefreshSession(t *testing.T) {
 	// Refresh from new ip
 	counOfMsgsBefore := getLenSmtpMessages(t, apiEndpoint)
 
+	// access token isn't needed to refresh
 	IP2 := "::2"
-	aT3, rT3, err = service.Auth.RefreshSession(ctx, aT2, rT2, IP2)
+	aT3, rT3, err = service.Auth.RefreshSession(ctx, "", rT2, IP2)
 	assert.NoError(t, err) // in my service we just notify user about login from new ip
 	assert.NotEmpty(t, aT3)
 	assert.NotEmpty(t, rT3)
@@ -216,4 +290,525 @@ func TestAuth_RefreshSession(t *testing.T) {
 
 	countOfMsgsAfter := getLenSmtpMessages(t, apiEndpoint)
 	assert.Equal(t, counOfMsgsBefore+1, countOfMsgsAfter)
+
+	// Replay of already rotated pair means that tokens were stolen,
+	// whole session must be revoked and user notified
+	aT4, rT4, err := service.Auth.RefreshSession(ctx, aT1, rT1, IP1)
+	assert.ErrorIs(t, err, auth.ErrTokenReused)
+	assert.Empty(t, aT4)
+	assert.Empty(t, rT4)
+
+	s3, err := service.Session.GetByID(ctx, p1.SessionID)
+	assert.NoError(t, err)
+	assert.NotZero(t, s3.RevokedAt)
+
+	countOfMsgsAfterReuse := getLenSmtpMessages(t, apiEndpoint)
+	assert.Equal(t, countOfMsgsAfter+1, countOfMsgsAfterReuse)
+
+	// Last issued pair is also not valid anymore, access token is denylisted
+	aT4, rT4, err = service.Auth.RefreshSession(ctx, aT3, rT3, IP1)
+	assert.ErrorIs(t, err, auth.ErrSessionRevoked)
+	assert.Empty(t, aT4)
+	assert.Empty(t, rT4)
+
+	_, _, err = service.JWT.VerifyToken(ctx, aT3)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+}
+
+func TestAuth_RevokeSession(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	user, err := service.User.GetByID(ctx, 1)
+	assert.NoError(t, err)
+
+	IP := "::1"
+	aT1, rT1, err := service.Auth.CreateSession(ctx, user.ID, IP)
+	assert.NoError(t, err)
+	aT2, rT2, err := service.Auth.CreateSession(ctx, user.ID, IP)
+	assert.NoError(t, err)
+	aT3, rT3, err := service.Auth.CreateSession(ctx, user.ID, IP)
+	assert.NoError(t, err)
+
+	// Logout from first device
+	_, payload1, err := service.JWT.VerifyToken(ctx, aT1)
+	assert.NoError(t, err)
+	err = service.Auth.RevokeSession(ctx, payload1)
+	assert.NoError(t, err)
+
+	_, _, err = service.Auth.RefreshSession(ctx, aT1, rT1, IP)
+	assert.ErrorIs(t, err, auth.ErrSessionRevoked)
+
+	// access token is rejected before it expires
+	_, _, err = service.JWT.VerifyToken(ctx, aT1)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+
+	// other devices are still logged in
+	aT2, rT2, err = service.Auth.RefreshSession(ctx, aT2, rT2, IP)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, aT2)
+	assert.NotEmpty(t, rT2)
+
+	// Logout everywhere
+	err = service.Auth.RevokeAllSessions(ctx, user.ID)
+	assert.NoError(t, err)
+
+	_, _, err = service.Auth.RefreshSession(ctx, aT2, rT2, IP)
+	assert.ErrorIs(t, err, auth.ErrSessionRevoked)
+	_, _, err = service.Auth.RefreshSession(ctx, aT3, rT3, IP)
+	assert.ErrorIs(t, err, auth.ErrSessionRevoked)
+}
+
+func TestAuth_Introspect(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+	aT, rT, err := service.Auth.CreateSession(ctx, 1, IP)
+	assert.NoError(t, err)
+
+	res, err := service.Auth.Introspect(ctx, aT, "")
+	assert.NoError(t, err)
+	assert.True(t, res.Active)
+	assert.Equal(t, "1", res.Sub)
+	assert.Equal(t, IP, res.IP)
+
+	res, err = service.Auth.Introspect(ctx, rT, auth.TokenTypeRefresh)
+	assert.NoError(t, err)
+	assert.True(t, res.Active)
+	assert.Equal(t, auth.TokenTypeRefresh, res.TokenType)
+
+	_, payload, err := service.JWT.VerifyToken(ctx, aT)
+	assert.NoError(t, err)
+	err = service.Auth.RevokeSession(ctx, payload)
+	assert.NoError(t, err)
+
+	res, err = service.Auth.Introspect(ctx, aT, "")
+	assert.NoError(t, err)
+	assert.False(t, res.Active)
+
+	res, err = service.Auth.Introspect(ctx, rT, auth.TokenTypeRefresh)
+	assert.NoError(t, err)
+	assert.False(t, res.Active)
+}
+
+func TestAuth_Login(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+	password := "password"
+
+	user, err := service.User.Create(ctx, model.User{Email: "login@gmail.com"}, "")
+	assert.NoError(t, err)
+
+	// password is not set yet
+	_, _, err = service.Auth.Login(ctx, user.Email, "", IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	err = service.User.SetPassword(ctx, user.ID, password)
+	assert.NoError(t, err)
+
+	aT, rT, err := service.Auth.Login(ctx, user.Email, password, IP)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, aT)
+	assert.NotEmpty(t, rT)
+
+	_, p, err := service.JWT.VerifyToken(ctx, aT)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, p.UserID)
+
+	// wrong password and unknown email are not distinguishable
+	_, _, err = service.Auth.Login(ctx, user.Email, "wrong_password", IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, _, err = service.Auth.Login(ctx, "unknown@gmail.com", password, IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	// old password is required to change it
+	err = service.User.ChangePassword(ctx, user.ID, "wrong_password", "new_password")
+	assert.ErrorIs(t, err, userService.ErrInvalidPassword)
+	err = service.User.ChangePassword(ctx, user.ID, password, "new_password")
+	assert.NoError(t, err)
+
+	_, _, err = service.Auth.Login(ctx, user.Email, password, IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, _, err = service.Auth.Login(ctx, user.Email, "new_password", IP)
+	assert.NoError(t, err)
+}
+
+func TestUser_VerifyEmail(t *testing.T) {
+	service, close, _, apiEndpoint, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	// users created before verification flow are trusted
+	user, err := service.User.GetByID(ctx, 1)
+	assert.NoError(t, err)
+	assert.NotZero(t, user.EmailVerifiedAt)
+
+	user, err = service.User.Create(ctx, model.User{Email: "verify@gmail.com"}, "")
+	assert.NoError(t, err)
+	assert.Zero(t, user.EmailVerifiedAt)
+
+	token := messageToken(t, apiEndpoint, user.Email, defaultConfig.EmailVerification.URL)
+
+	err = service.User.VerifyEmail(ctx, token+"a")
+	assert.ErrorIs(t, err, userService.ErrInvalidVerificationToken)
+
+	err = service.User.VerifyEmail(ctx, token)
+	assert.NoError(t, err)
+
+	user, err = service.User.GetByID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.NotZero(t, user.EmailVerifiedAt)
+
+	// link is single-use
+	err = service.User.VerifyEmail(ctx, token)
+	assert.ErrorIs(t, err, userService.ErrInvalidVerificationToken)
+}
+
+func TestAuth_ResetPassword(t *testing.T) {
+	service, close, _, apiEndpoint, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+	password := "password"
+
+	user, err := service.User.Create(ctx, model.User{Email: "reset@gmail.com"}, "")
+	assert.NoError(t, err)
+	err = service.User.SetPassword(ctx, user.ID, password)
+	assert.NoError(t, err)
+
+	aT, rT, err := service.Auth.Login(ctx, user.Email, password, IP)
+	assert.NoError(t, err)
+
+	// unknown email is not distinguishable
+	err = service.Auth.ForgotPassword(ctx, "unknown@gmail.com", IP)
+	assert.NoError(t, err)
+
+	err = service.Auth.ForgotPassword(ctx, user.Email, IP)
+	assert.NoError(t, err)
+	token := messageToken(t, apiEndpoint, user.Email, defaultConfig.PasswordReset.URL)
+
+	// weak password doesn't burn the link
+	err = service.Auth.ResetPassword(ctx, token, "short")
+	assert.ErrorIs(t, err, userService.ErrWeakPassword)
+
+	err = service.Auth.ResetPassword(ctx, token, "new_password")
+	assert.NoError(t, err)
+
+	// link is single-use
+	err = service.Auth.ResetPassword(ctx, token, "other_password")
+	assert.ErrorIs(t, err, auth.ErrInvalidResetToken)
+
+	// sessions opened with old password are closed
+	_, _, err = service.Auth.RefreshSession(ctx, aT, rT, IP)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+
+	_, _, err = service.Auth.Login(ctx, user.Email, password, IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	_, _, err = service.Auth.Login(ctx, user.Email, "new_password", IP)
+	assert.NoError(t, err)
+}
+
+func TestAuth_MagicLink(t *testing.T) {
+	service, close, _, apiEndpoint, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+
+	user, err := service.User.Create(ctx, model.User{Email: "magic@gmail.com"}, "")
+	assert.NoError(t, err)
+
+	// unknown email gets nonce too
+	nonce, err := service.Auth.SendMagicLink(ctx, "unknown@gmail.com", IP)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, nonce)
+
+	nonce, err = service.Auth.SendMagicLink(ctx, user.Email, IP)
+	assert.NoError(t, err)
+	token := messageToken(t, apiEndpoint, user.Email, defaultConfig.MagicLink.URL)
+
+	// link opened in other browser doesn't burn it
+	_, _, err = service.Auth.LoginByMagicLink(ctx, token, "other_nonce", IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+
+	aT, rT, err := service.Auth.LoginByMagicLink(ctx, token, nonce, IP)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, aT)
+	assert.NotEmpty(t, rT)
+
+	_, p, err := service.JWT.VerifyToken(ctx, aT)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, p.UserID)
+
+	// link is single-use
+	_, _, err = service.Auth.LoginByMagicLink(ctx, token, nonce, IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidMagicLink)
+}
+
+func TestAuth_TOTP(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+	password := "password"
+
+	user, err := service.User.Create(ctx, model.User{Email: "totp@gmail.com"}, "")
+	assert.NoError(t, err)
+	err = service.User.SetPassword(ctx, user.ID, password)
+	assert.NoError(t, err)
+
+	secret, uri, err := service.MFA.Enroll(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, uri, "otpauth://totp/")
+
+	authenticator := totp.New(nil)
+	code, err := authenticator.Code(secret, time.Now())
+	assert.NoError(t, err)
+
+	recoveryCodes, err := service.MFA.Confirm(ctx, user.ID, code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, mfa.RecoveryCodesCount)
+
+	// password is not enough anymore
+	_, _, err = service.Auth.Login(ctx, user.Email, password, IP)
+	var challenge *auth.MFAChallengeError
+	assert.ErrorAs(t, err, &challenge)
+
+	// code of confirmation can't be replayed
+	_, _, err = service.Auth.VerifyMFA(ctx, challenge.Token, code, IP)
+	assert.ErrorIs(t, err, mfa.ErrInvalidCode)
+
+	// challenge is burned by wrong code
+	_, _, err = service.Auth.VerifyMFA(ctx, challenge.Token, recoveryCodes[0], IP)
+	assert.ErrorIs(t, err, auth.ErrInvalidMFAChallenge)
+
+	_, _, err = service.Auth.Login(ctx, user.Email, password, IP)
+	assert.ErrorAs(t, err, &challenge)
+
+	aT, rT, err := service.Auth.VerifyMFA(ctx, challenge.Token, recoveryCodes[0], IP)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rT)
+
+	_, p, err := service.JWT.VerifyToken(ctx, aT)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.AMRPassword, model.AMRRecovery, model.AMRMFA}, p.AMR)
+
+	// recovery code is single-use
+	_, _, err = service.Auth.Login(ctx, user.Email, password, IP)
+	assert.ErrorAs(t, err, &challenge)
+	_, _, err = service.Auth.VerifyMFA(ctx, challenge.Token, recoveryCodes[0], IP)
+	assert.ErrorIs(t, err, mfa.ErrInvalidCode)
+}
+
+func TestAuth_Passkey(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+
+	user, err := service.User.Create(ctx, model.User{Email: "passkey@gmail.com"}, "")
+	assert.NoError(t, err)
+
+	authenticator, err := webauthntest.New("localhost", "http://localhost:8080")
+	assert.NoError(t, err)
+
+	creationOptions, err := service.Passkey.BeginRegistration(ctx, user.ID)
+	assert.NoError(t, err)
+	attestation, err := authenticator.Create(creationOptions)
+	assert.NoError(t, err)
+
+	_, err = service.Passkey.FinishRegistration(ctx, user.ID, attestation)
+	assert.NoError(t, err)
+
+	// challenge is single-use
+	_, err = service.Passkey.FinishRegistration(ctx, user.ID, attestation)
+	assert.ErrorIs(t, err, passkey.ErrInvalidChallenge)
+
+	// the same authenticator is excluded from next registration
+	creationOptions, err = service.Passkey.BeginRegistration(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, creationOptions.ExcludeCredentials, 1)
+
+	requestOptions, err := service.Passkey.BeginLogin(ctx, user.Email)
+	assert.NoError(t, err)
+	assert.Len(t, requestOptions.AllowCredentials, 1)
+	assertion, err := authenticator.Get(requestOptions)
+	assert.NoError(t, err)
+
+	uid, err := service.Passkey.FinishLogin(ctx, assertion)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, uid)
+
+	aT, _, err := service.Auth.CreateSession(ctx, uid, IP, model.AMRHardwareKey, model.AMRMFA)
+	assert.NoError(t, err)
+	_, p, err := service.JWT.VerifyToken(ctx, aT)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.AMRHardwareKey, model.AMRMFA}, p.AMR)
+
+	// clone of authenticator reports old counter
+	requestOptions, err = service.Passkey.BeginLogin(ctx, user.Email)
+	assert.NoError(t, err)
+	authenticator.SignCount = 0
+	assertion, err = authenticator.Get(requestOptions)
+	assert.NoError(t, err)
+	_, err = service.Passkey.FinishLogin(ctx, assertion)
+	assert.ErrorIs(t, err, passkey.ErrInvalidCredential)
+
+	// unknown email gets challenge which can't be used
+	requestOptions, err = service.Passkey.BeginLogin(ctx, "unknown@gmail.com")
+	assert.NoError(t, err)
+	assert.Empty(t, requestOptions.AllowCredentials)
+	assertion, err = authenticator.Get(requestOptions)
+	assert.NoError(t, err)
+	_, err = service.Passkey.FinishLogin(ctx, assertion)
+	assert.ErrorIs(t, err, passkey.ErrInvalidChallenge)
+}
+
+func TestOAuth_AuthorizationCode(t *testing.T) {
+	service, close, _, _, _ := setupService(t)
+	defer close()
+
+	ctx := context.Background()
+
+	IP := "::1"
+	verifier := "dBjftJeZ4CVP-mJ92K9qpfn3SSE3dRLt6wbHbV2Y9Ag"
+	challenge := "vnf8b8jxGZrDT0nc7-kI1kwPmMCW8QHPGVEli3Pd-fo"
+
+	user, err := service.User.Create(ctx, model.User{Email: "oauth@gmail.com"}, "")
+	assert.NoError(t, err)
+
+	userAT, _, err := service.Auth.CreateSession(ctx, user.ID, IP, model.AMRPassword)
+	assert.NoError(t, err)
+	_, userPayload, err := service.JWT.VerifyToken(ctx, userAT)
+	assert.NoError(t, err)
+
+	redirect, err := service.OAuth.Authorize(ctx, oauth.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            "spa",
+		Scope:               "openid",
+		State:               "xyz",
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: oauth.CodeChallengeMethodS256,
+		Nonce:               "nonce",
+	}, userPayload)
+	assert.NoError(t, err)
+	u, err := url.Parse(redirect)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz", u.Query().Get("state"))
+	code := u.Query().Get("code")
+
+	tokens, err := service.OAuth.Token(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		CodeVerifier: verifier,
+	}, IP)
+	assert.NoError(t, err)
+	assert.Equal(t, "openid", tokens.Scope)
+
+	_, p, err := service.JWT.VerifyToken(ctx, tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, p.UserID)
+	assert.Equal(t, "spa", p.ClientID)
+	assert.Equal(t, []string{model.AMRPassword}, p.AMR)
+	assert.Equal(t, userPayload.IssuedAt.Unix(), p.AuthTime)
+	assert.NotEmpty(t, tokens.IDToken)
+
+	info, err := service.OAuth.UserInfo(ctx, p)
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(user.ID), info.Subject)
+
+	// code is single-use
+	_, err = service.OAuth.Token(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         code,
+		CodeVerifier: verifier,
+	}, IP)
+	var oauthErr *oauth.Error
+	assert.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oauth.ErrorInvalidGrant, oauthErr.Code)
+
+	refreshed, err := service.OAuth.Token(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeRefreshToken,
+		ClientID:     "spa",
+		RefreshToken: tokens.RefreshToken,
+	}, IP)
+	assert.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+	_, p, err = service.JWT.VerifyToken(ctx, refreshed.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "spa", p.ClientID)
+	assert.Equal(t, "openid", p.Scope)
+
+	// rotated refresh token is rejected
+	_, err = service.OAuth.Token(ctx, oauth.TokenRequest{
+		GrantType:    oauth.GrantTypeRefreshToken,
+		ClientID:     "spa",
+		RefreshToken: tokens.RefreshToken,
+	}, IP)
+	assert.ErrorAs(t, err, &oauthErr)
+	assert.Equal(t, oauth.ErrorInvalidGrant, oauthErr.Code)
+}
+
+// messageToken extract token from link sent to MailHog for recipient
+// messageToken wait for message with link to be delivered, links are sent in background
+func messageToken(t *testing.T, apiEndpoint, to, link string) string {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if token := findMessageToken(t, apiEndpoint, to, link); token != "" {
+			return token
+		}
+	}
+
+	t.Errorf("message with %s to %s not found", link, to)
+	return ""
+}
+
+func findMessageToken(t *testing.T, apiEndpoint, to, link string) string {
+	// ref: https://github.com/mailhog/MailHog/blob/master/docs/APIv2/swagger-2.0.yaml#L8
+	res, err := http.Get(fmt.Sprintf("http://%s/api/v1/messages", apiEndpoint))
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	var msgs []struct {
+		Content struct {
+			Headers map[string][]string
+			Body    string
+		}
+	}
+	err = json.NewDecoder(res.Body).Decode(&msgs)
+	assert.NoError(t, err)
+
+	re := regexp.MustCompile(regexp.QuoteMeta(link) + `\?token=([A-Za-z0-9_.-]+)`)
+	for _, msg := range msgs {
+		if len(msg.Content.Headers["To"]) == 0 || msg.Content.Headers["To"][0] != to {
+			continue
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(msg.Content.Body)))
+		assert.NoError(t, err)
+
+		if match := re.FindStringSubmatch(string(body)); len(match) == 2 {
+			return match[1]
+		}
+	}
+	return ""
 }
@@ -0,0 +1,22 @@
+package model
+
+// AuthorizationCode is single-use code issued to OAuth 2.0 client, it's exchanged for tokens of user
+type AuthorizationCode struct {
+	CodeHash string `json:"-"`
+	ClientID string `json:"client_id"`
+	UserID   int    `json:"user_id"`
+	// as given in authorization request, empty if client omitted it
+	RedirectURI string `json:"redirect_uri"`
+	// base64url of sha256 of PKCE code verifier
+	CodeChallenge string `json:"code_challenge"`
+	// space-delimited, as in OAuth 2.0 requests
+	Scope string `json:"scope"`
+	// factors which user passed to open session that authorized client
+	AMR []string `json:"amr"`
+	// OpenID Connect nonce, returned in id token
+	Nonce string `json:"nonce"`
+	// unix time when user passed factors
+	AuthTime  int64 `json:"auth_time"`
+	ExpiresAt int64 `json:"expires_at"`
+	UsedAt    int64 `json:"used_at"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Geo is location and network of ip resolved by geoip database, fields which database doesn't know are empty
+type Geo struct {
+	// ISO 3166-1 alpha-2 code, e.g. RU
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	// autonomous system, i.e. provider which ip belongs to
+	ASN   uint   `json:"asn,omitempty"`
+	ASOrg string `json:"as_org,omitempty"`
+	// nil if coordinates are unknown, not saved with session
+	Location *Location `json:"-"`
+}
+
+// Location is approximate coordinates of ip
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// km, ip is somewhere within radius around coordinates
+	AccuracyRadius uint
+}
+
+// String format geo for logs and emails, e.g. "Moscow, RU, AS64500 Example Telecom", empty if nothing is known
+func (g Geo) String() string {
+	var parts []string
+	if g.City != "" {
+		parts = append(parts, g.City)
+	}
+	if g.Country != "" {
+		parts = append(parts, g.Country)
+	}
+	if g.ASN != 0 {
+		parts = append(parts, strings.TrimSpace(fmt.Sprintf("AS%d %s", g.ASN, g.ASOrg)))
+	} else if g.ASOrg != "" {
+		parts = append(parts, g.ASOrg)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDToken is claims of OpenID Connect id token, subject is id of user
+type IDToken struct {
+	// value of authentication request, protects client from replay
+	Nonce string `json:"nonce,omitempty"`
+	// unix time when user passed factors
+	AuthTime int64    `json:"auth_time"`
+	AMR      []string `json:"amr,omitempty"`
+	// released only with email scope
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserInfo is standard claims of user (OpenID Connect core section 5.1), released according to granted scope
+type UserInfo struct {
+	Subject       string `json:"sub" example:"1"`
+	Email         string `json:"email,omitempty" example:"user@gmail.com"`
+	EmailVerified *bool  `json:"email_verified,omitempty" example:"true"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// Introspection is state of token by RFC 7662, for inactive token only Active is set
+type Introspection struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+	IP        string `json:"ip,omitempty"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// OneTimeToken is single-use token sent to user, e.g. in email verification link
+type OneTimeToken struct {
+	TokenHash string `json:"-"`
+	Purpose   string `json:"purpose"`
+	UserID    int    `json:"user_id"`
+	ExpiresAt int64  `json:"expires_at"`
+	UsedAt    int64  `json:"used_at"`
+}
@@ -4,8 +4,30 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// authentication methods references (RFC 8176), values without registered name are named in the same manner
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+	// login link sent by email
+	AMREmail = "email"
+	// recovery code of second factor
+	AMRRecovery = "recovery"
+	// proof of possession of key, passkey with verified user
+	AMRHardwareKey = "hwk"
+)
+
 type Payload struct {
-	UserID int    `json:"user_id"`
-	IP     string `json:"ip"`
+	UserID    int    `json:"user_id"`
+	SessionID int    `json:"sid"`
+	IP        string `json:"ip"`
+	// factors used to open session
+	AMR []string `json:"amr,omitempty"`
+	// OAuth 2.0 client which session was opened for (RFC 9068), empty for first-party login
+	ClientID string `json:"client_id,omitempty"`
+	// space-delimited scope granted to client
+	Scope string `json:"scope,omitempty"`
+	// unix time when user passed factors, it's kept on refresh
+	AuthTime int64 `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
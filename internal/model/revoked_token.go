@@ -0,0 +1,7 @@
+package model
+
+// RevokedToken is jti of access token which must be rejected until it expires
+type RevokedToken struct {
+	JTI       string `json:"jti"`
+	ExpiresAt int64  `json:"expires_at"`
+}
@@ -1,10 +1,33 @@
 package model
 
 type Session struct {
-	ID         int    `json:"id"`
-	UserID     int    `json:"user_id"`
-	ATokenID   string `json:"access_token_id"`
-	RTokenHash string `json:"refresh_token_hash"`
-	CreatedAt  int64  `json:"created_at"`
-	Version    int64  `json:"version"`
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	ATokenID string `json:"access_token_id"`
+	// public part of refresh token, finds session by index, empty for sessions opened before selectors
+	RTokenSelector string `json:"-"`
+	RTokenHash     string `json:"refresh_token_hash"`
+	CreatedAt      int64  `json:"created_at"`
+	RevokedAt      int64  `json:"revoked_at"`
+	Version        int64  `json:"version"`
+
+	// ip and grant put into access tokens of session, refresh keeps them without access token
+	IP       string   `json:"ip"`
+	AMR      []string `json:"amr,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+	AuthTime int64    `json:"auth_time,omitempty"`
+
+	// geo of ip of session, empty without geoip database
+	Geo
+}
+
+// SessionRotation keeps tokens superseded by refresh, needed to detect reuse of old refresh token
+type SessionRotation struct {
+	ID             int    `json:"id"`
+	SessionID      int    `json:"session_id"`
+	ATokenID       string `json:"access_token_id"`
+	RTokenSelector string `json:"-"`
+	RTokenHash     string `json:"refresh_token_hash"`
+	RotatedAt      int64  `json:"rotated_at"`
 }
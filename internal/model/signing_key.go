@@ -0,0 +1,13 @@
+package model
+
+// SigningKey is key of jwt keyring as it's stored, material is sealed with master key
+type SigningKey struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Material  string `json:"-"`
+	State     string `json:"state"`
+	CreatedAt int64  `json:"created_at"`
+	// zero if key never signed or signs now
+	PromotedAt int64 `json:"promoted_at"`
+	DemotedAt  int64 `json:"demoted_at"`
+}
@@ -1,6 +1,13 @@
 package model
 
 type User struct {
-	ID    int    `json:"id"`
-	Email string `json:"email"`
+	ID           int    `json:"id"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"`
+	// 0 if email is not verified yet
+	EmailVerifiedAt int64 `json:"email_verified_at"`
+	// encrypted, empty if user never started enrollment of authenticator
+	TOTPSecret string `json:"-"`
+	// 0 if second factor is not enabled
+	TOTPEnabledAt int64 `json:"totp_enabled_at"`
 }
@@ -0,0 +1,14 @@
+package model
+
+// WebAuthnCredential is passkey of user registered by WebAuthn
+type WebAuthnCredential struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// base64url of credential id given by authenticator
+	CredentialID string `json:"credential_id"`
+	// COSE_Key
+	PublicKey  []byte `json:"-"`
+	SignCount  int64  `json:"sign_count"`
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+}
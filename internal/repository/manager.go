@@ -8,29 +8,97 @@ import (
 )
 
 type Manager struct {
-	User    User
-	Session Session
+	User         User
+	Session      Session
+	RevokedToken RevokedToken
+	OneTimeToken OneTimeToken
+	RecoveryCode RecoveryCode
+
+	WebAuthnCredential WebAuthnCredential
+	AuthorizationCode  AuthorizationCode
+	SigningKey         SigningKey
 }
 
 func New(conn *sql.DB) *Manager {
 	userRepo := postgres.NewUserRepository(conn)
 	sessionRepo := postgres.NewSessionRepository(conn)
+	revokedTokenRepo := postgres.NewRevokedTokenRepository(conn)
+	oneTimeTokenRepo := postgres.NewOneTimeTokenRepository(conn)
+	recoveryCodeRepo := postgres.NewRecoveryCodeRepository(conn)
+	webAuthnCredentialRepo := postgres.NewWebAuthnCredentialRepository(conn)
+	authorizationCodeRepo := postgres.NewAuthorizationCodeRepository(conn)
+	signingKeyRepo := postgres.NewSigningKeyRepository(conn)
 
 	return &Manager{
-		User:    userRepo,
-		Session: sessionRepo,
+		User:         userRepo,
+		Session:      sessionRepo,
+		RevokedToken: revokedTokenRepo,
+		OneTimeToken: oneTimeTokenRepo,
+		RecoveryCode: recoveryCodeRepo,
+
+		WebAuthnCredential: webAuthnCredentialRepo,
+		AuthorizationCode:  authorizationCodeRepo,
+		SigningKey:         signingKeyRepo,
 	}
 }
 
 type User interface {
-	Create(ctx context.Context, u model.User) error
+	Create(ctx context.Context, u model.User) (model.User, error)
+	UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id int, verifiedAt int64) error
+	UpdateTOTPSecret(ctx context.Context, id int, secret string) error
+	EnableTOTP(ctx context.Context, id int, enabledAt, step int64) error
+	UseTOTPStep(ctx context.Context, id int, step int64) error
 	GetByID(ctx context.Context, id int) (model.User, error)
+	GetByEmail(ctx context.Context, email string) (model.User, error)
 	List(ctx context.Context) ([]model.User, error)
 }
 
 type Session interface {
-	Create(ctx context.Context, session model.Session) error
+	Create(ctx context.Context, session model.Session) (model.Session, error)
 	Update(ctx context.Context, session model.Session) (model.Session, error)
-	GetByUserID(ctx context.Context, id int) (model.Session, error)
+	Rotate(ctx context.Context, session model.Session) (model.Session, error)
+	GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error)
+	GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error)
+	Revoke(ctx context.Context, id int, revokedAt int64) error
+	RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error
+	GetByID(ctx context.Context, id int) (model.Session, error)
+	GetBySelector(ctx context.Context, selector string) (model.Session, error)
+	ListByUserID(ctx context.Context, userID int) ([]model.Session, error)
 	List(ctx context.Context) ([]model.Session, error)
 }
+
+type RevokedToken interface {
+	Create(ctx context.Context, t model.RevokedToken) error
+	GetByJTI(ctx context.Context, jti string) (model.RevokedToken, error)
+	DeleteExpired(ctx context.Context, now int64) (int64, error)
+}
+
+type OneTimeToken interface {
+	Create(ctx context.Context, t model.OneTimeToken) error
+	Consume(ctx context.Context, tokenHash, purpose string, now int64) (model.OneTimeToken, error)
+	DeleteExpired(ctx context.Context, now int64) (int64, error)
+}
+
+type RecoveryCode interface {
+	Replace(ctx context.Context, userID int, codeHashes []string) error
+	Use(ctx context.Context, userID int, codeHash string, usedAt int64) error
+}
+
+type WebAuthnCredential interface {
+	Create(ctx context.Context, c model.WebAuthnCredential) (model.WebAuthnCredential, error)
+	UpdateSignCount(ctx context.Context, id int, oldCount, newCount, usedAt int64) error
+	GetByCredentialID(ctx context.Context, credentialID string) (model.WebAuthnCredential, error)
+	ListByUserID(ctx context.Context, userID int) ([]model.WebAuthnCredential, error)
+}
+
+type AuthorizationCode interface {
+	Create(ctx context.Context, c model.AuthorizationCode) error
+	Consume(ctx context.Context, codeHash string, now int64) (model.AuthorizationCode, error)
+	DeleteExpired(ctx context.Context, now int64) (int64, error)
+}
+
+type SigningKey interface {
+	List(ctx context.Context) ([]model.SigningKey, error)
+	Update(ctx context.Context, fn func(keys []model.SigningKey) ([]model.SigningKey, error)) error
+}
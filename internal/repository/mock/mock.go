@@ -36,11 +36,12 @@ func (m *MockUser) EXPECT() *MockUserMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockUser) Create(ctx context.Context, u model.User) error {
+func (m *MockUser) Create(ctx context.Context, u model.User) (model.User, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", ctx, u)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
@@ -49,6 +50,35 @@ func (mr *MockUserMockRecorder) Create(ctx, u interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUser)(nil).Create), ctx, u)
 }
 
+// EnableTOTP mocks base method.
+func (m *MockUser) EnableTOTP(ctx context.Context, id int, enabledAt, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", ctx, id, enabledAt, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockUserMockRecorder) EnableTOTP(ctx, id, enabledAt, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockUser)(nil).EnableTOTP), ctx, id, enabledAt, step)
+}
+
+// GetByEmail mocks base method.
+func (m *MockUser) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByEmail", ctx, email)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByEmail indicates an expected call of GetByEmail.
+func (mr *MockUserMockRecorder) GetByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockUser)(nil).GetByEmail), ctx, email)
+}
+
 // GetByID mocks base method.
 func (m *MockUser) GetByID(ctx context.Context, id int) (model.User, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +109,62 @@ func (mr *MockUserMockRecorder) List(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockUser)(nil).List), ctx)
 }
 
+// MarkEmailVerified mocks base method.
+func (m *MockUser) MarkEmailVerified(ctx context.Context, id int, verifiedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailVerified", ctx, id, verifiedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEmailVerified indicates an expected call of MarkEmailVerified.
+func (mr *MockUserMockRecorder) MarkEmailVerified(ctx, id, verifiedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailVerified", reflect.TypeOf((*MockUser)(nil).MarkEmailVerified), ctx, id, verifiedAt)
+}
+
+// UpdatePasswordHash mocks base method.
+func (m *MockUser) UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePasswordHash", ctx, id, passwordHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePasswordHash indicates an expected call of UpdatePasswordHash.
+func (mr *MockUserMockRecorder) UpdatePasswordHash(ctx, id, passwordHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePasswordHash", reflect.TypeOf((*MockUser)(nil).UpdatePasswordHash), ctx, id, passwordHash)
+}
+
+// UpdateTOTPSecret mocks base method.
+func (m *MockUser) UpdateTOTPSecret(ctx context.Context, id int, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTOTPSecret", ctx, id, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTOTPSecret indicates an expected call of UpdateTOTPSecret.
+func (mr *MockUserMockRecorder) UpdateTOTPSecret(ctx, id, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTOTPSecret", reflect.TypeOf((*MockUser)(nil).UpdateTOTPSecret), ctx, id, secret)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockUser) UseTOTPStep(ctx context.Context, id int, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, id, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockUserMockRecorder) UseTOTPStep(ctx, id, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockUser)(nil).UseTOTPStep), ctx, id, step)
+}
+
 // MockSession is a mock of Session interface.
 type MockSession struct {
 	ctrl     *gomock.Controller
@@ -103,11 +189,12 @@ func (m *MockSession) EXPECT() *MockSessionMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockSession) Create(ctx context.Context, session model.Session) error {
+func (m *MockSession) Create(ctx context.Context, session model.Session) (model.Session, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", ctx, session)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
@@ -116,19 +203,64 @@ func (mr *MockSessionMockRecorder) Create(ctx, session interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSession)(nil).Create), ctx, session)
 }
 
-// GetByUserID mocks base method.
-func (m *MockSession) GetByUserID(ctx context.Context, id int) (model.Session, error) {
+// GetByID mocks base method.
+func (m *MockSession) GetByID(ctx context.Context, id int) (model.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByUserID", ctx, id)
+	ret := m.ctrl.Call(m, "GetByID", ctx, id)
 	ret0, _ := ret[0].(model.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetByUserID indicates an expected call of GetByUserID.
-func (mr *MockSessionMockRecorder) GetByUserID(ctx, id interface{}) *gomock.Call {
+// GetByID indicates an expected call of GetByID.
+func (mr *MockSessionMockRecorder) GetByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUserID", reflect.TypeOf((*MockSession)(nil).GetByUserID), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockSession)(nil).GetByID), ctx, id)
+}
+
+// GetBySelector mocks base method.
+func (m *MockSession) GetBySelector(ctx context.Context, selector string) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBySelector", ctx, selector)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBySelector indicates an expected call of GetBySelector.
+func (mr *MockSessionMockRecorder) GetBySelector(ctx, selector interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBySelector", reflect.TypeOf((*MockSession)(nil).GetBySelector), ctx, selector)
+}
+
+// GetRotation mocks base method.
+func (m *MockSession) GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRotation", ctx, sessionID, aTokenID)
+	ret0, _ := ret[0].(model.SessionRotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRotation indicates an expected call of GetRotation.
+func (mr *MockSessionMockRecorder) GetRotation(ctx, sessionID, aTokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRotation", reflect.TypeOf((*MockSession)(nil).GetRotation), ctx, sessionID, aTokenID)
+}
+
+// GetRotationBySelector mocks base method.
+func (m *MockSession) GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRotationBySelector", ctx, selector)
+	ret0, _ := ret[0].(model.SessionRotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRotationBySelector indicates an expected call of GetRotationBySelector.
+func (mr *MockSessionMockRecorder) GetRotationBySelector(ctx, selector interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRotationBySelector", reflect.TypeOf((*MockSession)(nil).GetRotationBySelector), ctx, selector)
 }
 
 // List mocks base method.
@@ -146,6 +278,64 @@ func (mr *MockSessionMockRecorder) List(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSession)(nil).List), ctx)
 }
 
+// ListByUserID mocks base method.
+func (m *MockSession) ListByUserID(ctx context.Context, userID int) ([]model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUserID", ctx, userID)
+	ret0, _ := ret[0].([]model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUserID indicates an expected call of ListByUserID.
+func (mr *MockSessionMockRecorder) ListByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUserID", reflect.TypeOf((*MockSession)(nil).ListByUserID), ctx, userID)
+}
+
+// Revoke mocks base method.
+func (m *MockSession) Revoke(ctx context.Context, id int, revokedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockSessionMockRecorder) Revoke(ctx, id, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockSession)(nil).Revoke), ctx, id, revokedAt)
+}
+
+// RevokeAllByUserID mocks base method.
+func (m *MockSession) RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllByUserID", ctx, userID, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAllByUserID indicates an expected call of RevokeAllByUserID.
+func (mr *MockSessionMockRecorder) RevokeAllByUserID(ctx, userID, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllByUserID", reflect.TypeOf((*MockSession)(nil).RevokeAllByUserID), ctx, userID, revokedAt)
+}
+
+// Rotate mocks base method.
+func (m *MockSession) Rotate(ctx context.Context, session model.Session) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", ctx, session)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockSessionMockRecorder) Rotate(ctx, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockSession)(nil).Rotate), ctx, session)
+}
+
 // Update mocks base method.
 func (m *MockSession) Update(ctx context.Context, session model.Session) (model.Session, error) {
 	m.ctrl.T.Helper()
@@ -160,3 +350,389 @@ func (mr *MockSessionMockRecorder) Update(ctx, session interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSession)(nil).Update), ctx, session)
 }
+
+// MockRevokedToken is a mock of RevokedToken interface.
+type MockRevokedToken struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevokedTokenMockRecorder
+}
+
+// MockRevokedTokenMockRecorder is the mock recorder for MockRevokedToken.
+type MockRevokedTokenMockRecorder struct {
+	mock *MockRevokedToken
+}
+
+// NewMockRevokedToken creates a new mock instance.
+func NewMockRevokedToken(ctrl *gomock.Controller) *MockRevokedToken {
+	mock := &MockRevokedToken{ctrl: ctrl}
+	mock.recorder = &MockRevokedTokenMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevokedToken) EXPECT() *MockRevokedTokenMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockRevokedToken) Create(ctx context.Context, t model.RevokedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockRevokedTokenMockRecorder) Create(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRevokedToken)(nil).Create), ctx, t)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockRevokedToken) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockRevokedTokenMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockRevokedToken)(nil).DeleteExpired), ctx, now)
+}
+
+// GetByJTI mocks base method.
+func (m *MockRevokedToken) GetByJTI(ctx context.Context, jti string) (model.RevokedToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByJTI", ctx, jti)
+	ret0, _ := ret[0].(model.RevokedToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByJTI indicates an expected call of GetByJTI.
+func (mr *MockRevokedTokenMockRecorder) GetByJTI(ctx, jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByJTI", reflect.TypeOf((*MockRevokedToken)(nil).GetByJTI), ctx, jti)
+}
+
+// MockOneTimeToken is a mock of OneTimeToken interface.
+type MockOneTimeToken struct {
+	ctrl     *gomock.Controller
+	recorder *MockOneTimeTokenMockRecorder
+}
+
+// MockOneTimeTokenMockRecorder is the mock recorder for MockOneTimeToken.
+type MockOneTimeTokenMockRecorder struct {
+	mock *MockOneTimeToken
+}
+
+// NewMockOneTimeToken creates a new mock instance.
+func NewMockOneTimeToken(ctrl *gomock.Controller) *MockOneTimeToken {
+	mock := &MockOneTimeToken{ctrl: ctrl}
+	mock.recorder = &MockOneTimeTokenMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOneTimeToken) EXPECT() *MockOneTimeTokenMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockOneTimeToken) Consume(ctx context.Context, tokenHash, purpose string, now int64) (model.OneTimeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, tokenHash, purpose, now)
+	ret0, _ := ret[0].(model.OneTimeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockOneTimeTokenMockRecorder) Consume(ctx, tokenHash, purpose, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockOneTimeToken)(nil).Consume), ctx, tokenHash, purpose, now)
+}
+
+// Create mocks base method.
+func (m *MockOneTimeToken) Create(ctx context.Context, t model.OneTimeToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockOneTimeTokenMockRecorder) Create(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOneTimeToken)(nil).Create), ctx, t)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockOneTimeToken) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockOneTimeTokenMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockOneTimeToken)(nil).DeleteExpired), ctx, now)
+}
+
+// MockRecoveryCode is a mock of RecoveryCode interface.
+type MockRecoveryCode struct {
+	ctrl     *gomock.Controller
+	recorder *MockRecoveryCodeMockRecorder
+}
+
+// MockRecoveryCodeMockRecorder is the mock recorder for MockRecoveryCode.
+type MockRecoveryCodeMockRecorder struct {
+	mock *MockRecoveryCode
+}
+
+// NewMockRecoveryCode creates a new mock instance.
+func NewMockRecoveryCode(ctrl *gomock.Controller) *MockRecoveryCode {
+	mock := &MockRecoveryCode{ctrl: ctrl}
+	mock.recorder = &MockRecoveryCodeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRecoveryCode) EXPECT() *MockRecoveryCodeMockRecorder {
+	return m.recorder
+}
+
+// Replace mocks base method.
+func (m *MockRecoveryCode) Replace(ctx context.Context, userID int, codeHashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, userID, codeHashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockRecoveryCodeMockRecorder) Replace(ctx, userID, codeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockRecoveryCode)(nil).Replace), ctx, userID, codeHashes)
+}
+
+// Use mocks base method.
+func (m *MockRecoveryCode) Use(ctx context.Context, userID int, codeHash string, usedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Use", ctx, userID, codeHash, usedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Use indicates an expected call of Use.
+func (mr *MockRecoveryCodeMockRecorder) Use(ctx, userID, codeHash, usedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockRecoveryCode)(nil).Use), ctx, userID, codeHash, usedAt)
+}
+
+// MockWebAuthnCredential is a mock of WebAuthnCredential interface.
+type MockWebAuthnCredential struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebAuthnCredentialMockRecorder
+}
+
+// MockWebAuthnCredentialMockRecorder is the mock recorder for MockWebAuthnCredential.
+type MockWebAuthnCredentialMockRecorder struct {
+	mock *MockWebAuthnCredential
+}
+
+// NewMockWebAuthnCredential creates a new mock instance.
+func NewMockWebAuthnCredential(ctrl *gomock.Controller) *MockWebAuthnCredential {
+	mock := &MockWebAuthnCredential{ctrl: ctrl}
+	mock.recorder = &MockWebAuthnCredentialMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebAuthnCredential) EXPECT() *MockWebAuthnCredentialMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockWebAuthnCredential) Create(ctx context.Context, c model.WebAuthnCredential) (model.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, c)
+	ret0, _ := ret[0].(model.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockWebAuthnCredentialMockRecorder) Create(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockWebAuthnCredential)(nil).Create), ctx, c)
+}
+
+// GetByCredentialID mocks base method.
+func (m *MockWebAuthnCredential) GetByCredentialID(ctx context.Context, credentialID string) (model.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByCredentialID", ctx, credentialID)
+	ret0, _ := ret[0].(model.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByCredentialID indicates an expected call of GetByCredentialID.
+func (mr *MockWebAuthnCredentialMockRecorder) GetByCredentialID(ctx, credentialID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByCredentialID", reflect.TypeOf((*MockWebAuthnCredential)(nil).GetByCredentialID), ctx, credentialID)
+}
+
+// ListByUserID mocks base method.
+func (m *MockWebAuthnCredential) ListByUserID(ctx context.Context, userID int) ([]model.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUserID", ctx, userID)
+	ret0, _ := ret[0].([]model.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUserID indicates an expected call of ListByUserID.
+func (mr *MockWebAuthnCredentialMockRecorder) ListByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUserID", reflect.TypeOf((*MockWebAuthnCredential)(nil).ListByUserID), ctx, userID)
+}
+
+// UpdateSignCount mocks base method.
+func (m *MockWebAuthnCredential) UpdateSignCount(ctx context.Context, id int, oldCount, newCount, usedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignCount", ctx, id, oldCount, newCount, usedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSignCount indicates an expected call of UpdateSignCount.
+func (mr *MockWebAuthnCredentialMockRecorder) UpdateSignCount(ctx, id, oldCount, newCount, usedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignCount", reflect.TypeOf((*MockWebAuthnCredential)(nil).UpdateSignCount), ctx, id, oldCount, newCount, usedAt)
+}
+
+// MockAuthorizationCode is a mock of AuthorizationCode interface.
+type MockAuthorizationCode struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthorizationCodeMockRecorder
+}
+
+// MockAuthorizationCodeMockRecorder is the mock recorder for MockAuthorizationCode.
+type MockAuthorizationCodeMockRecorder struct {
+	mock *MockAuthorizationCode
+}
+
+// NewMockAuthorizationCode creates a new mock instance.
+func NewMockAuthorizationCode(ctrl *gomock.Controller) *MockAuthorizationCode {
+	mock := &MockAuthorizationCode{ctrl: ctrl}
+	mock.recorder = &MockAuthorizationCodeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthorizationCode) EXPECT() *MockAuthorizationCodeMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockAuthorizationCode) Consume(ctx context.Context, codeHash string, now int64) (model.AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, codeHash, now)
+	ret0, _ := ret[0].(model.AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockAuthorizationCodeMockRecorder) Consume(ctx, codeHash, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockAuthorizationCode)(nil).Consume), ctx, codeHash, now)
+}
+
+// Create mocks base method.
+func (m *MockAuthorizationCode) Create(ctx context.Context, c model.AuthorizationCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAuthorizationCodeMockRecorder) Create(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAuthorizationCode)(nil).Create), ctx, c)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockAuthorizationCode) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockAuthorizationCodeMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockAuthorizationCode)(nil).DeleteExpired), ctx, now)
+}
+
+// MockSigningKey is a mock of SigningKey interface.
+type MockSigningKey struct {
+	ctrl     *gomock.Controller
+	recorder *MockSigningKeyMockRecorder
+}
+
+// MockSigningKeyMockRecorder is the mock recorder for MockSigningKey.
+type MockSigningKeyMockRecorder struct {
+	mock *MockSigningKey
+}
+
+// NewMockSigningKey creates a new mock instance.
+func NewMockSigningKey(ctrl *gomock.Controller) *MockSigningKey {
+	mock := &MockSigningKey{ctrl: ctrl}
+	mock.recorder = &MockSigningKeyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSigningKey) EXPECT() *MockSigningKeyMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockSigningKey) List(ctx context.Context) ([]model.SigningKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]model.SigningKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSigningKeyMockRecorder) List(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSigningKey)(nil).List), ctx)
+}
+
+// Update mocks base method.
+func (m *MockSigningKey) Update(ctx context.Context, fn func([]model.SigningKey) ([]model.SigningKey, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockSigningKeyMockRecorder) Update(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockSigningKey)(nil).Update), ctx, fn)
+}
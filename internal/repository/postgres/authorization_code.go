@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"medods/internal/model"
+	"strings"
+)
+
+type AuthorizationCode struct {
+	conn *sql.DB
+}
+
+func NewAuthorizationCodeRepository(conn *sql.DB) *AuthorizationCode {
+	return &AuthorizationCode{conn: conn}
+}
+
+func (r AuthorizationCode) Create(ctx context.Context, c model.AuthorizationCode) error {
+	query := `
+	insert into oauth_authorization_codes(
+		code_hash,
+		client_id,
+		user_id,
+		redirect_uri,
+		code_challenge,
+		scope,
+		amr,
+		nonce,
+		auth_time,
+		expires_at
+	) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := r.conn.ExecContext(ctx, query,
+		c.CodeHash,
+		c.ClientID,
+		c.UserID,
+		c.RedirectURI,
+		c.CodeChallenge,
+		c.Scope,
+		strings.Join(c.AMR, " "),
+		c.Nonce,
+		c.AuthTime,
+		c.ExpiresAt,
+	)
+	return err
+}
+
+// Consume mark code as used in one statement, so code can't be exchanged twice concurrently.
+// Returns sql.ErrNoRows if code is unknown, already used or expired.
+func (r AuthorizationCode) Consume(ctx context.Context, codeHash string, now int64) (c model.AuthorizationCode, err error) {
+	query := `
+	update oauth_authorization_codes set used_at = $2
+	where code_hash = $1 and used_at = 0 and expires_at > $2
+	returning code_hash, client_id, user_id, redirect_uri, code_challenge, scope, amr, nonce, auth_time, expires_at, used_at`
+
+	var amr string
+	err = r.conn.QueryRowContext(ctx, query, codeHash, now).Scan(
+		&c.CodeHash,
+		&c.ClientID,
+		&c.UserID,
+		&c.RedirectURI,
+		&c.CodeChallenge,
+		&c.Scope,
+		&amr,
+		&c.Nonce,
+		&c.AuthTime,
+		&c.ExpiresAt,
+		&c.UsedAt,
+	)
+	c.AMR = strings.Fields(amr)
+	return c, err
+}
+
+// DeleteExpired remove codes which can't be exchanged anymore, used ones included, returns count of deleted rows
+func (r AuthorizationCode) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	query := `delete from oauth_authorization_codes where expires_at <= $1`
+	res, err := r.conn.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
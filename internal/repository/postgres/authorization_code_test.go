@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationCodeCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	authorizationCode := NewAuthorizationCodeRepository(db)
+
+	defaultCode := model.AuthorizationCode{
+		CodeHash:      "1",
+		ClientID:      "2",
+		UserID:        3,
+		RedirectURI:   "4",
+		CodeChallenge: "5",
+		Scope:         "6",
+		AMR:           []string{"pwd", "otp", "mfa"},
+		Nonce:         "7",
+		AuthTime:      8,
+		ExpiresAt:     9,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.AuthorizationCode
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultCode,
+			buildStubs: func() {
+				df := defaultCode
+				mock.ExpectExec("insert into oauth_authorization_codes").
+					WithArgs(df.CodeHash, df.ClientID, df.UserID, df.RedirectURI, df.CodeChallenge, df.Scope, "pwd otp mfa", df.Nonce, df.AuthTime, df.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultCode,
+			buildStubs: func() {
+				df := defaultCode
+				mock.ExpectExec("insert into oauth_authorization_codes").
+					WithArgs(df.CodeHash, df.ClientID, df.UserID, df.RedirectURI, df.CodeChallenge, df.Scope, "pwd otp mfa", df.Nonce, df.AuthTime, df.ExpiresAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := authorizationCode.Create(context.Background(), test.input)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestAuthorizationCodeConsume(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	authorizationCode := NewAuthorizationCodeRepository(db)
+
+	defaultCode := model.AuthorizationCode{
+		CodeHash:      "1",
+		ClientID:      "2",
+		UserID:        3,
+		RedirectURI:   "4",
+		CodeChallenge: "5",
+		Scope:         "6",
+		AMR:           []string{"pwd", "otp", "mfa"},
+		Nonce:         "7",
+		AuthTime:      8,
+		ExpiresAt:     9,
+		UsedAt:        10,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.AuthorizationCode, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				df := defaultCode
+				mock.ExpectQuery("update oauth_authorization_codes set used_at").
+					WithArgs(df.CodeHash, df.UsedAt).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"code_hash",
+						"client_id",
+						"user_id",
+						"redirect_uri",
+						"code_challenge",
+						"scope",
+						"amr",
+						"nonce",
+						"auth_time",
+						"expires_at",
+						"used_at",
+					}).AddRow(
+						df.CodeHash,
+						df.ClientID,
+						df.UserID,
+						df.RedirectURI,
+						df.CodeChallenge,
+						df.Scope,
+						"pwd otp mfa",
+						df.Nonce,
+						df.AuthTime,
+						df.ExpiresAt,
+						df.UsedAt,
+					))
+			},
+			checkResult: func(t *testing.T, db model.AuthorizationCode, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultCode, db)
+			},
+		},
+		{
+			name: "error used, expired or not found",
+			buildStubs: func() {
+				df := defaultCode
+				mock.ExpectQuery("update oauth_authorization_codes set used_at").
+					WithArgs(df.CodeHash, df.UsedAt).
+					WillReturnError(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, db model.AuthorizationCode, err error) {
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				df := defaultCode
+				mock.ExpectQuery("update oauth_authorization_codes set used_at").
+					WithArgs(df.CodeHash, df.UsedAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.AuthorizationCode, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			code, err := authorizationCode.Consume(context.Background(), defaultCode.CodeHash, defaultCode.UsedAt)
+			test.checkResult(t, code, err)
+		})
+	}
+}
+
+func TestAuthorizationCodeDeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	authorizationCode := NewAuthorizationCodeRepository(db)
+
+	var now int64 = 1
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, deleted int64, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("delete from oauth_authorization_codes").
+					WithArgs(now).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), deleted)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("delete from oauth_authorization_codes").
+					WithArgs(now).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			deleted, err := authorizationCode.DeleteExpired(context.Background(), now)
+			test.checkResult(t, deleted, err)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"medods/internal/model"
+)
+
+type OneTimeToken struct {
+	conn *sql.DB
+}
+
+func NewOneTimeTokenRepository(conn *sql.DB) *OneTimeToken {
+	return &OneTimeToken{conn: conn}
+}
+
+func (r OneTimeToken) Create(ctx context.Context, t model.OneTimeToken) error {
+	query := `
+	insert into one_time_tokens(
+		token_hash,
+		purpose,
+		user_id,
+		expires_at
+	) values($1, $2, $3, $4)`
+
+	_, err := r.conn.ExecContext(ctx, query, t.TokenHash, t.Purpose, t.UserID, t.ExpiresAt)
+	return err
+}
+
+// Consume mark token as used in one statement, so token can't be used twice concurrently.
+// Returns sql.ErrNoRows if token is unknown, already used or expired.
+func (r OneTimeToken) Consume(ctx context.Context, tokenHash, purpose string, now int64) (t model.OneTimeToken, err error) {
+	query := `
+	update one_time_tokens set used_at = $3
+	where token_hash = $1 and purpose = $2 and used_at = 0 and expires_at > $3
+	returning token_hash, purpose, user_id, expires_at, used_at`
+
+	err = r.conn.QueryRowContext(ctx, query, tokenHash, purpose, now).Scan(
+		&t.TokenHash,
+		&t.Purpose,
+		&t.UserID,
+		&t.ExpiresAt,
+		&t.UsedAt,
+	)
+	return t, err
+}
+
+// DeleteExpired remove tokens which can't be consumed anymore, used ones included, returns count of deleted rows
+func (r OneTimeToken) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	query := `delete from one_time_tokens where expires_at <= $1`
+	res, err := r.conn.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneTimeTokenCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	oneTimeToken := NewOneTimeTokenRepository(db)
+
+	defaultToken := model.OneTimeToken{
+		TokenHash: "1",
+		Purpose:   "2",
+		UserID:    3,
+		ExpiresAt: 4,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.OneTimeToken
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultToken,
+			buildStubs: func() {
+				df := defaultToken
+				mock.ExpectExec("insert into one_time_tokens").
+					WithArgs(df.TokenHash, df.Purpose, df.UserID, df.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultToken,
+			buildStubs: func() {
+				df := defaultToken
+				mock.ExpectExec("insert into one_time_tokens").
+					WithArgs(df.TokenHash, df.Purpose, df.UserID, df.ExpiresAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := oneTimeToken.Create(context.Background(), test.input)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestOneTimeTokenConsume(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	oneTimeToken := NewOneTimeTokenRepository(db)
+
+	defaultToken := model.OneTimeToken{
+		TokenHash: "1",
+		Purpose:   "2",
+		UserID:    3,
+		ExpiresAt: 4,
+		UsedAt:    5,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.OneTimeToken, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				df := defaultToken
+				mock.ExpectQuery("update one_time_tokens set used_at").
+					WithArgs(df.TokenHash, df.Purpose, df.UsedAt).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"token_hash",
+						"purpose",
+						"user_id",
+						"expires_at",
+						"used_at",
+					}).AddRow(
+						df.TokenHash,
+						df.Purpose,
+						df.UserID,
+						df.ExpiresAt,
+						df.UsedAt,
+					))
+			},
+			checkResult: func(t *testing.T, db model.OneTimeToken, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultToken, db)
+			},
+		},
+		{
+			name: "error used, expired or not found",
+			buildStubs: func() {
+				df := defaultToken
+				mock.ExpectQuery("update one_time_tokens set used_at").
+					WithArgs(df.TokenHash, df.Purpose, df.UsedAt).
+					WillReturnError(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, db model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				df := defaultToken
+				mock.ExpectQuery("update one_time_tokens set used_at").
+					WithArgs(df.TokenHash, df.Purpose, df.UsedAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.OneTimeToken, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			token, err := oneTimeToken.Consume(context.Background(), defaultToken.TokenHash, defaultToken.Purpose, defaultToken.UsedAt)
+			test.checkResult(t, token, err)
+		})
+	}
+}
+
+func TestOneTimeTokenDeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	oneTimeToken := NewOneTimeTokenRepository(db)
+
+	var now int64 = 1
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, deleted int64, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("delete from one_time_tokens").
+					WithArgs(now).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), deleted)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("delete from one_time_tokens").
+					WithArgs(now).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			deleted, err := oneTimeToken.DeleteExpired(context.Background(), now)
+			test.checkResult(t, deleted, err)
+		})
+	}
+}
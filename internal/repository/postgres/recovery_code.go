@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RecoveryCode struct {
+	conn *sql.DB
+}
+
+func NewRecoveryCodeRepository(conn *sql.DB) *RecoveryCode {
+	return &RecoveryCode{conn: conn}
+}
+
+// Replace delete all codes of user and save new ones in one transaction, so old codes stop working
+func (r RecoveryCode) Replace(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `delete from recovery_codes where user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	insertQuery := `
+	insert into recovery_codes(
+		user_id,
+		code_hash
+	) values($1, $2)`
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, insertQuery, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Use mark code as used in one statement, so code can't be used twice concurrently.
+// Returns sql.ErrNoRows if code is unknown or already used.
+func (r RecoveryCode) Use(ctx context.Context, userID int, codeHash string, usedAt int64) error {
+	query := `
+	update recovery_codes set used_at = $3
+	where id = (
+		select id from recovery_codes
+		where user_id = $1 and code_hash = $2 and used_at = 0
+		limit 1
+	) and used_at = 0`
+	res, err := r.conn.ExecContext(ctx, query, userID, codeHash, usedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryCodeReplace(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	recoveryCode := NewRecoveryCodeRepository(db)
+
+	defaultHashes := []string{"1", "2"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("delete from recovery_codes").
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				for _, hash := range defaultHashes {
+					mock.ExpectExec("insert into recovery_codes").
+						WithArgs(1, hash).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+				}
+				mock.ExpectCommit()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "delete error",
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("delete from recovery_codes").
+					WithArgs(1).
+					WillReturnError(unexpectedError)
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "insert error",
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("delete from recovery_codes").
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("insert into recovery_codes").
+					WithArgs(1, defaultHashes[0]).
+					WillReturnError(unexpectedError)
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := recoveryCode.Replace(context.Background(), 1, defaultHashes)
+			test.checkResult(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRecoveryCodeUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	recoveryCode := NewRecoveryCodeRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update recovery_codes set used_at").
+					WithArgs(1, "2", int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unknown or used",
+			buildStubs: func() {
+				mock.ExpectExec("update recovery_codes set used_at").
+					WithArgs(1, "2", int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update recovery_codes set used_at").
+					WithArgs(1, "2", int64(3)).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := recoveryCode.Use(context.Background(), 1, "2", 3)
+			test.checkResult(t, err)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"medods/internal/model"
+)
+
+type RevokedToken struct {
+	conn *sql.DB
+}
+
+func NewRevokedTokenRepository(conn *sql.DB) *RevokedToken {
+	return &RevokedToken{conn: conn}
+}
+
+func (r RevokedToken) Create(ctx context.Context, t model.RevokedToken) error {
+	query := `
+	insert into revoked_tokens(
+		jti,
+		expires_at
+	) values($1, $2)
+	on conflict (jti) do nothing`
+
+	_, err := r.conn.ExecContext(ctx, query, t.JTI, t.ExpiresAt)
+	return err
+}
+
+func (r RevokedToken) GetByJTI(ctx context.Context, jti string) (t model.RevokedToken, err error) {
+	query := `select jti, expires_at from revoked_tokens where jti = $1`
+	err = r.conn.QueryRowContext(ctx, query, jti).Scan(
+		&t.JTI,
+		&t.ExpiresAt,
+	)
+	return t, err
+}
+
+// DeleteExpired remove tokens which are expired anyway, returns count of deleted rows
+func (r RevokedToken) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	query := `delete from revoked_tokens where expires_at <= $1`
+	res, err := r.conn.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
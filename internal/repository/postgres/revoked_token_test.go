@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokedTokenCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	revokedToken := NewRevokedTokenRepository(db)
+
+	defaultToken := model.RevokedToken{
+		JTI:       "1",
+		ExpiresAt: 2,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.RevokedToken
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultToken,
+			buildStubs: func() {
+				mock.ExpectExec("insert into revoked_tokens").
+					WithArgs(defaultToken.JTI, defaultToken.ExpiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultToken,
+			buildStubs: func() {
+				mock.ExpectExec("insert into revoked_tokens").
+					WithArgs(defaultToken.JTI, defaultToken.ExpiresAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := revokedToken.Create(context.Background(), test.input)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestRevokedTokenGetByJTI(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	revokedToken := NewRevokedTokenRepository(db)
+
+	defaultToken := model.RevokedToken{
+		JTI:       "1",
+		ExpiresAt: 2,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       string
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.RevokedToken, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultToken.JTI,
+			buildStubs: func() {
+				mock.ExpectQuery("select (.+) from revoked_tokens").
+					WithArgs(defaultToken.JTI).
+					WillReturnRows(sqlmock.NewRows([]string{"jti", "expires_at"}).
+						AddRow(defaultToken.JTI, defaultToken.ExpiresAt))
+			},
+			checkResult: func(t *testing.T, db model.RevokedToken, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultToken, db)
+			},
+		},
+		{
+			name:  "not found",
+			input: defaultToken.JTI,
+			buildStubs: func() {
+				mock.ExpectQuery("select (.+) from revoked_tokens").
+					WithArgs(defaultToken.JTI).
+					WillReturnError(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, db model.RevokedToken, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultToken.JTI,
+			buildStubs: func() {
+				mock.ExpectQuery("select (.+) from revoked_tokens").
+					WithArgs(defaultToken.JTI).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.RevokedToken, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			token, err := revokedToken.GetByJTI(context.Background(), test.input)
+			test.checkResult(t, token, err)
+		})
+	}
+}
+
+func TestRevokedTokenDeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	revokedToken := NewRevokedTokenRepository(db)
+
+	var now int64 = 1
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, deleted int64, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("delete from revoked_tokens").
+					WithArgs(now).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), deleted)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("delete from revoked_tokens").
+					WithArgs(now).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, deleted int64, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			deleted, err := revokedToken.DeleteExpired(context.Background(), now)
+			test.checkResult(t, deleted, err)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"medods/internal/model"
+	"strings"
 )
 
 type Session struct {
@@ -16,22 +17,94 @@ func NewSessionRepository(conn *sql.DB) *Session {
 	}
 }
 
-func (r Session) Create(ctx context.Context, session model.Session) error {
+// sessionColumns are returned by every query of session, in order of scanSession
+const sessionColumns = `
+		id,
+		user_id,
+		access_token_id,
+		refresh_token_selector,
+		refresh_token_hash,
+		created_at,
+		revoked_at,
+		version,
+		ip,
+		amr,
+		client_id,
+		scope,
+		auth_time,
+		country,
+		city,
+		asn,
+		as_org`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row scanner) (model.Session, error) {
+	var s model.Session
+	var amr string
+	if err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.ATokenID,
+		&s.RTokenSelector,
+		&s.RTokenHash,
+		&s.CreatedAt,
+		&s.RevokedAt,
+		&s.Version,
+		&s.IP,
+		&amr,
+		&s.ClientID,
+		&s.Scope,
+		&s.AuthTime,
+		&s.Country,
+		&s.City,
+		&s.ASN,
+		&s.ASOrg,
+	); err != nil {
+		return model.Session{}, err
+	}
+	s.AMR = strings.Fields(amr)
+	return s, nil
+}
+
+func (r Session) Create(ctx context.Context, session model.Session) (model.Session, error) {
 	query := `
 	insert into sessions(
 		user_id,
 		access_token_id,
+		refresh_token_selector,
 		refresh_token_hash,
-		created_at
-	) values($1, $2, $3, $4)`
+		created_at,
+		ip,
+		amr,
+		client_id,
+		scope,
+		auth_time,
+		country,
+		city,
+		asn,
+		as_org
+	) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	returning` + sessionColumns
 
-	_, err := r.conn.ExecContext(ctx, query,
+	return scanSession(r.conn.QueryRowContext(ctx, query,
 		session.UserID,
 		session.ATokenID,
+		session.RTokenSelector,
 		session.RTokenHash,
 		session.CreatedAt,
-	)
-	return err
+		session.IP,
+		strings.Join(session.AMR, " "),
+		session.ClientID,
+		session.Scope,
+		session.AuthTime,
+		session.Country,
+		session.City,
+		session.ASN,
+		session.ASOrg,
+	))
 }
 
 func (r Session) Update(ctx context.Context, session model.Session) (model.Session, error) {
@@ -39,80 +112,236 @@ func (r Session) Update(ctx context.Context, session model.Session) (model.Sessi
 	update sessions set
 		user_id = $3,
 		access_token_id = $4,
-		refresh_token_hash = $5,
-		created_at = $6,
+		refresh_token_selector = $5,
+		refresh_token_hash = $6,
+		created_at = $7,
 		version = version + 1
 	where id = $1 and version = $2
-	returning
-		id, 
-		user_id, 
-		access_token_id, 
-		refresh_token_hash, 
-		created_at,
-		version`
+	returning` + sessionColumns
 
-	var res model.Session
-	err := r.conn.QueryRowContext(ctx, query,
+	return scanSession(r.conn.QueryRowContext(ctx, query,
 		session.ID,
 		session.Version,
 		session.UserID,
 		session.ATokenID,
+		session.RTokenSelector,
 		session.RTokenHash,
 		session.CreatedAt,
+	))
+}
+
+// Rotate save current tokens of session to session_rotations and update session with new ones.
+// Session is found by id and version, so sql.ErrNoRows means that session was changed by someone else.
+func (r Session) Rotate(ctx context.Context, session model.Session) (model.Session, error) {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Session{}, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	selectQuery := `
+	select
+		access_token_id,
+		refresh_token_selector,
+		refresh_token_hash
+	from sessions
+	where id = $1 and version = $2 and revoked_at = 0
+	for update`
+
+	var prev model.SessionRotation
+	if err := tx.QueryRowContext(ctx, selectQuery,
+		session.ID,
+		session.Version,
 	).Scan(
+		&prev.ATokenID,
+		&prev.RTokenSelector,
+		&prev.RTokenHash,
+	); err != nil {
+		return model.Session{}, err
+	}
+
+	insertQuery := `
+	insert into session_rotations(
+		session_id,
+		access_token_id,
+		refresh_token_selector,
+		refresh_token_hash,
+		rotated_at
+	) values($1, $2, $3, $4, $5)`
+
+	if _, err := tx.ExecContext(ctx, insertQuery,
+		session.ID,
+		prev.ATokenID,
+		prev.RTokenSelector,
+		prev.RTokenHash,
+		session.CreatedAt,
+	); err != nil {
+		return model.Session{}, err
+	}
+
+	// ip and grant are saved again, sessions opened before selectors get them on first refresh,
+	// geo is resolved again, so sessions opened before geoip database get it too
+	updateQuery := `
+	update sessions set
+		access_token_id = $2,
+		refresh_token_selector = $3,
+		refresh_token_hash = $4,
+		created_at = $5,
+		ip = $6,
+		amr = $7,
+		client_id = $8,
+		scope = $9,
+		auth_time = $10,
+		country = $11,
+		city = $12,
+		asn = $13,
+		as_org = $14,
+		version = version + 1
+	where id = $1
+	returning` + sessionColumns
+
+	res, err := scanSession(tx.QueryRowContext(ctx, updateQuery,
+		session.ID,
+		session.ATokenID,
+		session.RTokenSelector,
+		session.RTokenHash,
+		session.CreatedAt,
+		session.IP,
+		strings.Join(session.AMR, " "),
+		session.ClientID,
+		session.Scope,
+		session.AuthTime,
+		session.Country,
+		session.City,
+		session.ASN,
+		session.ASOrg,
+	))
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.Session{}, err
+	}
+	return res, nil
+}
+
+func (r Session) GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error) {
+	query := `
+	select
+		id,
+		session_id,
+		access_token_id,
+		refresh_token_selector,
+		refresh_token_hash,
+		rotated_at
+	from session_rotations
+	where session_id = $1 and access_token_id = $2`
+
+	var res model.SessionRotation
+	err := r.conn.QueryRowContext(ctx, query, sessionID, aTokenID).Scan(
 		&res.ID,
-		&res.UserID,
+		&res.SessionID,
 		&res.ATokenID,
+		&res.RTokenSelector,
 		&res.RTokenHash,
-		&res.CreatedAt,
-		&res.Version,
+		&res.RotatedAt,
 	)
 	if err != nil {
-		return model.Session{}, err
+		return model.SessionRotation{}, err
 	}
 	return res, nil
 }
 
-func (r Session) GetByUserID(ctx context.Context, id int) (model.Session, error) {
+// GetRotationBySelector find rotated refresh token, selector is unique as it's random
+func (r Session) GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error) {
 	query := `
 	select
 		id,
-		user_id,
+		session_id,
 		access_token_id,
+		refresh_token_selector,
 		refresh_token_hash,
-		created_at,
-		version
-	from sessions
-	where user_id = $1`
+		rotated_at
+	from session_rotations
+	where refresh_token_selector = $1`
 
-	var res model.Session
-	err := r.conn.QueryRowContext(ctx, query, id).Scan(
+	var res model.SessionRotation
+	err := r.conn.QueryRowContext(ctx, query, selector).Scan(
 		&res.ID,
-		&res.UserID,
+		&res.SessionID,
 		&res.ATokenID,
+		&res.RTokenSelector,
 		&res.RTokenHash,
-		&res.CreatedAt,
-		&res.Version,
+		&res.RotatedAt,
 	)
 	if err != nil {
-		return model.Session{}, err
+		return model.SessionRotation{}, err
 	}
 	return res, nil
+}
+
+func (r Session) Revoke(ctx context.Context, id int, revokedAt int64) error {
+	query := `
+	update sessions set
+		revoked_at = $2,
+		version = version + 1
+	where id = $1 and revoked_at = 0`
 
+	_, err := r.conn.ExecContext(ctx, query, id, revokedAt)
+	return err
+}
+
+func (r Session) RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error {
+	query := `
+	update sessions set
+		revoked_at = $2,
+		version = version + 1
+	where user_id = $1 and revoked_at = 0`
+
+	_, err := r.conn.ExecContext(ctx, query, userID, revokedAt)
+	return err
+}
+
+func (r Session) GetByID(ctx context.Context, id int) (model.Session, error) {
+	query := `
+	select` + sessionColumns + `
+	from sessions
+	where id = $1`
+
+	return scanSession(r.conn.QueryRowContext(ctx, query, id))
+}
+
+// GetBySelector find session by public part of its current refresh token
+func (r Session) GetBySelector(ctx context.Context, selector string) (model.Session, error) {
+	query := `
+	select` + sessionColumns + `
+	from sessions
+	where refresh_token_selector = $1`
+
+	return scanSession(r.conn.QueryRowContext(ctx, query, selector))
+}
+
+func (r Session) ListByUserID(ctx context.Context, userID int) ([]model.Session, error) {
+	query := `
+	select` + sessionColumns + `
+	from sessions
+	where user_id = $1
+	order by id`
+
+	return r.list(ctx, query, userID)
 }
 
 func (r Session) List(ctx context.Context) ([]model.Session, error) {
 	query := `
-	select
-		id,
-		user_id,
-		access_token_id,
-		refresh_token_hash,
-		created_at,
-		version
+	select` + sessionColumns + `
 	from sessions`
 
-	rows, err := r.conn.QueryContext(ctx, query)
+	return r.list(ctx, query)
+}
+
+func (r Session) list(ctx context.Context, query string, args ...interface{}) ([]model.Session, error) {
+	rows, err := r.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -120,15 +349,8 @@ func (r Session) List(ctx context.Context) ([]model.Session, error) {
 
 	var sessions []model.Session
 	for rows.Next() {
-		var s model.Session
-		if err := rows.Scan(
-			&s.ID,
-			&s.UserID,
-			&s.ATokenID,
-			&s.RTokenHash,
-			&s.CreatedAt,
-			&s.Version,
-		); err != nil {
+		s, err := scanSession(rows)
+		if err != nil {
 			return nil, err
 		}
 
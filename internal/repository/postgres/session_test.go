@@ -2,9 +2,11 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
 	"medods/internal/model"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -20,12 +22,19 @@ func TestSessionCreate(t *testing.T) {
 	session := NewSessionRepository(db)
 
 	defaultSession := model.Session{
-		ID:         1,
-		UserID:     2,
-		ATokenID:   "3",
-		RTokenHash: "4",
-		CreatedAt:  5,
-		Version:    6,
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -34,22 +43,69 @@ func TestSessionCreate(t *testing.T) {
 		name        string
 		input       model.Session
 		buildStubs  func()
-		checkResult func(t *testing.T, err error)
+		checkResult func(t *testing.T, in, db model.Session, err error)
 	}{
 		{
 			name:  "OK",
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				mock.ExpectExec("insert into sessions").WithArgs(
+				mock.ExpectQuery("insert into sessions").WithArgs(
+					df.UserID,
+					df.ATokenID,
+					df.RTokenSelector,
+					df.RTokenHash,
+					df.CreatedAt,
+					df.IP,
+					strings.Join(df.AMR, " "),
+					df.ClientID,
+					df.Scope,
+					df.AuthTime,
+					df.Country,
+					df.City,
+					df.ASN,
+					df.ASOrg,
+				).WillReturnRows(sqlmock.NewRows([]string{
+					"id",
+					"user_id",
+					"access_token_id",
+					"refresh_token_selector",
+					"refresh_token_hash",
+					"created_at",
+					"revoked_at",
+					"version",
+					"ip",
+					"amr",
+					"client_id",
+					"scope",
+					"auth_time",
+					"country",
+					"city",
+					"asn",
+					"as_org",
+				}).AddRow(
+					df.ID,
 					df.UserID,
 					df.ATokenID,
+					df.RTokenSelector,
 					df.RTokenHash,
 					df.CreatedAt,
-				).WillReturnResult(sqlmock.NewResult(1, 1))
+					df.RevokedAt,
+					df.Version,
+					df.IP,
+					strings.Join(df.AMR, " "),
+					df.ClientID,
+					df.Scope,
+					df.AuthTime,
+					df.Country,
+					df.City,
+					df.ASN,
+					df.ASOrg,
+				))
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
 				assert.NoError(t, err)
+				assert.Equal(t, in, db)
 			},
 		},
 		{
@@ -57,14 +113,24 @@ func TestSessionCreate(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				mock.ExpectExec("insert into sessions").WithArgs(
+				mock.ExpectQuery("insert into sessions").WithArgs(
 					df.UserID,
 					df.ATokenID,
+					df.RTokenSelector,
 					df.RTokenHash,
 					df.CreatedAt,
+					df.IP,
+					strings.Join(df.AMR, " "),
+					df.ClientID,
+					df.Scope,
+					df.AuthTime,
+					df.Country,
+					df.City,
+					df.ASN,
+					df.ASOrg,
 				).WillReturnError(unexpectedError)
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
 				assert.Error(t, err)
 				assert.Equal(t, err, unexpectedError)
 			},
@@ -74,8 +140,8 @@ func TestSessionCreate(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			err := session.Create(context.Background(), test.input)
-			test.checkResult(t, err)
+			session, err := session.Create(context.Background(), test.input)
+			test.checkResult(t, test.input, session, err)
 		})
 	}
 }
@@ -89,12 +155,19 @@ func TestSessionUpdate(t *testing.T) {
 	session := NewSessionRepository(db)
 
 	defaultSession := model.Session{
-		ID:         1,
-		UserID:     2,
-		ATokenID:   "3",
-		RTokenHash: "4",
-		CreatedAt:  5,
-		Version:    6,
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -115,22 +188,45 @@ func TestSessionUpdate(t *testing.T) {
 					df.Version,
 					df.UserID,
 					df.ATokenID,
+					df.RTokenSelector,
 					df.RTokenHash,
 					df.CreatedAt,
 				).WillReturnRows(sqlmock.NewRows([]string{
 					"id",
 					"user_id",
 					"access_token_id",
+					"refresh_token_selector",
 					"refresh_token_hash",
 					"created_at",
+					"revoked_at",
 					"version",
+					"ip",
+					"amr",
+					"client_id",
+					"scope",
+					"auth_time",
+					"country",
+					"city",
+					"asn",
+					"as_org",
 				}).AddRow(
 					df.ID,
 					df.UserID,
 					df.ATokenID,
+					df.RTokenSelector,
 					df.RTokenHash,
 					df.CreatedAt,
+					df.RevokedAt,
 					df.Version+1,
+					df.IP,
+					strings.Join(df.AMR, " "),
+					df.ClientID,
+					df.Scope,
+					df.AuthTime,
+					df.Country,
+					df.City,
+					df.ASN,
+					df.ASOrg,
 				))
 
 			},
@@ -150,6 +246,7 @@ func TestSessionUpdate(t *testing.T) {
 					df.Version,
 					df.UserID,
 					df.ATokenID,
+					df.RTokenSelector,
 					df.RTokenHash,
 					df.CreatedAt,
 				).WillReturnError(unexpectedError)
@@ -170,7 +267,443 @@ func TestSessionUpdate(t *testing.T) {
 	}
 }
 
-func TestSessionGetByUserID(t *testing.T) {
+func TestSessionRotate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
+	}
+
+	prevATokenID := "old_3"
+	prevRTokenSelector := "old_7"
+	prevRTokenHash := "old_4"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectBegin()
+				mock.ExpectQuery("select access_token_id, refresh_token_selector, refresh_token_hash from sessions").
+					WithArgs(df.ID, df.Version).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+					}).AddRow(prevATokenID, prevRTokenSelector, prevRTokenHash))
+				mock.ExpectExec("insert into session_rotations").
+					WithArgs(df.ID, prevATokenID, prevRTokenSelector, prevRTokenHash, df.CreatedAt).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("update sessions").
+					WithArgs(df.ID, df.ATokenID, df.RTokenSelector, df.RTokenHash, df.CreatedAt,
+						df.IP, strings.Join(df.AMR, " "), df.ClientID, df.Scope, df.AuthTime,
+						df.Country, df.City, df.ASN, df.ASOrg).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+						"created_at",
+						"revoked_at",
+						"version",
+						"ip",
+						"amr",
+						"client_id",
+						"scope",
+						"auth_time",
+						"country",
+						"city",
+						"asn",
+						"as_org",
+					}).AddRow(
+						df.ID,
+						df.UserID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.CreatedAt,
+						df.RevokedAt,
+						df.Version+1,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
+					))
+				mock.ExpectCommit()
+			},
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
+				assert.NoError(t, err)
+				in.Version += 1
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "error session changed",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectBegin()
+				mock.ExpectQuery("select access_token_id, refresh_token_selector, refresh_token_hash from sessions").
+					WithArgs(df.ID, df.Version).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+				assert.Equal(t, model.Session{}, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectBegin()
+				mock.ExpectQuery("select access_token_id, refresh_token_selector, refresh_token_hash from sessions").
+					WithArgs(df.ID, df.Version).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+					}).AddRow(prevATokenID, prevRTokenSelector, prevRTokenHash))
+				mock.ExpectExec("insert into session_rotations").
+					WithArgs(df.ID, prevATokenID, prevRTokenSelector, prevRTokenHash, df.CreatedAt).
+					WillReturnError(unexpectedError)
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.Session{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			session, err := session.Rotate(context.Background(), test.input)
+			test.checkResult(t, test.input, session, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSessionGetRotation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	defaultRotation := model.SessionRotation{
+		ID:             1,
+		SessionID:      2,
+		ATokenID:       "3",
+		RTokenSelector: "6",
+		RTokenHash:     "4",
+		RotatedAt:      5,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.SessionRotation
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.SessionRotation, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				mock.ExpectQuery("select id, session_id, access_token_id, refresh_token_selector, refresh_token_hash, rotated_at from session_rotations").
+					WithArgs(df.SessionID, df.ATokenID).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"session_id",
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+						"rotated_at",
+					}).AddRow(
+						df.ID,
+						df.SessionID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.RotatedAt,
+					))
+			},
+			checkResult: func(t *testing.T, in, db model.SessionRotation, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				mock.ExpectQuery("select id, session_id, access_token_id, refresh_token_selector, refresh_token_hash, rotated_at from session_rotations").
+					WithArgs(df.SessionID, df.ATokenID).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.SessionRotation, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.SessionRotation{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			rotation, err := session.GetRotation(context.Background(), test.input.SessionID, test.input.ATokenID)
+			test.checkResult(t, test.input, rotation, err)
+		})
+	}
+}
+
+func TestSessionGetRotationBySelector(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	defaultRotation := model.SessionRotation{
+		ID:             1,
+		SessionID:      2,
+		ATokenID:       "3",
+		RTokenSelector: "6",
+		RTokenHash:     "4",
+		RotatedAt:      5,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.SessionRotation
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.SessionRotation, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				mock.ExpectQuery("select id, session_id, access_token_id, refresh_token_selector, refresh_token_hash, rotated_at from session_rotations").
+					WithArgs(df.RTokenSelector).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"session_id",
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+						"rotated_at",
+					}).AddRow(
+						df.ID,
+						df.SessionID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.RotatedAt,
+					))
+			},
+			checkResult: func(t *testing.T, in, db model.SessionRotation, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				mock.ExpectQuery("select id, session_id, access_token_id, refresh_token_selector, refresh_token_hash, rotated_at from session_rotations").
+					WithArgs(df.RTokenSelector).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.SessionRotation, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.SessionRotation{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			rotation, err := session.GetRotationBySelector(context.Background(), test.input.RTokenSelector)
+			test.checkResult(t, test.input, rotation, err)
+		})
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		id        int
+		revokedAt int64
+	}
+
+	defaultArgs := args{
+		id:        1,
+		revokedAt: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update sessions set revoked_at").
+					WithArgs(defaultArgs.id, defaultArgs.revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update sessions set revoked_at").
+					WithArgs(defaultArgs.id, defaultArgs.revokedAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := session.Revoke(context.Background(), test.input.id, test.input.revokedAt)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestSessionRevokeAllByUserID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		userID    int
+		revokedAt int64
+	}
+
+	defaultArgs := args{
+		userID:    1,
+		revokedAt: 2,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update sessions set revoked_at").
+					WithArgs(defaultArgs.userID, defaultArgs.revokedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update sessions set revoked_at").
+					WithArgs(defaultArgs.userID, defaultArgs.revokedAt).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := session.RevokeAllByUserID(context.Background(), test.input.userID, test.input.revokedAt)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestSessionGetByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("create db mock error: %s", err.Error())
@@ -179,12 +712,19 @@ func TestSessionGetByUserID(t *testing.T) {
 	session := NewSessionRepository(db)
 
 	defaultSession := model.Session{
-		ID:         1,
-		UserID:     2,
-		ATokenID:   "3",
-		RTokenHash: "4",
-		CreatedAt:  5,
-		Version:    6,
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -200,22 +740,44 @@ func TestSessionGetByUserID(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				mock.ExpectQuery("select id, user_id, access_token_id, refresh_token_hash, created_at, version from sessions").
+				mock.ExpectQuery("select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions").
 					WithArgs(df.ID).
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id",
 						"user_id",
 						"access_token_id",
+						"refresh_token_selector",
 						"refresh_token_hash",
 						"created_at",
+						"revoked_at",
 						"version",
+						"ip",
+						"amr",
+						"client_id",
+						"scope",
+						"auth_time",
+						"country",
+						"city",
+						"asn",
+						"as_org",
 					}).AddRow(
 						df.ID,
 						df.UserID,
 						df.ATokenID,
+						df.RTokenSelector,
 						df.RTokenHash,
 						df.CreatedAt,
+						df.RevokedAt,
 						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
 					))
 
 			},
@@ -229,7 +791,7 @@ func TestSessionGetByUserID(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				mock.ExpectQuery(`select id, user_id, access_token_id, refresh_token_hash, created_at, version from sessions`).
+				mock.ExpectQuery(`select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions`).
 					WithArgs(df.ID).
 					WillReturnError(unexpectedError)
 			},
@@ -243,12 +805,248 @@ func TestSessionGetByUserID(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			session, err := session.GetByUserID(context.Background(), test.input.ID)
+			session, err := session.GetByID(context.Background(), test.input.ID)
+			test.checkResult(t, test.input, session, err)
+		})
+	}
+}
+
+func TestSessionGetBySelector(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectQuery("select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions").
+					WithArgs(df.RTokenSelector).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+						"created_at",
+						"revoked_at",
+						"version",
+						"ip",
+						"amr",
+						"client_id",
+						"scope",
+						"auth_time",
+						"country",
+						"city",
+						"asn",
+						"as_org",
+					}).AddRow(
+						df.ID,
+						df.UserID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.CreatedAt,
+						df.RevokedAt,
+						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
+					))
+
+			},
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectQuery(`select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions`).
+					WithArgs(df.RTokenSelector).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			session, err := session.GetBySelector(context.Background(), test.input.RTokenSelector)
 			test.checkResult(t, test.input, session, err)
 		})
 	}
 }
 
+func TestSessionListByUserID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	session := NewSessionRepository(db)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, db []model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectQuery(`select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions`).
+					WithArgs(df.UserID).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"access_token_id",
+						"refresh_token_selector",
+						"refresh_token_hash",
+						"created_at",
+						"revoked_at",
+						"version",
+						"ip",
+						"amr",
+						"client_id",
+						"scope",
+						"auth_time",
+						"country",
+						"city",
+						"asn",
+						"as_org",
+					}).AddRows([]driver.Value{
+						df.ID,
+						df.UserID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.CreatedAt,
+						df.RevokedAt,
+						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
+					}, []driver.Value{
+						df.ID + 1,
+						df.UserID,
+						df.ATokenID,
+						df.RTokenSelector,
+						df.RTokenHash,
+						df.CreatedAt,
+						df.RevokedAt,
+						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
+					}))
+			},
+			checkResult: func(t *testing.T, db []model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, db, 2)
+				assert.Equal(t, 1, db[0].ID)
+				assert.Equal(t, 2, db[1].ID)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				mock.ExpectQuery(`select id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version, ip, amr, client_id, scope, auth_time, country, city, asn, as_org from sessions`).
+					WithArgs(df.UserID).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, db []model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Empty(t, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			sessions, err := session.ListByUserID(context.Background(), test.input.UserID)
+			test.checkResult(t, sessions, err)
+		})
+	}
+}
+
 func TestSessionList(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -258,12 +1056,19 @@ func TestSessionList(t *testing.T) {
 	session := NewSessionRepository(db)
 
 	defaultSession := model.Session{
-		ID:         1,
-		UserID:     2,
-		ATokenID:   "3",
-		RTokenHash: "4",
-		CreatedAt:  5,
-		Version:    6,
+		ID:             1,
+		UserID:         2,
+		ATokenID:       "3",
+		RTokenSelector: "7",
+		RTokenHash:     "4",
+		CreatedAt:      5,
+		Version:        6,
+		IP:             "8",
+		AMR:            []string{"pwd", "otp"},
+		ClientID:       "9",
+		Scope:          "openid",
+		AuthTime:       10,
+		Geo:            model.Geo{Country: "RU", City: "Moscow", ASN: 64500, ASOrg: "Example Telecom"},
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -280,31 +1085,65 @@ func TestSessionList(t *testing.T) {
 			buildStubs: func() {
 				df := defaultSession
 				mock.ExpectQuery(`
-					select 
-						id, user_id, access_token_id, refresh_token_hash, created_at, version 
+					select
+						id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version,
+						ip, amr, client_id, scope, auth_time, country, city, asn, as_org
 					from sessions`).
 					WithoutArgs().
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id",
 						"user_id",
 						"access_token_id",
+						"refresh_token_selector",
 						"refresh_token_hash",
 						"created_at",
+						"revoked_at",
 						"version",
+						"ip",
+						"amr",
+						"client_id",
+						"scope",
+						"auth_time",
+						"country",
+						"city",
+						"asn",
+						"as_org",
 					}).AddRows([]driver.Value{
 						df.ID,
 						df.UserID,
 						df.ATokenID,
+						df.RTokenSelector,
 						df.RTokenHash,
 						df.CreatedAt,
+						df.RevokedAt,
 						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
 					}, []driver.Value{
 						df.ID + 1,
 						df.UserID + 1,
 						df.ATokenID,
+						df.RTokenSelector,
 						df.RTokenHash,
 						df.CreatedAt,
+						df.RevokedAt,
 						df.Version,
+						df.IP,
+						strings.Join(df.AMR, " "),
+						df.ClientID,
+						df.Scope,
+						df.AuthTime,
+						df.Country,
+						df.City,
+						df.ASN,
+						df.ASOrg,
 					}))
 
 			},
@@ -319,8 +1158,9 @@ func TestSessionList(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				mock.ExpectQuery(`
-					select 
-						id, user_id, access_token_id, refresh_token_hash, created_at, version 
+					select
+						id, user_id, access_token_id, refresh_token_selector, refresh_token_hash, created_at, revoked_at, version,
+						ip, amr, client_id, scope, auth_time, country, city, asn, as_org
 					from sessions`).
 					WithoutArgs().
 					WillReturnError(unexpectedError)
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"medods/internal/model"
+)
+
+type SigningKey struct {
+	conn *sql.DB
+}
+
+func NewSigningKeyRepository(conn *sql.DB) *SigningKey {
+	return &SigningKey{conn: conn}
+}
+
+const listSigningKeysQuery = `
+	select
+		kid,
+		alg,
+		material,
+		state,
+		created_at,
+		promoted_at,
+		demoted_at
+	from signing_keys
+	order by created_at, kid`
+
+func (r SigningKey) List(ctx context.Context) ([]model.SigningKey, error) {
+	return listSigningKeys(ctx, r.conn)
+}
+
+// Update replace keys with result of fn in one transaction. Table is locked against other writers,
+// so instances rotating keyring at the same time run one by one and the second sees keys of the first.
+// Readers are not blocked and see either old or new keys.
+func (r SigningKey) Update(ctx context.Context, fn func(keys []model.SigningKey) ([]model.SigningKey, error)) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `lock table signing_keys in exclusive mode`); err != nil {
+		return err
+	}
+
+	keys, err := listSigningKeys(ctx, tx)
+	if err != nil {
+		return err
+	}
+	keys, err = fn(keys)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from signing_keys`); err != nil {
+		return err
+	}
+
+	insertQuery := `
+	insert into signing_keys(
+		kid,
+		alg,
+		material,
+		state,
+		created_at,
+		promoted_at,
+		demoted_at
+	) values($1, $2, $3, $4, $5, $6, $7)`
+
+	for _, k := range keys {
+		if _, err := tx.ExecContext(ctx, insertQuery,
+			k.ID,
+			k.Algorithm,
+			k.Material,
+			k.State,
+			k.CreatedAt,
+			k.PromotedAt,
+			k.DemotedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func listSigningKeys(ctx context.Context, q queryer) ([]model.SigningKey, error) {
+	rows, err := q.QueryContext(ctx, listSigningKeysQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.SigningKey
+	for rows.Next() {
+		var k model.SigningKey
+		if err := rows.Scan(
+			&k.ID,
+			&k.Algorithm,
+			&k.Material,
+			&k.State,
+			&k.CreatedAt,
+			&k.PromotedAt,
+			&k.DemotedAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
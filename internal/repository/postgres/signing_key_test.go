@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"medods/internal/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var signingKeyColumns = []string{"kid", "alg", "material", "state", "created_at", "promoted_at", "demoted_at"}
+
+func TestSigningKeyList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	signingKey := NewSigningKeyRepository(db)
+
+	mock.ExpectQuery("select (.+) from signing_keys").
+		WillReturnRows(sqlmock.NewRows(signingKeyColumns).
+			AddRow("1", "EdDSA", "sealed", "active", int64(2), int64(3), int64(0)))
+
+	keys, err := signingKey.List(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SigningKey{{
+		ID:         "1",
+		Algorithm:  "EdDSA",
+		Material:   "sealed",
+		State:      "active",
+		CreatedAt:  2,
+		PromotedAt: 3,
+	}}, keys)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSigningKeyUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	signingKey := NewSigningKeyRepository(db)
+
+	oldKey := model.SigningKey{ID: "1", Algorithm: "EdDSA", Material: "sealed", State: "active", CreatedAt: 2}
+	newKey := model.SigningKey{ID: "2", Algorithm: "EdDSA", Material: "sealed", State: "verify", CreatedAt: 4}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       func(keys []model.SigningKey) ([]model.SigningKey, error)
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			input: func(keys []model.SigningKey) ([]model.SigningKey, error) {
+				assert.Equal(t, []model.SigningKey{oldKey}, keys)
+				return append(keys, newKey), nil
+			},
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("lock table signing_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("select (.+) from signing_keys").
+					WillReturnRows(sqlmock.NewRows(signingKeyColumns).
+						AddRow("1", "EdDSA", "sealed", "active", int64(2), int64(0), int64(0)))
+				mock.ExpectExec("delete from signing_keys").WillReturnResult(sqlmock.NewResult(0, 1))
+				for _, k := range []model.SigningKey{oldKey, newKey} {
+					mock.ExpectExec("insert into signing_keys").
+						WithArgs(k.ID, k.Algorithm, k.Material, k.State, k.CreatedAt, k.PromotedAt, k.DemotedAt).
+						WillReturnResult(sqlmock.NewResult(1, 1))
+				}
+				mock.ExpectCommit()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error of fn",
+			input: func(keys []model.SigningKey) ([]model.SigningKey, error) {
+				return nil, unexpectedError
+			},
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("lock table signing_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("select (.+) from signing_keys").
+					WillReturnRows(sqlmock.NewRows(signingKeyColumns))
+				// note: keys are kept
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "lock error",
+			input: func(keys []model.SigningKey) ([]model.SigningKey, error) {
+				t.Fatal("fn must not be called")
+				return nil, nil
+			},
+			buildStubs: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("lock table signing_keys").WillReturnError(unexpectedError)
+				mock.ExpectRollback()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := signingKey.Update(context.Background(), test.input)
+			test.checkResult(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
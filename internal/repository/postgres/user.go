@@ -14,23 +14,140 @@ func NewUserRepository(conn *sql.DB) *User {
 	return &User{conn: conn}
 }
 
-func (r User) Create(ctx context.Context, u model.User) error {
-	query := `insert into users(email) values($1)`
-	_, err := r.conn.ExecContext(ctx, query, u.Email)
+func (r User) Create(ctx context.Context, u model.User) (dbUser model.User, err error) {
+	query := `
+	insert into users(
+		email,
+		password_hash
+	) values($1, $2)
+	returning id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at`
+
+	err = r.conn.QueryRowContext(ctx, query, u.Email, u.PasswordHash).Scan(
+		&dbUser.ID,
+		&dbUser.Email,
+		&dbUser.PasswordHash,
+		&dbUser.EmailVerifiedAt,
+		&dbUser.TOTPSecret,
+		&dbUser.TOTPEnabledAt,
+	)
+	return dbUser, err
+}
+
+func (r User) UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error {
+	query := `update users set password_hash = $2 where id = $1`
+	res, err := r.conn.ExecContext(ctx, query, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MarkEmailVerified set time of email verification, already verified email keeps the first time
+func (r User) MarkEmailVerified(ctx context.Context, id int, verifiedAt int64) error {
+	query := `update users set email_verified_at = $2 where id = $1 and email_verified_at = 0`
+	_, err := r.conn.ExecContext(ctx, query, id, verifiedAt)
 	return err
 }
 
+// UpdateTOTPSecret replace secret of pending enrollment, secret of enabled second factor is kept.
+// Returns sql.ErrNoRows if user not exists or second factor is already enabled.
+func (r User) UpdateTOTPSecret(ctx context.Context, id int, secret string) error {
+	query := `update users set totp_secret = $2, totp_last_step = 0 where id = $1 and totp_enabled_at = 0`
+	res, err := r.conn.ExecContext(ctx, query, id, secret)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// EnableTOTP confirm enrollment, step of confirmation code is saved as used.
+// Returns sql.ErrNoRows if user has no pending secret or second factor is already enabled.
+func (r User) EnableTOTP(ctx context.Context, id int, enabledAt, step int64) error {
+	query := `
+	update users set totp_enabled_at = $2, totp_last_step = $3
+	where id = $1 and totp_enabled_at = 0 and totp_secret != ''`
+	res, err := r.conn.ExecContext(ctx, query, id, enabledAt, step)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UseTOTPStep save step of accepted code in one statement, so the same code can't be used twice concurrently.
+// Returns sql.ErrNoRows if code of this or later step was already used.
+func (r User) UseTOTPStep(ctx context.Context, id int, step int64) error {
+	query := `update users set totp_last_step = $2 where id = $1 and totp_last_step < $2`
+	res, err := r.conn.ExecContext(ctx, query, id, step)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (r User) GetByID(ctx context.Context, id int) (u model.User, err error) {
-	query := `select id, email from users where id = $1`
+	query := `select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users where id = $1`
 	err = r.conn.QueryRowContext(ctx, query, id).Scan(
 		&u.ID,
 		&u.Email,
+		&u.PasswordHash,
+		&u.EmailVerifiedAt,
+		&u.TOTPSecret,
+		&u.TOTPEnabledAt,
+	)
+	return u, err
+}
+
+func (r User) GetByEmail(ctx context.Context, email string) (u model.User, err error) {
+	query := `select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users where email = $1`
+	err = r.conn.QueryRowContext(ctx, query, email).Scan(
+		&u.ID,
+		&u.Email,
+		&u.PasswordHash,
+		&u.EmailVerifiedAt,
+		&u.TOTPSecret,
+		&u.TOTPEnabledAt,
 	)
 	return u, err
 }
 
 func (r User) List(ctx context.Context) ([]model.User, error) {
-	query := `select id, email from users`
+	query := `select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users`
 	rows, err := r.conn.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -43,6 +160,10 @@ func (r User) List(ctx context.Context) ([]model.User, error) {
 		if err := rows.Scan(
 			&u.ID,
 			&u.Email,
+			&u.PasswordHash,
+			&u.EmailVerifiedAt,
+			&u.TOTPSecret,
+			&u.TOTPEnabledAt,
 		); err != nil {
 			return nil, err
 		}
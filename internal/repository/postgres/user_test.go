@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
 	"medods/internal/model"
@@ -20,8 +21,12 @@ func TestUserCreate(t *testing.T) {
 	userRepo := NewUserRepository(db)
 
 	defaultUser := model.User{
-		ID:    1,
-		Email: "2",
+		ID:              1,
+		Email:           "2",
+		PasswordHash:    "3",
+		EmailVerifiedAt: 4,
+		TOTPSecret:      "5",
+		TOTPEnabledAt:   6,
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -30,19 +35,34 @@ func TestUserCreate(t *testing.T) {
 		name        string
 		input       model.User
 		buildStubs  func()
-		checkResult func(t *testing.T, err error)
+		checkResult func(t *testing.T, in, db model.User, err error)
 	}{
 		{
 			name:  "OK",
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				mock.ExpectExec("insert into users").
-					WithArgs(df.Email).
-					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("insert into users").
+					WithArgs(df.Email, df.PasswordHash).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"email",
+						"password_hash",
+						"email_verified_at",
+						"totp_secret",
+						"totp_enabled_at",
+					}).AddRow(
+						df.ID,
+						df.Email,
+						df.PasswordHash,
+						df.EmailVerifiedAt,
+						df.TOTPSecret,
+						df.TOTPEnabledAt,
+					))
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, in, db model.User, err error) {
 				assert.NoError(t, err)
+				assert.Equal(t, in, db)
 			},
 		},
 		{
@@ -50,8 +70,83 @@ func TestUserCreate(t *testing.T) {
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				mock.ExpectExec("insert into users").
-					WithArgs(df.Email).
+				mock.ExpectQuery("insert into users").
+					WithArgs(df.Email, df.PasswordHash).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.User, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			user, err := userRepo.Create(context.Background(), test.input)
+			test.checkResult(t, test.input, user, err)
+		})
+	}
+}
+
+func TestUserUpdatePasswordHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	type args struct {
+		id           int
+		passwordHash string
+	}
+
+	defaultArgs := args{
+		id:           1,
+		passwordHash: "2",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update users set password_hash").
+					WithArgs(defaultArgs.id, defaultArgs.passwordHash).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "not found",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update users set password_hash").
+					WithArgs(defaultArgs.id, defaultArgs.passwordHash).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				mock.ExpectExec("update users set password_hash").
+					WithArgs(defaultArgs.id, defaultArgs.passwordHash).
 					WillReturnError(unexpectedError)
 			},
 			checkResult: func(t *testing.T, err error) {
@@ -64,7 +159,250 @@ func TestUserCreate(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			err := userRepo.Create(context.Background(), test.input)
+			err := userRepo.UpdatePasswordHash(context.Background(), test.input.id, test.input.passwordHash)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserMarkEmailVerified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update users set email_verified_at").
+					WithArgs(1, int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK already verified",
+			buildStubs: func() {
+				mock.ExpectExec("update users set email_verified_at").
+					WithArgs(1, int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update users set email_verified_at").
+					WithArgs(1, int64(2)).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := userRepo.MarkEmailVerified(context.Background(), 1, 2)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserUpdateTOTPSecret(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_secret").
+					WithArgs(1, "2").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "already enabled",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_secret").
+					WithArgs(1, "2").
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_secret").
+					WithArgs(1, "2").
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := userRepo.UpdateTOTPSecret(context.Background(), 1, "2")
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserEnableTOTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_enabled_at").
+					WithArgs(1, int64(2), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "not enrolled",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_enabled_at").
+					WithArgs(1, int64(2), int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_enabled_at").
+					WithArgs(1, int64(2), int64(3)).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := userRepo.EnableTOTP(context.Background(), 1, 2, 3)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserUseTOTPStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_last_step").
+					WithArgs(1, int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "step already used",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_last_step").
+					WithArgs(1, int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update users set totp_last_step").
+					WithArgs(1, int64(2)).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := userRepo.UseTOTPStep(context.Background(), 1, 2)
 			test.checkResult(t, err)
 		})
 	}
@@ -79,8 +417,12 @@ func TestUserGetByID(t *testing.T) {
 	userRepo := NewUserRepository(db)
 
 	defaultUser := model.User{
-		ID:    1,
-		Email: "2",
+		ID:              1,
+		Email:           "2",
+		PasswordHash:    "3",
+		EmailVerifiedAt: 4,
+		TOTPSecret:      "5",
+		TOTPEnabledAt:   6,
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -96,14 +438,22 @@ func TestUserGetByID(t *testing.T) {
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				mock.ExpectQuery("select id, email from users").
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
 					WithArgs(df.ID).
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id",
 						"email",
+						"password_hash",
+						"email_verified_at",
+						"totp_secret",
+						"totp_enabled_at",
 					}).AddRow(
 						df.ID,
 						df.Email,
+						df.PasswordHash,
+						df.EmailVerifiedAt,
+						df.TOTPSecret,
+						df.TOTPEnabledAt,
 					))
 			},
 			checkResult: func(t *testing.T, in, db model.User, err error) {
@@ -116,7 +466,7 @@ func TestUserGetByID(t *testing.T) {
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				mock.ExpectQuery("select id, email from users").
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
 					WithArgs(df.ID).
 					WillReturnError(unexpectedError)
 			},
@@ -136,6 +486,98 @@ func TestUserGetByID(t *testing.T) {
 	}
 }
 
+func TestUserGetByEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	userRepo := NewUserRepository(db)
+
+	defaultUser := model.User{
+		ID:              1,
+		Email:           "2",
+		PasswordHash:    "3",
+		EmailVerifiedAt: 4,
+		TOTPSecret:      "5",
+		TOTPEnabledAt:   6,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.User
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.User, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultUser,
+			buildStubs: func() {
+				df := defaultUser
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
+					WithArgs(df.Email).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"email",
+						"password_hash",
+						"email_verified_at",
+						"totp_secret",
+						"totp_enabled_at",
+					}).AddRow(
+						df.ID,
+						df.Email,
+						df.PasswordHash,
+						df.EmailVerifiedAt,
+						df.TOTPSecret,
+						df.TOTPEnabledAt,
+					))
+			},
+			checkResult: func(t *testing.T, in, db model.User, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "not found",
+			input: defaultUser,
+			buildStubs: func() {
+				df := defaultUser
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
+					WithArgs(df.Email).
+					WillReturnError(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, in, db model.User, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultUser,
+			buildStubs: func() {
+				df := defaultUser
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
+					WithArgs(df.Email).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.User, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			user, err := userRepo.GetByEmail(context.Background(), test.input.Email)
+			test.checkResult(t, test.input, user, err)
+		})
+	}
+}
+
 func TestUserList(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -145,8 +587,12 @@ func TestUserList(t *testing.T) {
 	userRepo := NewUserRepository(db)
 
 	defaultUser := model.User{
-		ID:    1,
-		Email: "2",
+		ID:              1,
+		Email:           "2",
+		PasswordHash:    "3",
+		EmailVerifiedAt: 4,
+		TOTPSecret:      "5",
+		TOTPEnabledAt:   6,
 	}
 
 	unexpectedError := fmt.Errorf("unexpected error")
@@ -164,18 +610,30 @@ func TestUserList(t *testing.T) {
 				df1 := defaultUser
 				df2 := defaultUser
 				df2.ID = 2
-				mock.ExpectQuery("select id, email from users").WithoutArgs().
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").WithoutArgs().
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id",
 						"email",
+						"password_hash",
+						"email_verified_at",
+						"totp_secret",
+						"totp_enabled_at",
 					}).AddRows(
 						[]driver.Value{
 							df1.ID,
 							df1.Email,
+							df1.PasswordHash,
+							df1.EmailVerifiedAt,
+							df1.TOTPSecret,
+							df1.TOTPEnabledAt,
 						},
 						[]driver.Value{
 							df2.ID,
 							df2.Email,
+							df2.PasswordHash,
+							df2.EmailVerifiedAt,
+							df2.TOTPSecret,
+							df2.TOTPEnabledAt,
 						},
 					))
 			},
@@ -189,7 +647,7 @@ func TestUserList(t *testing.T) {
 			name:  "unexpected error",
 			input: defaultUser,
 			buildStubs: func() {
-				mock.ExpectQuery("select id, email from users").
+				mock.ExpectQuery("select id, email, password_hash, email_verified_at, totp_secret, totp_enabled_at from users").
 					WithoutArgs().
 					WillReturnError(unexpectedError)
 			},
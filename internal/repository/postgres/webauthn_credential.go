@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"medods/internal/model"
+)
+
+type WebAuthnCredential struct {
+	conn *sql.DB
+}
+
+func NewWebAuthnCredentialRepository(conn *sql.DB) *WebAuthnCredential {
+	return &WebAuthnCredential{conn: conn}
+}
+
+func (r WebAuthnCredential) Create(ctx context.Context, c model.WebAuthnCredential) (model.WebAuthnCredential, error) {
+	query := `
+	insert into webauthn_credentials(
+		user_id,
+		credential_id,
+		public_key,
+		sign_count,
+		created_at
+	) values($1, $2, $3, $4, $5)
+	returning
+		id,
+		user_id,
+		credential_id,
+		public_key,
+		sign_count,
+		created_at,
+		last_used_at`
+
+	var res model.WebAuthnCredential
+	err := r.conn.QueryRowContext(ctx, query,
+		c.UserID,
+		c.CredentialID,
+		c.PublicKey,
+		c.SignCount,
+		c.CreatedAt,
+	).Scan(
+		&res.ID,
+		&res.UserID,
+		&res.CredentialID,
+		&res.PublicKey,
+		&res.SignCount,
+		&res.CreatedAt,
+		&res.LastUsedAt,
+	)
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+	return res, nil
+}
+
+// UpdateSignCount save sign count of login only if it wasn't changed since credential was read,
+// so two concurrent logins with the same counter can't both succeed.
+// Returns sql.ErrNoRows if counter was changed.
+func (r WebAuthnCredential) UpdateSignCount(ctx context.Context, id int, oldCount, newCount, usedAt int64) error {
+	query := `
+	update webauthn_credentials set sign_count = $3, last_used_at = $4
+	where id = $1 and sign_count = $2`
+	res, err := r.conn.ExecContext(ctx, query, id, oldCount, newCount, usedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r WebAuthnCredential) GetByCredentialID(ctx context.Context, credentialID string) (model.WebAuthnCredential, error) {
+	query := `
+	select
+		id,
+		user_id,
+		credential_id,
+		public_key,
+		sign_count,
+		created_at,
+		last_used_at
+	from webauthn_credentials
+	where credential_id = $1`
+
+	var res model.WebAuthnCredential
+	err := r.conn.QueryRowContext(ctx, query, credentialID).Scan(
+		&res.ID,
+		&res.UserID,
+		&res.CredentialID,
+		&res.PublicKey,
+		&res.SignCount,
+		&res.CreatedAt,
+		&res.LastUsedAt,
+	)
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+	return res, nil
+}
+
+func (r WebAuthnCredential) ListByUserID(ctx context.Context, userID int) ([]model.WebAuthnCredential, error) {
+	query := `
+	select
+		id,
+		user_id,
+		credential_id,
+		public_key,
+		sign_count,
+		created_at,
+		last_used_at
+	from webauthn_credentials
+	where user_id = $1
+	order by id`
+
+	rows, err := r.conn.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []model.WebAuthnCredential
+	for rows.Next() {
+		var c model.WebAuthnCredential
+		if err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.CredentialID,
+			&c.PublicKey,
+			&c.SignCount,
+			&c.CreatedAt,
+			&c.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
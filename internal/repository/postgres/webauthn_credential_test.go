@@ -0,0 +1,333 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"medods/internal/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var webAuthnCredentialColumns = []string{
+	"id",
+	"user_id",
+	"credential_id",
+	"public_key",
+	"sign_count",
+	"created_at",
+	"last_used_at",
+}
+
+func TestWebAuthnCredentialCreate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	credential := NewWebAuthnCredentialRepository(db)
+
+	defaultCredential := model.WebAuthnCredential{
+		ID:           1,
+		UserID:       2,
+		CredentialID: "3",
+		PublicKey:    []byte{4},
+		SignCount:    5,
+		CreatedAt:    6,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.WebAuthnCredential
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.WebAuthnCredential, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("insert into webauthn_credentials").WithArgs(
+					df.UserID,
+					df.CredentialID,
+					df.PublicKey,
+					df.SignCount,
+					df.CreatedAt,
+				).WillReturnRows(sqlmock.NewRows(webAuthnCredentialColumns).AddRow(
+					df.ID,
+					df.UserID,
+					df.CredentialID,
+					df.PublicKey,
+					df.SignCount,
+					df.CreatedAt,
+					df.LastUsedAt,
+				))
+			},
+			checkResult: func(t *testing.T, in, db model.WebAuthnCredential, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("insert into webauthn_credentials").WithArgs(
+					df.UserID,
+					df.CredentialID,
+					df.PublicKey,
+					df.SignCount,
+					df.CreatedAt,
+				).WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.WebAuthnCredential, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			res, err := credential.Create(context.Background(), test.input)
+			test.checkResult(t, test.input, res, err)
+		})
+	}
+}
+
+func TestWebAuthnCredentialUpdateSignCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	credential := NewWebAuthnCredentialRepository(db)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				mock.ExpectExec("update webauthn_credentials set sign_count").
+					WithArgs(1, int64(2), int64(3), int64(4)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "changed concurrently",
+			buildStubs: func() {
+				mock.ExpectExec("update webauthn_credentials set sign_count").
+					WithArgs(1, int64(2), int64(3), int64(4)).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // note: nothing updated
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				mock.ExpectExec("update webauthn_credentials set sign_count").
+					WithArgs(1, int64(2), int64(3), int64(4)).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := credential.UpdateSignCount(context.Background(), 1, 2, 3, 4)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestWebAuthnCredentialGetByCredentialID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	credential := NewWebAuthnCredentialRepository(db)
+
+	defaultCredential := model.WebAuthnCredential{
+		ID:           1,
+		UserID:       2,
+		CredentialID: "3",
+		PublicKey:    []byte{4},
+		SignCount:    5,
+		CreatedAt:    6,
+		LastUsedAt:   7,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.WebAuthnCredential
+		buildStubs  func()
+		checkResult func(t *testing.T, in, db model.WebAuthnCredential, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("select id, user_id, credential_id, public_key, sign_count, created_at, last_used_at from webauthn_credentials").
+					WithArgs(df.CredentialID).
+					WillReturnRows(sqlmock.NewRows(webAuthnCredentialColumns).AddRow(
+						df.ID,
+						df.UserID,
+						df.CredentialID,
+						df.PublicKey,
+						df.SignCount,
+						df.CreatedAt,
+						df.LastUsedAt,
+					))
+			},
+			checkResult: func(t *testing.T, in, db model.WebAuthnCredential, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, in, db)
+			},
+		},
+		{
+			name:  "not found",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("select id, user_id, credential_id, public_key, sign_count, created_at, last_used_at from webauthn_credentials").
+					WithArgs(df.CredentialID).
+					WillReturnError(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, in, db model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("select id, user_id, credential_id, public_key, sign_count, created_at, last_used_at from webauthn_credentials").
+					WithArgs(df.CredentialID).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, in, db model.WebAuthnCredential, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			res, err := credential.GetByCredentialID(context.Background(), test.input.CredentialID)
+			test.checkResult(t, test.input, res, err)
+		})
+	}
+}
+
+func TestWebAuthnCredentialListByUserID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create db mock error: %s", err.Error())
+	}
+
+	credential := NewWebAuthnCredentialRepository(db)
+
+	defaultCredential := model.WebAuthnCredential{
+		ID:           1,
+		UserID:       2,
+		CredentialID: "3",
+		PublicKey:    []byte{4},
+		SignCount:    5,
+		CreatedAt:    6,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.WebAuthnCredential
+		buildStubs  func()
+		checkResult func(t *testing.T, db []model.WebAuthnCredential, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("select id, user_id, credential_id, public_key, sign_count, created_at, last_used_at from webauthn_credentials").
+					WithArgs(df.UserID).
+					WillReturnRows(sqlmock.NewRows(webAuthnCredentialColumns).AddRows([]driver.Value{
+						df.ID,
+						df.UserID,
+						df.CredentialID,
+						df.PublicKey,
+						df.SignCount,
+						df.CreatedAt,
+						df.LastUsedAt,
+					}, []driver.Value{
+						df.ID + 1,
+						df.UserID,
+						"4",
+						df.PublicKey,
+						df.SignCount,
+						df.CreatedAt,
+						df.LastUsedAt,
+					}))
+			},
+			checkResult: func(t *testing.T, db []model.WebAuthnCredential, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, db, 2)
+				assert.Equal(t, 1, db[0].ID)
+				assert.Equal(t, 2, db[1].ID)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultCredential,
+			buildStubs: func() {
+				df := defaultCredential
+				mock.ExpectQuery("select id, user_id, credential_id, public_key, sign_count, created_at, last_used_at from webauthn_credentials").
+					WithArgs(df.UserID).
+					WillReturnError(unexpectedError)
+			},
+			checkResult: func(t *testing.T, db []model.WebAuthnCredential, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Empty(t, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			res, err := credential.ListByUserID(context.Background(), test.input.UserID)
+			test.checkResult(t, res, err)
+		})
+	}
+}
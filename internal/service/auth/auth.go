@@ -8,171 +8,495 @@ import (
 	"errors"
 	"fmt"
 	"medods/internal/model"
+	"medods/internal/service/denylist"
 	"medods/internal/service/jwt"
+	"medods/internal/service/mfa"
+	"medods/internal/service/onetime"
 	"medods/internal/service/session"
 	"medods/internal/service/user"
 	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
+	"medods/pkg/authmiddleware"
 	"medods/pkg/logger"
 	"medods/pkg/smtp"
 	"time"
 
 	gjwt "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrValidationFailed = fmt.Errorf("fail to validate token")
+	ErrSessionRevoked   = fmt.Errorf("%w: session is revoked", ErrValidationFailed)
+	ErrTokenReused      = fmt.Errorf("%w: refresh token reuse detected", ErrValidationFailed)
+	ErrStepUpRequired   = fmt.Errorf("%w: refresh from new ip requires to log in again", ErrValidationFailed)
+	ErrIPDenied         = fmt.Errorf("%w: refresh from new ip is denied", ErrValidationFailed)
+	ErrClientMismatch   = fmt.Errorf("%w: refresh token was issued to other client", ErrValidationFailed)
+
+	ErrInvalidCredentials = fmt.Errorf("invalid email or password")
+	ErrEmailNotVerified   = fmt.Errorf("email is not verified")
+	ErrInvalidResetToken  = fmt.Errorf("password reset link is invalid, expired or already used")
+	ErrInvalidMagicLink   = fmt.Errorf("login link is invalid, expired, already used or opened in other browser")
+
+	ErrMFARequired         = fmt.Errorf("second factor is required")
+	ErrInvalidMFAChallenge = fmt.Errorf("two-factor challenge is invalid, expired or already used, log in again")
 )
 
+// types of tokens reported by introspection, also accepted as its hint (RFC 7662 section 2.1)
 const (
-	// life time of access token
-	ATokenLifetime = 30 * time.Minute
-	// life time of refresh token
-	RTokenLifeTime = 30 * 24 * time.Hour
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
 )
 
+// refresh token is <selector>.<secret>, base64url of neither part contains separator
+const rTokenSeparator = "."
+
+// selector is not secret, it only has to be unique and unguessable, so it's shorter than secret
+const rTokenSelectorBytes = 16
+
+// time to issue and send emailed token after response
+const deliveryTimeout = 30 * time.Second
+
+// Grant is what session was opened with, every access token of session carries it
+type Grant struct {
+	// factors which user passed to open session
+	AMR []string
+	// OAuth 2.0 client and scope granted to it, empty for first-party login
+	ClientID string
+	Scope    string
+	// unix time when user passed factors, now if empty,
+	// session of client keeps time of login of user who authorized it
+	AuthTime int64
+}
+
+// MFAChallengeError is returned by login when user has second factor enabled,
+// Token must be presented to VerifyMFA together with code of second factor.
+type MFAChallengeError struct {
+	Token string
+}
+
+func (e *MFAChallengeError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFAChallengeError) Unwrap() error {
+	return ErrMFARequired
+}
+
 type Interface interface {
-	CreateSession(ctx context.Context, uid int, ip string) (aToken string, rToken string, err error)
+	Login(ctx context.Context, email, password, ip string) (aToken string, rToken string, err error)
+	CreateSession(ctx context.Context, uid int, ip string, amr ...string) (aToken string, rToken string, err error)
+	CreateClientSession(ctx context.Context, uid int, ip string, grant Grant) (aToken string, rToken string, err error)
 	RefreshSession(ctx context.Context, aT, rT, ip string) (aToken string, rToken string, err error)
+	RefreshClientSession(ctx context.Context, aT, rT, ip, clientID, scope string) (aToken string, rToken string, err error)
+	RefreshTokenSession(ctx context.Context, rT string) (model.Session, error)
+	RevokeSession(ctx context.Context, payload *model.Payload) error
+	RevokeAllSessions(ctx context.Context, uid int) error
+	Introspect(ctx context.Context, token, tokenTypeHint string) (model.Introspection, error)
+
+	ForgotPassword(ctx context.Context, email, ip string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+
+	SendMagicLink(ctx context.Context, email, ip string) (nonce string, err error)
+	LoginByMagicLink(ctx context.Context, token, nonce, ip string) (aToken string, rToken string, err error)
+
+	VerifyMFA(ctx context.Context, challenge, code, ip string) (aToken string, rToken string, err error)
 }
 
 var _ Interface = (*auth)(nil)
 
 type auth struct {
-	session session.Interface
-	user    user.Interface
-	jwt     jwt.Interface
-	smtp    smtp.Interface
+	session      session.Interface
+	user         user.Interface
+	jwt          jwt.Interface
+	denylist     denylist.Interface
+	smtp         smtp.Interface
+	password     PasswordHasher
+	rTokenHasher TokenHasher
+	tokens       onetime.Interface
+	mfa          mfa.Interface
+	// nil if countries of ips are unknown
+	locator Locator
 
+	cfg      *Config
 	logger   logger.Interface
 	testMode bool
+
+	// compared with password when user has no password hash, so failure always takes the same time
+	dummyHash string
 }
 
 func New(
 	sessionService session.Interface,
 	userService user.Interface,
 	jwtMaker jwt.Interface,
+	denylist denylist.Interface,
 	smtp smtp.Interface,
+	password PasswordHasher,
+	rTokenHasher TokenHasher,
+	tokens onetime.Interface,
+	mfa mfa.Interface,
+	locator Locator,
+	cfg *Config,
 	logger logger.Interface,
 	testMode bool,
 ) *auth {
+	// error is possible only when system random source is broken
+	dummyHash, _ := password.Hash("dummy password")
+
 	return &auth{
-		session: sessionService,
-		user:    userService,
-		jwt:     jwtMaker,
-		smtp:    smtp,
+		session:      sessionService,
+		user:         userService,
+		jwt:          jwtMaker,
+		denylist:     denylist,
+		smtp:         smtp,
+		password:     password,
+		rTokenHasher: rTokenHasher,
+		tokens:       tokens,
+		mfa:          mfa,
+		locator:      locator,
 
+		cfg:    cfg,
 		logger: logger,
 
 		testMode: testMode,
+
+		dummyHash: dummyHash,
+	}
+}
+
+// Login open session for user with email and password,
+// user with second factor gets MFAChallengeError instead of tokens.
+// Hash made by outdated algorithm or parameters is replaced with actual one.
+// Unknown email, user without password and wrong password take the same time
+// and return the same error, so response does not reveal whether account exists.
+func (s auth) Login(ctx context.Context, email, password, ip string) (aToken, rToken string, err error) {
+	dbUser, err := s.user.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		err := fmt.Errorf("failed to get user: %w", err)
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	hash := dbUser.PasswordHash
+	if len(hash) == 0 {
+		hash = s.dummyHash
+	}
+
+	if !s.password.Compare(hash, password) || len(dbUser.PasswordHash) == 0 {
+		if dbUser.ID != 0 {
+			s.logger.Warn("failed login attempt: user[%d] ip[%s]", dbUser.ID, ip)
+		} else {
+			s.logger.Warn("failed login attempt of unknown email: ip[%s]", ip)
+		}
+		return "", "", ErrInvalidCredentials
+	}
+	s.logger.Debug("password of user[%d] success verified", dbUser.ID)
+
+	// plaintext is available only here, so legacy hashes are upgraded on login,
+	// failure is not critical, hash will be upgraded next time
+	if s.password.NeedsRehash(dbUser.PasswordHash) {
+		if err := s.user.SetPassword(ctx, dbUser.ID, password); err != nil {
+			s.logger.Error("failed to rehash password of user[%d]: %s", dbUser.ID, err.Error())
+		} else {
+			s.logger.Info("password hash of user[%d] upgraded", dbUser.ID)
+		}
+	}
+
+	return s.openSession(ctx, dbUser, ip, model.AMRPassword)
+}
+
+// Notify: do not check user with uid exists or not, pls use only correct input,
+// user is queried only when verified email is required by config.
+//
+// Every call opens a new session, so user can be logged in from few devices at the same time.
+// amr lists factors verified by caller, e.g. passkey, they are kept in tokens of session.
+func (s auth) CreateSession(ctx context.Context, uid int, ip string, amr ...string) (aToken, rToken string, err error) {
+	return s.CreateClientSession(ctx, uid, ip, Grant{AMR: amr})
+}
+
+// CreateClientSession open session on behalf of OAuth 2.0 client, e.g. when authorization code is exchanged.
+// Client and scope are kept in tokens of session, so refresh can check that tokens belong to client.
+func (s auth) CreateClientSession(ctx context.Context, uid int, ip string, grant Grant) (aToken, rToken string, err error) {
+	if s.cfg.RequireVerifiedEmail {
+		dbUser, err := s.user.GetByID(ctx, uid)
+		if err != nil {
+			err := fmt.Errorf("failed to get user: %w", err)
+			s.logger.Error(err)
+			return "", "", err
+		}
+
+		if dbUser.EmailVerifiedAt == 0 {
+			s.logger.Warn("session of user[%d] with unverified email refused", uid)
+			return "", "", ErrEmailNotVerified
+		}
+	}
+
+	return s.createSession(ctx, uid, ip, grant)
+}
+
+// openSession finish login after first factor of user is verified:
+// open session or ask for second factor if user has it enabled.
+func (s auth) openSession(ctx context.Context, dbUser model.User, ip, firstFactor string) (aToken, rToken string, err error) {
+	if s.cfg.RequireVerifiedEmail && dbUser.EmailVerifiedAt == 0 {
+		s.logger.Warn("login of user[%d] with unverified email refused", dbUser.ID)
+		return "", "", ErrEmailNotVerified
+	}
+
+	if dbUser.TOTPEnabledAt == 0 {
+		return s.createSession(ctx, dbUser.ID, ip, Grant{AMR: []string{firstFactor}})
+	}
+
+	// first factor is signed into challenge, so it can't be changed by client
+	token, err := s.tokens.IssueBound(ctx, onetime.PurposeMFAChallenge, dbUser.ID, s.cfg.MFAChallengeLifetime, firstFactor)
+	if err != nil {
+		err := fmt.Errorf("failed to issue mfa challenge: %w", err)
+		s.logger.Error(err)
+		return "", "", err
+	}
+	s.logger.Debug("second factor of user[%d] requested", dbUser.ID)
+
+	return "", "", &MFAChallengeError{Token: firstFactor + "." + token}
+}
+
+// VerifyMFA open session by challenge returned from login and code of second factor.
+// Challenge is single-use, wrong code burns it, so every guess costs the first factor again.
+func (s auth) VerifyMFA(ctx context.Context, challenge, code, ip string) (aToken, rToken string, err error) {
+	firstFactor, token, ok := strings.Cut(challenge, ".")
+	if !ok {
+		return "", "", ErrInvalidMFAChallenge
+	}
+
+	t, err := s.tokens.ConsumeBound(ctx, onetime.PurposeMFAChallenge, token, firstFactor)
+	if errors.Is(err, onetime.ErrInvalidToken) {
+		s.logger.Warn("invalid mfa challenge: ip[%s]", ip)
+		return "", "", ErrInvalidMFAChallenge
+	} else if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	dbUser, err := s.user.GetByID(ctx, t.UserID)
+	if err != nil {
+		err := fmt.Errorf("failed to get user: %w", err)
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	secondFactor, err := s.mfa.Verify(ctx, dbUser, code)
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		s.logger.Warn("failed second factor: user[%d] ip[%s]", dbUser.ID, ip)
+		return "", "", err
+	} else if err != nil {
+		err := fmt.Errorf("failed to verify second factor: %w", err)
+		s.logger.Error(err)
+		return "", "", err
 	}
+	s.logger.Debug("second factor of user[%d] success verified", dbUser.ID)
+
+	return s.createSession(ctx, dbUser.ID, ip, Grant{AMR: []string{firstFactor, secondFactor, model.AMRMFA}})
 }
 
-// Notify: do not check user with uid exists or not, pls use only correct input
-func (s auth) CreateSession(ctx context.Context, uid int, ip string) (aToken, rToken string, err error) {
+// createSession open session, tokens of it carry grant
+func (s auth) createSession(ctx context.Context, uid int, ip string, g Grant) (aToken, rToken string, err error) {
+	ip = normalizeIP(ip)
 	iat := time.Now()
 	jti := s.generateUUID()
+	if g.AuthTime == 0 {
+		g.AuthTime = iat.Unix()
+	}
 
-	aToken, rToken, err = s.createTokens(uid, ip, iat, jti)
+	rToken, selector, rTokenHash, err := s.createRefreshToken()
 	if err != nil {
+		s.logger.Error(err)
 		return "", "", err
 	}
-	s.logger.Debug("tokens created")
+	s.logger.Debug("refresh token created")
 
-	rTokenHash, err := s.hashString(rToken)
+	session, err := s.session.Create(ctx, model.Session{
+		UserID:         uid,
+		ATokenID:       jti,
+		RTokenSelector: selector,
+		RTokenHash:     rTokenHash,
+		CreatedAt:      iat.Unix(),
+		IP:             ip,
+		AMR:            g.AMR,
+		ClientID:       g.ClientID,
+		Scope:          g.Scope,
+		AuthTime:       g.AuthTime,
+		Geo:            s.locate(ip),
+	})
 	if err != nil {
-		s.logger.Error(err)
+		s.logger.Error("failed to create session: %s", err.Error())
 		return "", "", err
 	}
-	s.logger.Debug("refresh token hashed")
+	s.logger.Debug("session success created")
 
-	session, err := s.session.GetByUserID(ctx, uid)
-	if errors.Is(err, sql.ErrNoRows) { // create session if not exists
-		if err := s.session.Create(ctx, model.Session{
-			UserID:     uid,
-			ATokenID:   jti,
-			RTokenHash: rTokenHash,
-			CreatedAt:  iat.Unix(),
-		}); err != nil {
-			s.logger.Error("failed to create session: %s", err.Error())
-			return "", "", err
-		}
-		s.logger.Debug("session success created")
-		return aToken, rToken, nil
-	} else if err == nil { // update session if exists
-
-		// можно добавить проверку на логирование с нового ip, сравнив текущий ip с тем что в базе
-
-		if _, err := s.session.Update(ctx, model.Session{
-			ID:         session.ID,
-			UserID:     uid,
-			ATokenID:   jti,
-			RTokenHash: rTokenHash,
-			CreatedAt:  iat.Unix(),
-			Version:    session.Version,
-		}); err != nil {
-			s.logger.Error("failed to update session: %s", err.Error())
-			return "", "", err
-		}
-		s.logger.Debug("session success created")
-		return aToken, rToken, nil
+	aToken, err = s.createAccessToken(uid, session.ID, ip, g, iat, jti)
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
 	}
+	s.logger.Debug("access token created")
 
-	return "", "", err
+	return aToken, rToken, nil
 }
 
+// RefreshSession issue new pair of tokens for session of refresh token.
+// Refresh token <selector>.<secret> finds session by itself, so access token may be lost and aT empty,
+// aT is required only for refresh tokens issued before selectors, they are upgraded on refresh.
+// Sessions of OAuth 2.0 clients are refused, they are refreshed only by RefreshClientSession.
 func (s auth) RefreshSession(ctx context.Context, aT, rT, ip string) (aToken, rToken string, err error) {
-	_, payload, err := s.jwt.VerifyToken(aT)
-	if err != nil && !errors.Is(err, gjwt.ErrTokenExpired) {
-		err := fmt.Errorf("failed to verify access token: %w", err)
+	return s.refreshSession(ctx, aT, rT, ip, "", "")
+}
+
+// RefreshClientSession is RefreshSession for token endpoint of OAuth 2.0,
+// session must be opened for clientID which is already authenticated by caller.
+// Non-empty scope narrows scope of session to its part, empty one keeps it.
+func (s auth) RefreshClientSession(ctx context.Context, aT, rT, ip, clientID, scope string) (aToken, rToken string, err error) {
+	if clientID == "" {
+		return "", "", fmt.Errorf("%w: client is required", ErrValidationFailed)
+	}
+	return s.refreshSession(ctx, aT, rT, ip, clientID, scope)
+}
+
+// refreshSession refresh session of clientID, empty for first-party session
+func (s auth) refreshSession(ctx context.Context, aT, rT, ip, clientID, scope string) (aToken, rToken string, err error) {
+	ip = normalizeIP(ip)
+	selector, secret, ok := strings.Cut(rT, rTokenSeparator)
+	if !ok {
+		return s.refreshLegacySession(ctx, aT, rT, ip, clientID, scope)
+	}
+	if selector == "" || secret == "" {
+		err := fmt.Errorf("%w: refresh token is malformed", ErrValidationFailed)
 		s.logger.Error(err)
 		return "", "", err
 	}
-	s.logger.Debug("success verified token")
 
-	// dbSession.IP != payload.IP проверял до этого так, задался вопросом что это не имеет смылса только на интеграционных тестах)
-	// перепрочитал и понял что нужно ip непосредственно получать и просто сверять с payload
-	clientIP := net.ParseIP(ip)
-	payloadIP := net.ParseIP(payload.IP)
-	if !payloadIP.Equal(clientIP) {
-		s.logger.Warn("login from new IP addess: old[%s], new[%s]", payload.IP, ip)
+	dbSession, err := s.session.GetBySelector(ctx, selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		err := s.checkSelectorReuse(ctx, selector, secret, ip)
+		s.logger.Error(err)
+		return "", "", err
+	} else if err != nil {
+		err = fmt.Errorf("failed to get session: %w", err)
+		s.logger.Error(err)
+		return "", "", err
+	}
+	s.logger.Debug("success got session")
+
+	if dbSession.RevokedAt != 0 {
+		s.logger.Error(ErrSessionRevoked)
+		return "", "", ErrSessionRevoked
+	} else if !s.rTokenHasher.Compare(dbSession.RTokenHash, secret) {
+		s.logger.Error(ErrValidationFailed)
+		return "", "", ErrValidationFailed
+	} else if dbSession.ClientID != clientID {
+		s.logger.Warn("refresh token of client[%s] presented as token of client[%s]: session[%d]", dbSession.ClientID, clientID, dbSession.ID)
+		return "", "", ErrClientMismatch
+	}
+
+	rExp := time.Unix(dbSession.CreatedAt, 0).Add(s.cfg.RTokenLifetime).Add(s.cfg.Leeway)
+	if rExp.Before(time.Now()) {
+		err := fmt.Errorf("refresh token: %w", gjwt.ErrTokenExpired)
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	scope, err = narrowScope(dbSession.Scope, scope)
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	if err := s.checkIP(ctx, dbSession, dbSession.IP, ip); err != nil {
+		return "", "", err
+	}
+
+	return s.rotateSession(ctx, dbSession, ip, Grant{
+		AMR:      dbSession.AMR,
+		ClientID: dbSession.ClientID,
+		Scope:    scope,
+		AuthTime: dbSession.AuthTime,
+	})
+}
+
+// RefreshTokenSession return session which refresh token was issued for, rotated token included,
+// so OAuth 2.0 client could be checked before refresh. Secret isn't verified, RefreshSession does it.
+func (s auth) RefreshTokenSession(ctx context.Context, rT string) (model.Session, error) {
+	selector, _, ok := strings.Cut(rT, rTokenSeparator)
+	if !ok || selector == "" {
+		return model.Session{}, fmt.Errorf("%w: refresh token is malformed", ErrValidationFailed)
+	}
 
-		dbUser, err := s.user.GetByID(ctx, payload.UserID)
+	dbSession, err := s.session.GetBySelector(ctx, selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		rotation, err := s.session.GetRotationBySelector(ctx, selector)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Session{}, fmt.Errorf("%w: refresh token is unknown", ErrValidationFailed)
+		} else if err != nil {
+			return model.Session{}, fmt.Errorf("failed to get session rotation: %w", err)
+		}
+		dbSession, err = s.session.GetByID(ctx, rotation.SessionID)
 		if err != nil {
-			return "", "", err
+			return model.Session{}, fmt.Errorf("failed to get session: %w", err)
 		}
+	} else if err != nil {
+		return model.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
 
-		if err := s.smtp.SendLoginFromNewIP(payload.IP, dbUser.Email); err != nil {
-			return "", "", err
-		}
+	return dbSession, nil
+}
+
+// refreshLegacySession refresh by access token and refresh token without selector:
+// session is found by sid claim of access token, jti must match the last issued one
+func (s auth) refreshLegacySession(ctx context.Context, aT, rT, ip, clientID, scope string) (aToken, rToken string, err error) {
+	_, payload, err := s.jwt.VerifyToken(ctx, aT)
+	if err != nil && !errors.Is(err, gjwt.ErrTokenExpired) {
+		err := fmt.Errorf("failed to verify access token: %w", err)
+		s.logger.Error(err)
+		return "", "", err
 	}
+	s.logger.Debug("success verified token")
 
-	dbSession, err := s.session.GetByUserID(ctx, payload.UserID)
+	dbSession, err := s.session.GetByID(ctx, payload.SessionID)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = fmt.Errorf("session not exists: %w", err)
 		s.logger.Error(err)
 		return "", "", err
 	} else if err != nil {
-		err = fmt.Errorf("failed to get user: %w", err)
+		err = fmt.Errorf("failed to get session: %w", err)
 		s.logger.Error(err)
 		return "", "", err
 	}
-	s.logger.Debug("success got user")
+	s.logger.Debug("success got session")
 
-	if !CompareHash(dbSession.RTokenHash, rT) {
+	if dbSession.UserID != payload.UserID {
+		err := fmt.Errorf("%w: session belongs to other user", ErrValidationFailed)
 		s.logger.Error(err)
-		return "", "", ErrValidationFailed
+		return "", "", err
+	} else if dbSession.RevokedAt != 0 {
+		s.logger.Error(ErrSessionRevoked)
+		return "", "", ErrSessionRevoked
 	} else if payload.ID != dbSession.ATokenID {
-		err := fmt.Errorf("%w: invalid jti", ErrValidationFailed)
+		err := s.checkTokenReuse(ctx, dbSession, payload, rT, ip)
 		s.logger.Error(err)
 		return "", "", err
+	} else if !s.rTokenHasher.Compare(dbSession.RTokenHash, rT) {
+		s.logger.Error(ErrValidationFailed)
+		return "", "", ErrValidationFailed
+	} else if payload.ClientID != clientID {
+		s.logger.Warn("refresh token of client[%s] presented as token of client[%s]: session[%d]", payload.ClientID, clientID, dbSession.ID)
+		return "", "", ErrClientMismatch
 	}
 
 	now := time.Now()
 	iat := payload.IssuedAt.Time
-	rExp := iat.Add(RTokenLifeTime)
+	rExp := iat.Add(s.cfg.RTokenLifetime).Add(s.cfg.Leeway)
 	if iat.Unix() != dbSession.CreatedAt {
 		err := fmt.Errorf("different creation time of access and refresh token: %w", gjwt.ErrTokenExpired)
 		s.logger.Error(err)
@@ -183,30 +507,602 @@ func (s auth) RefreshSession(ctx context.Context, aT, rT, ip string) (aToken, rT
 		return "", "", err
 	}
 
-	return s.CreateSession(ctx, payload.UserID, payload.IP)
+	scope, err = narrowScope(payload.Scope, scope)
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	if err := s.checkIP(ctx, dbSession, payload.IP, ip); err != nil {
+		return "", "", err
+	}
+
+	// factors and client are not checked again on refresh, so new token keeps them
+	return s.rotateSession(ctx, dbSession, ip, Grant{
+		AMR:      payload.AMR,
+		ClientID: payload.ClientID,
+		Scope:    scope,
+		AuthTime: payload.AuthTime,
+	})
+}
+
+// narrowScope return requested scope if it's a part of granted one, empty request keeps granted scope.
+// Narrowed scope can't be widened back by later refresh, user has to authorize client again.
+func narrowScope(granted, requested string) (string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return granted, nil
+	}
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range scopes {
+		if !slices.Contains(grantedScopes, scope) {
+			return "", fmt.Errorf("%w: scope %q wasn't granted", ErrValidationFailed, scope)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// checkIP evaluate ip policy when refresh comes from other ip than tokens were issued to.
+// Refused refresh returns IPPolicyError, failure to notify user doesn't fail refresh.
+// Allowed refresh rotates session to new ip, so next refresh is compared with it, not with ip of login.
+// Impossible travel is emailed to user even if refresh is refused, as it could mean that tokens leaked.
+func (s auth) checkIP(ctx context.Context, dbSession model.Session, sessionIP, ip string) error {
+	// dbSession.IP != payload.IP проверял до этого так, задался вопросом что это не имеет смылса только на интеграционных тестах)
+	// перепрочитал и понял что нужно ip непосредственно получать и просто сверять с payload
+	change := ipChange{
+		sessionIP:    net.ParseIP(sessionIP),
+		ip:           net.ParseIP(ip),
+		sinceRefresh: time.Since(time.Unix(dbSession.CreatedAt, 0)),
+	}
+	if change.sessionIP.Equal(change.ip) {
+		return nil
+	}
+
+	known, err := s.knownIP(ctx, dbSession, change.ip)
+	if err != nil {
+		err := fmt.Errorf("failed to get sessions of user: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+	change.known = known
+
+	sessionGeo, geo := s.locate(sessionIP), s.locate(ip)
+	change.sessionCountry, change.country = sessionGeo.Country, geo.Country
+	change.sessionLocation, change.location = sessionGeo.Location, geo.Location
+
+	decision := s.cfg.IPPolicy.decide(change)
+	s.logger.Warn("refresh from new IP address: session_id[%d] old[%s] new[%s] geo[%s] %s", dbSession.ID, sessionIP, ip, geo, decision)
+
+	switch decision.Outcome {
+	case IPAllow:
+		return nil
+	case IPNotify:
+		s.notifyNewIP(ctx, dbSession.UserID, ip, geo)
+		return nil
+	default:
+		event := "refresh from new ip refused"
+		if decision.Has(IPReasonImpossibleTravel) {
+			event = fmt.Sprintf("impossible travel %.0fkm in %s from ip[%s] geo[%s], refresh refused",
+				travelDistance(change.sessionLocation, change.location), change.sinceRefresh.Round(time.Second), sessionIP, sessionGeo)
+			s.notifyImpossibleTravel(ctx, dbSession.UserID, ip, geo, sessionGeo)
+		}
+		s.securityEvent(event+", "+decision.String(), dbSession.UserID, dbSession.ID, ip)
+		return &IPPolicyError{Decision: decision}
+	}
+}
+
+// knownIP report whether other active session of user was refreshed from ip
+func (s auth) knownIP(ctx context.Context, dbSession model.Session, ip net.IP) (bool, error) {
+	sessions, err := s.session.ListByUserID(ctx, dbSession.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, other := range sessions {
+		if other.ID != dbSession.ID && other.RevokedAt == 0 && net.ParseIP(other.IP).Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notifyNewIP email user about refresh from new ip, refresh goes on if email isn't sent
+func (s auth) notifyNewIP(ctx context.Context, uid int, ip string, geo model.Geo) {
+	dbUser, err := s.user.GetByID(ctx, uid)
+	if err != nil {
+		s.logger.Error(fmt.Errorf("failed to get user to notify about new ip: %w", err))
+		return
+	}
+
+	if err := s.smtp.SendLoginFromNewIP(ip, geo.String(), dbUser.Email); err != nil {
+		s.logger.Error(fmt.Errorf("failed to notify user about new ip: %w", err))
+	}
+}
+
+// notifyImpossibleTravel email user about refused refresh from location too far from location of session
+func (s auth) notifyImpossibleTravel(ctx context.Context, uid int, ip string, geo, sessionGeo model.Geo) {
+	dbUser, err := s.user.GetByID(ctx, uid)
+	if err != nil {
+		s.logger.Error(fmt.Errorf("failed to get user to notify about impossible travel: %w", err))
+		return
+	}
+
+	if err := s.smtp.SendImpossibleTravel(ip, geo.String(), sessionGeo.String(), dbUser.Email); err != nil {
+		s.logger.Error(fmt.Errorf("failed to notify user about impossible travel: %w", err))
+	}
+}
+
+// RevokeSession close session of verified access token, e.g. payload put by auth middleware,
+// refresh of it will fail after that
+func (s auth) RevokeSession(ctx context.Context, payload *model.Payload) error {
+	if err := s.session.Revoke(ctx, payload.SessionID, time.Now().Unix()); err != nil {
+		err := fmt.Errorf("failed to revoke session: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+	s.logger.Debug("session success revoked")
+
+	// token is accepted for leeway after exp, so it must stay in denylist until then
+	if err := s.denylist.Add(ctx, payload.ID, payload.ExpiresAt.Add(s.cfg.Leeway)); err != nil {
+		err := fmt.Errorf("failed to revoke access token: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+	s.logger.Debug("access token success revoked")
+
+	return nil
+}
+
+// RevokeAllSessions revoke every session of user and denylist their access tokens
+func (s auth) RevokeAllSessions(ctx context.Context, uid int) error {
+	sessions, err := s.session.ListByUserID(ctx, uid)
+	if err != nil {
+		err := fmt.Errorf("failed to list sessions: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+
+	if err := s.session.RevokeAllByUserID(ctx, uid, time.Now().Unix()); err != nil {
+		err := fmt.Errorf("failed to revoke sessions: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+	s.logger.Debug("all sessions of user success revoked")
+
+	for _, session := range sessions {
+		if session.RevokedAt != 0 {
+			continue
+		}
+		if err := s.revokeAccessToken(ctx, session); err != nil {
+			s.logger.Error(err)
+			return err
+		}
+	}
+	s.logger.Debug("access tokens of user success revoked")
+
+	return nil
+}
+
+// ForgotPassword send password reset link to email if account exists.
+// Unknown email is not an error, so caller can't find out which emails are registered,
+// link is issued and sent in background, so time of response doesn't tell it either.
+func (s auth) ForgotPassword(ctx context.Context, email, ip string) error {
+	dbUser, err := s.user.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("password reset requested for unknown email: ip[%s]", ip)
+		return nil
+	} else if err != nil {
+		err := fmt.Errorf("failed to get user: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+
+	s.deliver(ctx, "password reset link", dbUser.ID, func(ctx context.Context) error {
+		token, err := s.tokens.Issue(ctx, onetime.PurposePasswordReset, dbUser.ID, s.cfg.PasswordResetTokenLifetime)
+		if err != nil {
+			return err
+		}
+
+		link, err := url.Parse(s.cfg.PasswordResetURL)
+		if err != nil {
+			return fmt.Errorf("invalid password reset url: %w", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+
+		return s.smtp.SendPasswordReset(link.String(), ip, dbUser.Email)
+	})
+
+	return nil
+}
+
+// ResetPassword set new password of user by token from reset link
+// and revoke all sessions, so whoever knew the old password is logged out.
+// Password is validated before token is consumed, so weak password doesn't burn the link.
+func (s auth) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if len(newPassword) < user.MinPasswordLength || len(newPassword) > user.MaxPasswordLength {
+		return user.ErrWeakPassword
+	}
+
+	t, err := s.tokens.Consume(ctx, onetime.PurposePasswordReset, token)
+	if errors.Is(err, onetime.ErrInvalidToken) {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		s.logger.Error(err)
+		return err
+	}
+
+	if err := s.user.SetPassword(ctx, t.UserID, newPassword); err != nil {
+		err := fmt.Errorf("failed to set password: %w", err)
+		s.logger.Error(err)
+		return err
+	}
+	s.logger.Info("password of user[%d] reset", t.UserID)
+
+	return s.RevokeAllSessions(ctx, t.UserID)
+}
+
+// SendMagicLink send single-use login link to email if account exists.
+// Link is bound to returned nonce, which must be kept by requesting browser,
+// nonce is returned for unknown email too and link is sent in background, so response does not reveal whether account exists.
+func (s auth) SendMagicLink(ctx context.Context, email, ip string) (nonce string, err error) {
+	nonce, err = s.randString()
+	if err != nil {
+		err := fmt.Errorf("failed to generate nonce: %w", err)
+		s.logger.Error(err)
+		return "", err
+	}
+
+	dbUser, err := s.user.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("login link requested for unknown email: ip[%s]", ip)
+		return nonce, nil
+	} else if err != nil {
+		err := fmt.Errorf("failed to get user: %w", err)
+		s.logger.Error(err)
+		return nonce, err
+	}
+
+	s.deliver(ctx, "login link", dbUser.ID, func(ctx context.Context) error {
+		token, err := s.tokens.IssueBound(ctx, onetime.PurposeMagicLink, dbUser.ID, s.cfg.MagicLinkTokenLifetime, nonce)
+		if err != nil {
+			return err
+		}
+
+		link, err := url.Parse(s.cfg.MagicLinkURL)
+		if err != nil {
+			return fmt.Errorf("invalid login link url: %w", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+
+		return s.smtp.SendMagicLink(link.String(), ip, dbUser.Email)
+	})
+
+	return nonce, nil
+}
+
+// LoginByMagicLink open session by token from login link,
+// nonce must be the one returned when link was requested.
+// Link replaces only password, user with second factor gets MFAChallengeError.
+func (s auth) LoginByMagicLink(ctx context.Context, token, nonce, ip string) (aToken, rToken string, err error) {
+	if len(nonce) == 0 {
+		s.logger.Warn("login link presented without nonce: ip[%s]", ip)
+		return "", "", ErrInvalidMagicLink
+	}
+
+	t, err := s.tokens.ConsumeBound(ctx, onetime.PurposeMagicLink, token, nonce)
+	if errors.Is(err, onetime.ErrInvalidToken) {
+		return "", "", ErrInvalidMagicLink
+	} else if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+	s.logger.Debug("login link of user[%d] success verified", t.UserID)
+
+	dbUser, err := s.user.GetByID(ctx, t.UserID)
+	if err != nil {
+		err := fmt.Errorf("failed to get user: %w", err)
+		s.logger.Error(err)
+		return "", "", err
+	}
+
+	return s.openSession(ctx, dbUser, ip, model.AMREmail)
+}
+
+// Introspect report state of token for other services (RFC 7662).
+// Invalid, expired or revoked tokens are not an error, they are just inactive.
+// Hint tells which type of token to look up first, the other type is looked up if token isn't found.
+// Refresh tokens without selector can be checked only together with access token, so they are inactive here.
+func (s auth) Introspect(ctx context.Context, token, tokenTypeHint string) (model.Introspection, error) {
+	introspect := []func(context.Context, string) (model.Introspection, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if tokenTypeHint == TokenTypeRefresh {
+		introspect[0], introspect[1] = introspect[1], introspect[0]
+	}
+
+	for _, f := range introspect {
+		res, err := f(ctx, token)
+		if err != nil || res.Active {
+			return res, err
+		}
+	}
+	return model.Introspection{Active: false}, nil
+}
+
+func (s auth) introspectAccessToken(ctx context.Context, token string) (model.Introspection, error) {
+	_, payload, err := s.jwt.VerifyToken(ctx, token)
+	if authmiddleware.IsTokenInvalid(err) {
+		s.logger.Debug("introspected token is invalid: %s", err.Error())
+		return model.Introspection{Active: false}, nil
+	} else if err != nil {
+		err := fmt.Errorf("failed to verify token: %w", err)
+		s.logger.Error(err)
+		return model.Introspection{}, err
+	}
+
+	dbSession, err := s.session.GetByID(ctx, payload.SessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Debug("session of introspected token not exists")
+		return model.Introspection{Active: false}, nil
+	} else if err != nil {
+		err := fmt.Errorf("failed to get session: %w", err)
+		s.logger.Error(err)
+		return model.Introspection{}, err
+	}
+
+	if dbSession.UserID != payload.UserID || dbSession.RevokedAt != 0 {
+		s.logger.Debug("session of introspected token is revoked")
+		return model.Introspection{Active: false}, nil
+	}
+
+	return model.Introspection{
+		Active:    true,
+		TokenType: TokenTypeAccess,
+		Sub:       strconv.Itoa(payload.UserID),
+		Exp:       payload.ExpiresAt.Unix(),
+		Iat:       payload.IssuedAt.Unix(),
+		JTI:       payload.ID,
+		IP:        payload.IP,
+	}, nil
+}
+
+// introspectRefreshToken check refresh token the same way as refresh does, but doesn't rotate it,
+// rotated token is just inactive here, its reuse is detected only on refresh
+func (s auth) introspectRefreshToken(ctx context.Context, token string) (model.Introspection, error) {
+	selector, secret, ok := strings.Cut(token, rTokenSeparator)
+	if !ok || selector == "" || secret == "" || strings.Contains(secret, rTokenSeparator) {
+		return model.Introspection{Active: false}, nil
+	}
+
+	dbSession, err := s.session.GetBySelector(ctx, selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Debug("session of introspected refresh token not exists")
+		return model.Introspection{Active: false}, nil
+	} else if err != nil {
+		err := fmt.Errorf("failed to get session: %w", err)
+		s.logger.Error(err)
+		return model.Introspection{}, err
+	}
+
+	exp := time.Unix(dbSession.CreatedAt, 0).Add(s.cfg.RTokenLifetime)
+	if dbSession.RevokedAt != 0 || !s.rTokenHasher.Compare(dbSession.RTokenHash, secret) || exp.Add(s.cfg.Leeway).Before(time.Now()) {
+		s.logger.Debug("introspected refresh token is invalid, expired or revoked")
+		return model.Introspection{Active: false}, nil
+	}
+
+	return model.Introspection{
+		Active:    true,
+		TokenType: TokenTypeRefresh,
+		Sub:       strconv.Itoa(dbSession.UserID),
+		Exp:       exp.Unix(),
+		Iat:       dbSession.CreatedAt,
+		IP:        dbSession.IP,
+	}, nil
+}
+
+// checkTokenReuse called when jti of access token is not the last issued one.
+// If presented pair was already rotated, somebody replay stolen tokens,
+// so we revoke session (and all tokens issued by it) and notify user.
+func (s auth) checkTokenReuse(ctx context.Context, dbSession model.Session, payload *model.Payload, rT, ip string) error {
+	rotation, err := s.session.GetRotation(ctx, dbSession.ID, payload.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: invalid jti", ErrValidationFailed)
+	} else if err != nil {
+		return fmt.Errorf("failed to get session rotation: %w", err)
+	}
+
+	if !s.rTokenHasher.Compare(rotation.RTokenHash, rT) {
+		return fmt.Errorf("%w: invalid jti", ErrValidationFailed)
+	}
+
+	return s.revokeReusedSession(ctx, dbSession, ip)
+}
+
+// checkSelectorReuse called when selector of refresh token doesn't select session.
+// If it selects rotated token and secret matches, the token was already used.
+func (s auth) checkSelectorReuse(ctx context.Context, selector, secret, ip string) error {
+	rotation, err := s.session.GetRotationBySelector(ctx, selector)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: refresh token is unknown", ErrValidationFailed)
+	} else if err != nil {
+		return fmt.Errorf("failed to get session rotation: %w", err)
+	}
+
+	if !s.rTokenHasher.Compare(rotation.RTokenHash, secret) {
+		return fmt.Errorf("%w: refresh token is unknown", ErrValidationFailed)
+	}
+
+	dbSession, err := s.session.GetByID(ctx, rotation.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return s.revokeReusedSession(ctx, dbSession, ip)
+}
+
+// revokeReusedSession revoke session (and all tokens issued by it) which rotated token was presented for, and notify user
+func (s auth) revokeReusedSession(ctx context.Context, dbSession model.Session, ip string) error {
+	s.securityEvent("refresh token reuse", dbSession.UserID, dbSession.ID, ip)
+
+	if err := s.session.Revoke(ctx, dbSession.ID, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if err := s.revokeAccessToken(ctx, dbSession); err != nil {
+		return err
+	}
+
+	// session is already revoked, so failed notification must not hide reuse from client
+	dbUser, err := s.user.GetByID(ctx, dbSession.UserID)
+	if err != nil {
+		s.logger.Error("failed to get user for reuse notification: %s", err.Error())
+		return ErrTokenReused
+	}
+	if err := s.smtp.SendRefreshTokenReuse(ip, dbUser.Email); err != nil {
+		s.logger.Error("failed to send reuse notification: %s", err.Error())
+	}
+
+	return ErrTokenReused
+}
+
+// revokeAccessToken put last access token issued by session to denylist
+func (s auth) revokeAccessToken(ctx context.Context, session model.Session) error {
+	exp := time.Unix(session.CreatedAt, 0).Add(s.cfg.ATokenLifetime).Add(s.cfg.Leeway)
+	if err := s.denylist.Add(ctx, session.ATokenID, exp); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// securityEvent write incidents which need attention of security team
+func (s auth) securityEvent(event string, uid, sid int, ip string) {
+	s.logger.Warn("[SECURITY] %s: user_id[%d] session_id[%d] ip[%s] geo[%s]", event, uid, sid, ip, s.locate(ip))
+}
+
+// locate return geo of ip, empty without locator or if ip is unknown
+func (s auth) locate(ip string) model.Geo {
+	parsed := net.ParseIP(ip)
+	if s.locator == nil || parsed == nil {
+		return model.Geo{}
+	}
+	geo, _ := s.locator.Locate(parsed)
+	return geo
+}
+
+// rotateSession issue new pair of tokens for existing session
+func (s auth) rotateSession(ctx context.Context, dbSession model.Session, ip string, g Grant) (aToken, rToken string, err error) {
+	iat := time.Now()
+	jti := s.generateUUID()
+
+	rToken, selector, rTokenHash, err := s.createRefreshToken()
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+	s.logger.Debug("refresh token created")
+	if dbSession.RTokenHash != "" && s.rTokenHasher.NeedsRehash(dbSession.RTokenHash) {
+		s.logger.Info("refresh token hash of session[%d] is migrated from %s", dbSession.ID, hashFormat(dbSession.RTokenHash))
+	}
+
+	// version protects from concurrent refresh with the same tokens,
+	// previous tokens are kept to detect their reuse
+	session, err := s.session.Rotate(ctx, model.Session{
+		ID:             dbSession.ID,
+		UserID:         dbSession.UserID,
+		ATokenID:       jti,
+		RTokenSelector: selector,
+		RTokenHash:     rTokenHash,
+		CreatedAt:      iat.Unix(),
+		Version:        dbSession.Version,
+		IP:             ip,
+		AMR:            g.AMR,
+		ClientID:       g.ClientID,
+		Scope:          g.Scope,
+		AuthTime:       g.AuthTime,
+		Geo:            s.locate(ip),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err := fmt.Errorf("%w: session was already refreshed or revoked", ErrValidationFailed)
+		s.logger.Error(err)
+		return "", "", err
+	} else if err != nil {
+		s.logger.Error("failed to rotate session: %s", err.Error())
+		return "", "", err
+	}
+	s.logger.Debug("session success rotated")
+
+	aToken, err = s.createAccessToken(session.UserID, session.ID, ip, g, iat, jti)
+	if err != nil {
+		s.logger.Error(err)
+		return "", "", err
+	}
+	s.logger.Debug("access token created")
+
+	return aToken, rToken, nil
 }
 
-func (s auth) createTokens(uid int, ip string, iat time.Time, jti string) (aToken, rToken string, err error) {
-	aToken, err = s.jwt.CreateToken(model.Payload{
-		UserID: uid,
-		IP:     ip,
+func (s auth) createAccessToken(uid, sid int, ip string, g Grant, iat time.Time, jti string) (string, error) {
+	aToken, err := s.jwt.CreateToken(model.Payload{
+		UserID:    uid,
+		SessionID: sid,
+		IP:        ip,
+		AMR:       g.AMR,
+		ClientID:  g.ClientID,
+		Scope:     g.Scope,
+		AuthTime:  g.AuthTime,
 
 		RegisteredClaims: gjwt.RegisteredClaims{
 			ID:        jti,
 			IssuedAt:  gjwt.NewNumericDate(iat),
-			ExpiresAt: gjwt.NewNumericDate(iat.Add(ATokenLifetime)),
+			ExpiresAt: gjwt.NewNumericDate(iat.Add(s.cfg.ATokenLifetime)),
 		},
 	})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create access token: %w", err)
+		return "", fmt.Errorf("failed to create access token: %w", err)
 	}
+	return aToken, nil
+}
 
-	rToken, err = s.randString()
+// createRefreshToken make token <selector>.<secret>, selector finds session by index, only secret is hashed
+func (s auth) createRefreshToken() (rToken, selector, rTokenHash string, err error) {
+	selector, err = s.randSelector()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create refresh token: %w", err)
+		return "", "", "", fmt.Errorf("failed to create refresh token selector: %w", err)
 	}
 
-	return aToken, rToken, nil
+	secret, err := s.randString()
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	rTokenHash, err = s.rTokenHasher.Hash(secret)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to hash refresh token: %w", err)
+	}
+
+	return selector + rTokenSeparator + secret, selector, rTokenHash, nil
+}
+
+// deliver run issue and send of emailed token of user in background and log its result.
+// Request context is done as soon as response is written, so only its values are kept.
+func (s auth) deliver(ctx context.Context, what string, uid int, send func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deliveryTimeout)
+	run := func() {
+		defer cancel()
+		if err := send(ctx); err != nil {
+			s.logger.Error(fmt.Errorf("failed to send %s to user[%d]: %w", what, uid, err))
+			return
+		}
+		s.logger.Info("%s sent to user[%d]", what, uid)
+	}
+
+	// tests check calls of mocks when function returns
+	if s.testMode {
+		run()
+		return
+	}
+	go run()
 }
 
 func (s auth) generateUUID() string {
@@ -222,7 +1118,7 @@ func (s auth) randString() (string, error) {
 		return "rand_string", nil
 	}
 
-	str := make([]byte, 52)
+	str := make([]byte, s.cfg.RTokenBytes)
 	if _, err := rand.Read(str); err != nil {
 		return "", err
 	}
@@ -230,14 +1126,16 @@ func (s auth) randString() (string, error) {
 	return base64.URLEncoding.EncodeToString(str), nil
 }
 
-func (s auth) hashString(str string) (string, error) {
-	hashedtoken, err := bcrypt.GenerateFromPassword([]byte(str), bcrypt.DefaultCost)
-	if err != nil {
+// randSelector return random selector, unpadded, so it never contains separator
+func (s auth) randSelector() (string, error) {
+	if s.testMode {
+		return "selector", nil
+	}
+
+	selector := make([]byte, rTokenSelectorBytes)
+	if _, err := rand.Read(selector); err != nil {
 		return "", err
 	}
-	return string(hashedtoken), nil
-}
 
-func CompareHash(hash, plain string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
+	return base64.RawURLEncoding.EncodeToString(selector), nil
 }
@@ -5,12 +5,20 @@ import (
 	"database/sql"
 	"fmt"
 	"medods/internal/model"
+	mock_denylist "medods/internal/service/denylist/mock"
 	mock_jwt "medods/internal/service/jwt/mock"
+	"medods/internal/service/mfa"
+	mock_mfa "medods/internal/service/mfa/mock"
+	"medods/internal/service/onetime"
+	mock_onetime "medods/internal/service/onetime/mock"
 	mock_session "medods/internal/service/session/mock"
+	userService "medods/internal/service/user"
 	mock_user "medods/internal/service/user/mock"
 	"medods/pkg/logger"
 	mock_smtp "medods/pkg/smtp/mock"
+	"net"
 	"reflect"
+	"strings"
 	"time"
 
 	"testing"
@@ -18,8 +26,40 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var defaultConfig = &Config{
+	ATokenLifetime: 30 * time.Minute,
+	RTokenLifetime: 30 * 24 * time.Hour,
+	RTokenBytes:    52,
+
+	PasswordResetURL:           "http://localhost:8080/password/reset",
+	PasswordResetTokenLifetime: 15 * time.Minute,
+	MagicLinkURL:               "http://localhost:8080/api/v1/auth/magic-link/consume",
+	MagicLinkTokenLifetime:     10 * time.Minute,
+
+	IPPolicy: IPPolicy{
+		Default:      IPNotify,
+		SameSubnet:   IPNotify,
+		SubnetBitsV4: 24,
+		SubnetBitsV6: 64,
+		SameCountry:  IPNotify,
+		KnownIP:      IPNotify,
+	},
+}
+
+var defaultTokenHasher = NewBcryptTokenHasher(bcrypt.MinCost, nil)
+
+// cheap parameters, hashing cost is not a subject of tests
+var defaultPasswordHasher = NewPasswordHasher(&PasswordConfig{
+	Memory:      64,
+	Time:        1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+})
+
 type sessionMatcher struct {
 	session         model.Session
 	compareHashFunc func(hash, plain string) bool
@@ -42,6 +82,9 @@ func (m sessionMatcher) Matches(x interface{}) bool {
 	if m.session.ATokenID != "" && m.session.ATokenID != input.ATokenID {
 		return false
 	}
+	if m.session.RTokenSelector != "" && m.session.RTokenSelector != input.RTokenSelector {
+		return false
+	}
 	// use for compare non hashed token
 	if m.session.RTokenHash != "" && !m.compareHashFunc(input.RTokenHash, m.session.RTokenHash) {
 		return false
@@ -52,6 +95,18 @@ func (m sessionMatcher) Matches(x interface{}) bool {
 	if m.session.Version != 0 && m.session.Version != input.Version {
 		return false
 	}
+	if m.session.IP != "" && m.session.IP != input.IP {
+		return false
+	}
+	if m.session.AMR != nil && !reflect.DeepEqual(m.session.AMR, input.AMR) {
+		return false
+	}
+	if m.session.ClientID != input.ClientID || m.session.Scope != input.Scope {
+		return false
+	}
+	if m.session.AuthTime != 0 && m.session.AuthTime != input.AuthTime {
+		return false
+	}
 
 	return true
 }
@@ -73,9 +128,21 @@ func (m payloadMatcher) Matches(x interface{}) bool {
 	if m.payload.UserID > 0 && m.payload.UserID != input.UserID {
 		return false
 	}
+	if m.payload.SessionID > 0 && m.payload.SessionID != input.SessionID {
+		return false
+	}
 	if m.payload.IP != "" && m.payload.IP != input.IP {
 		return false
 	}
+	if m.payload.AMR != nil && !reflect.DeepEqual(m.payload.AMR, input.AMR) {
+		return false
+	}
+	if m.payload.ClientID != input.ClientID || m.payload.Scope != input.Scope {
+		return false
+	}
+	if m.payload.AuthTime > 0 && m.payload.AuthTime != input.AuthTime {
+		return false
+	}
 	if m.payload.RegisteredClaims.ID != "" && m.payload.RegisteredClaims.ID != input.RegisteredClaims.ID {
 		return false
 	}
@@ -93,25 +160,28 @@ func TestCreateSession(t *testing.T) {
 	sessionService := mock_session.NewMockInterface(ctrl)
 	user := mock_user.NewMockInterface(ctrl)
 	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
 	logger := logger.New("debug", true)
 	smtp := mock_smtp.NewMockInterface(ctrl)
 
-	auth := New(sessionService, user, jwtMaker, smtp, logger, true)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, user, jwtMaker, denylistService, smtp, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
 
 	defaultATokenID := auth.generateUUID()            // means than uuid always generate than string when testMode is truw
 	defaultRTokenRandString, err := auth.randString() // like uuid
 	assert.NoError(t, err)
 
 	defaultAToken := "access_token"
-	defaultRToken := "rand_string"
-
-	iat := time.Now().Add(-1 * time.Minute)
+	defaultRToken := "selector.rand_string"
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
 	type args struct {
 		uid int
 		ip  string
+		amr []string
 	}
 
 	defaultIP := "2"
@@ -121,6 +191,8 @@ func TestCreateSession(t *testing.T) {
 		ip:  defaultIP,
 	}
 
+	defaultSessionID := 3
+
 	tc := []struct {
 		name        string
 		input       args
@@ -128,35 +200,27 @@ func TestCreateSession(t *testing.T) {
 		checkResult func(t *testing.T, aToken, rToken string, err error)
 	}{
 		{
-			name:  "OK with existing",
+			name:  "OK",
 			input: defaultInput,
 			buildStubs: func() {
+				checkCreateInput := model.Session{
+					UserID:         defaultInput.uid,
+					ATokenID:       defaultATokenID,
+					RTokenSelector: "selector",
+					RTokenHash:     defaultRTokenRandString, // use rand_string for compareHash
+					IP:             defaultInput.ip,
+				}
+
+				sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{checkCreateInput, defaultTokenHasher.Compare}).Times(1).
+					Return(model.Session{ID: defaultSessionID, UserID: defaultInput.uid}, nil)
+
 				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
 					UserID:           defaultInput.uid,
+					SessionID:        defaultSessionID, // note: sid of created session
 					IP:               defaultInput.ip,
 					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
 				}}).Times(1).Return(defaultAToken, nil)
-
-				dbSession := model.Session{
-					ID:         1,
-					UserID:     1,
-					ATokenID:   "other",
-					RTokenHash: "other",
-					CreatedAt:  iat.Unix(),
-					Version:    1,
-				}
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultInput.uid)).Times(1).
-					Return(dbSession, nil)
-
-				dbSession.ATokenID = defaultATokenID
-				dbSession.RTokenHash = defaultRTokenRandString // use rand_string for compareHash
-				dbSession.CreatedAt = 0                        // don't check time
-
-				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
-
-				sessionService.EXPECT().Update(gomock.Any(), sessionMatcher{dbSession, CompareHash}).Times(1).
-					Return(model.Session{}, nil) // first field is not matter cause we use '_, err'
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.NoError(t, err)
@@ -165,90 +229,64 @@ func TestCreateSession(t *testing.T) {
 			},
 		},
 		{
-			name:  "update session error",
-			input: defaultInput,
+			name: "with amr",
+			input: args{
+				uid: defaultInput.uid,
+				ip:  defaultInput.ip,
+				amr: []string{model.AMRHardwareKey, model.AMRMFA}, // note
+			},
 			buildStubs: func() {
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					Return(model.Session{ID: defaultSessionID, UserID: defaultInput.uid}, nil)
+
 				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
 					UserID:           defaultInput.uid,
+					SessionID:        defaultSessionID,
 					IP:               defaultInput.ip,
+					AMR:              []string{model.AMRHardwareKey, model.AMRMFA},
 					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
 				}}).Times(1).Return(defaultAToken, nil)
-
-				dbSession := model.Session{
-					ID:         1,
-					UserID:     1,
-					ATokenID:   "other",
-					RTokenHash: "other",
-					CreatedAt:  iat.Unix(),
-					Version:    1,
-				}
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultInput.uid)).Times(1).
-					Return(dbSession, nil)
-
-				dbSession.ATokenID = defaultATokenID
-				dbSession.RTokenHash = defaultRTokenRandString // use rand_string for compareHash
-				dbSession.CreatedAt = 0                        // don't check time
-
-				sessionService.EXPECT().Update(gomock.Any(), sessionMatcher{dbSession, CompareHash}).Times(1).
-					Return(model.Session{}, unexpectedError) // return unexpected error
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
-				assert.Error(t, err)
-				assert.ErrorIs(t, err, unexpectedError)
-				assert.Empty(t, aToken)
-				assert.Empty(t, rToken)
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
 			},
 		},
 		{
-			name:  "OK with new",
+			name:  "create session error",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
-					UserID:           defaultInput.uid,
-					IP:               defaultInput.ip,
-					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
-				}}).Times(1).Return(defaultAToken, nil)
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultInput.uid)).Times(1).
-					Return(model.Session{}, sql.ErrNoRows)
-
 				checkCreateInput := model.Session{
-					UserID:     1,
+					UserID:     defaultInput.uid,
 					ATokenID:   defaultATokenID,
 					RTokenHash: defaultRTokenRandString,
 				}
 
-				sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
-				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{checkCreateInput, CompareHash}).Times(1).Return(nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{checkCreateInput, defaultTokenHasher.Compare}).Times(1).
+					Return(model.Session{}, unexpectedError)
+
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
-				assert.NoError(t, err)
-				assert.Equal(t, defaultAToken, aToken)
-				assert.Equal(t, defaultRToken, rToken)
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
 			},
 		},
 		{
-			name:  "create session error",
+			name:  "jwt create token error",
 			input: defaultInput,
 			buildStubs: func() {
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					Return(model.Session{ID: defaultSessionID, UserID: defaultInput.uid}, nil)
+
 				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
 					UserID:           defaultInput.uid,
+					SessionID:        defaultSessionID,
 					IP:               defaultInput.ip,
 					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
-				}}).Times(1).Return(defaultAToken, nil)
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultInput.uid)).Times(1).
-					Return(model.Session{}, sql.ErrNoRows)
-
-				checkCreateInput := model.Session{
-					UserID:     1,
-					ATokenID:   defaultATokenID,
-					RTokenHash: defaultRTokenRandString,
-				}
-
-				sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
-				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{checkCreateInput, CompareHash}).Times(1).Return(unexpectedError)
+				}}).Times(1).Return("", unexpectedError)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -257,45 +295,138 @@ func TestCreateSession(t *testing.T) {
 				assert.Empty(t, rToken)
 			},
 		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			aT, rT, err := auth.CreateSession(context.Background(), test.input.uid, test.input.ip, test.input.amr...)
+			test.checkResult(t, aT, rT, err)
+		})
+	}
+}
+
+func TestCreateClientSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	user := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtp := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, user, jwtMaker, denylistService, smtp, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultAToken := "access_token"
+
+	// note: grant is kept in session, so refresh doesn't need access token
+	sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{model.Session{
+		UserID:   1,
+		IP:       "2",
+		AMR:      []string{model.AMRPassword},
+		ClientID: "client",
+		Scope:    "openid email",
+		AuthTime: 1600000000,
+	}, defaultTokenHasher.Compare}).Times(1).
+		Return(model.Session{ID: 3, UserID: 1}, nil)
+
+	// client, scope and auth time are kept in token, so refresh can check them
+	jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+		UserID:    1,
+		SessionID: 3,
+		IP:        "2",
+		AMR:       []string{model.AMRPassword},
+		ClientID:  "client",
+		Scope:     "openid email",
+		AuthTime:  1600000000,
+	}}).Times(1).Return(defaultAToken, nil)
+
+	aToken, rToken, err := auth.CreateClientSession(context.Background(), 1, "2", Grant{
+		AMR:      []string{model.AMRPassword},
+		ClientID: "client",
+		Scope:    "openid email",
+		AuthTime: 1600000000,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAToken, aToken)
+	assert.Equal(t, "selector.rand_string", rToken)
+}
+
+func TestCreateSessionRequireVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	cfg := *defaultConfig
+	cfg.RequireVerifiedEmail = true
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, &cfg, logger, true)
+
+	defaultUser := model.User{
+		ID:              1,
+		Email:           "user@gmail.com",
+		EmailVerifiedAt: 2,
+	}
+
+	defaultIP := "::1"
+	defaultAToken := "access_token"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, aToken, rToken string, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultUser.ID}}).Times(1).
+					Return(model.Session{ID: 3, UserID: defaultUser.ID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{UserID: defaultUser.ID, SessionID: 3, IP: defaultIP}}).Times(1).
+					Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
 		{
-			name:  "get session by user id unexpected error",
-			input: defaultInput,
+			name: "error email not verified",
 			buildStubs: func() {
-				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
-					UserID:           defaultInput.uid,
-					IP:               defaultInput.ip,
-					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
-				}}).Times(1).Return(defaultAToken, nil)
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultInput.uid)).Times(1).
-					Return(model.Session{}, unexpectedError)
+				df := defaultUser
+				df.EmailVerifiedAt = 0 // note: not verified
 
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(df, nil)
 				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
-				sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
-				assert.Error(t, err)
-				assert.ErrorIs(t, err, unexpectedError)
+				assert.ErrorIs(t, err, ErrEmailNotVerified)
 				assert.Empty(t, aToken)
 				assert.Empty(t, rToken)
 			},
 		},
 		{
-			name:  "jwt create token error",
-			input: defaultInput,
+			name: "unexpected error",
 			buildStubs: func() {
-				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
-					UserID:           defaultInput.uid,
-					IP:               defaultInput.ip,
-					RegisteredClaims: jwt.RegisteredClaims{ID: defaultATokenID},
-				}}).Times(1).Return("", unexpectedError)
-
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Any()).Times(0)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(model.User{}, unexpectedError)
 				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
-				sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
-				assert.Error(t, err)
 				assert.ErrorIs(t, err, unexpectedError)
 				assert.Empty(t, aToken)
 				assert.Empty(t, rToken)
@@ -306,42 +437,53 @@ func TestCreateSession(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			aT, rT, err := auth.CreateSession(context.Background(), test.input.uid, test.input.ip)
+			aT, rT, err := auth.CreateSession(context.Background(), defaultUser.ID, defaultIP)
 			test.checkResult(t, aT, rT, err)
 		})
 	}
 }
 
-func TestRefreshSession(t *testing.T) {
+func TestRefreshLegacySession(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	sessionService := mock_session.NewMockInterface(ctrl)
 	userService := mock_user.NewMockInterface(ctrl)
 	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
 	logger := logger.New("debug", true)
 	smtpService := mock_smtp.NewMockInterface(ctrl)
 
-	auth := New(sessionService, userService, jwtMaker, smtpService, logger, true)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
 
 	defaultATokenID := auth.generateUUID()
 	defaultRTokenRandString, err := auth.randString()
 	assert.NoError(t, err)
 
 	defaultAToken := "access_token"
+	// note: refresh token without selector, issued before them
 	defaultRToken := "rand_string"
+	defaultNewRToken := "selector.rand_string"
 
 	defaultMail := "mock@gmail.com"
 
 	iat := time.Now().Add(-1 * time.Minute)
-	exp := iat.Add(ATokenLifetime)
+	exp := iat.Add(defaultConfig.ATokenLifetime)
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
 	defaultIP := "::1"
 
 	defaultPayload := model.Payload{
-		UserID: 1,
-		IP:     defaultIP,
+		UserID:    1,
+		SessionID: 1,
+		IP:        defaultIP,
+		AMR:       []string{model.AMRPassword, model.AMROTP, model.AMRMFA},
+		ClientID:  "client",
+		Scope:     "openid email",
+		AuthTime:  1600000000,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 			IssuedAt:  jwt.NewNumericDate(iat),
@@ -349,7 +491,7 @@ func TestRefreshSession(t *testing.T) {
 		},
 	}
 
-	defaultRTokenHash, err := auth.hashString(defaultRTokenRandString)
+	defaultRTokenHash, err := defaultTokenHasher.Hash(defaultRTokenRandString)
 	assert.NoError(t, err)
 
 	defaultSession := model.Session{
@@ -361,22 +503,35 @@ func TestRefreshSession(t *testing.T) {
 		Version:    1,
 	}
 
-	callCreateSession := func(uid int, ip string, aTID, rTHash string) {
-		jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
-			UserID: uid,
-			IP:     ip,
-		}}).Times(1).Return(defaultAToken, nil)
-		sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(uid)).Times(1).
-			Return(model.Session{}, sql.ErrNoRows)
-
-		checkCreateInput := model.Session{
-			UserID:     1,
-			ATokenID:   aTID,
-			RTokenHash: rTHash,
+	callRotateSession := func(uid, sid int, ip string, aTID, rTHash string) {
+		// note: session gets selector and grant of access token
+		checkUpdateInput := model.Session{
+			ID:             sid,
+			UserID:         uid,
+			ATokenID:       aTID,
+			RTokenSelector: "selector",
+			RTokenHash:     rTHash,
+			Version:        defaultSession.Version,
+			IP:             ip,
+			AMR:            defaultPayload.AMR,
+			ClientID:       defaultPayload.ClientID,
+			Scope:          defaultPayload.Scope,
+			AuthTime:       defaultPayload.AuthTime,
 		}
 
-		sessionService.EXPECT().Update(gomock.Any(), gomock.Any()).Times(0)
-		sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{checkCreateInput, CompareHash}).Times(1).Return(nil)
+		sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+		sessionService.EXPECT().Rotate(gomock.Any(), sessionMatcher{checkUpdateInput, defaultTokenHasher.Compare}).Times(1).
+			Return(model.Session{ID: sid, UserID: uid, Version: defaultSession.Version + 1}, nil)
+
+		// note: factors and client of session are kept on refresh
+		jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+			UserID:    uid,
+			SessionID: sid,
+			IP:        ip,
+			AMR:       defaultPayload.AMR,
+			ClientID:  defaultPayload.ClientID,
+			Scope:     defaultPayload.Scope,
+		}}).Times(1).Return(defaultAToken, nil)
 	}
 
 	type args struct {
@@ -401,38 +556,38 @@ func TestRefreshSession(t *testing.T) {
 			name:  "OK",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
 
-				callCreateSession(defaultPayload.UserID, defaultPayload.IP, defaultATokenID, defaultRTokenRandString) // use rand_string cause it's a plain of compare func
+				callRotateSession(defaultPayload.UserID, defaultPayload.SessionID, defaultPayload.IP, defaultATokenID, defaultRTokenRandString) // use rand_string cause it's a plain of compare func
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.NoError(t, err)
 				assert.Equal(t, defaultAToken, aToken)
-				assert.Equal(t, defaultRToken, rToken)
+				assert.Equal(t, defaultNewRToken, rToken)
 			},
 		},
 		{
 			name:  "OK with expired aToken",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, jwt.ErrTokenExpired) // note: expired token
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, jwt.ErrTokenExpired) // note: expired token
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
 
-				callCreateSession(defaultPayload.UserID, defaultPayload.IP, defaultATokenID, defaultRTokenRandString) // use rand_string cause it's a plain of compare func
+				callRotateSession(defaultPayload.UserID, defaultPayload.SessionID, defaultPayload.IP, defaultATokenID, defaultRTokenRandString) // use rand_string cause it's a plain of compare func
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.NoError(t, err)
 				assert.Equal(t, defaultAToken, aToken)
-				assert.Equal(t, defaultRToken, rToken)
+				assert.Equal(t, defaultNewRToken, rToken)
 			},
 		},
 		{
 			name:  "error verify token",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, unexpectedError)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Any()).Times(0)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, unexpectedError)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -442,11 +597,11 @@ func TestRefreshSession(t *testing.T) {
 			},
 		},
 		{
-			name:  "error unexpected get session by user id",
+			name:  "error unexpected get session by id",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(model.Session{}, unexpectedError)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(model.Session{}, unexpectedError)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -456,11 +611,11 @@ func TestRefreshSession(t *testing.T) {
 			},
 		},
 		{
-			name:  "error no exists get session by user id",
+			name:  "error no exists get session by id",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(model.Session{}, sql.ErrNoRows)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -469,16 +624,67 @@ func TestRefreshSession(t *testing.T) {
 				assert.Empty(t, rToken)
 			},
 		},
+		{
+			name:  "error session belongs to other user",
+			input: defaultInput,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+
+				cpSession := defaultSession
+				cpSession.UserID = defaultPayload.UserID + 1
+
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error session already refreshed",
+			input: defaultInput,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).Return(model.Session{}, sql.ErrNoRows) // note: version changed
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error unexpected rotate session",
+			input: defaultInput,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).Return(model.Session{}, unexpectedError)
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
 		{
 			name:  "error compare different access iat and db iat times",
 			input: defaultInput,
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
 
 				copySession := defaultSession
 				copySession.CreatedAt = iat.Add(1 * time.Minute).Unix()
 
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(copySession, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(copySession, nil)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -490,19 +696,19 @@ func TestRefreshSession(t *testing.T) {
 			name:  "error compare refresh token expired",
 			input: defaultInput,
 			buildStubs: func() {
-				iat := time.Now().Add(-RTokenLifeTime).Add(-1 * time.Minute) // iat more than refresh token life
-				accessExp := iat.Add(ATokenLifetime)
+				iat := time.Now().Add(-defaultConfig.RTokenLifetime).Add(-1 * time.Minute) // iat more than refresh token life
+				accessExp := iat.Add(defaultConfig.ATokenLifetime)
 
 				cpPayload := defaultPayload
 				cpPayload.IssuedAt = jwt.NewNumericDate(iat)
 				cpPayload.ExpiresAt = jwt.NewNumericDate(accessExp)
 
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
 
 				cpSession := defaultSession
 				cpSession.CreatedAt = iat.Unix()
 
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(cpSession, nil)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -519,8 +725,8 @@ func TestRefreshSession(t *testing.T) {
 				ip:     defaultIP,
 			},
 			buildStubs: func() {
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
@@ -535,78 +741,2495 @@ func TestRefreshSession(t *testing.T) {
 				cpPayload := defaultPayload
 				cpPayload.ID = "other"
 
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{}, sql.ErrNoRows) // note: jti never was issued for session
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.NotErrorIs(t, err, ErrTokenReused)
 				assert.Empty(t, aToken)
 				assert.Empty(t, rToken)
 			},
 		},
 		{
-			name:  "OK login from new ip",
+			name:  "error revoked session",
 			input: defaultInput,
 			buildStubs: func() {
-				cpPayload := defaultPayload
-				cpPayload.IP = "::2"
-
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)                       // note return ip 'other'
-				sessionService.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(defaultSession, nil) // note return default ip
-
-				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).
-					Return(model.User{ID: cpPayload.UserID, Email: defaultMail}, nil)
-				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq(cpPayload.IP), gomock.Eq(defaultMail)).Times(1).Return(nil)
+				cpSession := defaultSession
+				cpSession.RevokedAt = iat.Unix()
 
-				callCreateSession(cpPayload.UserID, cpPayload.IP, defaultATokenID, defaultRTokenRandString)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
-				assert.NoError(t, err)
-				assert.Equal(t, defaultAToken, aToken)
-				assert.Equal(t, defaultRToken, rToken)
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrSessionRevoked)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
 			},
 		},
 		{
-			name:  "error unexpected get user by id",
+			name:  "error reuse of rotated tokens",
 			input: defaultInput,
 			buildStubs: func() {
 				cpPayload := defaultPayload
-				cpPayload.IP = "::2"
-
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil) // note return ip 'other'
-				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(model.User{}, unexpectedError)
+				cpPayload.ID = "old_jti"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{
+						SessionID:  defaultSession.ID,
+						ATokenID:   cpPayload.ID,
+						RTokenHash: defaultRTokenHash, // note: presented refresh token was already rotated
+					}, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq(defaultSession.ATokenID), gomock.Any()).Times(1).Return(nil) // note: last issued access token
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendRefreshTokenReuse(gomock.Eq(defaultIP), gomock.Eq(defaultMail)).Times(1).Return(nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrTokenReused)
+				assert.ErrorIs(t, err, ErrValidationFailed)
 				assert.Empty(t, aToken)
 				assert.Empty(t, rToken)
 			},
 		},
 		{
-			name:  "error unexpected send email",
+			name:  "error reuse of rotated tokens with failed notification",
 			input: defaultInput,
 			buildStubs: func() {
 				cpPayload := defaultPayload
-				cpPayload.IP = "::2"
+				cpPayload.ID = "old_jti"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{RTokenHash: defaultRTokenHash}, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq(defaultSession.ATokenID), gomock.Any()).Times(1).Return(nil) // note: last issued access token
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendRefreshTokenReuse(gomock.Eq(defaultIP), gomock.Eq(defaultMail)).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrTokenReused) // note: reuse is reported even if mail is not sent
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name: "error old jti with other refresh token",
+			input: args{
+				aToken: defaultAToken,
+				rToken: "other",
+				ip:     defaultIP,
+			},
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ID = "old_jti"
 
-				jwtMaker.EXPECT().VerifyToken(gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil) // note return ip 'other'
-				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).
-					Return(model.User{ID: cpPayload.UserID, Email: defaultMail}, nil)
-				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq(cpPayload.IP), gomock.Eq(defaultMail)).Times(1).Return(unexpectedError)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{RTokenHash: defaultRTokenHash}, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResult: func(t *testing.T, aToken, rToken string, err error) {
 				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.NotErrorIs(t, err, ErrTokenReused)
 				assert.Empty(t, aToken)
 				assert.Empty(t, rToken)
 			},
 		},
-	}
+		{
+			name:  "error unexpected get rotation",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ID = "old_jti"
 
-	for _, test := range tc {
-		t.Run(test.name, func(t *testing.T) {
-			test.buildStubs()
-			aT, rT, err := auth.RefreshSession(context.Background(), test.input.aToken, test.input.rToken, test.input.ip)
-			test.checkResult(t, aT, rT, err)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error unexpected revoke session",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ID = "old_jti"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().GetRotation(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Eq(cpPayload.ID)).Times(1).
+					Return(model.SessionRotation{RTokenHash: defaultRTokenHash}, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.Error(t, err)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "OK login from new ip",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.IP = "::2"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)        // note return ip 'other'
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.SessionID)).Times(1).Return(defaultSession, nil) // note return default ip
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return([]model.Session{defaultSession}, nil)
+
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).
+					Return(model.User{ID: cpPayload.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq(defaultIP), gomock.Eq(""), gomock.Eq(defaultMail)).Times(1).Return(nil)
+
+				// note: session moves to ip of refresh
+				callRotateSession(cpPayload.UserID, cpPayload.SessionID, defaultIP, defaultATokenID, defaultRTokenRandString)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name:  "OK user not notified about new ip",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.IP = "::2"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil) // note return ip 'other'
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(nil, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(model.User{}, unexpectedError)
+
+				// note: failed notification doesn't fail refresh
+				callRotateSession(cpPayload.UserID, cpPayload.SessionID, defaultIP, defaultATokenID, defaultRTokenRandString)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name:  "OK email about new ip not sent",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.IP = "::2"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil) // note return ip 'other'
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(nil, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).
+					Return(model.User{ID: cpPayload.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq(defaultIP), gomock.Eq(""), gomock.Eq(defaultMail)).Times(1).Return(unexpectedError)
+
+				callRotateSession(cpPayload.UserID, cpPayload.SessionID, defaultIP, defaultATokenID, defaultRTokenRandString)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name:  "error unexpected list sessions of user",
+			input: defaultInput,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.IP = "::2"
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil) // note return ip 'other'
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(cpPayload.SessionID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(cpPayload.UserID)).Times(1).Return(nil, unexpectedError)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			// note: session of client is refreshed only by token endpoint
+			aT, rT, err := auth.RefreshClientSession(context.Background(), test.input.aToken, test.input.rToken, test.input.ip, defaultPayload.ClientID, "")
+			test.checkResult(t, aT, rT, err)
+		})
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultATokenID := auth.generateUUID()
+	defaultSecret, err := auth.randString()
+	assert.NoError(t, err)
+	defaultSelector := "old_selector"
+
+	defaultAToken := "access_token"
+	defaultRToken := defaultSelector + "." + defaultSecret
+	defaultNewRToken := "selector.rand_string"
+
+	defaultMail := "mock@gmail.com"
+	defaultIP := "::1"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	defaultRTokenHash, err := defaultTokenHasher.Hash(defaultSecret)
+	assert.NoError(t, err)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         1,
+		ATokenID:       defaultATokenID,
+		RTokenSelector: defaultSelector,
+		RTokenHash:     defaultRTokenHash,
+		CreatedAt:      time.Now().Add(-1 * time.Minute).Unix(),
+		Version:        1,
+		IP:             defaultIP,
+		AMR:            []string{model.AMRPassword, model.AMROTP, model.AMRMFA},
+		ClientID:       "client",
+		Scope:          "openid email",
+		AuthTime:       1600000000,
+	}
+
+	callRotateSession := func(ip string) {
+		// note: grant is taken from session, access token isn't needed
+		sessionService.EXPECT().Rotate(gomock.Any(), sessionMatcher{model.Session{
+			ID:             defaultSession.ID,
+			UserID:         defaultSession.UserID,
+			ATokenID:       defaultATokenID,
+			RTokenSelector: "selector",
+			RTokenHash:     defaultSecret,
+			Version:        defaultSession.Version,
+			IP:             ip,
+			AMR:            defaultSession.AMR,
+			ClientID:       defaultSession.ClientID,
+			Scope:          defaultSession.Scope,
+			AuthTime:       defaultSession.AuthTime,
+		}, defaultTokenHasher.Compare}).Times(1).
+			Return(model.Session{ID: defaultSession.ID, UserID: defaultSession.UserID, Version: defaultSession.Version + 1}, nil)
+
+		jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+			UserID:    defaultSession.UserID,
+			SessionID: defaultSession.ID,
+			IP:        ip,
+			AMR:       defaultSession.AMR,
+			ClientID:  defaultSession.ClientID,
+			Scope:     defaultSession.Scope,
+			AuthTime:  defaultSession.AuthTime,
+		}}).Times(1).Return(defaultAToken, nil)
+	}
+
+	type args struct {
+		aToken string
+		rToken string
+		ip     string
+	}
+
+	defaultInput := args{
+		rToken: defaultRToken,
+		ip:     defaultIP,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, aToken, rToken string, err error)
+	}{
+		{
+			name:  "OK without access token",
+			input: defaultInput,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(0)
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(defaultSession, nil)
+				callRotateSession(defaultIP)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name: "OK access token is ignored",
+			input: args{
+				aToken: "lost_or_broken", // note
+				rToken: defaultRToken,
+				ip:     defaultIP,
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(0)
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(defaultSession, nil)
+				callRotateSession(defaultIP)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name: "OK login from new ip",
+			input: args{
+				rToken: defaultRToken,
+				ip:     "::2", // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).Return([]model.Session{defaultSession}, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq("::2"), gomock.Eq(""), gomock.Eq(defaultMail)).Times(1).Return(nil)
+				callRotateSession("::2") // note: session moves to ip of refresh
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNewRToken, rToken)
+			},
+		},
+		{
+			name: "error empty selector",
+			input: args{
+				rToken: "." + defaultSecret, // note: would select sessions without selector
+				ip:     defaultIP,
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name: "error wrong secret",
+			input: args{
+				rToken: defaultSelector + ".other", // note
+				ip:     defaultIP,
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error revoked session",
+			input: defaultInput,
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.RevokedAt = time.Now().Unix()
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrSessionRevoked)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error refresh token expired",
+			input: defaultInput,
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.CreatedAt = time.Now().Add(-defaultConfig.RTokenLifetime).Add(-1 * time.Minute).Unix()
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error unexpected get session by selector",
+			input: defaultInput,
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(model.Session{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error unknown selector",
+			input: defaultInput,
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).
+					Return(model.SessionRotation{}, sql.ErrNoRows) // note: selector never was issued
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.NotErrorIs(t, err, ErrTokenReused)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error reuse of rotated token",
+			input: defaultInput,
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).
+					Return(model.SessionRotation{
+						SessionID:      defaultSession.ID,
+						RTokenSelector: defaultSelector,
+						RTokenHash:     defaultRTokenHash, // note: presented refresh token was already rotated
+					}, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.ID)).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultSession.ID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq(defaultSession.ATokenID), gomock.Any()).Times(1).Return(nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendRefreshTokenReuse(gomock.Eq(defaultIP), gomock.Eq(defaultMail)).Times(1).Return(nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrTokenReused)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name: "error rotated selector with other secret",
+			input: args{
+				rToken: defaultSelector + ".other", // note
+				ip:     defaultIP,
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq(defaultSelector)).Times(1).
+					Return(model.SessionRotation{SessionID: defaultSession.ID, RTokenHash: defaultRTokenHash}, nil)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				assert.NotErrorIs(t, err, ErrTokenReused)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			aT, rT, err := auth.RefreshClientSession(context.Background(), test.input.aToken, test.input.rToken, test.input.ip, defaultSession.ClientID, "")
+			test.checkResult(t, aT, rT, err)
+		})
+	}
+}
+
+// mapLocator is Locator of fixed ips
+type mapLocator map[string]model.Geo
+
+func (l mapLocator) Locate(ip net.IP) (model.Geo, bool) {
+	geo, ok := l[ip.String()]
+	return geo, ok
+}
+
+func TestRefreshSessionIPPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	_, office, err := net.ParseCIDR("203.0.113.0/24")
+	assert.NoError(t, err)
+
+	cfg := *defaultConfig
+	cfg.IPPolicy = IPPolicy{
+		TrustedNetworks:   []*net.IPNet{office},
+		Default:           IPDeny,
+		SameSubnet:        IPAllow,
+		SubnetBitsV4:      24,
+		SubnetBitsV6:      64,
+		SameCountry:       IPNotify,
+		KnownIP:           IPAllow,
+		RapidChangeWindow: time.Minute,
+		RapidChange:       IPStepUp,
+		MaxTravelSpeed:    1000,
+		ImpossibleTravel:  IPStepUp,
+	}
+	locator := mapLocator{
+		"10.0.0.1":    {Country: "RU", City: "Moscow", Location: &model.Location{Latitude: 55.7558, Longitude: 37.6173}},
+		"172.16.0.1":  {Country: "RU", City: "Kazan", ASN: 64500, ASOrg: "Example Telecom"},
+		"192.168.0.1": {Country: "US"},
+		"2001:db8::1": {Country: "US", City: "New York", Location: &model.Location{Latitude: 40.7128, Longitude: -74.006}},
+	}
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	auth := New(sessionService, userService, jwtMaker, nil, smtpService, defaultPasswordHasher, defaultTokenHasher, nil, nil, locator, &cfg, logger, true)
+
+	defaultRTokenHash, err := defaultTokenHasher.Hash("rand_string")
+	assert.NoError(t, err)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         1,
+		RTokenSelector: "old_selector",
+		RTokenHash:     defaultRTokenHash,
+		CreatedAt:      time.Now().Add(-1 * time.Hour).Unix(),
+		Version:        1,
+		IP:             "10.0.0.1",
+	}
+	defaultMail := "mock@gmail.com"
+
+	callRotateSession := func() {
+		sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+		jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(1).Return("access_token", nil)
+	}
+
+	tc := []struct {
+		name        string
+		ip          string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK same ip",
+			ip:   defaultSession.IP,
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Any()).Times(0)
+				callRotateSession()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK the same ipv4-mapped ip",
+			ip:   "::ffff:10.0.0.1", // note
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Any()).Times(0)
+				callRotateSession()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK trusted network",
+			ip:   "203.0.113.7",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).Return(nil, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				callRotateSession()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK allow same subnet",
+			ip:   "10.0.0.2",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).Return(nil, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				callRotateSession()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK allow ip of other session",
+			ip:   "192.168.0.1",
+			buildStubs: func() {
+				other := model.Session{ID: 2, UserID: defaultSession.UserID, IP: "192.168.0.1"}
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return([]model.Session{defaultSession, other}, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				callRotateSession()
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK notify same country",
+			ip:   "172.16.0.1",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).Return(nil, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendLoginFromNewIP(gomock.Eq("172.16.0.1"), gomock.Eq("Kazan, RU, AS64500 Example Telecom"), gomock.Eq(defaultMail)).Times(1).Return(nil)
+				// note: session moves to ip and geo of refresh, next refresh is compared with them
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, session model.Session) (model.Session, error) {
+						assert.Equal(t, "172.16.0.1", session.IP)
+						assert.Equal(t, locator["172.16.0.1"], session.Geo)
+						return session, nil
+					})
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(1).Return("access_token", nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error deny new ip",
+			ip:   "192.168.0.1",
+			buildStubs: func() {
+				// note: ip of revoked session isn't trusted
+				other := model.Session{ID: 2, UserID: defaultSession.UserID, IP: "192.168.0.1", RevokedAt: 1}
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return([]model.Session{other}, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrIPDenied)
+				var policyErr *IPPolicyError
+				assert.ErrorAs(t, err, &policyErr)
+				assert.Equal(t, IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP}}, policyErr.Decision)
+			},
+		},
+		{
+			name: "error step-up after rapid change",
+			ip:   "10.0.0.2",
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.CreatedAt = time.Now().Add(-10 * time.Second).Unix() // note
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).Return(nil, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrStepUpRequired)
+				assert.ErrorIs(t, err, ErrValidationFailed)
+				var policyErr *IPPolicyError
+				assert.ErrorAs(t, err, &policyErr)
+				assert.Equal(t, []string{IPReasonSameSubnet, IPReasonRapidChange}, policyErr.Decision.Reasons)
+			},
+		},
+		{
+			name: "error step-up after impossible travel",
+			ip:   "2001:db8::1",
+			buildStubs: func() {
+				// note: known ip alone would be allowed
+				other := model.Session{ID: 2, UserID: defaultSession.UserID, IP: "2001:db8::1"}
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return([]model.Session{other}, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.UserID)).Times(1).
+					Return(model.User{ID: defaultSession.UserID, Email: defaultMail}, nil)
+				smtpService.EXPECT().SendImpossibleTravel(gomock.Eq("2001:db8::1"), gomock.Eq("New York, US"), gomock.Eq("Moscow, RU"), gomock.Eq(defaultMail)).
+					Times(1).Return(unexpectedError) // note: refresh is refused anyway
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrStepUpRequired)
+				var policyErr *IPPolicyError
+				assert.ErrorAs(t, err, &policyErr)
+				assert.Equal(t, IPDecision{Outcome: IPStepUp, Reasons: []string{IPReasonKnownIP, IPReasonImpossibleTravel}}, policyErr.Decision)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			_, _, err := auth.RefreshSession(context.Background(), "", "old_selector.rand_string", test.ip)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestRefreshSessionTravelFromLastRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	cfg := *defaultConfig
+	cfg.IPPolicy = IPPolicy{
+		Default:          IPDeny,
+		SameSubnet:       IPAllow,
+		SubnetBitsV4:     24,
+		SubnetBitsV6:     64,
+		KnownIP:          IPAllow,
+		MaxTravelSpeed:   1000,
+		ImpossibleTravel: IPDeny,
+	}
+	newYork := &model.Location{Latitude: 40.7128, Longitude: -74.006}
+	locator := mapLocator{
+		"10.0.0.1":    {Country: "RU", City: "Moscow", Location: &model.Location{Latitude: 55.7558, Longitude: 37.6173}},
+		"2001:db8::1": {Country: "US", City: "New York", Location: newYork},
+		"2001:db8::2": {Country: "US", City: "New York", Location: newYork},
+	}
+
+	auth := New(sessionService, nil, jwtMaker, nil, nil, defaultPasswordHasher, defaultTokenHasher, nil, nil, locator, &cfg, logger, true)
+
+	rTokenHash, err := defaultTokenHasher.Hash("rand_string")
+	assert.NoError(t, err)
+
+	// note: flight from Moscow took 12 hours, so the first refresh from New York is possible
+	dbSession := model.Session{
+		ID:             1,
+		UserID:         1,
+		RTokenSelector: "old_selector",
+		RTokenHash:     rTokenHash,
+		CreatedAt:      time.Now().Add(-12 * time.Hour).Unix(),
+		Version:        1,
+		IP:             "10.0.0.1",
+	}
+	other := model.Session{ID: 2, UserID: 1, IP: "2001:db8::1"}
+
+	// session storage keeps what the last refresh rotated session to
+	sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(_ context.Context, selector string) (model.Session, error) {
+			return dbSession, nil
+		})
+	sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(dbSession.UserID)).Times(2).
+		Return([]model.Session{dbSession, other}, nil)
+	sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(_ context.Context, session model.Session) (model.Session, error) {
+			session.Version++
+			dbSession = session
+			return session, nil
+		})
+	jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(2).Return("access_token", nil)
+
+	_, rT, err := auth.RefreshSession(context.Background(), "", "old_selector.rand_string", "2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", dbSession.IP)
+	assert.Equal(t, "New York", dbSession.City)
+
+	// note: a minute later from the same network, it would be impossible travel if it was measured from Moscow
+	dbSession.CreatedAt = time.Now().Add(-1 * time.Minute).Unix()
+	_, _, err = auth.RefreshSession(context.Background(), "", rT, "2001:db8::2")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::2", dbSession.IP)
+}
+
+func TestRefreshSessionMigratesHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	hmacHasher := NewHMACTokenHasher([]byte("0123456789abcdef0123456789abcdef"))
+	auth := New(sessionService, nil, jwtMaker, nil, nil, defaultPasswordHasher, hmacHasher, nil, nil, nil, defaultConfig, logger, true)
+
+	// note: session was refreshed last time when refresh tokens were hashed with bcrypt
+	bcryptHash, err := defaultTokenHasher.Hash("rand_string")
+	assert.NoError(t, err)
+
+	dbSession := model.Session{
+		ID:             1,
+		UserID:         1,
+		RTokenSelector: "old_selector",
+		RTokenHash:     bcryptHash,
+		CreatedAt:      time.Now().Unix(),
+		Version:        1,
+		IP:             "::1",
+	}
+
+	sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(dbSession, nil)
+	sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, session model.Session) (model.Session, error) {
+			assert.True(t, strings.HasPrefix(session.RTokenHash, hmacSHA256Prefix))
+			assert.True(t, hmacHasher.Compare(session.RTokenHash, "rand_string"))
+			return session, nil
+		})
+	jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(1).Return("access_token", nil)
+
+	_, rT, err := auth.RefreshSession(context.Background(), "", "old_selector.rand_string", "::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "selector.rand_string", rT)
+}
+
+func TestRefreshClientSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	auth := New(sessionService, nil, jwtMaker, nil, nil, defaultPasswordHasher, defaultTokenHasher, nil, nil, nil, defaultConfig, logger, true)
+
+	defaultRTokenHash, err := defaultTokenHasher.Hash("rand_string")
+	assert.NoError(t, err)
+
+	defaultSession := model.Session{
+		ID:             1,
+		UserID:         1,
+		RTokenSelector: "old_selector",
+		RTokenHash:     defaultRTokenHash,
+		CreatedAt:      time.Now().Unix(),
+		Version:        1,
+		IP:             "::1",
+		ClientID:       "client",
+		Scope:          "openid email",
+	}
+
+	defaultPayload := &model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "::1",
+		ClientID:  "client",
+		Scope:     "openid email",
+	}
+
+	tc := []struct {
+		name        string
+		refresh     func() (string, string, error)
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "client", "")
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(1).Return("access_token", nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK narrowed scope",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "client", "email") // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, session model.Session) (model.Session, error) {
+						assert.Equal(t, "email", session.Scope)
+						return session, nil
+					})
+				jwtMaker.EXPECT().CreateToken(gomock.Any()).Times(1).
+					DoAndReturn(func(payload model.Payload) (string, error) {
+						assert.Equal(t, "email", payload.Scope)
+						return "access_token", nil
+					})
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error scope wasn't granted",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "client", "email admin") // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+			},
+		},
+		{
+			name: "error session of client refreshed as first-party",
+			refresh: func() (string, string, error) {
+				return auth.RefreshSession(context.Background(), "", "old_selector.rand_string", "::1") // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrClientMismatch)
+			},
+		},
+		{
+			name: "error legacy session of client refreshed as first-party",
+			refresh: func() (string, string, error) {
+				return auth.RefreshSession(context.Background(), "access_token", "rand_string", "::1") // note
+			},
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.CreatedAt = 0
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq("access_token")).Times(1).Return(nil, defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.ID)).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrClientMismatch)
+			},
+		},
+		{
+			name: "error session of other client",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "other", "") // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(defaultSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrClientMismatch)
+			},
+		},
+		{
+			name: "error first-party session refreshed by client",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "client", "")
+			},
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.ClientID, cpSession.Scope = "", "" // note
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("old_selector")).Times(1).Return(cpSession, nil)
+				sessionService.EXPECT().Rotate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrClientMismatch)
+			},
+		},
+		{
+			name: "error empty client",
+			refresh: func() (string, string, error) {
+				return auth.RefreshClientSession(context.Background(), "", "old_selector.rand_string", "::1", "", "") // note
+			},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			_, _, err := test.refresh()
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestRefreshTokenSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	auth := New(sessionService, nil, nil, nil, nil, defaultPasswordHasher, defaultTokenHasher, nil, nil, nil, defaultConfig, logger, true)
+
+	defaultSession := model.Session{ID: 1, UserID: 2, ClientID: "client", Scope: "openid"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       string
+		buildStubs  func()
+		checkResult func(t *testing.T, session model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: "selector.secret",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, session model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultSession, session)
+			},
+		},
+		{
+			name:  "OK rotated token",
+			input: "selector.secret",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).
+					Return(model.SessionRotation{SessionID: defaultSession.ID}, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultSession.ID)).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, session model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultSession, session)
+			},
+		},
+		{
+			name:  "error unknown token",
+			input: "selector.secret",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).
+					Return(model.SessionRotation{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, session model.Session, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+			},
+		},
+		{
+			name:  "error token without selector",
+			input: "rand_string", // note
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, session model.Session, err error) {
+				assert.ErrorIs(t, err, ErrValidationFailed)
+			},
+		},
+		{
+			name:  "error unexpected get session",
+			input: "selector.secret",
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(model.Session{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, session model.Session, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			session, err := auth.RefreshTokenSession(context.Background(), test.input)
+			test.checkResult(t, session, err)
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, nil, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	exp := time.Now().Add(defaultConfig.ATokenLifetime)
+
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 2,
+		IP:        "::1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultPayload.SessionID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq(defaultPayload.ID), gomock.Eq(defaultPayload.ExpiresAt.Time)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error unexpected revoke session",
+			buildStubs: func() {
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultPayload.SessionID), gomock.Any()).Times(1).Return(unexpectedError)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "error unexpected revoke access token",
+			buildStubs: func() {
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Eq(defaultPayload.SessionID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq(defaultPayload.ID), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := auth.RevokeSession(context.Background(), &defaultPayload)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, nil, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 2,
+		IP:        "::1",
+	}
+
+	iat := time.Now().Add(-1 * time.Minute)
+
+	sessions := []model.Session{
+		{ID: 2, UserID: 1, ATokenID: "jti_2", CreatedAt: iat.Unix()},
+		{ID: 3, UserID: 1, ATokenID: "jti_3", CreatedAt: iat.Unix()},
+		{ID: 4, UserID: 1, ATokenID: "jti_4", CreatedAt: iat.Unix(), RevokedAt: iat.Unix()}, // note: already revoked
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(sessions, nil)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Any()).Times(1).Return(nil)
+
+				exp := time.Unix(iat.Unix(), 0).Add(defaultConfig.ATokenLifetime)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq("jti_2"), gomock.Eq(exp)).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq("jti_3"), gomock.Eq(exp)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error unexpected list sessions",
+			buildStubs: func() {
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(nil, unexpectedError)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "error unexpected revoke all sessions",
+			buildStubs: func() {
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(sessions, nil)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Any()).Times(1).Return(unexpectedError)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "error unexpected revoke access token",
+			buildStubs: func() {
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(sessions, nil)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Any()).Times(1).Return(nil)
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := auth.RevokeAllSessions(context.Background(), defaultPayload.UserID)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultToken := "access_token"
+
+	iat := time.Now()
+	exp := iat.Add(defaultConfig.ATokenLifetime)
+
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 2,
+		IP:        "::1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			IssuedAt:  jwt.NewNumericDate(iat),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+
+	defaultSession := model.Session{
+		ID:       defaultPayload.SessionID,
+		UserID:   defaultPayload.UserID,
+		ATokenID: defaultPayload.ID,
+	}
+
+	inactive := model.Introspection{Active: false}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, res model.Introspection, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.Introspection{
+					Active:    true,
+					TokenType: "access_token",
+					Sub:       "1",
+					Exp:       exp.Unix(),
+					Iat:       iat.Unix(),
+					JTI:       defaultPayload.ID,
+					IP:        defaultPayload.IP,
+				}, res)
+			},
+		},
+		{
+			name: "OK expired token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, jwt.ErrTokenExpired)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "OK opaque token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "OK revoked token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, nil, fmt.Errorf("%w: token is revoked", jwt.ErrTokenInvalidId))
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "OK session not exists",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(model.Session{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "OK session revoked",
+			buildStubs: func() {
+				df := defaultSession
+				df.RevokedAt = iat.Unix() // note: revoked
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(df, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "OK session of other user",
+			buildStubs: func() {
+				df := defaultSession
+				df.UserID = 100 // note: other user
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(df, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name: "error unexpected verify token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, nil, unexpectedError)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "error unexpected get session",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, &defaultPayload, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultPayload.SessionID)).Times(1).Return(model.Session{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			res, err := auth.Introspect(context.Background(), defaultToken, "")
+			test.checkResult(t, res, err)
+		})
+	}
+}
+
+func TestIntrospectRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+
+	auth := New(sessionService, nil, jwtMaker, nil, nil, defaultPasswordHasher, defaultTokenHasher, nil, nil, nil, defaultConfig, logger, true)
+
+	defaultToken := "selector.rand_string"
+
+	rTokenHash, err := defaultTokenHasher.Hash("rand_string")
+	assert.NoError(t, err)
+
+	iat := time.Now().Add(-1 * time.Hour)
+
+	defaultSession := model.Session{
+		ID:             2,
+		UserID:         1,
+		RTokenSelector: "selector",
+		RTokenHash:     rTokenHash,
+		CreatedAt:      iat.Unix(),
+		IP:             "::1",
+	}
+
+	inactive := model.Introspection{Active: false}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		token string
+		hint  string
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, res model.Introspection, err error)
+	}{
+		{
+			name:  "OK",
+			input: args{token: defaultToken, hint: TokenTypeRefresh},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(0)
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.Introspection{
+					Active:    true,
+					TokenType: TokenTypeRefresh,
+					Sub:       "1",
+					Exp:       iat.Add(defaultConfig.RTokenLifetime).Unix(),
+					Iat:       iat.Unix(),
+					IP:        defaultSession.IP,
+				}, res)
+			},
+		},
+		{
+			name:  "OK without hint",
+			input: args{token: defaultToken}, // note: access token is looked up first
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.True(t, res.Active)
+				assert.Equal(t, TokenTypeRefresh, res.TokenType)
+			},
+		},
+		{
+			name:  "OK access token with refresh hint",
+			input: args{token: "header.payload.signature", hint: TokenTypeRefresh},
+			buildStubs: func() {
+				// note: jwt isn't refresh token, so it isn't looked up by selector
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(0)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq("header.payload.signature")).Times(1).
+					Return(nil, &model.Payload{UserID: 1, SessionID: 2, RegisteredClaims: jwt.RegisteredClaims{
+						ID: "jti", IssuedAt: jwt.NewNumericDate(iat), ExpiresAt: jwt.NewNumericDate(iat.Add(time.Hour)),
+					}}, nil)
+				sessionService.EXPECT().GetByID(gomock.Any(), gomock.Eq(2)).Times(1).Return(model.Session{ID: 2, UserID: 1}, nil)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.True(t, res.Active)
+				assert.Equal(t, TokenTypeAccess, res.TokenType)
+			},
+		},
+		{
+			name:  "OK rotated token",
+			input: args{token: defaultToken, hint: TokenTypeRefresh},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(model.Session{}, sql.ErrNoRows)
+				sessionService.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Any()).Times(0)
+				sessionService.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name:  "OK other secret",
+			input: args{token: "selector.other", hint: TokenTypeRefresh}, // note
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(defaultSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name:  "OK revoked session",
+			input: args{token: defaultToken, hint: TokenTypeRefresh},
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.RevokedAt = time.Now().Unix() // note
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(cpSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name:  "OK expired token",
+			input: args{token: defaultToken, hint: TokenTypeRefresh},
+			buildStubs: func() {
+				cpSession := defaultSession
+				cpSession.CreatedAt = time.Now().Add(-defaultConfig.RTokenLifetime - time.Minute).Unix() // note
+
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(cpSession, nil)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name:  "OK legacy token without selector",
+			input: args{token: "rand_string", hint: TokenTypeRefresh}, // note
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Any()).Times(0)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil, jwt.ErrTokenMalformed)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, inactive, res)
+			},
+		},
+		{
+			name:  "error unexpected get session",
+			input: args{token: defaultToken, hint: TokenTypeRefresh},
+			buildStubs: func() {
+				sessionService.EXPECT().GetBySelector(gomock.Any(), gomock.Eq("selector")).Times(1).Return(model.Session{}, unexpectedError)
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, res model.Introspection, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			res, err := auth.Introspect(context.Background(), test.input.token, test.input.hint)
+			test.checkResult(t, res, err)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultPassword := "password"
+	passwordHash, err := defaultPasswordHasher.Hash(defaultPassword)
+	assert.NoError(t, err)
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte(defaultPassword), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	defaultUser := model.User{
+		ID:           1,
+		Email:        "user@gmail.com",
+		PasswordHash: passwordHash,
+	}
+
+	defaultIP := "::1"
+	defaultAToken := "access_token"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		email    string
+		password string
+	}
+
+	defaultArgs := args{
+		email:    defaultUser.Email,
+		password: defaultPassword,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, aToken, rToken string, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultUser.ID}}).Times(1).
+					Return(model.Session{ID: 2, UserID: defaultUser.ID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+					UserID:    defaultUser.ID,
+					SessionID: 2,
+					IP:        defaultIP,
+					AMR:       []string{model.AMRPassword},
+				}}).Times(1).Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
+		{
+			name:  "OK legacy hash is upgraded",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.PasswordHash = string(legacyHash) // note: bcrypt
+
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(df, nil)
+				userService.EXPECT().SetPassword(gomock.Any(), gomock.Eq(defaultUser.ID), gomock.Eq(defaultPassword)).Times(1).Return(nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultUser.ID}}).Times(1).
+					Return(model.Session{ID: 2, UserID: defaultUser.ID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{UserID: defaultUser.ID, SessionID: 2, IP: defaultIP}}).Times(1).
+					Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
+		{
+			name:  "OK upgrade of legacy hash failed",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.PasswordHash = string(legacyHash) // note: bcrypt
+
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(df, nil)
+				userService.EXPECT().SetPassword(gomock.Any(), gomock.Eq(defaultUser.ID), gomock.Eq(defaultPassword)).Times(1).Return(unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultUser.ID}}).Times(1).
+					Return(model.Session{ID: 2, UserID: defaultUser.ID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{UserID: defaultUser.ID, SessionID: 2, IP: defaultIP}}).Times(1).
+					Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
+		{
+			name:  "mfa required",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.TOTPEnabledAt = 1 // note: second factor enabled
+
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(df, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Eq(onetime.PurposeMFAChallenge), gomock.Eq(defaultUser.ID),
+					gomock.Eq(defaultConfig.MFAChallengeLifetime), gomock.Eq(model.AMRPassword)).Times(1).
+					Return("token.signature", nil)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrMFARequired)
+
+				var challenge *MFAChallengeError
+				assert.ErrorAs(t, err, &challenge)
+				assert.Equal(t, model.AMRPassword+".token.signature", challenge.Token)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error issue mfa challenge",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.TOTPEnabledAt = 1
+
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(df, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, ErrMFARequired)
+			},
+		},
+		{
+			name: "error wrong password",
+			input: args{
+				email:    defaultUser.Email,
+				password: "wrong", // note: wrong
+			},
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error unknown email",
+			input: defaultArgs,
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(model.User{}, sql.ErrNoRows)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+			},
+		},
+		{
+			name:  "error password not set",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.PasswordHash = "" // note: not set
+
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(df, nil)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+			},
+		},
+		{
+			name:  "error unexpected get user",
+			input: defaultArgs,
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(model.User{}, unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, ErrInvalidCredentials)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			aToken, rToken, err := auth.Login(context.Background(), test.input.email, test.input.password, defaultIP)
+			test.checkResult(t, aToken, rToken, err)
+		})
+	}
+}
+
+func TestLoginRequireVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	cfg := *defaultConfig
+	cfg.RequireVerifiedEmail = true
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, &cfg, logger, true)
+
+	defaultPassword := "password"
+	passwordHash, err := defaultPasswordHasher.Hash(defaultPassword)
+	assert.NoError(t, err)
+
+	unverifiedUser := model.User{
+		ID:           1,
+		Email:        "user@gmail.com",
+		PasswordHash: passwordHash,
+	}
+
+	userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(unverifiedUser.Email)).Times(1).Return(unverifiedUser, nil)
+	userService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0) // note: user is already known
+	sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+	aToken, rToken, err := auth.Login(context.Background(), unverifiedUser.Email, defaultPassword, "::1")
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.Empty(t, aToken)
+	assert.Empty(t, rToken)
+}
+
+func TestForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultUser := model.User{
+		ID:    1,
+		Email: "user@gmail.com",
+	}
+	defaultIP := "::1"
+	defaultToken := "token.signature"
+	defaultLink := defaultConfig.PasswordResetURL + "?token=" + defaultToken
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Eq(onetime.PurposePasswordReset), gomock.Eq(defaultUser.ID), gomock.Eq(defaultConfig.PasswordResetTokenLifetime)).Times(1).
+					Return(defaultToken, nil)
+				smtpService.EXPECT().SendPasswordReset(gomock.Eq(defaultLink), gomock.Eq(defaultIP), gomock.Eq(defaultUser.Email)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK unknown email",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(model.User{}, sql.ErrNoRows)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				smtpService.EXPECT().SendPasswordReset(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error unexpected get user",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(model.User{}, unexpectedError)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "OK unexpected issue",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+				smtpService.EXPECT().SendPasswordReset(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				// note: link is issued in background, response is the same as for unknown email
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "OK email not sent",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultToken, nil)
+				smtpService.EXPECT().SendPasswordReset(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := auth.ForgotPassword(context.Background(), defaultUser.Email, defaultIP)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userServiceMock := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userServiceMock, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultToken := "token.signature"
+	defaultPassword := "new_password"
+	defaultOneTimeToken := model.OneTimeToken{
+		Purpose: onetime.PurposePasswordReset,
+		UserID:  1,
+	}
+
+	now := time.Now()
+	defaultSessions := []model.Session{
+		{ID: 2, UserID: 1, ATokenID: "jti_2", CreatedAt: now.Unix()},
+		{ID: 3, UserID: 1, ATokenID: "jti_3", CreatedAt: now.Unix(), RevokedAt: now.Unix()}, // already revoked
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		token    string
+		password string
+	}
+
+	defaultArgs := args{
+		token:    defaultToken,
+		password: defaultPassword,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposePasswordReset), gomock.Eq(defaultToken)).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userServiceMock.EXPECT().SetPassword(gomock.Any(), gomock.Eq(defaultOneTimeToken.UserID), gomock.Eq(defaultPassword)).Times(1).Return(nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(defaultOneTimeToken.UserID)).Times(1).Return(defaultSessions, nil)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(defaultOneTimeToken.UserID), gomock.Any()).Times(1).Return(nil)
+				// note: only access token of active session is denylisted
+				denylistService.EXPECT().Add(gomock.Any(), gomock.Eq("jti_2"), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error weak password",
+			input: args{
+				token:    defaultToken,
+				password: "short", // note: too short
+			},
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, userService.ErrWeakPassword)
+			},
+		},
+		{
+			name:  "error invalid token",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposePasswordReset), gomock.Eq(defaultToken)).Times(1).
+					Return(model.OneTimeToken{}, onetime.ErrInvalidToken)
+				userServiceMock.EXPECT().SetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidResetToken)
+			},
+		},
+		{
+			name:  "error unexpected set password",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultOneTimeToken, nil)
+				userServiceMock.EXPECT().SetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultOneTimeToken, nil)
+				userServiceMock.EXPECT().SetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				sessionService.EXPECT().ListByUserID(gomock.Any(), gomock.Any()).Times(1).Return(defaultSessions, nil)
+				sessionService.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := auth.ResetPassword(context.Background(), test.input.token, test.input.password)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestSendMagicLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultUser := model.User{
+		ID:    1,
+		Email: "user@gmail.com",
+	}
+	defaultIP := "::1"
+	defaultNonce := "rand_string" // means that randString always returns it when testMode is true
+	defaultToken := "token.signature"
+	defaultLink := defaultConfig.MagicLinkURL + "?token=" + defaultToken
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, nonce string, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Eq(onetime.PurposeMagicLink), gomock.Eq(defaultUser.ID), gomock.Eq(defaultConfig.MagicLinkTokenLifetime), gomock.Eq(defaultNonce)).Times(1).
+					Return(defaultToken, nil)
+				smtpService.EXPECT().SendMagicLink(gomock.Eq(defaultLink), gomock.Eq(defaultIP), gomock.Eq(defaultUser.Email)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, nonce string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNonce, nonce)
+			},
+		},
+		{
+			name: "OK unknown email",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(model.User{}, sql.ErrNoRows)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				smtpService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, nonce string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNonce, nonce) // note: the same as for existing account
+			},
+		},
+		{
+			name: "OK unexpected issue",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+				smtpService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, nonce string, err error) {
+				// note: link is issued in background, response is the same as for unknown email
+				assert.NoError(t, err)
+				assert.Equal(t, defaultNonce, nonce)
+			},
+		},
+		{
+			name: "OK email not sent",
+			buildStubs: func() {
+				userService.EXPECT().GetByEmail(gomock.Any(), gomock.Eq(defaultUser.Email)).Times(1).Return(defaultUser, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultToken, nil)
+				smtpService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, nonce string, err error) {
+				assert.NoError(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			nonce, err := auth.SendMagicLink(context.Background(), defaultUser.Email, defaultIP)
+			test.checkResult(t, nonce, err)
+		})
+	}
+}
+
+func TestLoginByMagicLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultToken := "token.signature"
+	defaultNonce := "nonce"
+	defaultIP := "::1"
+	defaultAToken := "access_token"
+	defaultOneTimeToken := model.OneTimeToken{
+		Purpose: onetime.PurposeMagicLink,
+		UserID:  1,
+	}
+	defaultUser := model.User{
+		ID:    defaultOneTimeToken.UserID,
+		Email: "user@gmail.com",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		token string
+		nonce string
+	}
+
+	defaultArgs := args{
+		token: defaultToken,
+		nonce: defaultNonce,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, aToken, rToken string, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Eq(onetime.PurposeMagicLink), gomock.Eq(defaultToken), gomock.Eq(defaultNonce)).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultOneTimeToken.UserID}}).Times(1).
+					Return(model.Session{ID: 2, UserID: defaultOneTimeToken.UserID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+					UserID:    defaultOneTimeToken.UserID,
+					SessionID: 2,
+					IP:        defaultIP,
+					AMR:       []string{model.AMREmail},
+				}}).Times(1).Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
+		{
+			name:  "mfa required",
+			input: defaultArgs,
+			buildStubs: func() {
+				df := defaultUser
+				df.TOTPEnabledAt = 1 // note: second factor enabled
+
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(df, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), gomock.Eq(onetime.PurposeMFAChallenge), gomock.Eq(defaultUser.ID),
+					gomock.Any(), gomock.Eq(model.AMREmail)).Times(1).
+					Return("token.signature", nil)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				var challenge *MFAChallengeError
+				assert.ErrorAs(t, err, &challenge)
+				assert.Equal(t, model.AMREmail+".token.signature", challenge.Token)
+			},
+		},
+		{
+			name:  "error get user",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(model.User{}, unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "error without nonce",
+			input: args{
+				token: defaultToken,
+				nonce: "", // note: cookie is missing
+			},
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidMagicLink)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:  "error invalid token",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{}, onetime.ErrInvalidToken)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidMagicLink)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{}, unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, ErrInvalidMagicLink)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			aToken, rToken, err := auth.LoginByMagicLink(context.Background(), test.input.token, test.input.nonce, defaultIP)
+			test.checkResult(t, aToken, rToken, err)
+		})
+	}
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sessionService := mock_session.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	denylistService := mock_denylist.NewMockInterface(ctrl)
+	logger := logger.New("debug", true)
+	smtpService := mock_smtp.NewMockInterface(ctrl)
+
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	auth := New(sessionService, userService, jwtMaker, denylistService, smtpService, defaultPasswordHasher, defaultTokenHasher, tokens, mfaService, nil, defaultConfig, logger, true)
+
+	defaultToken := "token.signature"
+	defaultChallenge := model.AMRPassword + "." + defaultToken
+	defaultCode := "123456"
+	defaultIP := "::1"
+	defaultAToken := "access_token"
+	defaultOneTimeToken := model.OneTimeToken{
+		Purpose: onetime.PurposeMFAChallenge,
+		UserID:  1,
+	}
+	defaultUser := model.User{
+		ID:            defaultOneTimeToken.UserID,
+		Email:         "user@gmail.com",
+		TOTPEnabledAt: 1,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		challenge   string
+		buildStubs  func()
+		checkResult func(t *testing.T, aToken, rToken string, err error)
+	}{
+		{
+			name:      "OK",
+			challenge: defaultChallenge,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Eq(onetime.PurposeMFAChallenge), gomock.Eq(defaultToken), gomock.Eq(model.AMRPassword)).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				mfaService.EXPECT().Verify(gomock.Any(), gomock.Eq(defaultUser), gomock.Eq(defaultCode)).Times(1).Return(model.AMROTP, nil)
+				sessionService.EXPECT().Create(gomock.Any(), sessionMatcher{session: model.Session{UserID: defaultUser.ID}}).Times(1).
+					Return(model.Session{ID: 2, UserID: defaultUser.ID}, nil)
+				jwtMaker.EXPECT().CreateToken(payloadMatcher{model.Payload{
+					UserID:    defaultUser.ID,
+					SessionID: 2,
+					IP:        defaultIP,
+					AMR:       []string{model.AMRPassword, model.AMROTP, model.AMRMFA},
+				}}).Times(1).Return(defaultAToken, nil)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultAToken, aToken)
+				assert.NotEmpty(t, rToken)
+			},
+		},
+		{
+			name:      "error malformed challenge",
+			challenge: "challenge", // note: without first factor
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidMFAChallenge)
+			},
+		},
+		{
+			name:      "error invalid challenge",
+			challenge: defaultChallenge,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{}, onetime.ErrInvalidToken)
+				mfaService.EXPECT().Verify(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidMFAChallenge)
+				assert.Empty(t, aToken)
+				assert.Empty(t, rToken)
+			},
+		},
+		{
+			name:      "error invalid code",
+			challenge: defaultChallenge,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				mfaService.EXPECT().Verify(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", mfa.ErrInvalidCode)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, mfa.ErrInvalidCode)
+			},
+		},
+		{
+			name:      "error get user",
+			challenge: defaultChallenge,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(model.User{}, unexpectedError)
+				mfaService.EXPECT().Verify(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name:      "unexpected error verify",
+			challenge: defaultChallenge,
+			buildStubs: func() {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				mfaService.EXPECT().Verify(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+				sessionService.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, aToken, rToken string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, mfa.ErrInvalidCode)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			aToken, rToken, err := auth.VerifyMFA(context.Background(), test.challenge, defaultCode, defaultIP)
+			test.checkResult(t, aToken, rToken, err)
 		})
 	}
 }
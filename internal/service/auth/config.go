@@ -0,0 +1,26 @@
+package auth
+
+import "time"
+
+type Config struct {
+	// life time of access token
+	ATokenLifetime time.Duration
+	// life time of refresh token
+	RTokenLifetime time.Duration
+	// count of random bytes in refresh token
+	RTokenBytes int
+	// allowed clock skew, tokens are accepted this long after expiration
+	Leeway time.Duration
+	// refuse to open sessions for users with unverified email
+	RequireVerifiedEmail bool
+	// page of client app which posts token from query and new password to reset endpoint
+	PasswordResetURL           string
+	PasswordResetTokenLifetime time.Duration
+	// endpoint which consumes login link, token is added as query parameter
+	MagicLinkURL           string
+	MagicLinkTokenLifetime time.Duration
+	// time given to enter code of second factor after password is verified
+	MFAChallengeLifetime time.Duration
+	// what refresh from other ip results in
+	IPPolicy IPPolicy
+}
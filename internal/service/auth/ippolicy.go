@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"medods/internal/model"
+	"net"
+	"strings"
+	"time"
+)
+
+// IPOutcome is what refresh from other ip than tokens were issued to results in,
+// outcomes are ordered from the most lenient to the strictest
+type IPOutcome int
+
+const (
+	// refresh silently
+	IPAllow IPOutcome = iota
+	// refresh and email user about new ip
+	IPNotify
+	// refuse refresh until user logs in again, session itself is kept
+	IPStepUp
+	// refuse refresh
+	IPDeny
+)
+
+var ipOutcomeNames = []string{"allow", "notify", "step-up", "deny"}
+
+func (o IPOutcome) String() string {
+	if o < 0 || int(o) >= len(ipOutcomeNames) {
+		return fmt.Sprintf("IPOutcome(%d)", int(o))
+	}
+	return ipOutcomeNames[o]
+}
+
+// ParseIPOutcome parse name of outcome, e.g. from config
+func ParseIPOutcome(name string) (IPOutcome, error) {
+	for i, n := range ipOutcomeNames {
+		if n == name {
+			return IPOutcome(i), nil
+		}
+	}
+	return 0, fmt.Errorf("ip policy outcome must be one of %v, got %q", ipOutcomeNames, name)
+}
+
+// reasons of ip policy decision, rules which matched refresh
+const (
+	IPReasonNewIP            = "new_ip"
+	IPReasonTrustedNetwork   = "trusted_network"
+	IPReasonSameSubnet       = "same_subnet"
+	IPReasonSameCountry      = "same_country"
+	IPReasonKnownIP          = "known_ip"
+	IPReasonRapidChange      = "rapid_change"
+	IPReasonImpossibleTravel = "impossible_travel"
+)
+
+// IPPolicy is outcome of refresh from other ip per situation.
+// Trusted situations lower outcome: the most lenient of Default and outcomes of matched ones is taken, they never raise it.
+// Rapid change and impossible travel raise it, so refresh from trusted ip right after refresh from other one is still suspicious.
+type IPPolicy struct {
+	// refresh from these networks, e.g. egress of office VPN, is always allowed silently
+	TrustedNetworks []*net.IPNet
+	// ip matches no trusted situation
+	Default IPOutcome
+	// ip is in the same subnet as ip of session, e.g. mobile carrier gives addresses of one range,
+	// IPv4 and IPv6 subnets are compared by prefix of their own length
+	SameSubnet   IPOutcome
+	SubnetBitsV4 int
+	SubnetBitsV6 int
+	// ip is in the same country as ip of session, rule is skipped without Locator
+	SameCountry IPOutcome
+	// other active session of user is refreshed from ip
+	KnownIP IPOutcome
+	// ip changed sooner than window after last refresh, zero window disables rule
+	RapidChangeWindow time.Duration
+	RapidChange       IPOutcome
+	// getting from location of session ip to location of ip since last refresh needs speed faster than max, km/h,
+	// rule is skipped if either location is unknown, zero max disables rule
+	MaxTravelSpeed   float64
+	ImpossibleTravel IPOutcome
+}
+
+// Locator resolve geo of ip, e.g. by GeoIP database, ok is false if ip is unknown
+type Locator interface {
+	Locate(ip net.IP) (geo model.Geo, ok bool)
+}
+
+// IPDecision is result of ip policy for refresh from other ip and rules which it was made by
+type IPDecision struct {
+	Outcome IPOutcome
+	Reasons []string
+}
+
+func (d IPDecision) String() string {
+	return fmt.Sprintf("outcome[%s] reasons[%s]", d.Outcome, strings.Join(d.Reasons, ","))
+}
+
+// Has report whether rule of reason matched
+func (d IPDecision) Has(reason string) bool {
+	for _, r := range d.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// IPPolicyError is returned by refresh refused by ip policy, handlers map outcome to response
+type IPPolicyError struct {
+	Decision IPDecision
+}
+
+func (e *IPPolicyError) Error() string {
+	return e.Unwrap().Error()
+}
+
+func (e *IPPolicyError) Unwrap() error {
+	if e.Decision.Outcome == IPStepUp {
+		return ErrStepUpRequired
+	}
+	return ErrIPDenied
+}
+
+// ipChange is what ip policy is evaluated on
+type ipChange struct {
+	// ip of last refresh, session moves to new ip when refresh is allowed
+	sessionIP net.IP
+	ip        net.IP
+	// ip is used by other active session of user
+	known bool
+	// empty if unknown
+	sessionCountry string
+	country        string
+	// time since session was refreshed last time
+	sinceRefresh time.Duration
+	// nil if unknown
+	sessionLocation *model.Location
+	location        *model.Location
+}
+
+func (p IPPolicy) decide(c ipChange) IPDecision {
+	for _, network := range p.TrustedNetworks {
+		if network.Contains(c.ip) {
+			return IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonTrustedNetwork}}
+		}
+	}
+
+	d := IPDecision{Outcome: p.Default}
+
+	trusted := false
+	trust := func(outcome IPOutcome, reason string) {
+		if outcome < d.Outcome {
+			d.Outcome = outcome
+		}
+		trusted = true
+		d.Reasons = append(d.Reasons, reason)
+	}
+	if p.sameSubnet(c.sessionIP, c.ip) {
+		trust(p.SameSubnet, IPReasonSameSubnet)
+	}
+	if c.country != "" && c.country == c.sessionCountry {
+		trust(p.SameCountry, IPReasonSameCountry)
+	}
+	if c.known {
+		trust(p.KnownIP, IPReasonKnownIP)
+	}
+	if !trusted {
+		d.Reasons = append(d.Reasons, IPReasonNewIP)
+	}
+
+	raise := func(outcome IPOutcome, reason string) {
+		if outcome > d.Outcome {
+			d.Outcome = outcome
+		}
+		d.Reasons = append(d.Reasons, reason)
+	}
+	if p.RapidChangeWindow > 0 && c.sinceRefresh < p.RapidChangeWindow {
+		raise(p.RapidChange, IPReasonRapidChange)
+	}
+	if p.MaxTravelSpeed > 0 && c.sessionLocation != nil && c.location != nil &&
+		travelSpeed(travelDistance(c.sessionLocation, c.location), c.sinceRefresh) > p.MaxTravelSpeed {
+		raise(p.ImpossibleTravel, IPReasonImpossibleTravel)
+	}
+
+	return d
+}
+
+const earthRadiusKm = 6371
+
+// travelDistance return great-circle distance between locations in km less their accuracy radii,
+// so travel within bounds of geoip accuracy is never counted
+func travelDistance(a, b *model.Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLong := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLong/2), 2)
+	distance := 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+
+	return math.Max(0, distance-float64(a.AccuracyRadius)-float64(b.AccuracyRadius))
+}
+
+// travelSpeed return speed in km/h, any distance in no time is infinitely fast
+func travelSpeed(distance float64, elapsed time.Duration) float64 {
+	if distance == 0 {
+		return 0
+	}
+	if elapsed <= 0 {
+		return math.Inf(1)
+	}
+	return distance / elapsed.Hours()
+}
+
+// sameSubnet report whether ips of the same family share prefix of configured length
+func (p IPPolicy) sameSubnet(a, b net.IP) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	bits, size := p.SubnetBitsV6, 8*net.IPv6len
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return false
+		}
+		a, b = a4, b4
+		bits, size = p.SubnetBitsV4, 8*net.IPv4len
+	}
+	if bits <= 0 {
+		return false
+	}
+
+	mask := net.CIDRMask(bits, size)
+	return mask != nil && a.Mask(mask).Equal(b.Mask(mask))
+}
+
+// normalizeIP return canonical form of ip, IPv4-mapped IPv6 address becomes IPv4,
+// so client of dual-stack listener isn't taken for other one, ip is returned as is if it isn't valid
+func normalizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	return parsed.String()
+}
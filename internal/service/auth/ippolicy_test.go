@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"math"
+	"medods/internal/model"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPPolicyDecide(t *testing.T) {
+	_, office, err := net.ParseCIDR("203.0.113.0/24")
+	assert.NoError(t, err)
+
+	policy := IPPolicy{
+		TrustedNetworks:   []*net.IPNet{office},
+		Default:           IPDeny,
+		SameSubnet:        IPAllow,
+		SubnetBitsV4:      24,
+		SubnetBitsV6:      56,
+		SameCountry:       IPNotify,
+		KnownIP:           IPAllow,
+		RapidChangeWindow: time.Minute,
+		RapidChange:       IPStepUp,
+		MaxTravelSpeed:    1000,
+		ImpossibleTravel:  IPStepUp,
+	}
+
+	moscow := &model.Location{Latitude: 55.7558, Longitude: 37.6173, AccuracyRadius: 20}
+	newYork := &model.Location{Latitude: 40.7128, Longitude: -74.006, AccuracyRadius: 100}
+
+	defaultChange := ipChange{
+		sessionIP:    net.ParseIP("10.0.0.1"),
+		ip:           net.ParseIP("192.168.0.1"),
+		sinceRefresh: time.Hour,
+	}
+
+	tc := []struct {
+		name     string
+		input    func(c ipChange) ipChange
+		expected IPDecision
+	}{
+		{
+			name:     "new ip",
+			input:    func(c ipChange) ipChange { return c },
+			expected: IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP}},
+		},
+		{
+			name: "same subnet",
+			input: func(c ipChange) ipChange {
+				c.ip = net.ParseIP("10.0.0.200") // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonSameSubnet}},
+		},
+		{
+			name: "same subnet of ipv4-mapped ip",
+			input: func(c ipChange) ipChange {
+				c.ip = net.ParseIP("::ffff:10.0.0.200") // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonSameSubnet}},
+		},
+		{
+			name: "same ipv6 subnet",
+			input: func(c ipChange) ipChange {
+				c.sessionIP = net.ParseIP("2001:db8::1")
+				c.ip = net.ParseIP("2001:db8:0:ff::1") // note: other /64 of the same /56
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonSameSubnet}},
+		},
+		{
+			name: "other ipv6 subnet",
+			input: func(c ipChange) ipChange {
+				c.sessionIP = net.ParseIP("2001:db8::1")
+				c.ip = net.ParseIP("2001:db8:0:100::1") // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP}},
+		},
+		{
+			name: "ipv4 and ipv6",
+			input: func(c ipChange) ipChange {
+				c.ip = net.ParseIP("::a00:2") // note: the same bits as 10.0.0.2
+				return c
+			},
+			expected: IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP}},
+		},
+		{
+			name: "same country",
+			input: func(c ipChange) ipChange {
+				c.sessionCountry, c.country = "RU", "RU" // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPNotify, Reasons: []string{IPReasonSameCountry}},
+		},
+		{
+			name: "unknown country",
+			input: func(c ipChange) ipChange {
+				c.sessionCountry, c.country = "", "" // note: not the same country
+				return c
+			},
+			expected: IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP}},
+		},
+		{
+			name: "the most lenient trusted situation",
+			input: func(c ipChange) ipChange {
+				c.sessionCountry, c.country = "RU", "RU"
+				c.known = true // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonSameCountry, IPReasonKnownIP}},
+		},
+		{
+			name: "rapid change of trusted ip",
+			input: func(c ipChange) ipChange {
+				c.known = true
+				c.sinceRefresh = 10 * time.Second // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPStepUp, Reasons: []string{IPReasonKnownIP, IPReasonRapidChange}},
+		},
+		{
+			name: "trusted network",
+			input: func(c ipChange) ipChange {
+				c.ip = net.ParseIP("203.0.113.7") // note
+				c.sinceRefresh = 10 * time.Second // note: rapid change isn't checked
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonTrustedNetwork}},
+		},
+		{
+			name: "impossible travel",
+			input: func(c ipChange) ipChange {
+				c.known = true
+				c.sessionLocation, c.location = moscow, newYork // note: ~7400km in an hour
+				return c
+			},
+			expected: IPDecision{Outcome: IPStepUp, Reasons: []string{IPReasonKnownIP, IPReasonImpossibleTravel}},
+		},
+		{
+			name: "possible travel",
+			input: func(c ipChange) ipChange {
+				c.known = true
+				c.sessionLocation, c.location = moscow, newYork
+				c.sinceRefresh = 10 * time.Hour // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonKnownIP}},
+		},
+		{
+			name: "travel within accuracy radius",
+			input: func(c ipChange) ipChange {
+				c.known = true
+				c.sessionLocation = moscow
+				c.location = &model.Location{Latitude: 55.9, Longitude: 37.6, AccuracyRadius: 5} // note: ~16km away
+				c.sinceRefresh = 2 * time.Minute
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonKnownIP}},
+		},
+		{
+			name: "unknown location",
+			input: func(c ipChange) ipChange {
+				c.known = true
+				c.sessionLocation = moscow // note: location of ip is unknown
+				return c
+			},
+			expected: IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonKnownIP}},
+		},
+		{
+			name: "rapid change doesn't lower outcome",
+			input: func(c ipChange) ipChange {
+				c.sinceRefresh = 10 * time.Second // note
+				return c
+			},
+			expected: IPDecision{Outcome: IPDeny, Reasons: []string{IPReasonNewIP, IPReasonRapidChange}},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, policy.decide(test.input(defaultChange)))
+		})
+	}
+
+	// note: rule is disabled without window
+	policy.RapidChangeWindow = 0
+	assert.Equal(t, IPAllow, policy.decide(ipChange{sessionIP: defaultChange.sessionIP, ip: defaultChange.ip, known: true}).Outcome)
+
+	// note: trusted situation stricter than default doesn't raise it
+	policy.Default = IPAllow
+	assert.Equal(t, IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonSameCountry}},
+		policy.decide(ipChange{sessionIP: defaultChange.sessionIP, ip: defaultChange.ip, sessionCountry: "RU", country: "RU", sinceRefresh: time.Hour}))
+	assert.Equal(t, IPDecision{Outcome: IPAllow, Reasons: []string{IPReasonNewIP}}, policy.decide(defaultChange))
+}
+
+func TestTravelDistance(t *testing.T) {
+	moscow := &model.Location{Latitude: 55.7558, Longitude: 37.6173}
+	berlin := &model.Location{Latitude: 52.52, Longitude: 13.405}
+
+	assert.InDelta(t, 1608, travelDistance(moscow, berlin), 5)
+	assert.InDelta(t, 1608, travelDistance(berlin, moscow), 5)
+	assert.Equal(t, float64(0), travelDistance(moscow, moscow))
+
+	// note: accuracy radii are subtracted
+	berlin.AccuracyRadius, moscow.AccuracyRadius = 100, 8
+	assert.InDelta(t, 1500, travelDistance(moscow, berlin), 5)
+
+	assert.Equal(t, float64(800), travelSpeed(1600, 2*time.Hour))
+	assert.Equal(t, float64(0), travelSpeed(0, 0))
+	assert.True(t, math.IsInf(travelSpeed(1, 0), 1))
+}
+
+func TestNormalizeIP(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", normalizeIP("::ffff:10.0.0.1"))
+	assert.Equal(t, "2001:db8::1", normalizeIP("2001:DB8:0::1"))
+	assert.Equal(t, "10.0.0.1", normalizeIP("10.0.0.1"))
+	assert.Equal(t, "unknown", normalizeIP("unknown"))
+}
+
+func TestParseIPOutcome(t *testing.T) {
+	for _, outcome := range []IPOutcome{IPAllow, IPNotify, IPStepUp, IPDeny} {
+		parsed, err := ParseIPOutcome(outcome.String())
+		assert.NoError(t, err)
+		assert.Equal(t, outcome, parsed)
+	}
+
+	_, err := ParseIPOutcome("block")
+	assert.Error(t, err)
+}
@@ -6,6 +6,8 @@ package mock_auth
 
 import (
 	context "context"
+	model "medods/internal/model"
+	auth "medods/internal/service/auth"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,10 +36,30 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// CreateClientSession mocks base method.
+func (m *MockInterface) CreateClientSession(ctx context.Context, uid int, ip string, grant auth.Grant) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateClientSession", ctx, uid, ip, grant)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateClientSession indicates an expected call of CreateClientSession.
+func (mr *MockInterfaceMockRecorder) CreateClientSession(ctx, uid, ip, grant interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateClientSession", reflect.TypeOf((*MockInterface)(nil).CreateClientSession), ctx, uid, ip, grant)
+}
+
 // CreateSession mocks base method.
-func (m *MockInterface) CreateSession(ctx context.Context, uid int, ip string) (string, string, error) {
+func (m *MockInterface) CreateSession(ctx context.Context, uid int, ip string, amr ...string) (string, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", ctx, uid, ip)
+	varargs := []interface{}{ctx, uid, ip}
+	for _, a := range amr {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateSession", varargs...)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(string)
 	ret2, _ := ret[2].(error)
@@ -45,9 +67,87 @@ func (m *MockInterface) CreateSession(ctx context.Context, uid int, ip string) (
 }
 
 // CreateSession indicates an expected call of CreateSession.
-func (mr *MockInterfaceMockRecorder) CreateSession(ctx, uid, ip interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) CreateSession(ctx, uid, ip interface{}, amr ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, uid, ip}, amr...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockInterface)(nil).CreateSession), varargs...)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockInterface) ForgotPassword(ctx context.Context, email, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", ctx, email, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockInterfaceMockRecorder) ForgotPassword(ctx, email, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockInterface)(nil).ForgotPassword), ctx, email, ip)
+}
+
+// Introspect mocks base method.
+func (m *MockInterface) Introspect(ctx context.Context, token, tokenTypeHint string) (model.Introspection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Introspect", ctx, token, tokenTypeHint)
+	ret0, _ := ret[0].(model.Introspection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Introspect indicates an expected call of Introspect.
+func (mr *MockInterfaceMockRecorder) Introspect(ctx, token, tokenTypeHint interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockInterface)(nil).CreateSession), ctx, uid, ip)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Introspect", reflect.TypeOf((*MockInterface)(nil).Introspect), ctx, token, tokenTypeHint)
+}
+
+// Login mocks base method.
+func (m *MockInterface) Login(ctx context.Context, email, password, ip string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, email, password, ip)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockInterfaceMockRecorder) Login(ctx, email, password, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockInterface)(nil).Login), ctx, email, password, ip)
+}
+
+// LoginByMagicLink mocks base method.
+func (m *MockInterface) LoginByMagicLink(ctx context.Context, token, nonce, ip string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginByMagicLink", ctx, token, nonce, ip)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoginByMagicLink indicates an expected call of LoginByMagicLink.
+func (mr *MockInterfaceMockRecorder) LoginByMagicLink(ctx, token, nonce, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginByMagicLink", reflect.TypeOf((*MockInterface)(nil).LoginByMagicLink), ctx, token, nonce, ip)
+}
+
+// RefreshClientSession mocks base method.
+func (m *MockInterface) RefreshClientSession(ctx context.Context, aT, rT, ip, clientID, scope string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshClientSession", ctx, aT, rT, ip, clientID, scope)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RefreshClientSession indicates an expected call of RefreshClientSession.
+func (mr *MockInterfaceMockRecorder) RefreshClientSession(ctx, aT, rT, ip, clientID, scope interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshClientSession", reflect.TypeOf((*MockInterface)(nil).RefreshClientSession), ctx, aT, rT, ip, clientID, scope)
 }
 
 // RefreshSession mocks base method.
@@ -65,3 +165,91 @@ func (mr *MockInterfaceMockRecorder) RefreshSession(ctx, aT, rT, ip interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockInterface)(nil).RefreshSession), ctx, aT, rT, ip)
 }
+
+// RefreshTokenSession mocks base method.
+func (m *MockInterface) RefreshTokenSession(ctx context.Context, rT string) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokenSession", ctx, rT)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokenSession indicates an expected call of RefreshTokenSession.
+func (mr *MockInterfaceMockRecorder) RefreshTokenSession(ctx, rT interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokenSession", reflect.TypeOf((*MockInterface)(nil).RefreshTokenSession), ctx, rT)
+}
+
+// ResetPassword mocks base method.
+func (m *MockInterface) ResetPassword(ctx context.Context, token, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, token, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockInterfaceMockRecorder) ResetPassword(ctx, token, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockInterface)(nil).ResetPassword), ctx, token, newPassword)
+}
+
+// RevokeAllSessions mocks base method.
+func (m *MockInterface) RevokeAllSessions(ctx context.Context, uid int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllSessions", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAllSessions indicates an expected call of RevokeAllSessions.
+func (mr *MockInterfaceMockRecorder) RevokeAllSessions(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllSessions", reflect.TypeOf((*MockInterface)(nil).RevokeAllSessions), ctx, uid)
+}
+
+// RevokeSession mocks base method.
+func (m *MockInterface) RevokeSession(ctx context.Context, payload *model.Payload) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, payload)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockInterfaceMockRecorder) RevokeSession(ctx, payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockInterface)(nil).RevokeSession), ctx, payload)
+}
+
+// SendMagicLink mocks base method.
+func (m *MockInterface) SendMagicLink(ctx context.Context, email, ip string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMagicLink", ctx, email, ip)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMagicLink indicates an expected call of SendMagicLink.
+func (mr *MockInterfaceMockRecorder) SendMagicLink(ctx, email, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMagicLink", reflect.TypeOf((*MockInterface)(nil).SendMagicLink), ctx, email, ip)
+}
+
+// VerifyMFA mocks base method.
+func (m *MockInterface) VerifyMFA(ctx context.Context, challenge, code, ip string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyMFA", ctx, challenge, code, ip)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// VerifyMFA indicates an expected call of VerifyMFA.
+func (mr *MockInterfaceMockRecorder) VerifyMFA(ctx, challenge, code, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyMFA", reflect.TypeOf((*MockInterface)(nil).VerifyMFA), ctx, challenge, code, ip)
+}
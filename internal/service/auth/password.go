@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hash passwords and verify them.
+// NeedsRehash tells that hash was made by outdated algorithm or parameters
+// and should be replaced after successful login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) bool
+	NeedsRehash(hash string) bool
+}
+
+var _ PasswordHasher = (*passwordHasher)(nil)
+
+type PasswordConfig struct {
+	// memory in KiB
+	Memory      uint32
+	Time        uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// passwordHasher make argon2id hashes in PHC string format,
+// bcrypt hashes are still accepted for users created before
+type passwordHasher struct {
+	cfg *PasswordConfig
+}
+
+func NewPasswordHasher(cfg *PasswordConfig) *passwordHasher {
+	return &passwordHasher{cfg: cfg}
+}
+
+const argon2idPrefix = "$argon2id$"
+
+type argon2Params struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h passwordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.cfg.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.cfg.Time, h.cfg.Memory, h.cfg.Parallelism, h.cfg.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.cfg.Memory,
+		h.cfg.Time,
+		h.cfg.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h passwordHasher) Compare(hash, password string) bool {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1
+}
+
+func (h passwordHasher) NeedsRehash(hash string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return p.memory != h.cfg.Memory ||
+		p.time != h.cfg.Time ||
+		p.parallelism != h.cfg.Parallelism ||
+		uint32(len(p.salt)) != h.cfg.SaltLength ||
+		uint32(len(p.key)) != h.cfg.KeyLength
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+// parseArgon2id parse $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func parseArgon2id(hash string) (p argon2Params, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, fmt.Errorf("not argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, fmt.Errorf("invalid version: %w", err)
+	}
+	if version != argon2.Version {
+		return p, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.parallelism); err != nil {
+		return p, fmt.Errorf("invalid params: %w", err)
+	}
+	// argon2 panics on zero time or parallelism
+	if p.time < 1 || p.parallelism < 1 {
+		return p, fmt.Errorf("invalid params: %s", parts[3])
+	}
+
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, fmt.Errorf("invalid salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(p.key) == 0 {
+		return p, fmt.Errorf("empty key")
+	}
+
+	return p, nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	password := "password"
+
+	hash, err := defaultPasswordHasher.Hash(password)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`, hash)
+
+	// salt is random
+	other, err := defaultPasswordHasher.Hash(password)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	stronger := NewPasswordHasher(&PasswordConfig{
+		Memory:      128, // note: differs from default
+		Time:        1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+
+	tc := []struct {
+		name        string
+		hash        string
+		password    string
+		match       bool
+		needsRehash bool
+	}{
+		{
+			name:     "OK argon2id",
+			hash:     hash,
+			password: password,
+			match:    true,
+		},
+		{
+			name:     "wrong password",
+			hash:     hash,
+			password: "wrong",
+		},
+		{
+			name:        "OK legacy bcrypt",
+			hash:        string(legacyHash),
+			password:    password,
+			match:       true,
+			needsRehash: true,
+		},
+		{
+			name:        "wrong password legacy bcrypt",
+			hash:        string(legacyHash),
+			password:    "wrong",
+			needsRehash: true,
+		},
+		{
+			name:        "params changed",
+			hash:        func() string { h, _ := stronger.Hash(password); return h }(),
+			password:    password,
+			match:       true,
+			needsRehash: true,
+		},
+		{
+			name:        "malformed hash",
+			hash:        "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+			password:    password,
+			needsRehash: true,
+		},
+		{
+			name:        "unsupported version",
+			hash:        "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+			password:    password,
+			needsRehash: true,
+		},
+		{
+			name:        "empty hash",
+			hash:        "",
+			password:    "",
+			needsRehash: true,
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, defaultPasswordHasher.Compare(test.hash, test.password))
+			assert.Equal(t, test.needsRehash, defaultPasswordHasher.NeedsRehash(test.hash))
+		})
+	}
+}
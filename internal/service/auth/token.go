@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TokenHasher hash secrets of refresh tokens and verify them.
+// Hash starts with prefix of its format, so hashes of different hashers coexist in sessions.
+// NeedsRehash tells that hash was made by other hasher or parameters,
+// it's replaced with actual one when session is refreshed.
+type TokenHasher interface {
+	Hash(token string) (string, error)
+	Compare(hash, token string) bool
+	NeedsRehash(hash string) bool
+}
+
+var (
+	_ TokenHasher = (*bcryptTokenHasher)(nil)
+	_ TokenHasher = (*hmacTokenHasher)(nil)
+)
+
+// bcryptTokenHasher is slow on purpose, it makes no sense for random secrets,
+// but it's how refresh tokens were hashed from the start.
+// hmac-sha256 hashes are accepted if key is known, so switch back from hmac hasher doesn't log users out.
+type bcryptTokenHasher struct {
+	cost int
+	hmac *hmacTokenHasher
+}
+
+// NewBcryptTokenHasher create bcrypt hasher, hmacKey is key of hmac-sha256 hashes made before, it could be nil
+func NewBcryptTokenHasher(cost int, hmacKey []byte) *bcryptTokenHasher {
+	h := &bcryptTokenHasher{cost: cost}
+	if len(hmacKey) != 0 {
+		h.hmac = NewHMACTokenHasher(hmacKey)
+	}
+	return h
+}
+
+func (h bcryptTokenHasher) Hash(token string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h bcryptTokenHasher) Compare(hash, token string) bool {
+	if h.hmac != nil && strings.HasPrefix(hash, hmacSHA256Prefix) {
+		return h.hmac.Compare(hash, token)
+	}
+	if !isBcryptHash(hash) {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(token)) == nil
+}
+
+func (h bcryptTokenHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+const hmacSHA256Prefix = "$hmac-sha256$"
+
+// hmacTokenHasher make keyed HMAC-SHA256 of secret, secret has enough entropy,
+// so key, which never leaves config, is what stops offline guessing from dump of database.
+// bcrypt hashes are still accepted for sessions refreshed before.
+type hmacTokenHasher struct {
+	key []byte
+}
+
+func NewHMACTokenHasher(key []byte) *hmacTokenHasher {
+	return &hmacTokenHasher{key: key}
+}
+
+func (h hmacTokenHasher) Hash(token string) (string, error) {
+	return hmacSHA256Prefix + base64.RawStdEncoding.EncodeToString(h.sum(token)), nil
+}
+
+func (h hmacTokenHasher) Compare(hash, token string) bool {
+	if isBcryptHash(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(token)) == nil
+	}
+
+	encoded, ok := strings.CutPrefix(hash, hmacSHA256Prefix)
+	if !ok {
+		return false
+	}
+	sum, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(sum, h.sum(token))
+}
+
+func (h hmacTokenHasher) NeedsRehash(hash string) bool {
+	return !strings.HasPrefix(hash, hmacSHA256Prefix)
+}
+
+func (h hmacTokenHasher) sum(token string) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// hashFormat return prefix of hash for logs, hash itself must not be logged
+func hashFormat(hash string) string {
+	if isBcryptHash(hash) {
+		return "bcrypt"
+	}
+	if strings.HasPrefix(hash, hmacSHA256Prefix) {
+		return "hmac-sha256"
+	}
+	return fmt.Sprintf("unknown(%d bytes)", len(hash))
+}
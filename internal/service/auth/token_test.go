@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTokenHasher(t *testing.T) {
+	token := "rand_string"
+
+	hmacHasher := NewHMACTokenHasher(bytes.Repeat([]byte{1}, 32))
+	otherKeyHasher := NewHMACTokenHasher(bytes.Repeat([]byte{2}, 32))
+	bcryptHasher := defaultTokenHasher
+
+	hmacHash, err := hmacHasher.Hash(token)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\$hmac-sha256\$[A-Za-z0-9+/]{43}$`, hmacHash)
+
+	// hmac has no salt, secret is random itself
+	again, err := hmacHasher.Hash(token)
+	require.NoError(t, err)
+	assert.Equal(t, hmacHash, again)
+
+	bcryptHash, err := bcryptHasher.Hash(token)
+	require.NoError(t, err)
+
+	otherKeyHash, err := otherKeyHasher.Hash(token)
+	require.NoError(t, err)
+
+	strongerHash, err := NewBcryptTokenHasher(bcrypt.MinCost+1, nil).Hash(token)
+	require.NoError(t, err)
+
+	// rolled back from hmac, key is still configured
+	rollbackHasher := NewBcryptTokenHasher(bcrypt.MinCost, bytes.Repeat([]byte{1}, 32))
+
+	tc := []struct {
+		name        string
+		hasher      TokenHasher
+		hash        string
+		token       string
+		match       bool
+		needsRehash bool
+	}{
+		{
+			name:   "OK hmac",
+			hasher: hmacHasher,
+			hash:   hmacHash,
+			token:  token,
+			match:  true,
+		},
+		{
+			name:   "wrong token hmac",
+			hasher: hmacHasher,
+			hash:   hmacHash,
+			token:  "wrong",
+		},
+		{
+			name:   "hmac of other key",
+			hasher: hmacHasher,
+			hash:   otherKeyHash, // note
+			token:  token,
+		},
+		{
+			name:        "OK bcrypt by hmac hasher",
+			hasher:      hmacHasher,
+			hash:        bcryptHash, // note: session refreshed before switch to hmac
+			token:       token,
+			match:       true,
+			needsRehash: true,
+		},
+		{
+			name:   "malformed hmac",
+			hasher: hmacHasher,
+			hash:   hmacSHA256Prefix + "!!!",
+			token:  token,
+		},
+		{
+			name:        "unknown format",
+			hasher:      hmacHasher,
+			hash:        "plain",
+			token:       "plain",
+			needsRehash: true,
+		},
+		{
+			name:   "OK bcrypt",
+			hasher: bcryptHasher,
+			hash:   bcryptHash,
+			token:  token,
+			match:  true,
+		},
+		{
+			name:   "wrong token bcrypt",
+			hasher: bcryptHasher,
+			hash:   bcryptHash,
+			token:  "wrong",
+		},
+		{
+			name:        "bcrypt cost changed",
+			hasher:      bcryptHasher,
+			hash:        strongerHash, // note
+			token:       token,
+			match:       true,
+			needsRehash: true,
+		},
+		{
+			name:        "hmac by bcrypt hasher",
+			hasher:      bcryptHasher,
+			hash:        hmacHash, // note: key isn't known to bcrypt hasher
+			token:       token,
+			needsRehash: true,
+		},
+		{
+			name:        "OK hmac by bcrypt hasher with key",
+			hasher:      rollbackHasher,
+			hash:        hmacHash, // note: session refreshed before rollback to bcrypt
+			token:       token,
+			match:       true,
+			needsRehash: true,
+		},
+		{
+			name:        "hmac of other key by bcrypt hasher with key",
+			hasher:      rollbackHasher,
+			hash:        otherKeyHash, // note
+			token:       token,
+			needsRehash: true,
+		},
+		{
+			name:   "OK bcrypt by bcrypt hasher with key",
+			hasher: rollbackHasher,
+			hash:   bcryptHash,
+			token:  token,
+			match:  true,
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, test.hasher.Compare(test.hash, test.token))
+			assert.Equal(t, test.needsRehash, test.hasher.NeedsRehash(test.hash))
+		})
+	}
+}
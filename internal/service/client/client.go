@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"medods/pkg/logger"
+)
+
+var (
+	ErrInvalidClient = fmt.Errorf("invalid client credentials")
+)
+
+type Interface interface {
+	Authenticate(ctx context.Context, id, secret string) error
+}
+
+var _ Interface = (*client)(nil)
+
+// client authenticate services which call api on their own behalf (e.g. token introspection),
+// credentials are taken from config
+type client struct {
+	secrets map[string]string
+	logger  logger.Interface
+}
+
+func New(secrets map[string]string, logger logger.Interface) *client {
+	return &client{
+		secrets: secrets,
+		logger:  logger,
+	}
+}
+
+func (s client) Authenticate(ctx context.Context, id, secret string) error {
+	expected, ok := s.secrets[id]
+	if !ok || len(expected) == 0 {
+		s.logger.Warn("unknown client[%s] tried to authenticate", id)
+		return ErrInvalidClient
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(secret)) != 1 {
+		s.logger.Warn("client[%s] presented invalid secret", id)
+		return ErrInvalidClient
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"medods/pkg/logger"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientAuthenticate(t *testing.T) {
+	client := New(map[string]string{
+		"billing": "secret",
+		"empty":   "",
+	}, logger.New("debug", true))
+
+	type args struct {
+		id     string
+		secret string
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			input: args{
+				id:     "billing",
+				secret: "secret",
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error invalid secret",
+			input: args{
+				id:     "billing",
+				secret: "invalid", // note: invalid
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidClient)
+			},
+		},
+		{
+			name: "error unknown client",
+			input: args{
+				id:     "unknown", // note: unknown
+				secret: "secret",
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidClient)
+			},
+		},
+		{
+			name: "error client without secret",
+			input: args{
+				id:     "empty", // note: secret is not configured
+				secret: "",
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidClient)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			err := client.Authenticate(context.Background(), test.input.id, test.input.secret)
+			test.checkResult(t, err)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package denylist
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"medods/internal/model"
+	"medods/internal/repository"
+	"medods/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	// how often expired tokens are removed from cache and database
+	PruneInterval = 5 * time.Minute
+	// how long token which isn't in database is trusted not to be revoked,
+	// revocation by other replica takes effect on this one within it
+	NotRevokedTTL = 5 * time.Second
+)
+
+type Interface interface {
+	Add(ctx context.Context, jti string, exp time.Time) error
+	Contains(ctx context.Context, jti string) (bool, error)
+	Prune(ctx context.Context) error
+}
+
+var _ Interface = (*denylist)(nil)
+
+// denylist keep revoked jti in database, so every replica knows about them,
+// and cache them in memory until token expires to not query database every time.
+// Tokens which are not revoked are cached for NotRevokedTTL, so requests with the same token
+// don't query database one after another.
+type denylist struct {
+	repo   repository.RevokedToken
+	logger logger.Interface
+
+	mu    sync.RWMutex
+	cache map[string]time.Time
+	// jti which are not revoked, with time until which it's trusted
+	notRevoked map[string]time.Time
+
+	now func() time.Time
+}
+
+func New(repo repository.RevokedToken, logger logger.Interface) *denylist {
+	return &denylist{
+		repo:   repo,
+		logger: logger,
+
+		cache:      make(map[string]time.Time),
+		notRevoked: make(map[string]time.Time),
+
+		now: time.Now,
+	}
+}
+
+// Add revoke jti until exp, already expired tokens are skipped
+func (s *denylist) Add(ctx context.Context, jti string, exp time.Time) error {
+	if !exp.After(s.now()) {
+		return nil
+	}
+
+	if err := s.repo.Create(ctx, model.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: exp.Unix(),
+	}); err != nil {
+		return err
+	}
+
+	s.store(jti, exp)
+	return nil
+}
+
+func (s *denylist) Contains(ctx context.Context, jti string) (bool, error) {
+	if exp, ok := s.load(jti); ok {
+		return exp.After(s.now()), nil
+	}
+	if s.trustedNotRevoked(jti) {
+		return false, nil
+	}
+
+	// token could be revoked by other replica
+	t, err := s.repo.GetByJTI(ctx, jti)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.storeNotRevoked(jti)
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	exp := time.Unix(t.ExpiresAt, 0)
+	if !exp.After(s.now()) {
+		return false, nil
+	}
+
+	s.store(jti, exp)
+	return true, nil
+}
+
+// Prune remove tokens which would have expired anyway
+func (s *denylist) Prune(ctx context.Context) error {
+	now := s.now()
+
+	s.mu.Lock()
+	for jti, exp := range s.cache {
+		if !exp.After(now) {
+			delete(s.cache, jti)
+		}
+	}
+	for jti, until := range s.notRevoked {
+		if !until.After(now) {
+			delete(s.notRevoked, jti)
+		}
+	}
+	s.mu.Unlock()
+
+	deleted, err := s.repo.DeleteExpired(ctx, now.Unix())
+	if err != nil {
+		return err
+	}
+	s.logger.Debug("pruned %d expired revoked tokens", deleted)
+
+	return nil
+}
+
+// RunPruner call Prune every interval until ctx is done
+func RunPruner(ctx context.Context, d Interface, interval time.Duration, l logger.Interface) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Prune(ctx); err != nil {
+				l.Error("failed to prune revoked tokens: %s", err.Error())
+			}
+		}
+	}
+}
+
+func (s *denylist) load(jti string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exp, ok := s.cache[jti]
+	return exp, ok
+}
+
+func (s *denylist) store(jti string, exp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache[jti] = exp
+	delete(s.notRevoked, jti)
+}
+
+func (s *denylist) trustedNotRevoked(jti string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	until, ok := s.notRevoked[jti]
+	return ok && until.After(s.now())
+}
+
+func (s *denylist) storeNotRevoked(jti string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notRevoked[jti] = s.now().Add(NotRevokedTTL)
+}
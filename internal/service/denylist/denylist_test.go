@@ -0,0 +1,279 @@
+package denylist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	mock_repository "medods/internal/repository/mock"
+	"medods/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDenylistAdd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_repository.NewMockRevokedToken(ctrl)
+
+	now := time.Now()
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		jti string
+		exp time.Time
+	}
+
+	defaultArgs := args{
+		jti: "jti",
+		exp: now.Add(time.Minute),
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, d *denylist, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				repo.EXPECT().Create(gomock.Any(), gomock.Eq(model.RevokedToken{
+					JTI:       defaultArgs.jti,
+					ExpiresAt: defaultArgs.exp.Unix(),
+				})).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, d *denylist, err error) {
+				assert.NoError(t, err)
+
+				exp, ok := d.load(defaultArgs.jti)
+				assert.True(t, ok)
+				assert.Equal(t, defaultArgs.exp, exp)
+				assert.False(t, d.trustedNotRevoked(defaultArgs.jti))
+			},
+		},
+		{
+			name: "OK already expired",
+			input: args{
+				jti: "jti",
+				exp: now.Add(-time.Minute), // note: expired
+			},
+			buildStubs: func() {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, d *denylist, err error) {
+				assert.NoError(t, err)
+
+				_, ok := d.load(defaultArgs.jti)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, d *denylist, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, unexpectedError, err)
+
+				_, ok := d.load(defaultArgs.jti)
+				assert.False(t, ok)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			d := New(repo, logger.New("debug", true))
+			d.now = func() time.Time { return now }
+			d.storeNotRevoked(defaultArgs.jti)
+
+			test.buildStubs()
+			err := d.Add(context.Background(), test.input.jti, test.input.exp)
+			test.checkResult(t, d, err)
+		})
+	}
+}
+
+func TestDenylistContains(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_repository.NewMockRevokedToken(ctrl)
+
+	now := time.Now()
+	jti := "jti"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func(d *denylist)
+		checkResult func(t *testing.T, d *denylist, revoked bool, err error)
+	}{
+		{
+			name: "OK cached",
+			buildStubs: func(d *denylist) {
+				d.store(jti, now.Add(time.Minute))
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+			},
+		},
+		{
+			name: "OK cached but expired",
+			buildStubs: func(d *denylist) {
+				d.store(jti, now.Add(-time.Minute))
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.False(t, revoked)
+			},
+		},
+		{
+			name: "OK revoked by other replica",
+			buildStubs: func(d *denylist) {
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Eq(jti)).Times(1).
+					Return(model.RevokedToken{JTI: jti, ExpiresAt: now.Add(time.Minute).Unix()}, nil)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+
+				_, ok := d.load(jti) // note: next check is served by cache
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "OK not revoked",
+			buildStubs: func(d *denylist) {
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Eq(jti)).Times(1).Return(model.RevokedToken{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.False(t, revoked)
+				assert.True(t, d.trustedNotRevoked(jti)) // note: next check is served by cache
+			},
+		},
+		{
+			name: "OK not revoked cached",
+			buildStubs: func(d *denylist) {
+				d.storeNotRevoked(jti)
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.False(t, revoked)
+			},
+		},
+		{
+			name: "OK not revoked cache is stale",
+			buildStubs: func(d *denylist) {
+				d.storeNotRevoked(jti)
+				d.now = func() time.Time { return now.Add(NotRevokedTTL) } // note
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Eq(jti)).Times(1).
+					Return(model.RevokedToken{JTI: jti, ExpiresAt: now.Add(time.Minute).Unix()}, nil)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+			},
+		},
+		{
+			name: "OK not pruned yet",
+			buildStubs: func(d *denylist) {
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Eq(jti)).Times(1).
+					Return(model.RevokedToken{JTI: jti, ExpiresAt: now.Add(-time.Minute).Unix()}, nil)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.NoError(t, err)
+				assert.False(t, revoked)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func(d *denylist) {
+				repo.EXPECT().GetByJTI(gomock.Any(), gomock.Eq(jti)).Times(1).Return(model.RevokedToken{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, d *denylist, revoked bool, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, unexpectedError, err)
+				assert.False(t, revoked)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			d := New(repo, logger.New("debug", true))
+			d.now = func() time.Time { return now }
+
+			test.buildStubs(d)
+			revoked, err := d.Contains(context.Background(), jti)
+			test.checkResult(t, d, revoked, err)
+		})
+	}
+}
+
+func TestDenylistPrune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_repository.NewMockRevokedToken(ctrl)
+
+	now := time.Now()
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, d *denylist, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				repo.EXPECT().DeleteExpired(gomock.Any(), gomock.Eq(now.Unix())).Times(1).Return(int64(1), nil)
+			},
+			checkResult: func(t *testing.T, d *denylist, err error) {
+				assert.NoError(t, err)
+
+				_, ok := d.load("expired")
+				assert.False(t, ok)
+				_, ok = d.load("alive")
+				assert.True(t, ok)
+				_, ok = d.notRevoked["stale"]
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				repo.EXPECT().DeleteExpired(gomock.Any(), gomock.Eq(now.Unix())).Times(1).Return(int64(0), unexpectedError)
+			},
+			checkResult: func(t *testing.T, d *denylist, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, unexpectedError, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			d := New(repo, logger.New("debug", true))
+			d.now = func() time.Time { return now }
+			d.store("expired", now.Add(-time.Minute))
+			d.store("alive", now.Add(time.Minute))
+			d.notRevoked["stale"] = now
+
+			test.buildStubs()
+			err := d.Prune(context.Background())
+			test.checkResult(t, d, err)
+		})
+	}
+}
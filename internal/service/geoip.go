@@ -0,0 +1,52 @@
+package service
+
+import (
+	"medods/config"
+	"medods/internal/model"
+	"medods/internal/service/auth"
+	"medods/pkg/geoip"
+	"medods/pkg/logger"
+	"net"
+)
+
+// NewGeoIP open geoip database, nil if it isn't set in config
+func NewGeoIP(cfg *config.Config) (*geoip.DB, error) {
+	if cfg.GeoIP.DBPath == "" {
+		return nil, nil
+	}
+	return geoip.Open(cfg.GeoIP.DBPath)
+}
+
+var _ auth.Locator = (*geoLocator)(nil)
+
+// geoLocator resolve geo of ip for auth by geoip database
+type geoLocator struct {
+	db     *geoip.DB
+	logger logger.Interface
+}
+
+func (l geoLocator) Locate(ip net.IP) (model.Geo, bool) {
+	record, ok, err := l.db.Lookup(ip)
+	if err != nil {
+		l.logger.Error("failed to look up ip[%s] in geoip database: %s", ip, err.Error())
+		return model.Geo{}, false
+	}
+	if !ok {
+		return model.Geo{}, false
+	}
+
+	geo := model.Geo{
+		Country: record.Country,
+		City:    record.City,
+		ASN:     record.ASN,
+		ASOrg:   record.Organization,
+	}
+	if record.Location != nil {
+		geo.Location = &model.Location{
+			Latitude:       record.Location.Latitude,
+			Longitude:      record.Location.Longitude,
+			AccuracyRadius: record.Location.AccuracyRadius,
+		}
+	}
+	return geo, true
+}
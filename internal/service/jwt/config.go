@@ -0,0 +1,18 @@
+package jwt
+
+import "time"
+
+type Config struct {
+	SecretKey []byte
+	// HS512, RS512, ES512 or EdDSA, asymmetric methods sign with PrivateKey
+	SigningMethod string
+	// PEM of private key, PKCS #8, PKCS #1 for RSA or SEC 1 for ECDSA
+	PrivateKey []byte
+	// keys to sign and verify tokens with, SigningMethod and PrivateKey are ignored if it's set
+	Keyring *Keyring
+	// HS512 tokens signed with SecretKey are still accepted until this time,
+	// so sessions opened before switch to asymmetric method or keyring survive it
+	HS512AcceptedUntil time.Time
+	// allowed clock skew when checking exp, iat and nbf
+	Leeway time.Duration
+}
@@ -1,49 +1,134 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"medods/internal/model"
 	"medods/pkg/logger"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	ErrTokenRevoked = fmt.Errorf("%w: token is revoked", jwt.ErrTokenInvalidId)
+)
+
 type Interface interface {
 	CreateToken(payload model.Payload) (string, error)
-	VerifyToken(tokenString string) (token *jwt.Token, payload *model.Payload, err error)
+	CreateIDToken(claims model.IDToken) (string, error)
+	VerifyToken(ctx context.Context, tokenString string) (token *jwt.Token, payload *model.Payload, err error)
+	// Algorithm return name of signing method of new tokens
+	Algorithm() string
+	// JWKS return public keys which tokens are verified with, it is empty for HS512
+	JWKS() model.JSONWebKeySet
+}
+
+// Denylist is storage of revoked jti, consulted on every verification
+type Denylist interface {
+	Contains(ctx context.Context, jti string) (bool, error)
 }
 
 var _ Interface = (*Maker)(nil)
 
 type Maker struct {
-	sercretKey    []byte
-	signingMethod jwt.SigningMethod
+	keyring *Keyring
+	// tokens signed with SECRET_KEY have no kid, they are accepted until hs512Until
+	// after switch to keyring or asymmetric method
+	sercretKey []byte
+	hs512Until time.Time
+	leeway     time.Duration
+	denylist   Denylist
+
+	now func() time.Time
 }
 
-func New(secretKey []byte, logger logger.Interface) *Maker {
+// New create jwt maker, denylist could be nil then revocation is not checked.
+// Tokens are signed with active key of keyring, or with single key of signing method if keyring is not set.
+func New(cfg *Config, denylist Denylist, logger logger.Interface) (*Maker, error) {
+	keyring := cfg.Keyring
+	if keyring == nil {
+		key, err := singleKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keyring, err = NewKeyring([]Key{key}, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := keyring.active(); !ok {
+		return nil, ErrNoActiveKey
+	}
+
 	return &Maker{
-		signingMethod: jwt.SigningMethodHS512,
-		sercretKey:    secretKey,
+		keyring:    keyring,
+		sercretKey: cfg.SecretKey,
+		hs512Until: cfg.HS512AcceptedUntil,
+		leeway:     cfg.Leeway,
+		denylist:   denylist,
+		now:        time.Now,
+	}, nil
+}
+
+// singleKey make active key of configured signing method, HS512 key has no kid like tokens signed before keyring
+func singleKey(cfg *Config) (Key, error) {
+	if cfg.SigningMethod == "" || cfg.SigningMethod == jwt.SigningMethodHS512.Alg() {
+		return Key{Algorithm: jwt.SigningMethodHS512.Alg(), Material: cfg.SecretKey, State: KeyStateActive}, nil
 	}
+
+	key, err := NewKey(cfg.SigningMethod, cfg.PrivateKey, time.Time{})
+	if err != nil {
+		return Key{}, err
+	}
+	key.State = KeyStateActive
+	return key, nil
 }
 
 func (s Maker) CreateToken(payload model.Payload) (string, error) {
-	token := jwt.NewWithClaims(s.signingMethod, payload)
-	return token.SignedString(s.sercretKey)
+	return s.sign(payload)
 }
 
-func (s Maker) VerifyToken(tokenString string) (token *jwt.Token, payload *model.Payload, err error) {
-	payload = &model.Payload{}
-	token, err = jwt.ParseWithClaims(tokenString, payload, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
+// CreateIDToken sign OpenID Connect id token, it has no claims of session, so it can't be used as access token
+func (s Maker) CreateIDToken(claims model.IDToken) (string, error) {
+	return s.sign(claims)
+}
 
-		return s.sercretKey, nil
-	},
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}),
+func (s Maker) sign(claims jwt.Claims) (string, error) {
+	key, ok := s.keyring.active()
+	if !ok {
+		return "", ErrNoActiveKey
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signingKey())
+}
+
+func (s Maker) Algorithm() string {
+	key, _ := s.keyring.active()
+	return key.Algorithm
+}
+
+func (s Maker) JWKS() model.JSONWebKeySet {
+	return s.keyring.jwks()
+}
+
+// VerifyToken check signature and claims of access token, then check that it isn't revoked within ctx
+func (s Maker) VerifyToken(ctx context.Context, tokenString string) (token *jwt.Token, payload *model.Payload, err error) {
+	validMethods := s.keyring.algorithms()
+	if s.now().Before(s.hs512Until) {
+		validMethods = append(validMethods, jwt.SigningMethodHS512.Alg())
+	}
+
+	payload = &model.Payload{}
+	token, err = jwt.ParseWithClaims(tokenString, payload, s.verificationKey,
+		jwt.WithValidMethods(validMethods),
 		jwt.WithExpirationRequired(),
 		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
 	)
 	if err != nil {
 		return token, payload, err
@@ -53,10 +138,45 @@ func (s Maker) VerifyToken(tokenString string) (token *jwt.Token, payload *model
 		return nil, nil,
 			fmt.Errorf("%w: user_id is requied", jwt.ErrTokenInvalidClaims)
 	}
+	if payload.SessionID <= 0 {
+		return nil, nil,
+			fmt.Errorf("%w: sid is required", jwt.ErrTokenInvalidClaims)
+	}
 	if len(payload.IP) == 0 {
 		return nil, nil,
 			fmt.Errorf("%w: ip is required", jwt.ErrTokenInvalidClaims)
 	}
 
+	if s.denylist != nil {
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+
+		revoked, err := s.denylist.Contains(ctx, payload.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, nil, ErrTokenRevoked
+		}
+	}
+
 	return token, payload, nil
 }
+
+// verificationKey choose key by kid, algorithm of token is already checked to be one of keyring
+func (s Maker) verificationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := s.keyring.lookup(kid)
+	if !ok && kid == "" && t.Method == jwt.SigningMethodHS512 && s.now().Before(s.hs512Until) {
+		return s.sercretKey, nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// otherwise public key could be used as hmac secret
+	if key.Algorithm != t.Method.Alg() {
+		return nil, fmt.Errorf("key %q doesn't sign %s", kid, t.Method.Alg())
+	}
+	return key.verificationKey(), nil
+}
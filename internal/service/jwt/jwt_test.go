@@ -1,7 +1,15 @@
 package jwt
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
 	"medods/internal/model"
+	mock_jwt "medods/internal/service/jwt/mock"
 	mock_logger "medods/pkg/logger/mock"
 	"testing"
 	"time"
@@ -9,6 +17,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -21,10 +30,12 @@ func TestCreateToken(t *testing.T) {
 
 	now := time.Now()
 
-	jwtMaker := New(secretKey, l)
+	jwtMaker, err := New(&Config{SecretKey: secretKey}, nil, l)
+	require.NoError(t, err)
 	defaultPayload := model.Payload{
-		UserID: 1,
-		IP:     "2",
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
@@ -34,12 +45,49 @@ func TestCreateToken(t *testing.T) {
 	token, err := jwtMaker.CreateToken(defaultPayload)
 	assert.NoError(t, err)
 
-	jwtToken, payload, err := jwtMaker.VerifyToken(token)
+	jwtToken, payload, err := jwtMaker.VerifyToken(context.Background(), token)
 	assert.NoError(t, err)
 	assert.Equal(t, defaultPayload, *payload)
 	assert.True(t, jwtToken.Valid)
 }
 
+func TestCreateIDToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	now := time.Now()
+	verified := true
+
+	jwtMaker, err := New(&Config{SecretKey: secretKey}, nil, l)
+	require.NoError(t, err)
+	defaultClaims := model.IDToken{
+		Nonce:         "nonce",
+		AuthTime:      now.Add(-time.Hour).Unix(),
+		AMR:           []string{model.AMRPassword},
+		Email:         "user@gmail.com",
+		EmailVerified: &verified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "http://localhost:8080",
+			Subject:   "1",
+			Audience:  jwt.ClaimStrings{"client"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+
+	token, err := jwtMaker.CreateIDToken(defaultClaims)
+	assert.NoError(t, err)
+
+	claims := model.IDToken{}
+	_, err = jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) { return secretKey, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, defaultClaims, claims)
+
+	// note: id token is not access token
+	_, _, err = jwtMaker.VerifyToken(context.Background(), token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidClaims)
+}
+
 func TestVerifyToken(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	l := mock_logger.NewMockInterface(ctrl)
@@ -47,10 +95,12 @@ func TestVerifyToken(t *testing.T) {
 	iat := time.Now().Add(-1 * time.Minute)
 	exp := iat.Add(30 * time.Minute)
 
-	jwtMaker := New(secretKey, l)
+	jwtMaker, err := New(&Config{SecretKey: secretKey}, nil, l)
+	require.NoError(t, err)
 	defaultPayload := model.Payload{
-		UserID: 1,
-		IP:     "2",
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 			IssuedAt:  jwt.NewNumericDate(iat),
@@ -154,6 +204,24 @@ func TestVerifyToken(t *testing.T) {
 				assert.Empty(t, payload)
 			},
 		},
+		{
+			name: "field sid is required",
+			input: func(t *testing.T) (token string) {
+				df := defaultPayload
+
+				df.SessionID = 0
+
+				token, err := jwtMaker.CreateToken(df)
+				assert.NoError(t, err)
+				return token
+			},
+			checkResult: func(t *testing.T, token *jwt.Token, payload *model.Payload, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, jwt.ErrTokenInvalidClaims)
+				assert.Empty(t, token)
+				assert.Empty(t, payload)
+			},
+		},
 		{
 			name: "field ip is required",
 			input: func(t *testing.T) (token string) {
@@ -176,8 +244,274 @@ func TestVerifyToken(t *testing.T) {
 
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
-			token, payload, err := jwtMaker.VerifyToken(test.input(t))
+			token, payload, err := jwtMaker.VerifyToken(context.Background(), test.input(t))
 			test.checkResult(t, token, payload, err)
 		})
 	}
 }
+
+func TestVerifyTokenDenylist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+	denylist := mock_jwt.NewMockDenylist(ctrl)
+
+	iat := time.Now().Add(-1 * time.Minute)
+	exp := iat.Add(30 * time.Minute)
+
+	jwtMaker, err := New(&Config{SecretKey: secretKey}, denylist, l)
+	require.NoError(t, err)
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(exp),
+			IssuedAt:  jwt.NewNumericDate(iat),
+			ID:        "test",
+		},
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, token *jwt.Token, payload *model.Payload, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				denylist.EXPECT().Contains(gomock.Any(), gomock.Eq(defaultPayload.ID)).Times(1).Return(false, nil)
+			},
+			checkResult: func(t *testing.T, token *jwt.Token, payload *model.Payload, err error) {
+				assert.NoError(t, err)
+				assert.True(t, token.Valid)
+				assert.Equal(t, defaultPayload, *payload)
+			},
+		},
+		{
+			name: "token is revoked",
+			buildStubs: func() {
+				denylist.EXPECT().Contains(gomock.Any(), gomock.Eq(defaultPayload.ID)).Times(1).Return(true, nil)
+			},
+			checkResult: func(t *testing.T, token *jwt.Token, payload *model.Payload, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrTokenRevoked)
+				assert.ErrorIs(t, err, jwt.ErrTokenInvalidId)
+				assert.Empty(t, token)
+				assert.Empty(t, payload)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				denylist.EXPECT().Contains(gomock.Any(), gomock.Eq(defaultPayload.ID)).Times(1).Return(false, unexpectedError)
+			},
+			checkResult: func(t *testing.T, token *jwt.Token, payload *model.Payload, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, token)
+				assert.Empty(t, payload)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			token, err := jwtMaker.CreateToken(defaultPayload)
+			assert.NoError(t, err)
+
+			jwtToken, payload, err := jwtMaker.VerifyToken(context.Background(), token)
+			test.checkResult(t, jwtToken, payload, err)
+		})
+	}
+}
+
+func TestVerifyTokenLeeway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	now := time.Now()
+
+	payload := model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now.Add(-10 * time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(-10 * time.Second)), // note: just expired
+		},
+	}
+
+	strict, err := New(&Config{SecretKey: secretKey}, nil, l)
+	require.NoError(t, err)
+	tolerant, err := New(&Config{SecretKey: secretKey, Leeway: time.Minute}, nil, l)
+	require.NoError(t, err)
+
+	token, err := strict.CreateToken(payload)
+	assert.NoError(t, err)
+
+	_, _, err = strict.VerifyToken(context.Background(), token)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	_, _, err = tolerant.VerifyToken(context.Background(), token)
+	assert.NoError(t, err)
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+
+	tc := []struct {
+		name  string
+		input Config
+		kty   string
+	}{
+		{
+			name:  "RS512",
+			input: Config{SigningMethod: "RS512", PrivateKey: encodePrivateKey(t, rsaKey)},
+			kty:   "RSA",
+		},
+		{
+			name:  "ES512",
+			input: Config{SigningMethod: "ES512", PrivateKey: encodePrivateKey(t, ecKey)},
+			kty:   "EC",
+		},
+		{
+			name:  "EdDSA",
+			input: Config{SigningMethod: "EdDSA", PrivateKey: encodePrivateKey(t, edKey)},
+			kty:   "OKP",
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.input.SecretKey = secretKey
+			jwtMaker, err := New(&test.input, nil, l)
+			require.NoError(t, err)
+			assert.Equal(t, test.name, jwtMaker.Algorithm())
+
+			jwks := jwtMaker.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, test.kty, jwks.Keys[0].KeyType)
+			assert.Equal(t, test.name, jwks.Keys[0].Algorithm)
+
+			token, err := jwtMaker.CreateToken(defaultPayload)
+			require.NoError(t, err)
+
+			jwtToken, payload, err := jwtMaker.VerifyToken(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, jwks.Keys[0].KeyID, jwtToken.Header["kid"])
+			assert.Equal(t, defaultPayload, *payload)
+
+			// note: HS512 is not accepted without migration window
+			hsToken, err := jwt.NewWithClaims(jwt.SigningMethodHS512, defaultPayload).SignedString(secretKey)
+			require.NoError(t, err)
+			_, _, err = jwtMaker.VerifyToken(context.Background(), hsToken)
+			assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+		})
+	}
+}
+
+func TestVerifyTokenHS512Migration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	defaultPayload := model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+
+	jwtMaker, err := New(&Config{
+		SecretKey:          secretKey,
+		SigningMethod:      "EdDSA",
+		PrivateKey:         encodePrivateKey(t, edKey),
+		HS512AcceptedUntil: now.Add(time.Hour),
+	}, nil, l)
+	require.NoError(t, err)
+
+	tc := []struct {
+		name        string
+		input       func(t *testing.T) (token string)
+		now         time.Time
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK HS512 within window",
+			input: func(t *testing.T) (token string) {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, defaultPayload).SignedString(secretKey)
+				require.NoError(t, err)
+				return token
+			},
+			now: now,
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error HS512 after window",
+			input: func(t *testing.T) (token string) {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, defaultPayload).SignedString(secretKey)
+				require.NoError(t, err)
+				return token
+			},
+			now: now.Add(2 * time.Hour), // note
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+			},
+		},
+		{
+			name: "error unknown key id",
+			input: func(t *testing.T) (token string) {
+				other := jwt.NewWithClaims(jwt.SigningMethodEdDSA, defaultPayload)
+				other.Header["kid"] = "other"
+				token, err := other.SignedString(otherKey)
+				require.NoError(t, err)
+				return token
+			},
+			now: now,
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, jwt.ErrTokenUnverifiable)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			jwtMaker.now = func() time.Time { return test.now }
+			_, _, err := jwtMaker.VerifyToken(context.Background(), test.input(t))
+			test.checkResult(t, err)
+		})
+	}
+}
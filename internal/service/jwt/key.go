@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"medods/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minimal size of RSA key, NIST SP 800-57
+const minRSABits = 2048
+
+// ParsePrivateKey decode PEM private key and check that it fits signing method
+func ParsePrivateKey(method jwt.SigningMethod, data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if method != jwt.SigningMethodRS512 {
+			break
+		}
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits, got %d", minRSABits, k.N.BitLen())
+		}
+		return k, nil
+	case *ecdsa.PrivateKey:
+		if method != jwt.SigningMethodES512 {
+			break
+		}
+		if k.Curve != elliptic.P521() {
+			return nil, fmt.Errorf("%s requires P-521 key, got %s", method.Alg(), k.Curve.Params().Name)
+		}
+		return k, nil
+	case ed25519.PrivateKey:
+		if method != jwt.SigningMethodEdDSA {
+			break
+		}
+		return k, nil
+	}
+
+	return nil, fmt.Errorf("private key %T doesn't fit signing method %s", key, method.Alg())
+}
+
+// publicJWK encode public key, kid is its thumbprint (RFC 7638),
+// so it is stable across restarts and doesn't need to be configured
+func publicJWK(method jwt.SigningMethod, pub crypto.PublicKey) (model.JSONWebKey, error) {
+	jwk := model.JSONWebKey{Use: "sig", Algorithm: method.Alg()}
+
+	// members of thumbprint are required ones in lexicographic order
+	var thumbprint string
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(k.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(k.E)).Bytes())
+		thumbprint = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.KeyType, jwk.N)
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = k.Curve.Params().Name
+		jwk.X = b64(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(k.Y.FillBytes(make([]byte, size)))
+		thumbprint = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Curve, jwk.KeyType, jwk.X, jwk.Y)
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(k)
+		thumbprint = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Curve, jwk.KeyType, jwk.X)
+	default:
+		return model.JSONWebKey{}, fmt.Errorf("unsupported public key %T", pub)
+	}
+
+	sum := sha256.Sum256([]byte(thumbprint))
+	jwk.KeyID = b64(sum[:])
+	return jwk, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePrivateKey(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	shortRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	type args struct {
+		method jwt.SigningMethod
+		data   []byte
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		checkResult func(t *testing.T, key crypto.Signer, err error)
+	}{
+		{
+			name:  "OK RS512",
+			input: args{jwt.SigningMethodRS512, encodePrivateKey(t, rsaKey)},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, rsaKey, key)
+			},
+		},
+		{
+			name: "OK RS512 PKCS #1",
+			input: args{jwt.SigningMethodRS512, pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+			})},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				require.NoError(t, err)
+				assert.True(t, rsaKey.Equal(key))
+			},
+		},
+		{
+			name:  "OK ES512 SEC 1",
+			input: args{jwt.SigningMethodES512, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				require.NoError(t, err)
+				assert.True(t, ecKey.Equal(key))
+			},
+		},
+		{
+			name:  "OK EdDSA",
+			input: args{jwt.SigningMethodEdDSA, encodePrivateKey(t, edKey)},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, edKey, key)
+			},
+		},
+		{
+			name:  "error not PEM",
+			input: args{jwt.SigningMethodEdDSA, []byte("not pem")},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:  "error key doesn't fit method",
+			input: args{jwt.SigningMethodEdDSA, encodePrivateKey(t, rsaKey)}, // note
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:  "error short rsa key",
+			input: args{jwt.SigningMethodRS512, encodePrivateKey(t, shortRSAKey)},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:  "error wrong curve",
+			input: args{jwt.SigningMethodES512, encodePrivateKey(t, p256Key)},
+			checkResult: func(t *testing.T, key crypto.Signer, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := ParsePrivateKey(test.input.method, test.input.data)
+			test.checkResult(t, key, err)
+		})
+	}
+}
+
+// example of RFC 7638 section 3.1
+func TestPublicJWKThumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	require.NoError(t, err)
+
+	jwk, err := publicJWK(jwt.SigningMethodRS512, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537})
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jwk.KeyID)
+	assert.Equal(t, "AQAB", jwk.E)
+	assert.Equal(t, "RS512", jwk.Algorithm)
+}
@@ -0,0 +1,351 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"medods/internal/model"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// states of key in keyring
+const (
+	// key signs new tokens, keyring has at most one active key
+	KeyStateActive = "active"
+	// key only verifies tokens: it is introduced and waits for promotion, so verifiers learn it from jwks in advance,
+	// or it was active and waits for retirement until tokens signed with it expire
+	KeyStateVerify = "verify"
+)
+
+var (
+	ErrKeyNotFound = fmt.Errorf("key is not found")
+	ErrKeyExists   = fmt.Errorf("key with the same kid already exists")
+	ErrKeyActive   = fmt.Errorf("active key can't be retired, promote other key first")
+	ErrKeyInUse    = fmt.Errorf("tokens signed with key may be still valid")
+	ErrNoActiveKey = fmt.Errorf("keyring has no active key")
+)
+
+// size of random secret of generated HS512 key, the same as size of hash
+const hs512SecretBytes = 64
+
+// size of generated RSA keys
+const rsaBits = 3072
+
+// Key is signing key of keyring, material is kept as is, so keyring could be saved and loaded back
+type Key struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	// PEM of private key, secret itself for HS512
+	Material []byte `json:"material"`
+	State    string `json:"state"`
+	// unix time
+	CreatedAt int64 `json:"created_at"`
+	// unix time when key started signing, scheduled rotation is counted from it
+	PromotedAt int64 `json:"promoted_at,omitempty"`
+	// unix time when key stopped signing, retirement is counted from it
+	DemotedAt int64 `json:"demoted_at,omitempty"`
+
+	method jwt.SigningMethod
+	// private key of asymmetric method, nil for HS512
+	signer crypto.Signer
+	public model.JSONWebKey
+}
+
+// NewKey make verification-only key of material, kid of asymmetric key is thumbprint of its public key
+func NewKey(alg string, material []byte, createdAt time.Time) (Key, error) {
+	key := Key{
+		Algorithm: alg,
+		Material:  material,
+		State:     KeyStateVerify,
+		CreatedAt: createdAt.Unix(),
+	}
+	if alg == jwt.SigningMethodHS512.Alg() {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return Key{}, fmt.Errorf("failed to generate kid: %w", err)
+		}
+		key.ID = b64(id)
+	}
+
+	if err := key.parse(); err != nil {
+		return Key{}, err
+	}
+	if key.ID == "" {
+		key.ID = key.public.KeyID
+	}
+	key.public.KeyID = key.ID
+
+	return key, nil
+}
+
+// GenerateKey make verification-only key with random material
+func GenerateKey(alg string, createdAt time.Time) (Key, error) {
+	var private interface{}
+	var err error
+	switch alg {
+	case jwt.SigningMethodHS512.Alg():
+		secret := make([]byte, hs512SecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return Key{}, fmt.Errorf("failed to generate secret: %w", err)
+		}
+		return NewKey(alg, secret, createdAt)
+	case jwt.SigningMethodRS512.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	case jwt.SigningMethodES512.Alg():
+		private, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return Key{}, fmt.Errorf("unsupported signing method %q", alg)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to encode key: %w", err)
+	}
+	return NewKey(alg, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), createdAt)
+}
+
+// parse material of key according to its algorithm
+func (k *Key) parse() error {
+	switch k.Algorithm {
+	case jwt.SigningMethodHS512.Alg():
+		if len(k.Material) == 0 {
+			return fmt.Errorf("secret of key %q is empty", k.ID)
+		}
+		k.method = jwt.SigningMethodHS512
+		return nil
+	case jwt.SigningMethodRS512.Alg(), jwt.SigningMethodES512.Alg(), jwt.SigningMethodEdDSA.Alg():
+		k.method = jwt.GetSigningMethod(k.Algorithm)
+	default:
+		return fmt.Errorf("unsupported signing method %q", k.Algorithm)
+	}
+
+	signer, err := ParsePrivateKey(k.method, k.Material)
+	if err != nil {
+		return err
+	}
+	public, err := publicJWK(k.method, signer.Public())
+	if err != nil {
+		return err
+	}
+	k.signer = signer
+	k.public = public
+	return nil
+}
+
+// signingKey return secret or private key, which token is signed with
+func (k Key) signingKey() interface{} {
+	if k.signer == nil {
+		return k.Material
+	}
+	return k.signer
+}
+
+// verificationKey return secret or public key, which token is verified with
+func (k Key) verificationKey() interface{} {
+	if k.signer == nil {
+		return k.Material
+	}
+	return k.signer.Public()
+}
+
+// Keyring hold one active key, which signs new tokens, and verification-only keys, selected by kid.
+// Rotation is: introduce new key, promote it, when old key has no valid tokens retire it.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []Key
+	// time after demotion when tokens signed with key may be still valid
+	retireAfter time.Duration
+
+	now func() time.Time
+}
+
+// NewKeyring parse keys, e.g. loaded from KeyStore; keyring without active key can't sign and is valid only for administration
+func NewKeyring(keys []Key, retireAfter time.Duration) (*Keyring, error) {
+	keyring := &Keyring{
+		retireAfter: retireAfter,
+		now:         time.Now,
+	}
+	if err := keyring.Reload(keys); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
+
+// Reload replace keys, e.g. changed in KeyStore by other instance, keyring is kept as is if keys are invalid
+func (k *Keyring) Reload(keys []Key) error {
+	parsed := &Keyring{keys: make([]Key, 0, len(keys))}
+
+	active := 0
+	for _, key := range keys {
+		if err := key.parse(); err != nil {
+			return fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		key.public.KeyID = key.ID
+
+		if parsed.find(key.ID) >= 0 {
+			return fmt.Errorf("%w: %q", ErrKeyExists, key.ID)
+		}
+		if key.State == KeyStateActive {
+			active++
+		} else if key.State != KeyStateVerify {
+			return fmt.Errorf("key %q has unknown state %q", key.ID, key.State)
+		}
+
+		parsed.keys = append(parsed.keys, key)
+	}
+	if active > 1 {
+		return fmt.Errorf("keyring has %d active keys", active)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = parsed.keys
+	return nil
+}
+
+// Keys return copy of keys to save or list them
+func (k *Keyring) Keys() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return append([]Key(nil), k.keys...)
+}
+
+// Introduce add key as verification-only
+func (k *Keyring) Introduce(key Key) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.find(key.ID) >= 0 {
+		return fmt.Errorf("%w: %q", ErrKeyExists, key.ID)
+	}
+
+	key.State = KeyStateVerify
+	key.PromotedAt = 0
+	key.DemotedAt = 0
+	k.keys = append(k.keys, key)
+	return nil
+}
+
+// Promote make key active, previous active key stays for verification until it is retired
+func (k *Keyring) Promote(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := k.find(kid)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+	}
+	if k.keys[i].State == KeyStateActive {
+		return nil
+	}
+
+	for j := range k.keys {
+		if k.keys[j].State == KeyStateActive {
+			k.keys[j].State = KeyStateVerify
+			k.keys[j].DemotedAt = k.now().Unix()
+		}
+	}
+	k.keys[i].State = KeyStateActive
+	k.keys[i].PromotedAt = k.now().Unix()
+	k.keys[i].DemotedAt = 0
+	return nil
+}
+
+// Retire remove verification-only key, demoted key is removed only when all tokens signed with it are expired
+func (k *Keyring) Retire(kid string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := k.find(kid)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+	}
+	key := k.keys[i]
+	if key.State == KeyStateActive {
+		return ErrKeyActive
+	}
+	if key.DemotedAt != 0 {
+		if retireAt := time.Unix(key.DemotedAt, 0).Add(k.retireAfter); k.now().Before(retireAt) {
+			return fmt.Errorf("%w: key %q can be retired after %s", ErrKeyInUse, kid, retireAt.Format(time.RFC3339))
+		}
+	}
+
+	k.keys = append(k.keys[:i], k.keys[i+1:]...)
+	return nil
+}
+
+// active return key which signs new tokens
+func (k *Keyring) active() (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.State == KeyStateActive {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+func (k *Keyring) lookup(kid string) (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if i := k.find(kid); i >= 0 {
+		return k.keys[i], true
+	}
+	return Key{}, false
+}
+
+// algorithms return signing methods of all keys, tokens with other alg are rejected before key lookup
+func (k *Keyring) algorithms() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	algs := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		algs = append(algs, key.Algorithm)
+	}
+	return algs
+}
+
+// jwks return public parts of asymmetric keys, introduced ones included
+func (k *Keyring) jwks() model.JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := model.JSONWebKeySet{Keys: []model.JSONWebKey{}}
+	for _, key := range k.keys {
+		if key.signer != nil {
+			set.Keys = append(set.Keys, key.public)
+		}
+	}
+	return set
+}
+
+// find return index of key, caller must hold lock
+func (k *Keyring) find(kid string) int {
+	for i, key := range k.keys {
+		if key.ID == kid {
+			return i
+		}
+	}
+	return -1
+}
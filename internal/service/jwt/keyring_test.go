@@ -0,0 +1,314 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"medods/internal/model"
+	mock_logger "medods/pkg/logger/mock"
+	"medods/pkg/sealer"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKey(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	for _, alg := range []string{"HS512", "ES512", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateKey(alg, now)
+			require.NoError(t, err)
+			assert.NotEmpty(t, key.ID)
+			assert.Equal(t, alg, key.Algorithm)
+			assert.Equal(t, KeyStateVerify, key.State)
+			assert.Equal(t, now.Unix(), key.CreatedAt)
+		})
+	}
+
+	_, err := GenerateKey("HS256", now)
+	assert.Error(t, err)
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	retireAfter := 30 * time.Minute
+
+	oldKey, err := GenerateKey("EdDSA", now)
+	require.NoError(t, err)
+	oldKey.State = KeyStateActive
+	newKey, err := GenerateKey("EdDSA", now)
+	require.NoError(t, err)
+
+	newKeyring := func(t *testing.T) *Keyring {
+		keyring, err := NewKeyring([]Key{oldKey}, retireAfter)
+		require.NoError(t, err)
+		keyring.now = func() time.Time { return now }
+		return keyring
+	}
+
+	tc := []struct {
+		name        string
+		input       func(t *testing.T, keyring *Keyring) error
+		checkResult func(t *testing.T, keyring *Keyring, err error)
+	}{
+		{
+			name: "OK introduce",
+			input: func(t *testing.T, keyring *Keyring) error {
+				return keyring.Introduce(newKey)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				active, _ := keyring.active()
+				assert.Equal(t, oldKey.ID, active.ID)
+				// note: introduced key is published before it signs
+				assert.Len(t, keyring.jwks().Keys, 2)
+			},
+		},
+		{
+			name: "OK promote",
+			input: func(t *testing.T, keyring *Keyring) error {
+				require.NoError(t, keyring.Introduce(newKey))
+				return keyring.Promote(newKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				active, _ := keyring.active()
+				assert.Equal(t, newKey.ID, active.ID)
+
+				old, ok := keyring.lookup(oldKey.ID)
+				require.True(t, ok)
+				assert.Equal(t, KeyStateVerify, old.State)
+				assert.Equal(t, now.Unix(), old.DemotedAt)
+			},
+		},
+		{
+			name: "OK retire after tokens expired",
+			input: func(t *testing.T, keyring *Keyring) error {
+				require.NoError(t, keyring.Introduce(newKey))
+				require.NoError(t, keyring.Promote(newKey.ID))
+				keyring.now = func() time.Time { return now.Add(retireAfter) }
+				return keyring.Retire(oldKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				_, ok := keyring.lookup(oldKey.ID)
+				assert.False(t, ok)
+				assert.Len(t, keyring.Keys(), 1)
+			},
+		},
+		{
+			name: "OK retire not promoted key",
+			input: func(t *testing.T, keyring *Keyring) error {
+				require.NoError(t, keyring.Introduce(newKey))
+				return keyring.Retire(newKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				assert.Len(t, keyring.Keys(), 1)
+			},
+		},
+		{
+			name: "error introduce existing key",
+			input: func(t *testing.T, keyring *Keyring) error {
+				return keyring.Introduce(oldKey)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				assert.ErrorIs(t, err, ErrKeyExists)
+			},
+		},
+		{
+			name: "error promote unknown key",
+			input: func(t *testing.T, keyring *Keyring) error {
+				return keyring.Promote(newKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				assert.ErrorIs(t, err, ErrKeyNotFound)
+			},
+		},
+		{
+			name: "error retire active key",
+			input: func(t *testing.T, keyring *Keyring) error {
+				return keyring.Retire(oldKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				assert.ErrorIs(t, err, ErrKeyActive)
+			},
+		},
+		{
+			name: "error retire before tokens expired",
+			input: func(t *testing.T, keyring *Keyring) error {
+				require.NoError(t, keyring.Introduce(newKey))
+				require.NoError(t, keyring.Promote(newKey.ID))
+				keyring.now = func() time.Time { return now.Add(retireAfter - time.Second) } // note
+				return keyring.Retire(oldKey.ID)
+			},
+			checkResult: func(t *testing.T, keyring *Keyring, err error) {
+				assert.ErrorIs(t, err, ErrKeyInUse)
+				assert.Len(t, keyring.Keys(), 2)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			keyring := newKeyring(t)
+			err := test.input(t, keyring)
+			test.checkResult(t, keyring, err)
+		})
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	first, err := GenerateKey("EdDSA", time.Now())
+	require.NoError(t, err)
+	first.State = KeyStateActive
+	second, err := GenerateKey("HS512", time.Now())
+	require.NoError(t, err)
+	second.State = KeyStateActive
+
+	_, err = NewKeyring([]Key{first, second}, time.Minute)
+	assert.Error(t, err)
+
+	_, err = NewKeyring([]Key{first, first}, time.Minute)
+	assert.ErrorIs(t, err, ErrKeyExists)
+
+	broken := first
+	broken.Material = []byte("not pem")
+	_, err = NewKeyring([]Key{broken}, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestFileKeyStore(t *testing.T) {
+	store := NewFileKeyStore(filepath.Join(t.TempDir(), "keyring.json"))
+
+	keys, err := store.Load(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	key, err := GenerateKey("EdDSA", time.Unix(1600000000, 0))
+	require.NoError(t, err)
+	key.State = KeyStateActive
+	require.NoError(t, store.Update(context.Background(), func(keys []Key) ([]Key, error) {
+		return append(keys, key), nil
+	}))
+
+	keys, err = store.Load(context.Background())
+	require.NoError(t, err)
+	keyring, err := NewKeyring(keys, time.Minute)
+	require.NoError(t, err)
+
+	loaded, ok := keyring.active()
+	require.True(t, ok)
+	assert.Equal(t, key.ID, loaded.ID)
+	assert.Equal(t, key.Material, loaded.Material)
+	assert.Equal(t, key.CreatedAt, loaded.CreatedAt)
+}
+
+// memoryKeyRepository is KeyRepository without database
+type memoryKeyRepository struct {
+	keys []model.SigningKey
+}
+
+func (r *memoryKeyRepository) List(ctx context.Context) ([]model.SigningKey, error) {
+	return r.keys, nil
+}
+
+func (r *memoryKeyRepository) Update(ctx context.Context, fn func(keys []model.SigningKey) ([]model.SigningKey, error)) error {
+	keys, err := fn(r.keys)
+	if err != nil {
+		return err
+	}
+	r.keys = keys
+	return nil
+}
+
+func TestSealedKeyStore(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{1}, 32)
+	s, err := sealer.New(masterKey)
+	require.NoError(t, err)
+
+	repo := &memoryKeyRepository{}
+	store := NewSealedKeyStore(repo, s)
+
+	key, err := GenerateKey("HS512", time.Unix(1600000000, 0))
+	require.NoError(t, err)
+	require.NoError(t, store.Update(context.Background(), func(keys []Key) ([]Key, error) {
+		return append(keys, key), nil
+	}))
+
+	// note: material isn't stored in plain
+	require.Len(t, repo.keys, 1)
+	assert.NotContains(t, repo.keys[0].Material, string(key.Material))
+	assert.NotContains(t, repo.keys[0].Material, b64(key.Material))
+
+	keys, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+	assert.Equal(t, key.Material, keys[0].Material)
+
+	other, err := sealer.New(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+	_, err = NewSealedKeyStore(repo, other).Load(context.Background())
+	assert.Error(t, err)
+}
+
+func TestMakerKeyRotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	oldKey, err := GenerateKey("HS512", time.Now())
+	require.NoError(t, err)
+	oldKey.State = KeyStateActive
+	newKey, err := GenerateKey("EdDSA", time.Now())
+	require.NoError(t, err)
+
+	keyring, err := NewKeyring([]Key{oldKey}, 0)
+	require.NoError(t, err)
+
+	jwtMaker, err := New(&Config{SecretKey: secretKey, Keyring: keyring}, nil, l)
+	require.NoError(t, err)
+
+	now := time.Now()
+	payload := model.Payload{
+		UserID:    1,
+		SessionID: 1,
+		IP:        "2",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+
+	oldToken, err := jwtMaker.CreateToken(payload)
+	require.NoError(t, err)
+
+	require.NoError(t, keyring.Introduce(newKey))
+	require.NoError(t, keyring.Promote(newKey.ID))
+	assert.Equal(t, "EdDSA", jwtMaker.Algorithm())
+
+	newToken, err := jwtMaker.CreateToken(payload)
+	require.NoError(t, err)
+
+	token, _, err := jwtMaker.VerifyToken(context.Background(), newToken)
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, token.Header["kid"])
+
+	// note: token of demoted key is valid until key is retired
+	token, _, err = jwtMaker.VerifyToken(context.Background(), oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.ID, token.Header["kid"])
+
+	require.NoError(t, keyring.Retire(oldKey.ID))
+	_, _, err = jwtMaker.VerifyToken(context.Background(), oldToken)
+	assert.Error(t, err)
+
+	// note: keyring without active key can't sign
+	_, err = New(&Config{Keyring: &Keyring{}}, nil, l)
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+}
@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"medods/internal/model"
+	"medods/pkg/sealer"
+	"os"
+	"path/filepath"
+)
+
+// KeyStore keeps keys of keyring, so they survive restarts and are shared by instances and admin command
+type KeyStore interface {
+	Load(ctx context.Context) ([]Key, error)
+	// Update replace keys with result of fn, updates of other instances wait until it's done
+	Update(ctx context.Context, fn func(keys []Key) ([]Key, error)) error
+}
+
+var _ KeyStore = (*FileKeyStore)(nil)
+
+// FileKeyStore keeps keys in json file readable only by owner, it's not locked, so it suits single instance
+type FileKeyStore struct {
+	path string
+}
+
+func NewFileKeyStore(path string) *FileKeyStore {
+	return &FileKeyStore{path: path}
+}
+
+type keyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Load return no keys if file doesn't exist yet
+func (s FileKeyStore) Load(ctx context.Context) ([]Key, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode keyring: %w", err)
+	}
+	return file.Keys, nil
+}
+
+// Update replace file atomically, so instances reading it concurrently never read half-written keyring
+func (s FileKeyStore) Update(ctx context.Context, fn func(keys []Key) ([]Key, error)) error {
+	keys, err := s.Load(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err = fn(keys)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(keyFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keyring: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	return nil
+}
+
+// KeyRepository is storage of sealed keys shared by instances
+type KeyRepository interface {
+	List(ctx context.Context) ([]model.SigningKey, error)
+	Update(ctx context.Context, fn func(keys []model.SigningKey) ([]model.SigningKey, error)) error
+}
+
+var _ KeyStore = (*SealedKeyStore)(nil)
+
+// SealedKeyStore keeps keys in database, material is encrypted with master key,
+// so dump of database doesn't allow to sign tokens
+type SealedKeyStore struct {
+	repo   KeyRepository
+	sealer *sealer.Sealer
+}
+
+func NewSealedKeyStore(repo KeyRepository, sealer *sealer.Sealer) *SealedKeyStore {
+	return &SealedKeyStore{repo: repo, sealer: sealer}
+}
+
+func (s SealedKeyStore) Load(ctx context.Context) ([]Key, error) {
+	stored, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keyring: %w", err)
+	}
+	return s.open(stored)
+}
+
+func (s SealedKeyStore) Update(ctx context.Context, fn func(keys []Key) ([]Key, error)) error {
+	return s.repo.Update(ctx, func(stored []model.SigningKey) ([]model.SigningKey, error) {
+		keys, err := s.open(stored)
+		if err != nil {
+			return nil, err
+		}
+		keys, err = fn(keys)
+		if err != nil {
+			return nil, err
+		}
+		return s.seal(keys)
+	})
+}
+
+func (s SealedKeyStore) open(stored []model.SigningKey) ([]Key, error) {
+	keys := make([]Key, 0, len(stored))
+	for _, k := range stored {
+		material, err := s.sealer.Open(k.Material)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open key %q: %w", k.ID, err)
+		}
+		keys = append(keys, Key{
+			ID:         k.ID,
+			Algorithm:  k.Algorithm,
+			Material:   material,
+			State:      k.State,
+			CreatedAt:  k.CreatedAt,
+			PromotedAt: k.PromotedAt,
+			DemotedAt:  k.DemotedAt,
+		})
+	}
+	return keys, nil
+}
+
+func (s SealedKeyStore) seal(keys []Key) ([]model.SigningKey, error) {
+	stored := make([]model.SigningKey, 0, len(keys))
+	for _, k := range keys {
+		material, err := s.sealer.Seal(k.Material)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal key %q: %w", k.ID, err)
+		}
+		stored = append(stored, model.SigningKey{
+			ID:         k.ID,
+			Algorithm:  k.Algorithm,
+			Material:   material,
+			State:      k.State,
+			CreatedAt:  k.CreatedAt,
+			PromotedAt: k.PromotedAt,
+			DemotedAt:  k.DemotedAt,
+		})
+	}
+	return stored, nil
+}
@@ -5,6 +5,7 @@
 package mock_jwt
 
 import (
+	context "context"
 	model "medods/internal/model"
 	reflect "reflect"
 
@@ -35,6 +36,35 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// Algorithm mocks base method.
+func (m *MockInterface) Algorithm() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Algorithm")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Algorithm indicates an expected call of Algorithm.
+func (mr *MockInterfaceMockRecorder) Algorithm() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Algorithm", reflect.TypeOf((*MockInterface)(nil).Algorithm))
+}
+
+// CreateIDToken mocks base method.
+func (m *MockInterface) CreateIDToken(claims model.IDToken) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIDToken", claims)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIDToken indicates an expected call of CreateIDToken.
+func (mr *MockInterfaceMockRecorder) CreateIDToken(claims interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIDToken", reflect.TypeOf((*MockInterface)(nil).CreateIDToken), claims)
+}
+
 // CreateToken mocks base method.
 func (m *MockInterface) CreateToken(payload model.Payload) (string, error) {
 	m.ctrl.T.Helper()
@@ -50,10 +80,24 @@ func (mr *MockInterfaceMockRecorder) CreateToken(payload interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockInterface)(nil).CreateToken), payload)
 }
 
+// JWKS mocks base method.
+func (m *MockInterface) JWKS() model.JSONWebKeySet {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(model.JSONWebKeySet)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockInterfaceMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockInterface)(nil).JWKS))
+}
+
 // VerifyToken mocks base method.
-func (m *MockInterface) VerifyToken(tokenString string) (*jwt.Token, *model.Payload, error) {
+func (m *MockInterface) VerifyToken(ctx context.Context, tokenString string) (*jwt.Token, *model.Payload, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VerifyToken", tokenString)
+	ret := m.ctrl.Call(m, "VerifyToken", ctx, tokenString)
 	ret0, _ := ret[0].(*jwt.Token)
 	ret1, _ := ret[1].(*model.Payload)
 	ret2, _ := ret[2].(error)
@@ -61,7 +105,45 @@ func (m *MockInterface) VerifyToken(tokenString string) (*jwt.Token, *model.Payl
 }
 
 // VerifyToken indicates an expected call of VerifyToken.
-func (mr *MockInterfaceMockRecorder) VerifyToken(tokenString interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) VerifyToken(ctx, tokenString interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyToken", reflect.TypeOf((*MockInterface)(nil).VerifyToken), ctx, tokenString)
+}
+
+// MockDenylist is a mock of Denylist interface.
+type MockDenylist struct {
+	ctrl     *gomock.Controller
+	recorder *MockDenylistMockRecorder
+}
+
+// MockDenylistMockRecorder is the mock recorder for MockDenylist.
+type MockDenylistMockRecorder struct {
+	mock *MockDenylist
+}
+
+// NewMockDenylist creates a new mock instance.
+func NewMockDenylist(ctrl *gomock.Controller) *MockDenylist {
+	mock := &MockDenylist{ctrl: ctrl}
+	mock.recorder = &MockDenylistMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDenylist) EXPECT() *MockDenylistMockRecorder {
+	return m.recorder
+}
+
+// Contains mocks base method.
+func (m *MockDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Contains", ctx, jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Contains indicates an expected call of Contains.
+func (mr *MockDenylistMockRecorder) Contains(ctx, jti interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyToken", reflect.TypeOf((*MockInterface)(nil).VerifyToken), tokenString)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Contains", reflect.TypeOf((*MockDenylist)(nil).Contains), ctx, jti)
 }
@@ -0,0 +1,186 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"medods/pkg/logger"
+	"time"
+)
+
+// RotationConfig is schedule of automatic rotation, zero interval disables it and keyring is only reloaded
+type RotationConfig struct {
+	// signing method of generated keys
+	Algorithm string
+	// active key is replaced when it has been signing this long
+	Interval time.Duration
+	// next key is introduced this long before promotion, so verifiers caching jwks learn it in advance
+	PrePublish time.Duration
+}
+
+// Rotator rotate keys in store on schedule and reload keyring from it,
+// so every instance picks up keys changed by others or by admin command without restart
+type Rotator struct {
+	keyring *Keyring
+	store   KeyStore
+	cfg     *RotationConfig
+	logger  logger.Interface
+
+	now func() time.Time
+}
+
+func NewRotator(keyring *Keyring, store KeyStore, cfg *RotationConfig, logger logger.Interface) *Rotator {
+	return &Rotator{
+		keyring: keyring,
+		store:   store,
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// rotationPlan is changes of keyring which are due
+type rotationPlan struct {
+	// generate next key, it's promoted at once if keyring has no active key
+	introduce bool
+	promote   string
+	retire    []string
+}
+
+func (p rotationPlan) empty() bool {
+	return !p.introduce && p.promote == "" && len(p.retire) == 0
+}
+
+// Rotate apply due changes to stored keys and reload keyring with them.
+// Changes are planned on keys read without lock and planned again under lock,
+// so instances don't lock store on every tick and don't repeat rotation made by other instance.
+func (r *Rotator) Rotate(ctx context.Context) error {
+	keys, err := r.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if r.cfg.Interval > 0 {
+		current, err := NewKeyring(keys, r.keyring.retireAfter)
+		if err != nil {
+			return err
+		}
+
+		if !r.plan(current).empty() {
+			var events []string
+			err := r.store.Update(ctx, func(stored []Key) ([]Key, error) {
+				rotated, rotatedEvents, err := r.rotate(stored)
+				keys, events = rotated, rotatedEvents
+				return rotated, err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rotate keys: %w", err)
+			}
+			for _, event := range events {
+				r.logger.Warn("[SECURITY] %s", event)
+			}
+		}
+	}
+
+	return r.keyring.Reload(keys)
+}
+
+// RunRotation call Rotate every interval until ctx is done
+func RunRotation(ctx context.Context, r *Rotator, interval time.Duration, l logger.Interface) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Rotate(ctx); err != nil {
+				l.Error("failed to rotate signing keys: %s", err.Error())
+			}
+		}
+	}
+}
+
+func (r *Rotator) plan(keyring *Keyring) rotationPlan {
+	now := r.now()
+	var plan rotationPlan
+
+	var next *Key
+	for _, key := range keyring.Keys() {
+		key := key
+		switch {
+		case key.State == KeyStateVerify && key.PromotedAt == 0:
+			next = &key
+		case key.State == KeyStateVerify && !now.Before(time.Unix(key.DemotedAt, 0).Add(keyring.retireAfter)):
+			plan.retire = append(plan.retire, key.ID)
+		}
+	}
+
+	active, ok := keyring.active()
+	if !ok {
+		if next != nil {
+			plan.promote = next.ID
+		} else {
+			plan.introduce = true
+		}
+		return plan
+	}
+
+	activeSince := time.Unix(active.CreatedAt, 0)
+	if active.PromotedAt != 0 {
+		activeSince = time.Unix(active.PromotedAt, 0)
+	}
+	rotateAt := activeSince.Add(r.cfg.Interval)
+
+	if next == nil && !now.Before(rotateAt.Add(-r.cfg.PrePublish)) {
+		plan.introduce = true
+	} else if next != nil && !now.Before(rotateAt) && !now.Before(time.Unix(next.CreatedAt, 0).Add(r.cfg.PrePublish)) {
+		plan.promote = next.ID
+	}
+
+	return plan
+}
+
+// rotate apply plan to keys and describe changes for security log
+func (r *Rotator) rotate(keys []Key) ([]Key, []string, error) {
+	keyring, err := NewKeyring(keys, r.keyring.retireAfter)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyring.now = r.now
+
+	plan := r.plan(keyring)
+	var events []string
+
+	for _, kid := range plan.retire {
+		if err := keyring.Retire(kid); err != nil {
+			return nil, nil, err
+		}
+		events = append(events, fmt.Sprintf("signing key retired: kid[%s]", kid))
+	}
+
+	if plan.introduce {
+		key, err := GenerateKey(r.cfg.Algorithm, r.now())
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := keyring.Introduce(key); err != nil {
+			return nil, nil, err
+		}
+		events = append(events, fmt.Sprintf("signing key introduced: kid[%s] alg[%s]", key.ID, key.Algorithm))
+
+		// first key of empty keyring signs at once, there is nobody to pre-publish it to
+		if _, ok := keyring.active(); !ok {
+			plan.promote = key.ID
+		}
+	}
+
+	if plan.promote != "" {
+		if err := keyring.Promote(plan.promote); err != nil {
+			return nil, nil, err
+		}
+		events = append(events, fmt.Sprintf("signing key promoted: kid[%s]", plan.promote))
+	}
+
+	return keyring.Keys(), events, nil
+}
@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"context"
+	mock_logger "medods/pkg/logger/mock"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryKeyStore is KeyStore of one instance, it counts updates to check that store is locked only when needed
+type memoryKeyStore struct {
+	keys    []Key
+	updates int
+}
+
+func (s *memoryKeyStore) Load(ctx context.Context) ([]Key, error) {
+	return append([]Key(nil), s.keys...), nil
+}
+
+func (s *memoryKeyStore) Update(ctx context.Context, fn func(keys []Key) ([]Key, error)) error {
+	keys, err := fn(append([]Key(nil), s.keys...))
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.updates++
+	return nil
+}
+
+func TestRotatorRotate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	now := time.Unix(1600000000, 0)
+	retireAfter := 30 * time.Minute
+	cfg := &RotationConfig{Algorithm: "EdDSA", Interval: 24 * time.Hour, PrePublish: time.Hour}
+
+	newKey := func(t *testing.T, state string, createdAt, promotedAt, demotedAt time.Time) Key {
+		key, err := GenerateKey("EdDSA", createdAt)
+		require.NoError(t, err)
+		key.State = state
+		if !promotedAt.IsZero() {
+			key.PromotedAt = promotedAt.Unix()
+		}
+		if !demotedAt.IsZero() {
+			key.DemotedAt = demotedAt.Unix()
+		}
+		return key
+	}
+
+	activeKey := newKey(t, KeyStateActive, now.Add(-48*time.Hour), now.Add(-24*time.Hour), time.Time{})
+	nextKey := newKey(t, KeyStateVerify, now.Add(-time.Hour), time.Time{}, time.Time{})
+	demotedKey := newKey(t, KeyStateVerify, now.Add(-72*time.Hour), now.Add(-48*time.Hour), now.Add(-retireAfter))
+
+	tc := []struct {
+		name        string
+		input       []Key
+		now         time.Time
+		buildStubs  func()
+		checkResult func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error)
+	}{
+		{
+			name:  "OK bootstrap",
+			input: nil,
+			now:   now,
+			buildStubs: func() {
+				// introduced and promoted
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(2)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				require.Len(t, store.keys, 1)
+				active, ok := keyring.active()
+				require.True(t, ok)
+				assert.Equal(t, store.keys[0].ID, active.ID)
+				assert.Equal(t, "EdDSA", active.Algorithm)
+			},
+		},
+		{
+			name:  "OK nothing is due",
+			input: []Key{activeKey},
+			now:   now.Add(-2 * time.Hour), // note: before pre-publish
+			buildStubs: func() {
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, 0, store.updates)
+				active, _ := keyring.active()
+				assert.Equal(t, activeKey.ID, active.ID)
+			},
+		},
+		{
+			name:  "OK introduce before promotion",
+			input: []Key{activeKey},
+			now:   now.Add(-time.Hour),
+			buildStubs: func() {
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				require.Len(t, store.keys, 2)
+				active, _ := keyring.active()
+				assert.Equal(t, activeKey.ID, active.ID)
+				assert.Len(t, keyring.jwks().Keys, 2)
+			},
+		},
+		{
+			name:  "OK promote next key",
+			input: []Key{activeKey, nextKey},
+			now:   now,
+			buildStubs: func() {
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				active, _ := keyring.active()
+				assert.Equal(t, nextKey.ID, active.ID)
+
+				old, ok := keyring.lookup(activeKey.ID)
+				require.True(t, ok)
+				assert.Equal(t, now.Unix(), old.DemotedAt)
+			},
+		},
+		{
+			name:  "OK next key waits for pre-publish",
+			input: []Key{activeKey, newKey(t, KeyStateVerify, now.Add(-time.Minute), time.Time{}, time.Time{})},
+			now:   now,
+			buildStubs: func() {
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				active, _ := keyring.active()
+				assert.Equal(t, activeKey.ID, active.ID)
+			},
+		},
+		{
+			name:  "OK retire demoted key",
+			input: []Key{newKey(t, KeyStateActive, now, now, time.Time{}), demotedKey},
+			now:   now,
+			buildStubs: func() {
+				l.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResult: func(t *testing.T, store *memoryKeyStore, keyring *Keyring, err error) {
+				require.NoError(t, err)
+				require.Len(t, store.keys, 1)
+				_, ok := keyring.lookup(demotedKey.ID)
+				assert.False(t, ok)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			store := &memoryKeyStore{keys: test.input}
+			keyring, err := NewKeyring(nil, retireAfter)
+			require.NoError(t, err)
+
+			rotator := NewRotator(keyring, store, cfg, l)
+			rotator.now = func() time.Time { return test.now }
+
+			err = rotator.Rotate(context.Background())
+			test.checkResult(t, store, keyring, err)
+		})
+	}
+}
+
+func TestRotatorReload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := mock_logger.NewMockInterface(ctrl)
+
+	key, err := GenerateKey("EdDSA", time.Now())
+	require.NoError(t, err)
+	key.State = KeyStateActive
+
+	store := &memoryKeyStore{keys: []Key{key}}
+	keyring, err := NewKeyring(nil, time.Minute)
+	require.NoError(t, err)
+
+	// note: rotation is disabled, keys are changed by admin command
+	rotator := NewRotator(keyring, store, &RotationConfig{}, l)
+	require.NoError(t, rotator.Rotate(context.Background()))
+
+	active, ok := keyring.active()
+	require.True(t, ok)
+	assert.Equal(t, key.ID, active.ID)
+
+	other, err := GenerateKey("HS512", time.Now())
+	require.NoError(t, err)
+	other.State = KeyStateActive
+	key.State = KeyStateVerify
+	store.keys = []Key{key, other}
+
+	require.NoError(t, rotator.Rotate(context.Background()))
+	active, _ = keyring.active()
+	assert.Equal(t, other.ID, active.ID)
+	assert.Equal(t, 0, store.updates)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"medods/config"
+	"medods/internal/repository"
+	"medods/internal/service/jwt"
+	"medods/pkg/sealer"
+)
+
+// NewKeyStore return store of signing keys, nil if key is set in config
+func NewKeyStore(cfg *config.Config, repo *repository.Manager) (jwt.KeyStore, error) {
+	switch {
+	case cfg.JWT.MasterKey != "":
+		key, err := cfg.JWT.Master()
+		if err != nil {
+			return nil, err
+		}
+		s, err := sealer.New(key)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewSealedKeyStore(repo.SigningKey, s), nil
+	case cfg.JWT.KeyringFile != "":
+		return jwt.NewFileKeyStore(cfg.JWT.KeyringFile), nil
+	default:
+		return nil, nil
+	}
+}
+
+// rotationConfig return schedule of rotation, keyring file is changed only by cmd/keyring
+func rotationConfig(cfg *config.Config) *jwt.RotationConfig {
+	if cfg.JWT.MasterKey == "" {
+		return &jwt.RotationConfig{}
+	}
+	return &jwt.RotationConfig{
+		Algorithm:  cfg.JWT.SigningMethod,
+		Interval:   cfg.JWT.RotationInterval,
+		PrePublish: cfg.JWT.KeyPrePublish,
+	}
+}
@@ -1,33 +1,229 @@
 package service
 
 import (
+	"context"
 	"medods/config"
 	"medods/internal/repository"
 	"medods/internal/service/auth"
+	"medods/internal/service/client"
+	"medods/internal/service/denylist"
 	"medods/internal/service/jwt"
+	"medods/internal/service/mfa"
+	"medods/internal/service/oauth"
+	"medods/internal/service/onetime"
+	"medods/internal/service/passkey"
 	"medods/internal/service/session"
 	"medods/internal/service/user"
+	"medods/pkg/geoip"
 	"medods/pkg/logger"
 	"medods/pkg/smtp"
 )
 
 type Manager struct {
-	Auth    auth.Interface
-	User    user.Interface
-	Session session.Interface
-	JWT     jwt.Interface
+	Auth     auth.Interface
+	User     user.Interface
+	Session  session.Interface
+	JWT      jwt.Interface
+	Denylist denylist.Interface
+	Client   client.Interface
+	MFA      mfa.Interface
+	Passkey  passkey.Interface
+	OAuth    oauth.Interface
+	OneTime  onetime.Interface
+	// nil if signing key is set in config
+	KeyRotator *jwt.Rotator
+	// nil if geoip database isn't set in config
+	GeoIP *geoip.DB
 }
 
-func New(cfg *config.Config, repo *repository.Manager, smtp smtp.Interface, l logger.Interface) *Manager {
-	userService := user.New(repo.User, l)
+func New(cfg *config.Config, repo *repository.Manager, smtp smtp.Interface, l logger.Interface) (*Manager, error) {
+	passwordHasher := auth.NewPasswordHasher(&auth.PasswordConfig{
+		Memory:      cfg.Password.Memory,
+		Time:        cfg.Password.Time,
+		Parallelism: cfg.Password.Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	oneTimeKey, err := cfg.OneTimeToken.Key()
+	if err != nil {
+		return nil, err
+	}
+	oneTimeService := onetime.New(repo.OneTimeToken, &onetime.Config{
+		SecretKey: oneTimeKey,
+	}, l)
+	userService := user.New(repo.User, passwordHasher, oneTimeService, smtp, &user.Config{
+		VerificationURL:           cfg.EmailVerification.URL,
+		VerificationTokenLifetime: cfg.EmailVerification.TokenLifetime,
+	}, l)
 	sessionService := session.New(repo.Session, l)
-	jwtMaker := jwt.New([]byte(cfg.JWT.SecretKey), l)
-	authService := auth.New(sessionService, userService, jwtMaker, smtp, l, false)
+	denylistService := denylist.New(repo.RevokedToken, l)
+	jwtKey, err := cfg.JWT.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	keyStore, err := NewKeyStore(cfg, repo)
+	if err != nil {
+		return nil, err
+	}
+	var keyring *jwt.Keyring
+	var keyRotator *jwt.Rotator
+	if keyStore != nil {
+		keyring, err = jwt.NewKeyring(nil, cfg.Auth.MaxTokenAge())
+		if err != nil {
+			return nil, err
+		}
+		keyRotator = jwt.NewRotator(keyring, keyStore, rotationConfig(cfg), l)
+		// loads keyring, empty keyring in database gets its first key
+		if err := keyRotator.Rotate(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	jwtMaker, err := jwt.New(&jwt.Config{
+		SecretKey:          []byte(cfg.JWT.SecretKey),
+		SigningMethod:      cfg.JWT.SigningMethod,
+		PrivateKey:         jwtKey,
+		Keyring:            keyring,
+		HS512AcceptedUntil: cfg.JWT.HS512AcceptedUntil,
+		Leeway:             cfg.Auth.Leeway,
+	}, denylistService, l)
+	if err != nil {
+		return nil, err
+	}
+	mfaKey, err := cfg.MFA.Key()
+	if err != nil {
+		return nil, err
+	}
+	mfaService, err := mfa.New(repo.User, repo.RecoveryCode, &mfa.Config{
+		EncryptionKey: mfaKey,
+		Issuer:        cfg.MFA.Issuer,
+	}, l)
+	if err != nil {
+		return nil, err
+	}
+	rTokenHasher, err := newTokenHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ipPolicy, err := newIPPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	geoDB, err := NewGeoIP(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var locator auth.Locator
+	if geoDB != nil {
+		locator = geoLocator{db: geoDB, logger: l}
+	}
+	authService := auth.New(sessionService, userService, jwtMaker, denylistService, smtp, passwordHasher, rTokenHasher, oneTimeService, mfaService, locator, &auth.Config{
+		ATokenLifetime: cfg.Auth.ATokenLifetime,
+		RTokenLifetime: cfg.Auth.RTokenLifetime,
+		RTokenBytes:    cfg.Auth.RTokenBytes,
+		Leeway:         cfg.Auth.Leeway,
+
+		RequireVerifiedEmail:       cfg.EmailVerification.Required,
+		PasswordResetURL:           cfg.PasswordReset.URL,
+		PasswordResetTokenLifetime: cfg.PasswordReset.TokenLifetime,
+		MagicLinkURL:               cfg.MagicLink.URL,
+		MagicLinkTokenLifetime:     cfg.MagicLink.TokenLifetime,
+		MFAChallengeLifetime:       cfg.MFA.ChallengeLifetime,
+		IPPolicy:                   ipPolicy,
+	}, l, false)
+	clientService := client.New(cfg.Introspection.Clients, l)
+	webAuthnKey, err := cfg.WebAuthn.Key()
+	if err != nil {
+		return nil, err
+	}
+	passkeyService := passkey.New(repo.User, repo.WebAuthnCredential, oneTimeService, &passkey.Config{
+		RPID:              cfg.WebAuthn.RPID,
+		RPName:            cfg.WebAuthn.RPName,
+		Origins:           cfg.WebAuthn.Origins,
+		ChallengeLifetime: cfg.WebAuthn.ChallengeLifetime,
+		FakeCredentialKey: webAuthnKey,
+	}, l)
+	clients := make([]oauth.Client, 0, len(cfg.OAuth.Clients))
+	for _, c := range cfg.OAuth.Clients {
+		clients = append(clients, oauth.Client{
+			ID:           c.ID,
+			Secret:       c.Secret,
+			RedirectURIs: c.RedirectURIs,
+			Scopes:       c.Scopes,
+		})
+	}
+	oauthService := oauth.New(authService, jwtMaker, userService, repo.AuthorizationCode, &oauth.Config{
+		Issuer:         cfg.OAuth.Issuer,
+		Clients:        clients,
+		CodeLifetime:   cfg.OAuth.CodeLifetime,
+		ATokenLifetime: cfg.Auth.ATokenLifetime,
+	}, l)
 
 	return &Manager{
-		Auth:    authService,
-		User:    userService,
-		Session: sessionService,
-		JWT:     jwtMaker,
+		Auth:     authService,
+		User:     userService,
+		Session:  sessionService,
+		JWT:      jwtMaker,
+		Denylist: denylistService,
+		Client:   clientService,
+		MFA:      mfaService,
+		Passkey:  passkeyService,
+		OAuth:    oauthService,
+		OneTime:  oneTimeService,
+
+		KeyRotator: keyRotator,
+		GeoIP:      geoDB,
+	}, nil
+}
+
+// newTokenHasher return hasher of refresh tokens selected in config,
+// bcrypt hasher keeps verifying hmac hashes if key is still set, e.g. after rollback from hmac
+func newTokenHasher(cfg *config.Config) (auth.TokenHasher, error) {
+	var key []byte
+	if cfg.Auth.RTokenHasher == config.RTokenHasherHMAC || cfg.Auth.RTokenHMACKey != "" {
+		var err error
+		key, err = cfg.Auth.HMACKey()
+		if err != nil {
+			return nil, err
+		}
 	}
+
+	if cfg.Auth.RTokenHasher != config.RTokenHasherHMAC {
+		return auth.NewBcryptTokenHasher(cfg.Auth.BcryptCost, key), nil
+	}
+	return auth.NewHMACTokenHasher(key), nil
+}
+
+// newIPPolicy parse outcomes and trusted networks of ip policy from config
+func newIPPolicy(cfg *config.Config) (auth.IPPolicy, error) {
+	networks, err := cfg.IPPolicy.Networks()
+	if err != nil {
+		return auth.IPPolicy{}, err
+	}
+	p := auth.IPPolicy{
+		TrustedNetworks:   networks,
+		SubnetBitsV4:      cfg.IPPolicy.SubnetBitsV4,
+		SubnetBitsV6:      cfg.IPPolicy.SubnetBitsV6,
+		RapidChangeWindow: cfg.IPPolicy.RapidChangeWindow,
+		MaxTravelSpeed:    cfg.IPPolicy.MaxTravelSpeed,
+	}
+
+	for _, o := range []struct {
+		name    string
+		outcome *auth.IPOutcome
+	}{
+		{cfg.IPPolicy.Default, &p.Default},
+		{cfg.IPPolicy.SameSubnet, &p.SameSubnet},
+		{cfg.IPPolicy.SameCountry, &p.SameCountry},
+		{cfg.IPPolicy.KnownIP, &p.KnownIP},
+		{cfg.IPPolicy.RapidChange, &p.RapidChange},
+		{cfg.IPPolicy.ImpossibleTravel, &p.ImpossibleTravel},
+	} {
+		outcome, err := auth.ParseIPOutcome(o.name)
+		if err != nil {
+			return auth.IPPolicy{}, err
+		}
+		*o.outcome = outcome
+	}
+
+	return p, nil
 }
@@ -0,0 +1,8 @@
+package mfa
+
+type Config struct {
+	// key of AES-256-GCM which encrypts totp secrets in database, 32 bytes
+	EncryptionKey []byte
+	// name of service shown by authenticator app
+	Issuer string
+}
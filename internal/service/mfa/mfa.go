@@ -0,0 +1,240 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/repository"
+	"medods/pkg/logger"
+	"medods/pkg/sealer"
+	"medods/pkg/totp"
+	"strings"
+	"time"
+)
+
+const (
+	// length of authenticator app code
+	totpDigits = 6
+	// count of recovery codes given on confirmation, previous codes stop working
+	RecoveryCodesCount = 10
+	// count of random bytes in recovery code, 80 bits
+	recoveryCodeBytes = 10
+	// recovery code is shown as groups of base32 characters, e.g. ABCD-EFGH-IJKL-MNOP
+	recoveryCodeGroup = 4
+)
+
+var (
+	ErrAlreadyEnabled = fmt.Errorf("two-factor authentication is already enabled")
+	ErrNotEnrolled    = fmt.Errorf("two-factor authentication enrollment is not started")
+	ErrNotEnabled     = fmt.Errorf("two-factor authentication is not enabled")
+	ErrInvalidCode    = fmt.Errorf("invalid authentication code")
+
+	recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+type Interface interface {
+	Enroll(ctx context.Context, uid int) (secret, uri string, err error)
+	Confirm(ctx context.Context, uid int, code string) (recoveryCodes []string, err error)
+	Verify(ctx context.Context, u model.User, code string) (amr string, err error)
+}
+
+var _ Interface = (*mfa)(nil)
+
+// mfa manage second factor of user: TOTP authenticator app (RFC 6238) and recovery codes.
+// Secret is stored encrypted, recovery codes are stored as sha256, they have enough entropy for it.
+type mfa struct {
+	userRepo repository.User
+	codeRepo repository.RecoveryCode
+	sealer   *sealer.Sealer
+	totp     *totp.TOTP
+	logger   logger.Interface
+
+	now func() time.Time
+}
+
+func New(userRepo repository.User, codeRepo repository.RecoveryCode, cfg *Config, logger logger.Interface) (*mfa, error) {
+	sealer, err := sealer.New(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key of totp secrets: %w", err)
+	}
+
+	return &mfa{
+		userRepo: userRepo,
+		codeRepo: codeRepo,
+		sealer:   sealer,
+		totp:     totp.New(&totp.Config{Issuer: cfg.Issuer, Digits: totpDigits}),
+		logger:   logger,
+
+		now: time.Now,
+	}, nil
+}
+
+// Enroll generate new secret of authenticator app, second factor is enabled only after Confirm.
+// Repeated call replaces pending secret, so user can restart enrollment.
+func (s mfa) Enroll(ctx context.Context, uid int) (secret, uri string, err error) {
+	dbUser, err := s.userRepo.GetByID(ctx, uid)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if dbUser.TOTPEnabledAt != 0 {
+		return "", "", ErrAlreadyEnabled
+	}
+
+	secret, err = s.totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed, err := s.sealer.Seal([]byte(secret))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	// enabled concurrently
+	if err := s.userRepo.UpdateTOTPSecret(ctx, uid, sealed); errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrAlreadyEnabled
+	} else if err != nil {
+		return "", "", fmt.Errorf("failed to save totp secret: %w", err)
+	}
+	s.logger.Info("totp enrollment of user[%d] started", uid)
+
+	return secret, s.totp.URI(dbUser.Email, secret), nil
+}
+
+// Confirm enable second factor if code of pending secret is valid
+// and return recovery codes, they are shown to user only once.
+func (s mfa) Confirm(ctx context.Context, uid int, code string) (recoveryCodes []string, err error) {
+	dbUser, err := s.userRepo.GetByID(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if dbUser.TOTPEnabledAt != 0 {
+		return nil, ErrAlreadyEnabled
+	}
+	if dbUser.TOTPSecret == "" {
+		return nil, ErrNotEnrolled
+	}
+
+	secret, err := s.sealer.Open(dbUser.TOTPSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	now := s.now()
+	step, ok := s.totp.Validate(string(secret), code, now)
+	if !ok {
+		s.logger.Warn("invalid totp code on confirmation: user[%d]", uid)
+		return nil, ErrInvalidCode
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	// codes are saved first, so enabled second factor always has them
+	if err := s.codeRepo.Replace(ctx, uid, hashes); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+
+	if err := s.userRepo.EnableTOTP(ctx, uid, now.Unix(), step); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAlreadyEnabled
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to enable totp: %w", err)
+	}
+	s.logger.Info("totp of user[%d] enabled", uid)
+
+	return recoveryCodes, nil
+}
+
+// Verify check second factor of user, code is either code of authenticator app or recovery code.
+// Both are accepted only once. Returned amr tells which one was used.
+func (s mfa) Verify(ctx context.Context, u model.User, code string) (amr string, err error) {
+	if u.TOTPEnabledAt == 0 {
+		return "", ErrNotEnabled
+	}
+
+	if !isTOTPCode(code) {
+		return s.useRecoveryCode(ctx, u.ID, code)
+	}
+
+	secret, err := s.sealer.Open(u.TOTPSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	step, ok := s.totp.Validate(string(secret), code, s.now())
+	if !ok {
+		s.logger.Warn("invalid totp code: user[%d]", u.ID)
+		return "", ErrInvalidCode
+	}
+
+	if err := s.userRepo.UseTOTPStep(ctx, u.ID, step); errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("replay of totp code: user[%d]", u.ID)
+		return "", ErrInvalidCode
+	} else if err != nil {
+		return "", fmt.Errorf("failed to save totp step: %w", err)
+	}
+
+	return model.AMROTP, nil
+}
+
+func (s mfa) useRecoveryCode(ctx context.Context, uid int, code string) (amr string, err error) {
+	err = s.codeRepo.Use(ctx, uid, hashRecoveryCode(code), s.now().Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("invalid recovery code: user[%d]", uid)
+		return "", ErrInvalidCode
+	} else if err != nil {
+		return "", fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	s.logger.Info("recovery code of user[%d] used", uid)
+
+	return model.AMRRecovery, nil
+}
+
+// isTOTPCode tells that code has format of authenticator app code, recovery codes are longer
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodesCount; i++ {
+		random := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := recoveryEncoding.EncodeToString(random)
+
+		var groups []string
+		for j := 0; j < len(encoded); j += recoveryCodeGroup {
+			groups = append(groups, encoded[j:j+recoveryCodeGroup])
+		}
+		code := strings.Join(groups, "-")
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignore case and separators, so code typed by user matches the shown one
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
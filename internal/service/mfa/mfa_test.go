@@ -0,0 +1,371 @@
+package mfa
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	mock_repository "medods/internal/repository/mock"
+	mock_logger "medods/pkg/logger/mock"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultConfig = &Config{
+	EncryptionKey: bytes.Repeat([]byte{1}, 32),
+	Issuer:        "medods",
+}
+
+const defaultSecret = "JBSWY3DPEHPK3PXP"
+
+func newTestService(t *testing.T, ctrl *gomock.Controller) (*mfa, *mock_repository.MockUser, *mock_repository.MockRecoveryCode) {
+	logger := mock_logger.NewMockInterface(ctrl)
+	logger.EXPECT().Info(gomock.Any(), gomock.Any()).AnyTimes()
+	logger.EXPECT().Warn(gomock.Any(), gomock.Any()).AnyTimes()
+	userRepo := mock_repository.NewMockUser(ctrl)
+	codeRepo := mock_repository.NewMockRecoveryCode(ctrl)
+
+	service, err := New(userRepo, codeRepo, defaultConfig, logger)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	service.now = func() time.Time { return now }
+
+	return service, userRepo, codeRepo
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, nil, &Config{EncryptionKey: []byte("short")}, nil)
+	assert.Error(t, err)
+}
+
+func TestEnroll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, userRepo, _ := newTestService(t, ctrl)
+
+	defaultUser := model.User{ID: 1, Email: "user@mail.com"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, secret, uri string, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				userRepo.EXPECT().UpdateTOTPSecret(gomock.Any(), 1, gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, _ int, sealed string) error {
+						assert.NotEmpty(t, sealed)
+						return nil
+					})
+			},
+			checkResult: func(t *testing.T, secret, uri string, err error) {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, secret)
+
+				u, err := url.Parse(uri)
+				assert.NoError(t, err)
+				assert.Equal(t, "otpauth", u.Scheme)
+				assert.Equal(t, "/medods:user@mail.com", u.Path)
+				assert.Equal(t, secret, u.Query().Get("secret"))
+			},
+		},
+		{
+			name: "already enabled",
+			buildStubs: func() {
+				u := defaultUser
+				u.TOTPEnabledAt = 1 // note
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(u, nil)
+			},
+			checkResult: func(t *testing.T, secret, uri string, err error) {
+				assert.ErrorIs(t, err, ErrAlreadyEnabled)
+			},
+		},
+		{
+			name: "enabled concurrently",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				userRepo.EXPECT().UpdateTOTPSecret(gomock.Any(), 1, gomock.Any()).Times(1).Return(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, secret, uri string, err error) {
+				assert.ErrorIs(t, err, ErrAlreadyEnabled)
+			},
+		},
+		{
+			name: "failed get user",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(model.User{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, secret, uri string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed save secret",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				userRepo.EXPECT().UpdateTOTPSecret(gomock.Any(), 1, gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, secret, uri string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			secret, uri, err := service.Enroll(context.Background(), 1)
+			test.checkResult(t, secret, uri, err)
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, userRepo, codeRepo := newTestService(t, ctrl)
+
+	sealed, err := service.sealer.Seal([]byte(defaultSecret))
+	require.NoError(t, err)
+	defaultUser := model.User{ID: 1, Email: "user@mail.com", TOTPSecret: sealed}
+
+	defaultCode, err := service.totp.Code(defaultSecret, service.now())
+	require.NoError(t, err)
+	defaultStep := service.totp.Step(service.now())
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		code        string
+		buildStubs  func()
+		checkResult func(t *testing.T, codes []string, err error)
+	}{
+		{
+			name: "OK",
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				codeRepo.EXPECT().Replace(gomock.Any(), 1, gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, _ int, hashes []string) error {
+						assert.Len(t, hashes, RecoveryCodesCount)
+						return nil
+					})
+				userRepo.EXPECT().EnableTOTP(gomock.Any(), 1, service.now().Unix(), defaultStep).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, codes, RecoveryCodesCount)
+				for _, code := range codes {
+					assert.Regexp(t, `^[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}$`, code)
+				}
+			},
+		},
+		{
+			name: "invalid code",
+			code: "000000", // note
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCode)
+				assert.Nil(t, codes)
+			},
+		},
+		{
+			name: "not enrolled",
+			code: defaultCode,
+			buildStubs: func() {
+				u := defaultUser
+				u.TOTPSecret = "" // note
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(u, nil)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, ErrNotEnrolled)
+			},
+		},
+		{
+			name: "already enabled",
+			code: defaultCode,
+			buildStubs: func() {
+				u := defaultUser
+				u.TOTPEnabledAt = 1 // note
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(u, nil)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, ErrAlreadyEnabled)
+			},
+		},
+		{
+			name: "enabled concurrently",
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				codeRepo.EXPECT().Replace(gomock.Any(), 1, gomock.Any()).Times(1).Return(nil)
+				userRepo.EXPECT().EnableTOTP(gomock.Any(), 1, gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, ErrAlreadyEnabled)
+			},
+		},
+		{
+			name: "failed save codes",
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				codeRepo.EXPECT().Replace(gomock.Any(), 1, gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed get user",
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(model.User{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, codes []string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			codes, err := service.Confirm(context.Background(), 1, test.code)
+			test.checkResult(t, codes, err)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, userRepo, codeRepo := newTestService(t, ctrl)
+
+	sealed, err := service.sealer.Seal([]byte(defaultSecret))
+	require.NoError(t, err)
+	defaultUser := model.User{ID: 1, Email: "user@mail.com", TOTPSecret: sealed, TOTPEnabledAt: 1}
+
+	defaultCode, err := service.totp.Code(defaultSecret, service.now())
+	require.NoError(t, err)
+	defaultStep := service.totp.Step(service.now())
+
+	defaultRecoveryCode := "ABCD-EFGH-IJKL-MNOP"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		user        model.User
+		code        string
+		buildStubs  func()
+		checkResult func(t *testing.T, amr string, err error)
+	}{
+		{
+			name: "OK totp",
+			user: defaultUser,
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().UseTOTPStep(gomock.Any(), 1, defaultStep).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.AMROTP, amr)
+			},
+		},
+		{
+			name: "OK recovery code",
+			user: defaultUser,
+			code: "abcd efgh ijkl mnop", // note: case and separators are ignored
+			buildStubs: func() {
+				codeRepo.EXPECT().Use(gomock.Any(), 1, hashRecoveryCode(defaultRecoveryCode), service.now().Unix()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.AMRRecovery, amr)
+			},
+		},
+		{
+			name: "totp replay",
+			user: defaultUser,
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().UseTOTPStep(gomock.Any(), 1, defaultStep).Times(1).Return(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCode)
+			},
+		},
+		{
+			name:       "invalid totp",
+			user:       defaultUser,
+			code:       "000000", // note
+			buildStubs: func() {},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCode)
+			},
+		},
+		{
+			name: "invalid recovery code",
+			user: defaultUser,
+			code: defaultRecoveryCode,
+			buildStubs: func() {
+				codeRepo.EXPECT().Use(gomock.Any(), 1, gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCode)
+			},
+		},
+		{
+			name: "not enabled",
+			user: model.User{ID: 1, TOTPSecret: sealed}, // note
+			code: defaultCode,
+			buildStubs: func() {
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, ErrNotEnabled)
+			},
+		},
+		{
+			name: "failed save step",
+			user: defaultUser,
+			code: defaultCode,
+			buildStubs: func() {
+				userRepo.EXPECT().UseTOTPStep(gomock.Any(), 1, defaultStep).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed use recovery code",
+			user: defaultUser,
+			code: defaultRecoveryCode,
+			buildStubs: func() {
+				codeRepo.EXPECT().Use(gomock.Any(), 1, gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, amr string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			amr, err := service.Verify(context.Background(), test.user, test.code)
+			test.checkResult(t, amr, err)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package oauth
+
+import "time"
+
+type Config struct {
+	// url of OpenID Connect provider, iss claim of id tokens
+	Issuer  string
+	Clients []Client
+	// time given to client to exchange authorization code
+	CodeLifetime time.Duration
+	// lifetime of access token issued by auth service, returned as expires_in, id token lives the same
+	ATokenLifetime time.Duration
+}
+
+// Client is registered OAuth 2.0 client
+type Client struct {
+	ID string
+	// empty for public clients, e.g. SPA and mobile apps, they are authenticated only by PKCE
+	Secret string
+	// allowlist, redirect uri of request must be equal to one of them
+	RedirectURIs []string
+	// scopes which client may request, all of them are granted when request has no scope
+	Scopes []string
+}
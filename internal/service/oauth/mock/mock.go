@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/oauth/oauth.go
+
+// Package mock_oauth is a generated GoMock package.
+package mock_oauth
+
+import (
+	context "context"
+	model "medods/internal/model"
+	oauth "medods/internal/service/oauth"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockInterface is a mock of Interface interface.
+type MockInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockInterfaceMockRecorder
+}
+
+// MockInterfaceMockRecorder is the mock recorder for MockInterface.
+type MockInterfaceMockRecorder struct {
+	mock *MockInterface
+}
+
+// NewMockInterface creates a new mock instance.
+func NewMockInterface(ctrl *gomock.Controller) *MockInterface {
+	mock := &MockInterface{ctrl: ctrl}
+	mock.recorder = &MockInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Authorize mocks base method.
+func (m *MockInterface) Authorize(ctx context.Context, req oauth.AuthorizeRequest, user *model.Payload) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", ctx, req, user)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockInterfaceMockRecorder) Authorize(ctx, req, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockInterface)(nil).Authorize), ctx, req, user)
+}
+
+// Prune mocks base method.
+func (m *MockInterface) Prune(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prune", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Prune indicates an expected call of Prune.
+func (mr *MockInterfaceMockRecorder) Prune(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prune", reflect.TypeOf((*MockInterface)(nil).Prune), ctx)
+}
+
+// Token mocks base method.
+func (m *MockInterface) Token(ctx context.Context, req oauth.TokenRequest, ip string) (oauth.TokenResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Token", ctx, req, ip)
+	ret0, _ := ret[0].(oauth.TokenResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Token indicates an expected call of Token.
+func (mr *MockInterfaceMockRecorder) Token(ctx, req, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Token", reflect.TypeOf((*MockInterface)(nil).Token), ctx, req, ip)
+}
+
+// UserInfo mocks base method.
+func (m *MockInterface) UserInfo(ctx context.Context, payload *model.Payload) (model.UserInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserInfo", ctx, payload)
+	ret0, _ := ret[0].(model.UserInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserInfo indicates an expected call of UserInfo.
+func (mr *MockInterfaceMockRecorder) UserInfo(ctx, payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserInfo", reflect.TypeOf((*MockInterface)(nil).UserInfo), ctx, payload)
+}
@@ -0,0 +1,495 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/repository"
+	"medods/internal/service/auth"
+	"medods/internal/service/jwt"
+	"medods/internal/service/user"
+	"medods/pkg/logger"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	gjwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ResponseTypeCode = "code"
+	// the only PKCE method accepted, plain is not allowed
+	CodeChallengeMethodS256 = "S256"
+
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+
+	TokenTypeBearer = "Bearer"
+)
+
+// error codes of RFC 6749 section 4.1.2.1 and 5.2
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorInvalidScope            = "invalid_scope"
+	ErrorAccessDenied            = "access_denied"
+	ErrorServerError             = "server_error"
+)
+
+// count of random bytes in authorization code
+const codeBytes = 32
+
+// refresh tokens issued before selectors were access and refresh token of session joined by separator,
+// both are needed to rotate such session, separator is not used by base64url and JWT
+const legacyRefreshTokenSeparator = "~"
+
+var (
+	// S256 challenge is base64url of sha256 without padding
+	codeChallengeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+	// RFC 7636 section 4.1
+	codeVerifierRe = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+)
+
+// Error is error response of RFC 6749, its json is body of token endpoint response
+type Error struct {
+	Code        string `json:"error" example:"invalid_request"`
+	Description string `json:"error_description,omitempty" example:"code_challenge is required"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newError(code, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
+
+// AuthorizeRequest is parameters of authorization request, RFC 6749 section 4.1.1 and RFC 7636 section 4.3
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// OpenID Connect, returned in id token
+	Nonce string
+}
+
+// TokenRequest is parameters of access token request, client credentials are already taken from request
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+
+	// authorization_code grant
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+
+	// refresh_token grant
+	RefreshToken string
+	Scope        string
+}
+
+// TokenResponse is successful response of token endpoint, RFC 6749 section 5.1
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty" example:"openid email"`
+	// issued when openid scope is granted
+	IDToken string `json:"id_token,omitempty"`
+}
+
+type Interface interface {
+	Authorize(ctx context.Context, req AuthorizeRequest, user *model.Payload) (redirectURI string, err error)
+	Token(ctx context.Context, req TokenRequest, ip string) (TokenResponse, error)
+	UserInfo(ctx context.Context, payload *model.Payload) (model.UserInfo, error)
+	Prune(ctx context.Context) error
+}
+
+var _ Interface = (*oauth)(nil)
+
+// oauth is OAuth 2.0 authorization server of registered clients.
+// User is authenticated by access token of own session, client gets tokens of a new session opened by auth service,
+// so they are refreshed, revoked and introspected the same way as first-party ones.
+type oauth struct {
+	auth    auth.Interface
+	jwt     jwt.Interface
+	user    user.Interface
+	codes   repository.AuthorizationCode
+	clients map[string]Client
+
+	cfg    *Config
+	logger logger.Interface
+
+	now func() time.Time
+}
+
+func New(
+	authService auth.Interface,
+	jwtMaker jwt.Interface,
+	userService user.Interface,
+	codes repository.AuthorizationCode,
+	cfg *Config,
+	logger logger.Interface,
+) *oauth {
+	clients := make(map[string]Client, len(cfg.Clients))
+	for _, c := range cfg.Clients {
+		clients[c.ID] = c
+	}
+
+	return &oauth{
+		auth:    authService,
+		jwt:     jwtMaker,
+		user:    userService,
+		codes:   codes,
+		clients: clients,
+
+		cfg:    cfg,
+		logger: logger,
+
+		now: time.Now,
+	}
+}
+
+// Authorize issue authorization code to client on behalf of user which access token payload belongs to
+// and return redirect uri with it. Only token of first-party session can authorize, client can't pass its grant to other one.
+// Errors which must not be sent to client, unknown client or redirect uri, are returned as *Error,
+// other errors of request are put into returned redirect uri as RFC 6749 section 4.1.2.1 requires.
+func (s oauth) Authorize(ctx context.Context, req AuthorizeRequest, user *model.Payload) (string, error) {
+	client, ok := s.clients[req.ClientID]
+	if !ok {
+		s.logger.Warn("authorization request of unknown client[%s]", req.ClientID)
+		return "", newError(ErrorInvalidRequest, "client_id is unknown")
+	}
+
+	redirectURI, ok := client.redirectURI(req.RedirectURI)
+	if !ok {
+		s.logger.Warn("authorization request of client[%s] with unregistered redirect uri[%s]", client.ID, req.RedirectURI)
+		return "", newError(ErrorInvalidRequest, "redirect_uri is not registered for client")
+	}
+
+	redirect := func(params url.Values) (string, error) {
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		return withQuery(redirectURI, params)
+	}
+	redirectError := func(code, description string) (string, error) {
+		return redirect(url.Values{"error": {code}, "error_description": {description}})
+	}
+
+	if user.ClientID != "" {
+		s.logger.Warn("token of client[%s] used to authorize client[%s]: user[%d]", user.ClientID, client.ID, user.UserID)
+		return redirectError(ErrorAccessDenied, "user must authorize client by own session")
+	} else if req.ResponseType != ResponseTypeCode {
+		return redirectError(ErrorUnsupportedResponseType, "only code response type is supported")
+	} else if req.CodeChallenge == "" {
+		return redirectError(ErrorInvalidRequest, "code_challenge is required")
+	} else if req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return redirectError(ErrorInvalidRequest, "only S256 code_challenge_method is supported")
+	} else if !codeChallengeRe.MatchString(req.CodeChallenge) {
+		return redirectError(ErrorInvalidRequest, "code_challenge is not S256 of verifier")
+	}
+
+	scope, ok := client.grantScope(req.Scope)
+	if !ok {
+		return redirectError(ErrorInvalidScope, "scope is not allowed for client")
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		return "", err
+	}
+
+	// tokens issued before auth_time claim have only time of last refresh
+	authTime := user.AuthTime
+	if authTime == 0 && user.IssuedAt != nil {
+		authTime = user.IssuedAt.Unix()
+	}
+
+	if err := s.codes.Create(ctx, model.AuthorizationCode{
+		CodeHash:      hashCode(code),
+		ClientID:      client.ID,
+		UserID:        user.UserID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		Scope:         scope,
+		AMR:           user.AMR,
+		Nonce:         req.Nonce,
+		AuthTime:      authTime,
+		ExpiresAt:     s.now().Add(s.cfg.CodeLifetime).Unix(),
+	}); err != nil {
+		return "", fmt.Errorf("failed to save authorization code: %w", err)
+	}
+	s.logger.Info("user[%d] authorized client[%s] with scope[%s]", user.UserID, client.ID, scope)
+
+	return redirect(url.Values{"code": {code}})
+}
+
+// Token authenticate client and exchange grant for tokens.
+// Errors of request are returned as *Error, invalid_client means that client must be answered with 401.
+func (s oauth) Token(ctx context.Context, req TokenRequest, ip string) (TokenResponse, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, client, req, ip)
+	case GrantTypeRefreshToken:
+		return s.refresh(ctx, client, req, ip)
+	case "":
+		return TokenResponse{}, newError(ErrorInvalidRequest, "grant_type is required")
+	default:
+		return TokenResponse{}, newError(ErrorUnsupportedGrantType, "grant_type is not supported")
+	}
+}
+
+// exchangeCode burn authorization code and open session for its user.
+// Code is burned before checks, so code presented with wrong verifier can't be tried again.
+func (s oauth) exchangeCode(ctx context.Context, client Client, req TokenRequest, ip string) (TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return TokenResponse{}, newError(ErrorInvalidRequest, "code and code_verifier are required")
+	} else if !codeVerifierRe.MatchString(req.CodeVerifier) {
+		return TokenResponse{}, newError(ErrorInvalidRequest, "code_verifier is malformed")
+	}
+
+	code, err := s.codes.Consume(ctx, hashCode(req.Code), s.now().Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("client[%s] presented invalid authorization code", client.ID)
+		return TokenResponse{}, newError(ErrorInvalidGrant, "authorization code is invalid, expired or already used")
+	} else if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	if code.ClientID != client.ID {
+		s.logger.Warn("client[%s] presented authorization code of client[%s]", client.ID, code.ClientID)
+		return TokenResponse{}, newError(ErrorInvalidGrant, "authorization code was issued to other client")
+	} else if code.RedirectURI != req.RedirectURI {
+		return TokenResponse{}, newError(ErrorInvalidGrant, "redirect_uri differs from authorization request")
+	} else if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		s.logger.Warn("client[%s] presented invalid code verifier", client.ID)
+		return TokenResponse{}, newError(ErrorInvalidGrant, "code_verifier does not match code_challenge")
+	}
+
+	aToken, rToken, err := s.auth.CreateClientSession(ctx, code.UserID, ip, auth.Grant{
+		AMR:      code.AMR,
+		ClientID: client.ID,
+		Scope:    code.Scope,
+		AuthTime: code.AuthTime,
+	})
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		return TokenResponse{}, newError(ErrorInvalidGrant, err.Error())
+	} else if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	res := s.tokenResponse(aToken, rToken, code.Scope)
+	if hasScope(code.Scope, ScopeOpenID) {
+		if res.IDToken, err = s.idToken(ctx, client, code); err != nil {
+			return TokenResponse{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// refresh rotate session which refresh token belongs to, session must be opened for the same client
+func (s oauth) refresh(ctx context.Context, client Client, req TokenRequest, ip string) (TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return TokenResponse{}, newError(ErrorInvalidRequest, "refresh_token is required")
+	}
+
+	aT, rT, legacy := strings.Cut(req.RefreshToken, legacyRefreshTokenSeparator)
+	if !legacy {
+		aT, rT = "", req.RefreshToken
+	}
+
+	clientID, scope, err := s.refreshGrant(ctx, aT, rT, legacy)
+	if isInvalidGrant(err) {
+		s.logger.Warn("client[%s] presented invalid refresh token: %s", client.ID, err)
+		return TokenResponse{}, newError(ErrorInvalidGrant, "refresh token is invalid")
+	} else if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to get session of refresh token: %w", err)
+	} else if clientID != client.ID {
+		s.logger.Warn("client[%s] presented refresh token of client[%s]", client.ID, clientID)
+		return TokenResponse{}, newError(ErrorInvalidGrant, "refresh token was issued to other client")
+	}
+
+	// scope can't be widened on refresh, request may only repeat it or narrow session to its part
+	if scopes := strings.Fields(req.Scope); len(scopes) > 0 {
+		if !isSubset(scopes, strings.Fields(scope)) {
+			return TokenResponse{}, newError(ErrorInvalidScope, "scope exceeds scope granted by user")
+		}
+		scope = strings.Join(scopes, " ")
+	}
+
+	aToken, rToken, err := s.auth.RefreshClientSession(ctx, aT, rT, ip, client.ID, req.Scope)
+	if isInvalidGrant(err) {
+		return TokenResponse{}, newError(ErrorInvalidGrant, err.Error())
+	} else if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	return s.tokenResponse(aToken, rToken, scope), nil
+}
+
+// refreshGrant return client and scope of session which refresh token belongs to
+func (s oauth) refreshGrant(ctx context.Context, aT, rT string, legacy bool) (clientID, scope string, err error) {
+	if !legacy {
+		session, err := s.auth.RefreshTokenSession(ctx, rT)
+		if err != nil {
+			return "", "", err
+		}
+		return session.ClientID, session.Scope, nil
+	}
+
+	// access token is usually expired here, the rest is checked by auth service
+	_, payload, err := s.jwt.VerifyToken(ctx, aT)
+	if err != nil && !errors.Is(err, gjwt.ErrTokenExpired) {
+		return "", "", fmt.Errorf("%w: %w", auth.ErrValidationFailed, err)
+	}
+	return payload.ClientID, payload.Scope, nil
+}
+
+func (s oauth) tokenResponse(aToken, rToken, scope string) TokenResponse {
+	return TokenResponse{
+		AccessToken:  aToken,
+		TokenType:    TokenTypeBearer,
+		ExpiresIn:    int64(s.cfg.ATokenLifetime / time.Second),
+		RefreshToken: rToken,
+		Scope:        scope,
+	}
+}
+
+// authenticateClient check secret of confidential client, public client must not present secret
+// Prune remove expired authorization codes from database
+func (s oauth) Prune(ctx context.Context) error {
+	deleted, err := s.codes.DeleteExpired(ctx, s.now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired authorization codes: %w", err)
+	}
+	s.logger.Debug("pruned %d expired authorization codes", deleted)
+	return nil
+}
+
+func (s oauth) authenticateClient(id, secret string) (Client, error) {
+	client, ok := s.clients[id]
+	if !ok {
+		s.logger.Warn("token request of unknown client[%s]", id)
+		return Client{}, newError(ErrorInvalidClient, "client is unknown")
+	}
+
+	if client.Secret == "" && secret != "" {
+		s.logger.Warn("public client[%s] presented secret", id)
+		return Client{}, newError(ErrorInvalidClient, "client has no secret")
+	} else if subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) != 1 {
+		s.logger.Warn("client[%s] presented invalid secret", id)
+		return Client{}, newError(ErrorInvalidClient, "client authentication failed")
+	}
+
+	return client, nil
+}
+
+// redirectURI return uri to redirect, it may be omitted in request only if client has one registered
+func (c Client) redirectURI(requested string) (string, bool) {
+	if requested == "" {
+		if len(c.RedirectURIs) != 1 {
+			return "", false
+		}
+		return c.RedirectURIs[0], true
+	}
+
+	for _, uri := range c.RedirectURIs {
+		if uri == requested {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// grantScope return scope granted for requested one, empty request gets all scopes of client
+func (c Client) grantScope(requested string) (string, bool) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(c.Scopes, " "), true
+	}
+	return strings.Join(scopes, " "), isSubset(scopes, c.Scopes)
+}
+
+func isSubset(subset, set []string) bool {
+	for _, s := range subset {
+		found := false
+		for _, v := range set {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isInvalidGrant tell whether refresh failed because of tokens, not of server
+func isInvalidGrant(err error) bool {
+	return errors.Is(err, auth.ErrValidationFailed) ||
+		errors.Is(err, gjwt.ErrTokenExpired) ||
+		errors.Is(err, gjwt.ErrTokenInvalidId) ||
+		errors.Is(err, sql.ErrNoRows)
+}
+
+// withQuery add params to query of uri, query of registered uri is kept
+func withQuery(uri string, params url.Values) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect uri: %w", err)
+	}
+
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// verifyCodeChallenge compare S256 of verifier with challenge, RFC 7636 section 4.6
+func verifyCodeChallenge(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+func randomCode() (string, error) {
+	random := make([]byte, codeBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
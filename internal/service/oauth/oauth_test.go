@@ -0,0 +1,978 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	mock_repository "medods/internal/repository/mock"
+	"medods/internal/service/auth"
+	mock_auth "medods/internal/service/auth/mock"
+	mock_jwt "medods/internal/service/jwt/mock"
+	mock_user "medods/internal/service/user/mock"
+	mock_logger "medods/pkg/logger/mock"
+	"net/url"
+	"testing"
+	"time"
+
+	gjwt "github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultConfig = &Config{
+	Issuer: "https://auth.example.com",
+	Clients: []Client{
+		{
+			ID:           "spa",
+			RedirectURIs: []string{"https://app.example.com/callback"},
+			Scopes:       []string{"openid", "email"},
+		},
+		{
+			ID:           "backend",
+			Secret:       "secret",
+			RedirectURIs: []string{"https://backend.example.com/callback?tenant=1", "https://backend.example.com/other"},
+			Scopes:       []string{"openid"},
+		},
+	},
+	CodeLifetime:   time.Minute,
+	ATokenLifetime: 15 * time.Minute,
+}
+
+const (
+	defaultVerifier  = "dBjftJeZ4CVP-mJ92K9qpfn3SSE3dRLt6wbHbV2Y9Ag"
+	defaultChallenge = "vnf8b8jxGZrDT0nc7-kI1kwPmMCW8QHPGVEli3Pd-fo"
+)
+
+func newTestService(ctrl *gomock.Controller) (*oauth, *mock_auth.MockInterface, *mock_jwt.MockInterface, *mock_user.MockInterface, *mock_repository.MockAuthorizationCode) {
+	logger := mock_logger.NewMockInterface(ctrl)
+	logger.EXPECT().Info(gomock.Any(), gomock.Any()).AnyTimes()
+	logger.EXPECT().Warn(gomock.Any(), gomock.Any()).AnyTimes()
+	logger.EXPECT().Debug(gomock.Any(), gomock.Any()).AnyTimes()
+	authService := mock_auth.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	codes := mock_repository.NewMockAuthorizationCode(ctrl)
+
+	service := New(authService, jwtMaker, userService, codes, defaultConfig, logger)
+
+	now := time.Unix(1700000000, 0)
+	service.now = func() time.Time { return now }
+
+	return service, authService, jwtMaker, userService, codes
+}
+
+func TestAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, _, _, codes := newTestService(ctrl)
+
+	defaultRequest := AuthorizeRequest{
+		ResponseType:        ResponseTypeCode,
+		ClientID:            "spa",
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid",
+		State:               "xyz",
+		CodeChallenge:       defaultChallenge,
+		CodeChallengeMethod: CodeChallengeMethodS256,
+		Nonce:               "nonce",
+	}
+	defaultAMR := []string{model.AMRPassword}
+	defaultPayload := &model.Payload{UserID: 1, SessionID: 2, IP: "::1", AMR: defaultAMR, AuthTime: 1600000000}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	// checkRedirectError parse redirect uri with error of request
+	checkRedirectError := func(t *testing.T, uri string, code string) {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		assert.Equal(t, "app.example.com", u.Host)
+		assert.Equal(t, code, u.Query().Get("error"))
+		assert.NotEmpty(t, u.Query().Get("error_description"))
+		assert.Equal(t, "xyz", u.Query().Get("state"))
+		assert.Empty(t, u.Query().Get("code"))
+	}
+
+	tc := []struct {
+		name        string
+		input       AuthorizeRequest
+		payload     *model.Payload
+		buildStubs  func()
+		checkResult func(t *testing.T, uri string, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, c model.AuthorizationCode) error {
+						assert.Len(t, c.CodeHash, 64)
+						assert.Equal(t, "spa", c.ClientID)
+						assert.Equal(t, 1, c.UserID)
+						assert.Equal(t, defaultRequest.RedirectURI, c.RedirectURI)
+						assert.Equal(t, defaultChallenge, c.CodeChallenge)
+						assert.Equal(t, "openid", c.Scope)
+						assert.Equal(t, defaultAMR, c.AMR)
+						assert.Equal(t, "nonce", c.Nonce)
+						assert.Equal(t, defaultPayload.AuthTime, c.AuthTime)
+						assert.Equal(t, service.now().Add(defaultConfig.CodeLifetime).Unix(), c.ExpiresAt)
+						return nil
+					})
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				u, err := url.Parse(uri)
+				require.NoError(t, err)
+				assert.Equal(t, "https://app.example.com/callback", u.Scheme+"://"+u.Host+u.Path)
+				assert.Len(t, u.Query().Get("code"), 43)
+				assert.Equal(t, "xyz", u.Query().Get("state"))
+			},
+		},
+		{
+			name: "OK redirect uri omitted, all scopes of client",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            "spa",
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, c model.AuthorizationCode) error {
+						assert.Empty(t, c.RedirectURI) // note: token request must omit it too
+						assert.Equal(t, "openid email", c.Scope)
+						return nil
+					})
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				u, err := url.Parse(uri)
+				require.NoError(t, err)
+				assert.Equal(t, "app.example.com", u.Host)
+				assert.NotEmpty(t, u.Query().Get("code"))
+				assert.False(t, u.Query().Has("state"))
+			},
+		},
+		{
+			name: "OK query of registered uri is kept",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            "backend",
+				RedirectURI:         "https://backend.example.com/callback?tenant=1",
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				u, err := url.Parse(uri)
+				require.NoError(t, err)
+				assert.Equal(t, "1", u.Query().Get("tenant"))
+				assert.NotEmpty(t, u.Query().Get("code"))
+			},
+		},
+		{
+			name: "error unknown client",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            "unknown", // note
+				RedirectURI:         defaultRequest.RedirectURI,
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				var oauthErr *Error
+				require.ErrorAs(t, err, &oauthErr)
+				assert.Equal(t, ErrorInvalidRequest, oauthErr.Code)
+				assert.Empty(t, uri)
+			},
+		},
+		{
+			name: "error redirect uri not registered",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            "spa",
+				RedirectURI:         "https://app.example.com/callback/../evil", // note
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				var oauthErr *Error
+				require.ErrorAs(t, err, &oauthErr)
+				assert.Equal(t, ErrorInvalidRequest, oauthErr.Code)
+				assert.Empty(t, uri)
+			},
+		},
+		{
+			name: "error redirect uri omitted by client with few",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            "backend", // note
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				var oauthErr *Error
+				require.ErrorAs(t, err, &oauthErr)
+				assert.Empty(t, uri)
+			},
+		},
+		{
+			name: "error unsupported response type",
+			input: AuthorizeRequest{
+				ResponseType:        "token", // note
+				ClientID:            defaultRequest.ClientID,
+				RedirectURI:         defaultRequest.RedirectURI,
+				State:               defaultRequest.State,
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorUnsupportedResponseType)
+			},
+		},
+		{
+			name: "error without code challenge",
+			input: AuthorizeRequest{
+				ResponseType: ResponseTypeCode,
+				ClientID:     defaultRequest.ClientID,
+				RedirectURI:  defaultRequest.RedirectURI,
+				State:        defaultRequest.State,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorInvalidRequest)
+			},
+		},
+		{
+			name: "error plain code challenge method",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            defaultRequest.ClientID,
+				RedirectURI:         defaultRequest.RedirectURI,
+				State:               defaultRequest.State,
+				CodeChallenge:       defaultVerifier,
+				CodeChallengeMethod: "plain", // note
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorInvalidRequest)
+			},
+		},
+		{
+			name: "error malformed code challenge",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            defaultRequest.ClientID,
+				RedirectURI:         defaultRequest.RedirectURI,
+				State:               defaultRequest.State,
+				CodeChallenge:       "short", // note
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorInvalidRequest)
+			},
+		},
+		{
+			name: "error scope not allowed",
+			input: AuthorizeRequest{
+				ResponseType:        ResponseTypeCode,
+				ClientID:            defaultRequest.ClientID,
+				RedirectURI:         defaultRequest.RedirectURI,
+				State:               defaultRequest.State,
+				Scope:               "openid admin", // note
+				CodeChallenge:       defaultChallenge,
+				CodeChallengeMethod: CodeChallengeMethodS256,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorInvalidScope)
+			},
+		},
+		{
+			name:    "error token of client session",
+			input:   defaultRequest,
+			payload: &model.Payload{UserID: 1, SessionID: 2, IP: "::1", ClientID: "backend"}, // note
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				require.NoError(t, err)
+				checkRedirectError(t, uri, ErrorAccessDenied)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, uri string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, uri)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			payload := defaultPayload
+			if test.payload != nil {
+				payload = test.payload
+			}
+			uri, err := service.Authorize(context.Background(), test.input, payload)
+			test.checkResult(t, uri, err)
+		})
+	}
+}
+
+func TestTokenAuthorizationCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, authService, jwtMaker, userService, codes := newTestService(ctrl)
+
+	defaultIP := "::1"
+
+	defaultRequest := TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     "spa",
+		Code:         "code",
+		RedirectURI:  "https://app.example.com/callback",
+		CodeVerifier: defaultVerifier,
+	}
+
+	defaultCode := model.AuthorizationCode{
+		CodeHash:      hashCode("code"),
+		ClientID:      "spa",
+		UserID:        1,
+		RedirectURI:   "https://app.example.com/callback",
+		CodeChallenge: defaultChallenge,
+		Scope:         "openid email",
+		AMR:           []string{model.AMRPassword},
+		Nonce:         "nonce",
+		AuthTime:      1600000000,
+	}
+
+	defaultGrant := auth.Grant{
+		AMR:      defaultCode.AMR,
+		ClientID: "spa",
+		Scope:    defaultCode.Scope,
+		AuthTime: defaultCode.AuthTime,
+	}
+
+	defaultUser := model.User{ID: 1, Email: "user@gmail.com", EmailVerifiedAt: 2}
+	verified := true
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	checkOAuthError := func(t *testing.T, err error, code string) {
+		var oauthErr *Error
+		require.ErrorAs(t, err, &oauthErr)
+		assert.Equal(t, code, oauthErr.Code)
+	}
+
+	tc := []struct {
+		name        string
+		input       TokenRequest
+		buildStubs  func()
+		checkResult func(t *testing.T, resp TokenResponse, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), hashCode("code"), service.now().Unix()).Times(1).Return(defaultCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), 1, defaultIP, defaultGrant).Times(1).
+					Return("access_token", "refresh_token", nil)
+				userService.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				jwtMaker.EXPECT().CreateIDToken(model.IDToken{
+					Nonce:         "nonce",
+					AuthTime:      defaultCode.AuthTime,
+					AMR:           defaultCode.AMR,
+					Email:         defaultUser.Email,
+					EmailVerified: &verified,
+					RegisteredClaims: gjwt.RegisteredClaims{
+						Issuer:    defaultConfig.Issuer,
+						Subject:   "1",
+						Audience:  gjwt.ClaimStrings{"spa"},
+						IssuedAt:  gjwt.NewNumericDate(service.now()),
+						ExpiresAt: gjwt.NewNumericDate(service.now().Add(defaultConfig.ATokenLifetime)),
+					},
+				}).Times(1).Return("id_token", nil)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, TokenResponse{
+					AccessToken:  "access_token",
+					TokenType:    TokenTypeBearer,
+					ExpiresIn:    900,
+					RefreshToken: "refresh_token",
+					Scope:        "openid email",
+					IDToken:      "id_token",
+				}, resp)
+			},
+		},
+		{
+			name: "OK confidential client",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "backend",
+				ClientSecret: "secret",
+				Code:         "code",
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				backendCode := defaultCode
+				backendCode.ClientID = "backend"
+				backendCode.RedirectURI = ""
+				backendCode.Scope = "" // note: without openid scope
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(backendCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), 1, defaultIP, gomock.Any()).Times(1).
+					Return("access_token", "refresh_token", nil)
+				jwtMaker.EXPECT().CreateIDToken(gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, "access_token", resp.AccessToken)
+				assert.Empty(t, resp.IDToken)
+			},
+		},
+		{
+			name: "error unknown client",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "unknown", // note
+				Code:         "code",
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidClient)
+			},
+		},
+		{
+			name: "error invalid client secret",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "backend",
+				ClientSecret: "wrong", // note
+				Code:         "code",
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidClient)
+			},
+		},
+		{
+			name: "error secret of public client",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "spa",
+				ClientSecret: "secret", // note
+				Code:         "code",
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidClient)
+			},
+		},
+		{
+			name: "error unsupported grant type",
+			input: TokenRequest{
+				GrantType: "password", // note
+				ClientID:  "spa",
+			},
+			buildStubs: func() {},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorUnsupportedGrantType)
+			},
+		},
+		{
+			name: "error without verifier",
+			input: TokenRequest{
+				GrantType:   GrantTypeAuthorizationCode,
+				ClientID:    "spa",
+				Code:        "code",
+				RedirectURI: defaultRequest.RedirectURI,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidRequest)
+			},
+		},
+		{
+			name:  "error invalid, expired or used code",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.AuthorizationCode{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error code of other client",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "backend",
+				ClientSecret: "secret",
+				Code:         "code",
+				RedirectURI:  defaultRequest.RedirectURI,
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error other redirect uri",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "spa",
+				Code:         "code",
+				RedirectURI:  "https://app.example.com/other", // note
+				CodeVerifier: defaultVerifier,
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultCode, nil)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error wrong verifier",
+			input: TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     "spa",
+				Code:         "code",
+				RedirectURI:  defaultRequest.RedirectURI,
+				CodeVerifier: defaultChallenge, // note
+			},
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name:  "error email not verified",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", auth.ErrEmailNotVerified)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name:  "error create id token",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultCode, nil)
+				authService.EXPECT().CreateClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("access_token", "refresh_token", nil)
+				userService.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				jwtMaker.EXPECT().CreateIDToken(gomock.Any()).Times(1).Return("", unexpectedError)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, resp.AccessToken)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				codes.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.AuthorizationCode{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				var oauthErr *Error
+				assert.False(t, errors.As(err, &oauthErr))
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			resp, err := service.Token(context.Background(), test.input, defaultIP)
+			test.checkResult(t, resp, err)
+		})
+	}
+}
+
+func TestTokenRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, authService, jwtMaker, _, _ := newTestService(ctrl)
+
+	defaultIP := "::1"
+
+	defaultRequest := TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		ClientID:     "spa",
+		RefreshToken: "selector.secret",
+	}
+
+	defaultSession := model.Session{
+		ID:       2,
+		UserID:   1,
+		IP:       defaultIP,
+		ClientID: "spa",
+		Scope:    "openid email",
+	}
+
+	defaultPayload := &model.Payload{
+		UserID:    1,
+		SessionID: 2,
+		IP:        defaultIP,
+		ClientID:  "spa",
+		Scope:     "openid email",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	checkOAuthError := func(t *testing.T, err error, code string) {
+		var oauthErr *Error
+		require.ErrorAs(t, err, &oauthErr)
+		assert.Equal(t, code, oauthErr.Code)
+	}
+
+	tc := []struct {
+		name        string
+		input       TokenRequest
+		buildStubs  func()
+		checkResult func(t *testing.T, resp TokenResponse, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(0)
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), "selector.secret").Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), "", "selector.secret", defaultIP, "spa", "").Times(1).
+					Return("new_access_token", "new_selector.new_secret", nil)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, TokenResponse{
+					AccessToken:  "new_access_token",
+					TokenType:    TokenTypeBearer,
+					ExpiresIn:    900,
+					RefreshToken: "new_selector.new_secret",
+					Scope:        "openid email",
+				}, resp)
+			},
+		},
+		{
+			name: "OK legacy refresh token",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "spa",
+				RefreshToken: "access_token~refresh_token", // note
+			},
+			buildStubs: func() {
+				// note: expired access token is normal for refresh
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), "access_token").Times(1).Return(nil, defaultPayload, gjwt.ErrTokenExpired)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), "access_token", "refresh_token", defaultIP, "spa", "").Times(1).
+					Return("new_access_token", "new_selector.new_secret", nil)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				require.NoError(t, err)
+				// note: session is upgraded, client gets refresh token of new format
+				assert.Equal(t, "new_selector.new_secret", resp.RefreshToken)
+				assert.Equal(t, "openid email", resp.Scope)
+			},
+		},
+		{
+			name: "OK part of granted scope",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "spa",
+				RefreshToken: defaultRequest.RefreshToken,
+				Scope:        "email", // note
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), "", "selector.secret", defaultIP, "spa", "email").Times(1).
+					Return("new_access_token", "new_selector.new_secret", nil)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				require.NoError(t, err)
+				// note: session is narrowed to requested scope
+				assert.Equal(t, "email", resp.Scope)
+			},
+		},
+		{
+			name: "error unknown refresh token",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "spa",
+				RefreshToken: "refresh_token", // note
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), "refresh_token").Times(1).
+					Return(model.Session{}, auth.ErrValidationFailed)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error invalid access token of legacy refresh token",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "spa",
+				RefreshToken: "access_token~refresh_token",
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil, gjwt.ErrSignatureInvalid)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error token of other client",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "backend", // note
+				ClientSecret: "secret",
+				RefreshToken: defaultRequest.RefreshToken,
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name:  "error token of first-party session",
+			input: defaultRequest,
+			buildStubs: func() {
+				session := defaultSession
+				session.ClientID = "" // note
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(session, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "error scope exceeds granted",
+			input: TokenRequest{
+				GrantType:    GrantTypeRefreshToken,
+				ClientID:     "spa",
+				RefreshToken: defaultRequest.RefreshToken,
+				Scope:        "openid admin", // note
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidScope)
+			},
+		},
+		{
+			name:  "error reused refresh token",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", auth.ErrTokenReused)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				checkOAuthError(t, err, ErrorInvalidGrant)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().RefreshTokenSession(gomock.Any(), gomock.Any()).Times(1).Return(defaultSession, nil)
+				authService.EXPECT().RefreshClientSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", unexpectedError)
+			},
+			checkResult: func(t *testing.T, resp TokenResponse, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			resp, err := service.Token(context.Background(), test.input, defaultIP)
+			test.checkResult(t, resp, err)
+		})
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	assert.True(t, verifyCodeChallenge(defaultVerifier, defaultChallenge))
+	assert.False(t, verifyCodeChallenge(defaultVerifier+"A", defaultChallenge))
+	assert.False(t, verifyCodeChallenge(defaultChallenge, defaultChallenge))
+}
+
+func TestUserInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, _, userService, _ := newTestService(ctrl)
+
+	defaultUser := model.User{ID: 1, Email: "user@gmail.com"}
+	notVerified := false
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       *model.Payload
+		buildStubs  func()
+		checkResult func(t *testing.T, info model.UserInfo, err error)
+	}{
+		{
+			name:  "OK",
+			input: &model.Payload{UserID: 1, ClientID: "spa", Scope: "openid email"},
+			buildStubs: func() {
+				userService.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+			},
+			checkResult: func(t *testing.T, info model.UserInfo, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, model.UserInfo{Subject: "1", Email: "user@gmail.com", EmailVerified: &notVerified}, info)
+			},
+		},
+		{
+			name:  "OK without email scope",
+			input: &model.Payload{UserID: 1, ClientID: "spa", Scope: "openid"}, // note
+			buildStubs: func() {
+				userService.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+			},
+			checkResult: func(t *testing.T, info model.UserInfo, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, model.UserInfo{Subject: "1"}, info)
+			},
+		},
+		{
+			name:  "error without openid scope",
+			input: &model.Payload{UserID: 1, ClientID: "spa", Scope: "email"}, // note
+			buildStubs: func() {
+				userService.EXPECT().GetByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, info model.UserInfo, err error) {
+				assert.ErrorIs(t, err, ErrInsufficientScope)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: &model.Payload{UserID: 1, ClientID: "spa", Scope: "openid"},
+			buildStubs: func() {
+				userService.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(model.User{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, info model.UserInfo, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			info, err := service.UserInfo(context.Background(), test.input)
+			test.checkResult(t, info, err)
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, _, _, codes := newTestService(ctrl)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				codes.EXPECT().DeleteExpired(gomock.Any(), gomock.Eq(service.now().Unix())).Times(1).Return(int64(2), nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				codes.EXPECT().DeleteExpired(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			test.checkResult(t, service.Prune(context.Background()))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"medods/internal/model"
+	"strconv"
+	"strings"
+
+	gjwt "github.com/golang-jwt/jwt/v5"
+)
+
+// scopes of OpenID Connect, openid makes request OpenID Connect one
+const (
+	ScopeOpenID = "openid"
+	ScopeEmail  = "email"
+)
+
+var (
+	ErrInsufficientScope = fmt.Errorf("token is not granted openid scope")
+)
+
+// UserInfo return claims of user which access token belongs to, token must be granted openid scope.
+// Email is released only with email scope.
+func (s oauth) UserInfo(ctx context.Context, payload *model.Payload) (model.UserInfo, error) {
+	if !hasScope(payload.Scope, ScopeOpenID) {
+		return model.UserInfo{}, ErrInsufficientScope
+	}
+
+	dbUser, err := s.user.GetByID(ctx, payload.UserID)
+	if err != nil {
+		return model.UserInfo{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	info := model.UserInfo{Subject: strconv.Itoa(dbUser.ID)}
+	if hasScope(payload.Scope, ScopeEmail) {
+		verified := dbUser.EmailVerifiedAt != 0
+		info.Email = dbUser.Email
+		info.EmailVerified = &verified
+	}
+
+	return info, nil
+}
+
+// idToken sign id token for user of authorization code, audience is client which code was issued to
+func (s oauth) idToken(ctx context.Context, client Client, code model.AuthorizationCode) (string, error) {
+	info, err := s.UserInfo(ctx, &model.Payload{UserID: code.UserID, Scope: code.Scope})
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+	token, err := s.jwt.CreateIDToken(model.IDToken{
+		Nonce:         code.Nonce,
+		AuthTime:      code.AuthTime,
+		AMR:           code.AMR,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		RegisteredClaims: gjwt.RegisteredClaims{
+			Issuer:    s.cfg.Issuer,
+			Subject:   info.Subject,
+			Audience:  gjwt.ClaimStrings{client.ID},
+			IssuedAt:  gjwt.NewNumericDate(now),
+			ExpiresAt: gjwt.NewNumericDate(now.Add(s.cfg.ATokenLifetime)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create id token: %w", err)
+	}
+
+	return token, nil
+}
+
+func hasScope(scope, value string) bool {
+	return isSubset([]string{value}, strings.Fields(scope))
+}
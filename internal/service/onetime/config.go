@@ -0,0 +1,6 @@
+package onetime
+
+type Config struct {
+	// key of token signature, tokens with wrong signature are rejected without database query
+	SecretKey []byte
+}
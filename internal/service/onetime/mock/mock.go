@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/onetime/onetime.go
+
+// Package mock_onetime is a generated GoMock package.
+package mock_onetime
+
+import (
+	context "context"
+	model "medods/internal/model"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockInterface is a mock of Interface interface.
+type MockInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockInterfaceMockRecorder
+}
+
+// MockInterfaceMockRecorder is the mock recorder for MockInterface.
+type MockInterfaceMockRecorder struct {
+	mock *MockInterface
+}
+
+// NewMockInterface creates a new mock instance.
+func NewMockInterface(ctrl *gomock.Controller) *MockInterface {
+	mock := &MockInterface{ctrl: ctrl}
+	mock.recorder = &MockInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockInterface) Consume(ctx context.Context, purpose, token string) (model.OneTimeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, purpose, token)
+	ret0, _ := ret[0].(model.OneTimeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockInterfaceMockRecorder) Consume(ctx, purpose, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockInterface)(nil).Consume), ctx, purpose, token)
+}
+
+// ConsumeBound mocks base method.
+func (m *MockInterface) ConsumeBound(ctx context.Context, purpose, token, binding string) (model.OneTimeToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeBound", ctx, purpose, token, binding)
+	ret0, _ := ret[0].(model.OneTimeToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeBound indicates an expected call of ConsumeBound.
+func (mr *MockInterfaceMockRecorder) ConsumeBound(ctx, purpose, token, binding interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeBound", reflect.TypeOf((*MockInterface)(nil).ConsumeBound), ctx, purpose, token, binding)
+}
+
+// Issue mocks base method.
+func (m *MockInterface) Issue(ctx context.Context, purpose string, userID int, ttl time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", ctx, purpose, userID, ttl)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Issue indicates an expected call of Issue.
+func (mr *MockInterfaceMockRecorder) Issue(ctx, purpose, userID, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockInterface)(nil).Issue), ctx, purpose, userID, ttl)
+}
+
+// IssueBound mocks base method.
+func (m *MockInterface) IssueBound(ctx context.Context, purpose string, userID int, ttl time.Duration, binding string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueBound", ctx, purpose, userID, ttl, binding)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueBound indicates an expected call of IssueBound.
+func (mr *MockInterfaceMockRecorder) IssueBound(ctx, purpose, userID, ttl, binding interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueBound", reflect.TypeOf((*MockInterface)(nil).IssueBound), ctx, purpose, userID, ttl, binding)
+}
+
+// Prune mocks base method.
+func (m *MockInterface) Prune(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prune", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Prune indicates an expected call of Prune.
+func (mr *MockInterfaceMockRecorder) Prune(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prune", reflect.TypeOf((*MockInterface)(nil).Prune), ctx)
+}
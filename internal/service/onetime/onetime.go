@@ -0,0 +1,138 @@
+package onetime
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/repository"
+	"medods/pkg/logger"
+	"strings"
+	"time"
+)
+
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	PurposeMagicLink         = "magic_link"
+	PurposeMFAChallenge      = "mfa_challenge"
+	// challenges of WebAuthn ceremonies, token itself is the challenge signed by authenticator
+	PurposeWebAuthnRegistration = "webauthn_registration"
+	PurposeWebAuthnLogin        = "webauthn_login"
+)
+
+// count of random bytes in token, before signature and base64 encoding
+const tokenBytes = 32
+
+var (
+	ErrInvalidToken = fmt.Errorf("token is invalid, expired or already used")
+)
+
+type Interface interface {
+	Issue(ctx context.Context, purpose string, userID int, ttl time.Duration) (string, error)
+	Consume(ctx context.Context, purpose, token string) (model.OneTimeToken, error)
+
+	IssueBound(ctx context.Context, purpose string, userID int, ttl time.Duration, binding string) (string, error)
+	ConsumeBound(ctx context.Context, purpose, token, binding string) (model.OneTimeToken, error)
+
+	Prune(ctx context.Context) error
+}
+
+var _ Interface = (*onetime)(nil)
+
+// onetime issue signed single-use tokens for links sent to user.
+// Token is <random>.<signature>, signature binds token to purpose and optional binding, e.g. browser nonce,
+// database keeps only sha256 of random part, so leaked table can't be used to forge links.
+type onetime struct {
+	repo      repository.OneTimeToken
+	secretKey []byte
+	logger    logger.Interface
+
+	now func() time.Time
+}
+
+func New(repo repository.OneTimeToken, cfg *Config, logger logger.Interface) *onetime {
+	return &onetime{
+		repo:      repo,
+		secretKey: cfg.SecretKey,
+		logger:    logger,
+
+		now: time.Now,
+	}
+}
+
+// Issue create token of purpose for user, token is valid for ttl or until it's consumed
+func (s onetime) Issue(ctx context.Context, purpose string, userID int, ttl time.Duration) (string, error) {
+	return s.IssueBound(ctx, purpose, userID, ttl, "")
+}
+
+// Consume verify token of purpose and mark it used, second call with the same token fails
+func (s onetime) Consume(ctx context.Context, purpose, token string) (model.OneTimeToken, error) {
+	return s.ConsumeBound(ctx, purpose, token, "")
+}
+
+// IssueBound create token which could be consumed only with the same binding
+func (s onetime) IssueBound(ctx context.Context, purpose string, userID int, ttl time.Duration, binding string) (string, error) {
+	random := make([]byte, tokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(random)
+
+	if err := s.repo.Create(ctx, model.OneTimeToken{
+		TokenHash: hashToken(encoded),
+		Purpose:   purpose,
+		UserID:    userID,
+		ExpiresAt: s.now().Add(ttl).Unix(),
+	}); err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return encoded + "." + s.sign(purpose, encoded, binding), nil
+}
+
+// ConsumeBound verify token of purpose with binding and mark it used.
+// Token with other binding is rejected before database, so it's not burned.
+func (s onetime) ConsumeBound(ctx context.Context, purpose, token, binding string) (model.OneTimeToken, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(purpose, encoded, binding))) {
+		s.logger.Warn("%s token with invalid signature presented", purpose)
+		return model.OneTimeToken{}, ErrInvalidToken
+	}
+
+	t, err := s.repo.Consume(ctx, hashToken(encoded), purpose, s.now().Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.OneTimeToken{}, ErrInvalidToken
+	} else if err != nil {
+		return model.OneTimeToken{}, fmt.Errorf("failed to consume token: %w", err)
+	}
+
+	return t, nil
+}
+
+// Prune remove expired tokens from database, unauthenticated requests keep issuing them
+func (s onetime) Prune(ctx context.Context) error {
+	deleted, err := s.repo.DeleteExpired(ctx, s.now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+	s.logger.Debug("pruned %d expired one-time tokens", deleted)
+	return nil
+}
+
+func (s onetime) sign(purpose, encoded, binding string) string {
+	mac := hmac.New(sha256.New, s.secretKey)
+	mac.Write([]byte(purpose + "." + encoded + "." + binding))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashToken(encoded string) string {
+	sum := sha256.Sum256([]byte(encoded))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,329 @@
+package onetime
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"medods/internal/model"
+	mock_repository "medods/internal/repository/mock"
+	mock_logger "medods/pkg/logger/mock"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultConfig = &Config{
+	SecretKey: []byte("secret"),
+}
+
+func TestIssue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	repo := mock_repository.NewMockOneTimeToken(ctrl)
+
+	service := New(repo, defaultConfig, logger)
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, token string, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, ott model.OneTimeToken) error {
+						assert.Equal(t, PurposeEmailVerification, ott.Purpose)
+						assert.Equal(t, 1, ott.UserID)
+						assert.Equal(t, now.Add(time.Hour).Unix(), ott.ExpiresAt)
+						assert.Len(t, ott.TokenHash, 64)
+						return nil
+					})
+			},
+			checkResult: func(t *testing.T, token string, err error) {
+				assert.NoError(t, err)
+
+				// token is url safe, so could be sent in link without escaping
+				random, signature, ok := strings.Cut(token, ".")
+				assert.True(t, ok)
+				assert.NotEmpty(t, random)
+				assert.NotEmpty(t, signature)
+				assert.NotContains(t, token, "+")
+				assert.NotContains(t, token, "/")
+				assert.NotContains(t, token, "=")
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, token string, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.Empty(t, token)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			token, err := service.Issue(context.Background(), PurposeEmailVerification, 1, time.Hour)
+			test.checkResult(t, token, err)
+		})
+	}
+}
+
+func TestConsume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	repo := mock_repository.NewMockOneTimeToken(ctrl)
+
+	service := New(repo, defaultConfig, logger)
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	var tokenHash string
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(_ context.Context, ott model.OneTimeToken) error {
+			tokenHash = ott.TokenHash
+			return nil
+		})
+
+	defaultToken, err := service.Issue(context.Background(), PurposeEmailVerification, 1, time.Hour)
+	assert.NoError(t, err)
+
+	defaultOneTimeToken := model.OneTimeToken{
+		TokenHash: tokenHash,
+		Purpose:   PurposeEmailVerification,
+		UserID:    1,
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		UsedAt:    now.Unix(),
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		purpose string
+		token   string
+	}
+
+	defaultArgs := args{
+		purpose: PurposeEmailVerification,
+		token:   defaultToken,
+	}
+
+	tc := []struct {
+		name        string
+		input       args
+		buildStubs  func()
+		checkResult func(t *testing.T, ott model.OneTimeToken, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				repo.EXPECT().Consume(gomock.Any(), gomock.Eq(tokenHash), gomock.Eq(PurposeEmailVerification), gomock.Eq(now.Unix())).Times(1).
+					Return(defaultOneTimeToken, nil)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultOneTimeToken, ott)
+			},
+		},
+		{
+			name:  "error used, expired or unknown",
+			input: defaultArgs,
+			buildStubs: func() {
+				repo.EXPECT().Consume(gomock.Any(), gomock.Eq(tokenHash), gomock.Eq(PurposeEmailVerification), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+		{
+			name: "error invalid signature",
+			input: args{
+				purpose: PurposeEmailVerification,
+				token:   defaultToken + "a", // note: tampered
+			},
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+		{
+			name: "error other purpose",
+			input: args{
+				purpose: "other", // note: token was issued for email verification
+				token:   defaultToken,
+			},
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+		{
+			name: "error malformed",
+			input: args{
+				purpose: PurposeEmailVerification,
+				token:   "evenNotToken", // note: no signature
+			},
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				repo.EXPECT().Consume(gomock.Any(), gomock.Eq(tokenHash), gomock.Eq(PurposeEmailVerification), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			ott, err := service.Consume(context.Background(), test.input.purpose, test.input.token)
+			test.checkResult(t, ott, err)
+		})
+	}
+}
+
+func TestConsumeBound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	repo := mock_repository.NewMockOneTimeToken(ctrl)
+
+	service := New(repo, defaultConfig, logger)
+
+	var tokenHash string
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(_ context.Context, ott model.OneTimeToken) error {
+			tokenHash = ott.TokenHash
+			return nil
+		})
+
+	defaultBinding := "nonce"
+	defaultToken, err := service.IssueBound(context.Background(), PurposeMagicLink, 1, time.Minute, defaultBinding)
+	assert.NoError(t, err)
+
+	tc := []struct {
+		name        string
+		input       string
+		buildStubs  func()
+		checkResult func(t *testing.T, ott model.OneTimeToken, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultBinding,
+			buildStubs: func() {
+				repo.EXPECT().Consume(gomock.Any(), gomock.Eq(tokenHash), gomock.Eq(PurposeMagicLink), gomock.Any()).Times(1).
+					Return(model.OneTimeToken{UserID: 1}, nil)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, ott.UserID)
+			},
+		},
+		{
+			name:  "error other binding",
+			input: "other_nonce", // note: link opened in other browser
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+		{
+			name:  "error without binding",
+			input: "",
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, ott model.OneTimeToken, err error) {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			ott, err := service.ConsumeBound(context.Background(), PurposeMagicLink, defaultToken, test.input)
+			test.checkResult(t, ott, err)
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	repo := mock_repository.NewMockOneTimeToken(ctrl)
+
+	service := New(repo, defaultConfig, logger)
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				repo.EXPECT().DeleteExpired(gomock.Any(), gomock.Eq(now.Unix())).Times(1).Return(int64(2), nil)
+				logger.EXPECT().Debug(gomock.Any(), gomock.Any()).Times(1)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				repo.EXPECT().DeleteExpired(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			test.checkResult(t, service.Prune(context.Background()))
+		})
+	}
+}
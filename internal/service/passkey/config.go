@@ -0,0 +1,16 @@
+package passkey
+
+import "time"
+
+type Config struct {
+	// relying party id, registrable domain of site, e.g. example.com
+	RPID string
+	// name of site shown by authenticator
+	RPName string
+	// origins of pages which run ceremonies, e.g. https://example.com
+	Origins []string
+	// time given to finish ceremony
+	ChallengeLifetime time.Duration
+	// key of HMAC which derives fake passkey ids from email
+	FakeCredentialKey []byte
+}
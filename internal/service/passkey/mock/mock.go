@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./internal/service/passkey/passkey.go
+
+// Package mock_passkey is a generated GoMock package.
+package mock_passkey
+
+import (
+	context "context"
+	model "medods/internal/model"
+	webauthn "medods/pkg/webauthn"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockInterface is a mock of Interface interface.
+type MockInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockInterfaceMockRecorder
+}
+
+// MockInterfaceMockRecorder is the mock recorder for MockInterface.
+type MockInterfaceMockRecorder struct {
+	mock *MockInterface
+}
+
+// NewMockInterface creates a new mock instance.
+func NewMockInterface(ctrl *gomock.Controller) *MockInterface {
+	mock := &MockInterface{ctrl: ctrl}
+	mock.recorder = &MockInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
+	return m.recorder
+}
+
+// BeginLogin mocks base method.
+func (m *MockInterface) BeginLogin(ctx context.Context, email string) (webauthn.RequestOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginLogin", ctx, email)
+	ret0, _ := ret[0].(webauthn.RequestOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginLogin indicates an expected call of BeginLogin.
+func (mr *MockInterfaceMockRecorder) BeginLogin(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginLogin", reflect.TypeOf((*MockInterface)(nil).BeginLogin), ctx, email)
+}
+
+// BeginRegistration mocks base method.
+func (m *MockInterface) BeginRegistration(ctx context.Context, uid int) (webauthn.CreationOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginRegistration", ctx, uid)
+	ret0, _ := ret[0].(webauthn.CreationOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginRegistration indicates an expected call of BeginRegistration.
+func (mr *MockInterfaceMockRecorder) BeginRegistration(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginRegistration", reflect.TypeOf((*MockInterface)(nil).BeginRegistration), ctx, uid)
+}
+
+// FinishLogin mocks base method.
+func (m *MockInterface) FinishLogin(ctx context.Context, resp webauthn.AssertionResponse) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishLogin", ctx, resp)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishLogin indicates an expected call of FinishLogin.
+func (mr *MockInterfaceMockRecorder) FinishLogin(ctx, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishLogin", reflect.TypeOf((*MockInterface)(nil).FinishLogin), ctx, resp)
+}
+
+// FinishRegistration mocks base method.
+func (m *MockInterface) FinishRegistration(ctx context.Context, uid int, resp webauthn.AttestationResponse) (model.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishRegistration", ctx, uid, resp)
+	ret0, _ := ret[0].(model.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishRegistration indicates an expected call of FinishRegistration.
+func (mr *MockInterfaceMockRecorder) FinishRegistration(ctx, uid, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishRegistration", reflect.TypeOf((*MockInterface)(nil).FinishRegistration), ctx, uid, resp)
+}
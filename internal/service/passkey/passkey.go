@@ -0,0 +1,259 @@
+package passkey
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/repository"
+	"medods/internal/service/onetime"
+	"medods/pkg/logger"
+	"medods/pkg/webauthn"
+	"strconv"
+	"time"
+)
+
+// count of random bytes in parts of fake challenge of unknown email, the same as in real one
+const fakeChallengeBytes = 32
+
+var (
+	ErrInvalidChallenge  = fmt.Errorf("passkey challenge is invalid, expired or already used")
+	ErrInvalidCredential = fmt.Errorf("invalid passkey")
+	ErrCredentialExists  = fmt.Errorf("passkey is already registered")
+)
+
+type Interface interface {
+	BeginRegistration(ctx context.Context, uid int) (webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, uid int, resp webauthn.AttestationResponse) (model.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, email string) (webauthn.RequestOptions, error)
+	FinishLogin(ctx context.Context, resp webauthn.AssertionResponse) (uid int, err error)
+}
+
+var _ Interface = (*passkey)(nil)
+
+// passkey register WebAuthn credentials of users and log them in by credentials.
+// Challenge of ceremony is single-use token bound to user, so it's checked and burned without server-side state.
+type passkey struct {
+	userRepo       repository.User
+	credentialRepo repository.WebAuthnCredential
+	tokens         onetime.Interface
+	webauthn       *webauthn.WebAuthn
+
+	cfg    *Config
+	logger logger.Interface
+
+	now func() time.Time
+}
+
+func New(
+	userRepo repository.User,
+	credentialRepo repository.WebAuthnCredential,
+	tokens onetime.Interface,
+	cfg *Config,
+	logger logger.Interface,
+) *passkey {
+	return &passkey{
+		userRepo:       userRepo,
+		credentialRepo: credentialRepo,
+		tokens:         tokens,
+		webauthn: webauthn.New(&webauthn.Config{
+			RPID:    cfg.RPID,
+			RPName:  cfg.RPName,
+			Origins: cfg.Origins,
+			Timeout: cfg.ChallengeLifetime,
+		}),
+
+		cfg:    cfg,
+		logger: logger,
+
+		now: time.Now,
+	}
+}
+
+// BeginRegistration return options of navigator.credentials.create() for logged in user
+func (s passkey) BeginRegistration(ctx context.Context, uid int) (webauthn.CreationOptions, error) {
+	dbUser, err := s.userRepo.GetByID(ctx, uid)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	exclude, err := s.credentialIDs(ctx, uid)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+
+	challenge, err := s.tokens.IssueBound(ctx, onetime.PurposeWebAuthnRegistration, uid, s.cfg.ChallengeLifetime, strconv.Itoa(uid))
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("failed to issue challenge: %w", err)
+	}
+
+	user := webauthn.UserEntity{
+		ID:          []byte(strconv.Itoa(uid)),
+		Name:        dbUser.Email,
+		DisplayName: dbUser.Email,
+	}
+	return s.webauthn.CreationOptions([]byte(challenge), user, exclude), nil
+}
+
+// FinishRegistration verify new credential of user and save it
+func (s passkey) FinishRegistration(ctx context.Context, uid int, resp webauthn.AttestationResponse) (model.WebAuthnCredential, error) {
+	challenge, err := s.consumeChallenge(ctx, onetime.PurposeWebAuthnRegistration, resp.Response.ClientDataJSON, uid)
+	if err != nil {
+		return model.WebAuthnCredential{}, err
+	}
+
+	cred, err := s.webauthn.VerifyRegistration(resp, challenge)
+	if err != nil {
+		s.logger.Warn("passkey registration of user[%d] failed: %s", uid, err)
+		return model.WebAuthnCredential{}, fmt.Errorf("%w: %s", ErrInvalidCredential, err)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(cred.ID)
+	if _, err := s.credentialRepo.GetByCredentialID(ctx, credentialID); err == nil {
+		return model.WebAuthnCredential{}, ErrCredentialExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return model.WebAuthnCredential{}, fmt.Errorf("failed to get passkey: %w", err)
+	}
+
+	dbCredential, err := s.credentialRepo.Create(ctx, model.WebAuthnCredential{
+		UserID:       uid,
+		CredentialID: credentialID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		CreatedAt:    s.now().Unix(),
+	})
+	if err != nil {
+		return model.WebAuthnCredential{}, fmt.Errorf("failed to save passkey: %w", err)
+	}
+	s.logger.Info("passkey[%d] of user[%d] registered", dbCredential.ID, uid)
+
+	return dbCredential, nil
+}
+
+// BeginLogin return options of navigator.credentials.get() with passkeys of user.
+// Unknown email gets options with challenge which can't be used and fake passkey, user without passkeys
+// gets fake passkey too, so response doesn't tell whether user exists or has passkeys.
+func (s passkey) BeginLogin(ctx context.Context, email string) (webauthn.RequestOptions, error) {
+	dbUser, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Info("passkey login requested for unknown email")
+		return s.fakeRequestOptions(email)
+	} else if err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	allow, err := s.credentialIDs(ctx, dbUser.ID)
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+	if len(allow) == 0 {
+		allow = [][]byte{s.fakeCredentialID(email)}
+	}
+
+	challenge, err := s.tokens.IssueBound(ctx, onetime.PurposeWebAuthnLogin, dbUser.ID, s.cfg.ChallengeLifetime, strconv.Itoa(dbUser.ID))
+	if err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("failed to issue challenge: %w", err)
+	}
+
+	return s.webauthn.RequestOptions([]byte(challenge), allow), nil
+}
+
+// FinishLogin verify assertion by stored credential and return its user.
+// Sign count which didn't grow means that credential is cloned, login is refused.
+func (s passkey) FinishLogin(ctx context.Context, resp webauthn.AssertionResponse) (uid int, err error) {
+	dbCredential, err := s.credentialRepo.GetByCredentialID(ctx, base64.RawURLEncoding.EncodeToString(resp.RawID))
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("login by unknown passkey")
+		return 0, ErrInvalidCredential
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get passkey: %w", err)
+	}
+
+	challenge, err := s.consumeChallenge(ctx, onetime.PurposeWebAuthnLogin, resp.Response.ClientDataJSON, dbCredential.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	signCount, err := s.webauthn.VerifyAssertion(resp, challenge, webauthn.Credential{
+		ID:        resp.RawID,
+		PublicKey: dbCredential.PublicKey,
+		SignCount: uint32(dbCredential.SignCount),
+	})
+	if errors.Is(err, webauthn.ErrSignCount) {
+		s.logger.Warn("sign count of passkey[%d] of user[%d] is not increased, authenticator may be cloned", dbCredential.ID, dbCredential.UserID)
+		return 0, ErrInvalidCredential
+	} else if err != nil {
+		s.logger.Warn("passkey login of user[%d] failed: %s", dbCredential.UserID, err)
+		return 0, ErrInvalidCredential
+	}
+
+	// concurrent login with the same counter
+	err = s.credentialRepo.UpdateSignCount(ctx, dbCredential.ID, dbCredential.SignCount, int64(signCount), s.now().Unix())
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("sign count of passkey[%d] of user[%d] changed concurrently", dbCredential.ID, dbCredential.UserID)
+		return 0, ErrInvalidCredential
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to update sign count: %w", err)
+	}
+
+	return dbCredential.UserID, nil
+}
+
+// consumeChallenge burn challenge claimed by client data, it must be issued for purpose and user
+func (s passkey) consumeChallenge(ctx context.Context, purpose string, clientDataJSON []byte, uid int) ([]byte, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	_, err = s.tokens.ConsumeBound(ctx, purpose, string(challenge), strconv.Itoa(uid))
+	if errors.Is(err, onetime.ErrInvalidToken) {
+		s.logger.Warn("invalid %s challenge of user[%d]", purpose, uid)
+		return nil, ErrInvalidChallenge
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to consume challenge: %w", err)
+	}
+
+	return challenge, nil
+}
+
+func (s passkey) credentialIDs(ctx context.Context, uid int) ([][]byte, error) {
+	credentials, err := s.credentialRepo.ListByUserID(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+
+	ids := make([][]byte, 0, len(credentials))
+	for _, c := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id of passkey[%d]: %w", c.ID, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// fakeRequestOptions has challenge of the same format as token, <random>.<signature>
+func (s passkey) fakeRequestOptions(email string) (webauthn.RequestOptions, error) {
+	random := make([]byte, 2*fakeChallengeBytes)
+	if _, err := rand.Read(random); err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(random[:fakeChallengeBytes]) + "." +
+		base64.RawURLEncoding.EncodeToString(random[fakeChallengeBytes:])
+	return s.webauthn.RequestOptions([]byte(challenge), [][]byte{s.fakeCredentialID(email)}), nil
+}
+
+// fakeCredentialID is the same for email on every request, so repeated requests don't tell it's fake
+func (s passkey) fakeCredentialID(email string) []byte {
+	mac := hmac.New(sha256.New, s.cfg.FakeCredentialKey)
+	mac.Write([]byte(email))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,482 @@
+package passkey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"medods/internal/model"
+	mock_repository "medods/internal/repository/mock"
+	"medods/internal/service/onetime"
+	mock_onetime "medods/internal/service/onetime/mock"
+	mock_logger "medods/pkg/logger/mock"
+	"medods/pkg/webauthn"
+	"medods/pkg/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultConfig = &Config{
+	RPID:              "example.com",
+	RPName:            "Example",
+	Origins:           []string{"https://example.com"},
+	ChallengeLifetime: 5 * time.Minute,
+	FakeCredentialKey: []byte("fake credential key"),
+}
+
+const defaultChallenge = "challenge.signature"
+
+func newTestService(ctrl *gomock.Controller) (*passkey, *mock_repository.MockUser, *mock_repository.MockWebAuthnCredential, *mock_onetime.MockInterface) {
+	logger := mock_logger.NewMockInterface(ctrl)
+	logger.EXPECT().Info(gomock.Any(), gomock.Any()).AnyTimes()
+	logger.EXPECT().Warn(gomock.Any(), gomock.Any()).AnyTimes()
+	userRepo := mock_repository.NewMockUser(ctrl)
+	credentialRepo := mock_repository.NewMockWebAuthnCredential(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+
+	service := New(userRepo, credentialRepo, tokens, defaultConfig, logger)
+
+	now := time.Unix(1700000000, 0)
+	service.now = func() time.Time { return now }
+
+	return service, userRepo, credentialRepo, tokens
+}
+
+func newAuthenticator(t *testing.T, origin string) *webauthntest.Authenticator {
+	a, err := webauthntest.New(defaultConfig.RPID, origin)
+	require.NoError(t, err)
+	return a
+}
+
+func TestBeginRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, userRepo, credentialRepo, tokens := newTestService(ctrl)
+
+	defaultUser := model.User{ID: 1, Email: "user@mail.com"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, options webauthn.CreationOptions, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).
+					Return([]model.WebAuthnCredential{{ID: 2, CredentialID: "AQI"}}, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, 1, defaultConfig.ChallengeLifetime, "1").
+					Times(1).Return(defaultChallenge, nil)
+			},
+			checkResult: func(t *testing.T, options webauthn.CreationOptions, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, []byte(defaultChallenge), []byte(options.Challenge))
+				assert.Equal(t, "example.com", options.RP.ID)
+				assert.Equal(t, []byte("1"), []byte(options.User.ID))
+				assert.Equal(t, "user@mail.com", options.User.Name)
+				assert.Len(t, options.ExcludeCredentials, 1)
+				assert.Equal(t, []byte{1, 2}, []byte(options.ExcludeCredentials[0].ID))
+				assert.Equal(t, "required", options.AuthenticatorSelection.UserVerification)
+				assert.Equal(t, int64(5*60*1000), options.Timeout)
+			},
+		},
+		{
+			name: "failed get user",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(model.User{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, options webauthn.CreationOptions, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed list credentials",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).Return(nil, unexpectedError)
+			},
+			checkResult: func(t *testing.T, options webauthn.CreationOptions, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed issue challenge",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), 1).Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).Return(nil, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, 1, gomock.Any(), "1").
+					Times(1).Return("", unexpectedError)
+			},
+			checkResult: func(t *testing.T, options webauthn.CreationOptions, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			options, err := service.BeginRegistration(context.Background(), 1)
+			test.checkResult(t, options, err)
+		})
+	}
+}
+
+func TestFinishRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, credentialRepo, tokens := newTestService(ctrl)
+
+	options := service.webauthn.CreationOptions([]byte(defaultChallenge), webauthn.UserEntity{ID: []byte("1")}, nil)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		origin      string
+		buildStubs  func(a *webauthntest.Authenticator)
+		checkResult func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error)
+	}{
+		{
+			name:   "OK",
+			origin: "https://example.com",
+			buildStubs: func(a *webauthntest.Authenticator) {
+				credentialID := base64.RawURLEncoding.EncodeToString(a.CredentialID)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), credentialID).Times(1).
+					Return(model.WebAuthnCredential{}, sql.ErrNoRows)
+				credentialRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, c model.WebAuthnCredential) (model.WebAuthnCredential, error) {
+						assert.Equal(t, 1, c.UserID)
+						assert.Equal(t, credentialID, c.CredentialID)
+						assert.NotEmpty(t, c.PublicKey)
+						assert.Equal(t, service.now().Unix(), c.CreatedAt)
+						c.ID = 2
+						return c, nil
+					})
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 2, cred.ID)
+			},
+		},
+		{
+			name:   "invalid challenge",
+			origin: "https://example.com",
+			buildStubs: func(a *webauthntest.Authenticator) {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{}, onetime.ErrInvalidToken)
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, ErrInvalidChallenge)
+			},
+		},
+		{
+			name:   "failed consume challenge",
+			origin: "https://example.com",
+			buildStubs: func(a *webauthntest.Authenticator) {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name:   "other origin",
+			origin: "https://evil.com", // note
+			buildStubs: func(a *webauthntest.Authenticator) {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredential)
+			},
+		},
+		{
+			name:   "already registered",
+			origin: "https://example.com",
+			buildStubs: func(a *webauthntest.Authenticator) {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{ID: 2}, nil)
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, ErrCredentialExists)
+			},
+		},
+		{
+			name:   "failed save",
+			origin: "https://example.com",
+			buildStubs: func(a *webauthntest.Authenticator) {
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnRegistration, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{}, sql.ErrNoRows)
+				credentialRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred model.WebAuthnCredential, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			a := newAuthenticator(t, test.origin)
+			resp, err := a.Create(options)
+			require.NoError(t, err)
+
+			test.buildStubs(a)
+			cred, err := service.FinishRegistration(context.Background(), 1, resp)
+			test.checkResult(t, a, cred, err)
+		})
+	}
+}
+
+func TestBeginLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, userRepo, credentialRepo, tokens := newTestService(ctrl)
+
+	defaultUser := model.User{ID: 1, Email: "user@mail.com"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, options webauthn.RequestOptions, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByEmail(gomock.Any(), "user@mail.com").Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).
+					Return([]model.WebAuthnCredential{{ID: 2, CredentialID: "AQI"}}, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), onetime.PurposeWebAuthnLogin, 1, defaultConfig.ChallengeLifetime, "1").
+					Times(1).Return(defaultChallenge, nil)
+			},
+			checkResult: func(t *testing.T, options webauthn.RequestOptions, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, []byte(defaultChallenge), []byte(options.Challenge))
+				assert.Equal(t, "example.com", options.RPID)
+				assert.Len(t, options.AllowCredentials, 1)
+				assert.Equal(t, []byte{1, 2}, []byte(options.AllowCredentials[0].ID))
+			},
+		},
+		{
+			name: "unknown email",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByEmail(gomock.Any(), "user@mail.com").Times(1).Return(model.User{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, options webauthn.RequestOptions, err error) {
+				assert.NoError(t, err)
+				assert.Regexp(t, `^[A-Za-z0-9_-]{43}\.[A-Za-z0-9_-]{43}$`, string(options.Challenge))
+				assert.Len(t, options.AllowCredentials, 1)
+				assert.Equal(t, service.fakeCredentialID("user@mail.com"), []byte(options.AllowCredentials[0].ID))
+			},
+		},
+		{
+			name: "OK user without passkeys",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByEmail(gomock.Any(), "user@mail.com").Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).Return(nil, nil) // note
+				tokens.EXPECT().IssueBound(gomock.Any(), onetime.PurposeWebAuthnLogin, 1, defaultConfig.ChallengeLifetime, "1").
+					Times(1).Return(defaultChallenge, nil)
+			},
+			checkResult: func(t *testing.T, options webauthn.RequestOptions, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, options.AllowCredentials, 1)
+				assert.Equal(t, service.fakeCredentialID("user@mail.com"), []byte(options.AllowCredentials[0].ID))
+			},
+		},
+		{
+			name: "failed get user",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByEmail(gomock.Any(), "user@mail.com").Times(1).Return(model.User{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, options webauthn.RequestOptions, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name: "failed issue challenge",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByEmail(gomock.Any(), "user@mail.com").Times(1).Return(defaultUser, nil)
+				credentialRepo.EXPECT().ListByUserID(gomock.Any(), 1).Times(1).Return(nil, nil)
+				tokens.EXPECT().IssueBound(gomock.Any(), onetime.PurposeWebAuthnLogin, 1, gomock.Any(), "1").
+					Times(1).Return("", unexpectedError)
+			},
+			checkResult: func(t *testing.T, options webauthn.RequestOptions, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			options, err := service.BeginLogin(context.Background(), "user@mail.com")
+			test.checkResult(t, options, err)
+		})
+	}
+}
+
+func TestFakeCredentialID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, _, _ := newTestService(ctrl)
+
+	id := service.fakeCredentialID("user@mail.com")
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, service.fakeCredentialID("user@mail.com"))
+	assert.NotEqual(t, id, service.fakeCredentialID("other@mail.com"))
+}
+
+func TestFinishLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service, _, credentialRepo, tokens := newTestService(ctrl)
+
+	challenge := []byte(defaultChallenge)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		modify      func(resp *webauthn.AssertionResponse)
+		buildStubs  func(dbCredential model.WebAuthnCredential)
+		checkResult func(t *testing.T, uid int, err error)
+	}{
+		{
+			name:   "OK",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().UpdateSignCount(gomock.Any(), 2, int64(0), int64(1), service.now().Unix()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, uid)
+			},
+		},
+		{
+			name:   "unknown credential",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).
+					Return(model.WebAuthnCredential{}, sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredential)
+			},
+		},
+		{
+			name:   "failed get credential",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).
+					Return(model.WebAuthnCredential{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name:   "invalid challenge",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{}, onetime.ErrInvalidToken)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, ErrInvalidChallenge)
+			},
+		},
+		{
+			name: "invalid signature",
+			modify: func(resp *webauthn.AssertionResponse) {
+				resp.Response.Signature[len(resp.Response.Signature)-1]++
+			},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredential)
+			},
+		},
+		{
+			name:   "cloned authenticator",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				dbCredential.SignCount = 10 // note
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredential)
+			},
+		},
+		{
+			name:   "sign count changed concurrently",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().UpdateSignCount(gomock.Any(), 2, int64(0), int64(1), gomock.Any()).Times(1).Return(sql.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCredential)
+			},
+		},
+		{
+			name:   "failed update sign count",
+			modify: func(resp *webauthn.AssertionResponse) {},
+			buildStubs: func(dbCredential model.WebAuthnCredential) {
+				credentialRepo.EXPECT().GetByCredentialID(gomock.Any(), dbCredential.CredentialID).Times(1).Return(dbCredential, nil)
+				tokens.EXPECT().ConsumeBound(gomock.Any(), onetime.PurposeWebAuthnLogin, defaultChallenge, "1").
+					Times(1).Return(model.OneTimeToken{UserID: 1}, nil)
+				credentialRepo.EXPECT().UpdateSignCount(gomock.Any(), 2, int64(0), int64(1), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, uid int, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			a := newAuthenticator(t, "https://example.com")
+			attestation, err := a.Create(service.webauthn.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("1")}, nil))
+			require.NoError(t, err)
+			cred, err := service.webauthn.VerifyRegistration(attestation, challenge)
+			require.NoError(t, err)
+
+			resp, err := a.Get(service.webauthn.RequestOptions(challenge, [][]byte{cred.ID}))
+			require.NoError(t, err)
+			test.modify(&resp)
+
+			test.buildStubs(model.WebAuthnCredential{
+				ID:           2,
+				UserID:       1,
+				CredentialID: base64.RawURLEncoding.EncodeToString(cred.ID),
+				PublicKey:    cred.PublicKey,
+			})
+			uid, err := service.FinishLogin(context.Background(), resp)
+			test.checkResult(t, uid, err)
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"medods/pkg/logger"
+	"time"
+)
+
+// how often expired one-time tokens and authorization codes are removed from database
+const PruneInterval = 5 * time.Minute
+
+// RunPruner remove expired one-time tokens and authorization codes every PruneInterval until ctx is done,
+// failure of one doesn't stop the other
+func (m *Manager) RunPruner(ctx context.Context, l logger.Interface) {
+	ticker := time.NewTicker(PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.OneTime.Prune(ctx); err != nil {
+				l.Error("failed to prune one-time tokens: %s", err.Error())
+			}
+			if err := m.OAuth.Prune(ctx); err != nil {
+				l.Error("failed to prune authorization codes: %s", err.Error())
+			}
+		}
+	}
+}
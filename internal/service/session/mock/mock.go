@@ -36,11 +36,12 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockInterface) Create(ctx context.Context, session model.Session) error {
+func (m *MockInterface) Create(ctx context.Context, session model.Session) (model.Session, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", ctx, session)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
@@ -49,19 +50,64 @@ func (mr *MockInterfaceMockRecorder) Create(ctx, session interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockInterface)(nil).Create), ctx, session)
 }
 
-// GetByUserID mocks base method.
-func (m *MockInterface) GetByUserID(ctx context.Context, id int) (model.Session, error) {
+// GetByID mocks base method.
+func (m *MockInterface) GetByID(ctx context.Context, id int) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByID", ctx, id)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByID indicates an expected call of GetByID.
+func (mr *MockInterfaceMockRecorder) GetByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockInterface)(nil).GetByID), ctx, id)
+}
+
+// GetBySelector mocks base method.
+func (m *MockInterface) GetBySelector(ctx context.Context, selector string) (model.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByUserID", ctx, id)
+	ret := m.ctrl.Call(m, "GetBySelector", ctx, selector)
 	ret0, _ := ret[0].(model.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetByUserID indicates an expected call of GetByUserID.
-func (mr *MockInterfaceMockRecorder) GetByUserID(ctx, id interface{}) *gomock.Call {
+// GetBySelector indicates an expected call of GetBySelector.
+func (mr *MockInterfaceMockRecorder) GetBySelector(ctx, selector interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBySelector", reflect.TypeOf((*MockInterface)(nil).GetBySelector), ctx, selector)
+}
+
+// GetRotation mocks base method.
+func (m *MockInterface) GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRotation", ctx, sessionID, aTokenID)
+	ret0, _ := ret[0].(model.SessionRotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRotation indicates an expected call of GetRotation.
+func (mr *MockInterfaceMockRecorder) GetRotation(ctx, sessionID, aTokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRotation", reflect.TypeOf((*MockInterface)(nil).GetRotation), ctx, sessionID, aTokenID)
+}
+
+// GetRotationBySelector mocks base method.
+func (m *MockInterface) GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRotationBySelector", ctx, selector)
+	ret0, _ := ret[0].(model.SessionRotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRotationBySelector indicates an expected call of GetRotationBySelector.
+func (mr *MockInterfaceMockRecorder) GetRotationBySelector(ctx, selector interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUserID", reflect.TypeOf((*MockInterface)(nil).GetByUserID), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRotationBySelector", reflect.TypeOf((*MockInterface)(nil).GetRotationBySelector), ctx, selector)
 }
 
 // List mocks base method.
@@ -79,6 +125,64 @@ func (mr *MockInterfaceMockRecorder) List(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockInterface)(nil).List), ctx)
 }
 
+// ListByUserID mocks base method.
+func (m *MockInterface) ListByUserID(ctx context.Context, userID int) ([]model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUserID", ctx, userID)
+	ret0, _ := ret[0].([]model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUserID indicates an expected call of ListByUserID.
+func (mr *MockInterfaceMockRecorder) ListByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUserID", reflect.TypeOf((*MockInterface)(nil).ListByUserID), ctx, userID)
+}
+
+// Revoke mocks base method.
+func (m *MockInterface) Revoke(ctx context.Context, id int, revokedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockInterfaceMockRecorder) Revoke(ctx, id, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockInterface)(nil).Revoke), ctx, id, revokedAt)
+}
+
+// RevokeAllByUserID mocks base method.
+func (m *MockInterface) RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllByUserID", ctx, userID, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAllByUserID indicates an expected call of RevokeAllByUserID.
+func (mr *MockInterfaceMockRecorder) RevokeAllByUserID(ctx, userID, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllByUserID", reflect.TypeOf((*MockInterface)(nil).RevokeAllByUserID), ctx, userID, revokedAt)
+}
+
+// Rotate mocks base method.
+func (m *MockInterface) Rotate(ctx context.Context, session model.Session) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", ctx, session)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockInterfaceMockRecorder) Rotate(ctx, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockInterface)(nil).Rotate), ctx, session)
+}
+
 // Update mocks base method.
 func (m *MockInterface) Update(ctx context.Context, session model.Session) (model.Session, error) {
 	m.ctrl.T.Helper()
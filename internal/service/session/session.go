@@ -8,9 +8,16 @@ import (
 )
 
 type Interface interface {
-	Create(ctx context.Context, session model.Session) error
+	Create(ctx context.Context, session model.Session) (model.Session, error)
 	Update(ctx context.Context, session model.Session) (model.Session, error)
-	GetByUserID(ctx context.Context, id int) (model.Session, error)
+	Rotate(ctx context.Context, session model.Session) (model.Session, error)
+	GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error)
+	GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error)
+	Revoke(ctx context.Context, id int, revokedAt int64) error
+	RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error
+	GetByID(ctx context.Context, id int) (model.Session, error)
+	GetBySelector(ctx context.Context, selector string) (model.Session, error)
+	ListByUserID(ctx context.Context, userID int) ([]model.Session, error)
 	List(ctx context.Context) ([]model.Session, error)
 }
 
@@ -28,14 +35,35 @@ func New(repo repository.Session, logger logger.Interface) *session {
 	}
 }
 
-func (s session) Create(ctx context.Context, session model.Session) error {
+func (s session) Create(ctx context.Context, session model.Session) (model.Session, error) {
 	return s.repo.Create(ctx, session)
 }
 func (s session) Update(ctx context.Context, session model.Session) (model.Session, error) {
 	return s.repo.Update(ctx, session)
 }
-func (s session) GetByUserID(ctx context.Context, id int) (model.Session, error) {
-	return s.repo.GetByUserID(ctx, id)
+func (s session) Rotate(ctx context.Context, session model.Session) (model.Session, error) {
+	return s.repo.Rotate(ctx, session)
+}
+func (s session) GetRotation(ctx context.Context, sessionID int, aTokenID string) (model.SessionRotation, error) {
+	return s.repo.GetRotation(ctx, sessionID, aTokenID)
+}
+func (s session) GetRotationBySelector(ctx context.Context, selector string) (model.SessionRotation, error) {
+	return s.repo.GetRotationBySelector(ctx, selector)
+}
+func (s session) Revoke(ctx context.Context, id int, revokedAt int64) error {
+	return s.repo.Revoke(ctx, id, revokedAt)
+}
+func (s session) RevokeAllByUserID(ctx context.Context, userID int, revokedAt int64) error {
+	return s.repo.RevokeAllByUserID(ctx, userID, revokedAt)
+}
+func (s session) GetByID(ctx context.Context, id int) (model.Session, error) {
+	return s.repo.GetByID(ctx, id)
+}
+func (s session) GetBySelector(ctx context.Context, selector string) (model.Session, error) {
+	return s.repo.GetBySelector(ctx, selector)
+}
+func (s session) ListByUserID(ctx context.Context, userID int) ([]model.Session, error) {
+	return s.repo.ListByUserID(ctx, userID)
 }
 func (s session) List(ctx context.Context) ([]model.Session, error) {
 	return s.repo.List(ctx)
@@ -34,17 +34,18 @@ func TestSessionCreate(t *testing.T) {
 		name        string
 		input       model.Session
 		buildStubs  func()
-		checkResult func(t *testing.T, err error)
+		checkResult func(t *testing.T, db model.Session, err error)
 	}{
 		{
 			name:  "OK",
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				sessRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(nil)
+				sessRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(defaultSession, nil)
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, db model.Session, err error) {
 				assert.NoError(t, err)
+				assert.Equal(t, defaultSession, db)
 			},
 		},
 		{
@@ -52,11 +53,12 @@ func TestSessionCreate(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				sessRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(unexpectedError)
+				sessRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(model.Session{}, unexpectedError)
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, db model.Session, err error) {
 				assert.Error(t, err)
 				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.Session{}, db)
 			},
 		},
 	}
@@ -64,8 +66,8 @@ func TestSessionCreate(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			err := service.Create(context.Background(), test.input)
-			test.checkResult(t, err)
+			session, err := service.Create(context.Background(), test.input)
+			test.checkResult(t, session, err)
 		})
 	}
 }
@@ -130,7 +132,275 @@ func TestSessionUpdate(t *testing.T) {
 	}
 }
 
-func TestUserGetByUserID(t *testing.T) {
+func TestSessionRotate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessRepo, logger)
+
+	defaultSession := model.Session{
+		ID:         1,
+		UserID:     2,
+		ATokenID:   "3",
+		RTokenHash: "4",
+		CreatedAt:  5,
+		Version:    6,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, session model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				sessRepo.EXPECT().Rotate(gomock.Any(), gomock.Eq(df)).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, db model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultSession, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				sessRepo.EXPECT().Rotate(gomock.Any(), gomock.Eq(df)).Times(1).Return(model.Session{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.Session{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			session, err := service.Rotate(context.Background(), test.input)
+			test.checkResult(t, session, err)
+		})
+	}
+}
+
+func TestSessionGetRotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessRepo, logger)
+
+	defaultRotation := model.SessionRotation{
+		ID:         1,
+		SessionID:  2,
+		ATokenID:   "3",
+		RTokenHash: "4",
+		RotatedAt:  5,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.SessionRotation
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.SessionRotation, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				sessRepo.EXPECT().GetRotation(gomock.Any(), gomock.Eq(df.SessionID), gomock.Eq(df.ATokenID)).Times(1).Return(defaultRotation, nil)
+			},
+			checkResult: func(t *testing.T, db model.SessionRotation, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultRotation, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				sessRepo.EXPECT().GetRotation(gomock.Any(), gomock.Eq(df.SessionID), gomock.Eq(df.ATokenID)).Times(1).Return(model.SessionRotation{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.SessionRotation, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.SessionRotation{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			rotation, err := service.GetRotation(context.Background(), test.input.SessionID, test.input.ATokenID)
+			test.checkResult(t, rotation, err)
+		})
+	}
+}
+
+func TestSessionGetRotationBySelector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessRepo, logger)
+
+	defaultRotation := model.SessionRotation{
+		ID:         1,
+		SessionID:  2,
+		ATokenID:   "3",
+		RTokenHash: "4",
+		RotatedAt:  5,
+
+		RTokenSelector: "6",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.SessionRotation
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.SessionRotation, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				sessRepo.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq(df.RTokenSelector)).Times(1).Return(defaultRotation, nil)
+			},
+			checkResult: func(t *testing.T, db model.SessionRotation, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultRotation, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRotation,
+			buildStubs: func() {
+				df := defaultRotation
+				sessRepo.EXPECT().GetRotationBySelector(gomock.Any(), gomock.Eq(df.RTokenSelector)).Times(1).Return(model.SessionRotation{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.SessionRotation, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.SessionRotation{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			rotation, err := service.GetRotationBySelector(context.Background(), test.input.RTokenSelector)
+			test.checkResult(t, rotation, err)
+		})
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessRepo, logger)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				sessRepo.EXPECT().Revoke(gomock.Any(), gomock.Eq(1), gomock.Eq(int64(2))).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				sessRepo.EXPECT().Revoke(gomock.Any(), gomock.Eq(1), gomock.Eq(int64(2))).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := service.Revoke(context.Background(), 1, 2)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestSessionRevokeAllByUserID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessRepo, logger)
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				sessRepo.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(1), gomock.Eq(int64(2))).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				sessRepo.EXPECT().RevokeAllByUserID(gomock.Any(), gomock.Eq(1), gomock.Eq(int64(2))).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := service.RevokeAllByUserID(context.Background(), 1, 2)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestSessionGetByID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	logger := mock_logger.NewMockInterface(ctrl)
 	sessionRepo := mock_repository.NewMockSession(ctrl)
@@ -159,7 +429,7 @@ func TestUserGetByUserID(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				sessionRepo.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(df.UserID)).Times(1).Return(defaultSession, nil)
+				sessionRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(df.ID)).Times(1).Return(defaultSession, nil)
 			},
 			checkResult: func(t *testing.T, db model.Session, err error) {
 				assert.NoError(t, err)
@@ -171,7 +441,7 @@ func TestUserGetByUserID(t *testing.T) {
 			input: defaultSession,
 			buildStubs: func() {
 				df := defaultSession
-				sessionRepo.EXPECT().GetByUserID(gomock.Any(), gomock.Eq(df.UserID)).Times(1).Return(model.Session{}, unexpectedError)
+				sessionRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(df.ID)).Times(1).Return(model.Session{}, unexpectedError)
 			},
 			checkResult: func(t *testing.T, db model.Session, err error) {
 				assert.Error(t, err)
@@ -184,8 +454,134 @@ func TestUserGetByUserID(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			user, err := service.GetByUserID(context.Background(), test.input.UserID)
-			test.checkResult(t, user, err)
+			session, err := service.GetByID(context.Background(), test.input.ID)
+			test.checkResult(t, session, err)
+		})
+	}
+}
+
+func TestSessionGetBySelector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessionRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessionRepo, logger)
+
+	defaultSession := model.Session{
+		ID:         1,
+		UserID:     2,
+		ATokenID:   "3",
+		RTokenHash: "4",
+		CreatedAt:  5,
+		Version:    6,
+
+		RTokenSelector: "7",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, db model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				sessionRepo.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(df.RTokenSelector)).Times(1).Return(defaultSession, nil)
+			},
+			checkResult: func(t *testing.T, db model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultSession, db)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				sessionRepo.EXPECT().GetBySelector(gomock.Any(), gomock.Eq(df.RTokenSelector)).Times(1).Return(model.Session{}, unexpectedError)
+			},
+			checkResult: func(t *testing.T, db model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Equal(t, model.Session{}, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			session, err := service.GetBySelector(context.Background(), test.input.RTokenSelector)
+			test.checkResult(t, session, err)
+		})
+	}
+}
+
+func TestSessionListByUserID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	sessionRepo := mock_repository.NewMockSession(ctrl)
+
+	service := New(sessionRepo, logger)
+
+	defaultSession := model.Session{
+		ID:         1,
+		UserID:     2,
+		ATokenID:   "3",
+		RTokenHash: "4",
+		CreatedAt:  5,
+		Version:    6,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       model.Session
+		buildStubs  func()
+		checkResult func(t *testing.T, db []model.Session, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultSession,
+			buildStubs: func() {
+				df1 := defaultSession
+				df2 := defaultSession
+				df2.ID = 2
+				sessionRepo.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(df1.UserID)).Times(1).Return([]model.Session{df1, df2}, nil)
+			},
+			checkResult: func(t *testing.T, db []model.Session, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, db, 2)
+				assert.Equal(t, 1, db[0].ID)
+				assert.Equal(t, 2, db[1].ID)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultSession,
+			buildStubs: func() {
+				df := defaultSession
+				sessionRepo.EXPECT().ListByUserID(gomock.Any(), gomock.Eq(df.UserID)).Times(1).Return(nil, unexpectedError)
+			},
+			checkResult: func(t *testing.T, db []model.Session, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+				assert.Empty(t, db)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			sessions, err := service.ListByUserID(context.Background(), test.input.UserID)
+			test.checkResult(t, sessions, err)
 		})
 	}
 }
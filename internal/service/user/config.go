@@ -0,0 +1,9 @@
+package user
+
+import "time"
+
+type Config struct {
+	// link sent to new user, token is added as query parameter
+	VerificationURL           string
+	VerificationTokenLifetime time.Duration
+}
@@ -35,18 +35,48 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
-// Create mocks base method.
-func (m *MockInterface) Create(ctx context.Context, u model.User) error {
+// ChangePassword mocks base method.
+func (m *MockInterface) ChangePassword(ctx context.Context, id int, oldPassword, newPassword string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, u)
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, id, oldPassword, newPassword)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockInterfaceMockRecorder) ChangePassword(ctx, id, oldPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockInterface)(nil).ChangePassword), ctx, id, oldPassword, newPassword)
+}
+
+// Create mocks base method.
+func (m *MockInterface) Create(ctx context.Context, u model.User, password string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, u, password)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Create indicates an expected call of Create.
-func (mr *MockInterfaceMockRecorder) Create(ctx, u interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) Create(ctx, u, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockInterface)(nil).Create), ctx, u, password)
+}
+
+// GetByEmail mocks base method.
+func (m *MockInterface) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByEmail", ctx, email)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByEmail indicates an expected call of GetByEmail.
+func (mr *MockInterfaceMockRecorder) GetByEmail(ctx, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockInterface)(nil).Create), ctx, u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockInterface)(nil).GetByEmail), ctx, email)
 }
 
 // GetByID mocks base method.
@@ -78,3 +108,83 @@ func (mr *MockInterfaceMockRecorder) List(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockInterface)(nil).List), ctx)
 }
+
+// SetPassword mocks base method.
+func (m *MockInterface) SetPassword(ctx context.Context, id int, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", ctx, id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockInterfaceMockRecorder) SetPassword(ctx, id, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockInterface)(nil).SetPassword), ctx, id, password)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockInterface) VerifyEmail(ctx context.Context, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockInterfaceMockRecorder) VerifyEmail(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockInterface)(nil).VerifyEmail), ctx, token)
+}
+
+// MockHasher is a mock of Hasher interface.
+type MockHasher struct {
+	ctrl     *gomock.Controller
+	recorder *MockHasherMockRecorder
+}
+
+// MockHasherMockRecorder is the mock recorder for MockHasher.
+type MockHasherMockRecorder struct {
+	mock *MockHasher
+}
+
+// NewMockHasher creates a new mock instance.
+func NewMockHasher(ctrl *gomock.Controller) *MockHasher {
+	mock := &MockHasher{ctrl: ctrl}
+	mock.recorder = &MockHasherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHasher) EXPECT() *MockHasherMockRecorder {
+	return m.recorder
+}
+
+// Compare mocks base method.
+func (m *MockHasher) Compare(hash, password string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Compare", hash, password)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Compare indicates an expected call of Compare.
+func (mr *MockHasherMockRecorder) Compare(hash, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Compare", reflect.TypeOf((*MockHasher)(nil).Compare), hash, password)
+}
+
+// Hash mocks base method.
+func (m *MockHasher) Hash(password string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hash", password)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hash indicates an expected call of Hash.
+func (mr *MockHasherMockRecorder) Hash(password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hash", reflect.TypeOf((*MockHasher)(nil).Hash), password)
+}
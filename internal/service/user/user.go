@@ -2,39 +2,182 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"medods/internal/model"
 	"medods/internal/repository"
+	"medods/internal/service/onetime"
 	"medods/pkg/logger"
+	"medods/pkg/smtp"
+	"net/url"
+	"time"
+)
+
+const (
+	MinPasswordLength = 8
+	// limit of bcrypt input, kept for users which still have bcrypt hashes
+	MaxPasswordLength = 72
+)
+
+var (
+	ErrInvalidPassword = fmt.Errorf("invalid password")
+	ErrWeakPassword    = fmt.Errorf("password length must be from %d to %d bytes", MinPasswordLength, MaxPasswordLength)
+
+	ErrInvalidVerificationToken = fmt.Errorf("verification link is invalid, expired or already used")
 )
 
 type Interface interface {
-	Create(ctx context.Context, u model.User) error
+	Create(ctx context.Context, u model.User, password string) (model.User, error)
 	GetByID(ctx context.Context, id int) (model.User, error)
+	GetByEmail(ctx context.Context, email string) (model.User, error)
 	List(ctx context.Context) ([]model.User, error)
+
+	SetPassword(ctx context.Context, id int, password string) error
+	ChangePassword(ctx context.Context, id int, oldPassword, newPassword string) error
+
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+// Hasher hash and verify passwords, e.g. auth.PasswordHasher
+type Hasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) bool
 }
 
 var _ Interface = (*user)(nil)
 
 type user struct {
 	repo   repository.User
+	hasher Hasher
+	tokens onetime.Interface
+	smtp   smtp.Interface
+
+	cfg    *Config
 	logger logger.Interface
 }
 
-func New(repo repository.User, logger logger.Interface) *user {
+func New(
+	repo repository.User,
+	hasher Hasher,
+	tokens onetime.Interface,
+	smtp smtp.Interface,
+	cfg *Config,
+	logger logger.Interface,
+) *user {
 	return &user{
 		repo:   repo,
+		hasher: hasher,
+		tokens: tokens,
+		smtp:   smtp,
+
+		cfg:    cfg,
 		logger: logger,
 	}
 }
 
-func (s user) Create(ctx context.Context, u model.User) error {
-	return s.repo.Create(ctx, u)
+// Create save user with unverified email and send verification link to it.
+// Password is hashed before insert, so user never exists without it, empty password leaves user without one.
+// User is created even if email was not sent, failure is only logged.
+func (s user) Create(ctx context.Context, u model.User, password string) (model.User, error) {
+	if password != "" {
+		hash, err := s.hashPassword(password)
+		if err != nil {
+			return model.User{}, err
+		}
+		u.PasswordHash = hash
+	}
+
+	dbUser, err := s.repo.Create(ctx, u)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if err := s.sendVerification(ctx, dbUser); err != nil {
+		s.logger.Error("failed to send verification email to user[%d]: %s", dbUser.ID, err.Error())
+	}
+
+	return dbUser, nil
+}
+
+// VerifyEmail mark email of user verified by token from verification link, token is single-use
+func (s user) VerifyEmail(ctx context.Context, token string) error {
+	t, err := s.tokens.Consume(ctx, onetime.PurposeEmailVerification, token)
+	if errors.Is(err, onetime.ErrInvalidToken) {
+		return ErrInvalidVerificationToken
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, t.UserID, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	s.logger.Info("email of user[%d] verified", t.UserID)
+
+	return nil
+}
+
+func (s user) sendVerification(ctx context.Context, u model.User) error {
+	token, err := s.tokens.Issue(ctx, onetime.PurposeEmailVerification, u.ID, s.cfg.VerificationTokenLifetime)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(s.cfg.VerificationURL)
+	if err != nil {
+		return fmt.Errorf("invalid verification url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return s.smtp.SendEmailVerification(link.String(), u.Email)
 }
 
 func (s user) GetByID(ctx context.Context, id int) (model.User, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
+func (s user) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	return s.repo.GetByEmail(ctx, email)
+}
+
 func (s user) List(ctx context.Context) ([]model.User, error) {
 	return s.repo.List(ctx)
 }
+
+// SetPassword replace password of user without checking the old one
+func (s user) SetPassword(ctx context.Context, id int, password string) error {
+	hash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UpdatePasswordHash(ctx, id, hash)
+}
+
+func (s user) hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrWeakPassword
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return hash, nil
+}
+
+// ChangePassword replace password of user if old one is correct
+func (s user) ChangePassword(ctx context.Context, id int, oldPassword, newPassword string) error {
+	dbUser, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(dbUser.PasswordHash) == 0 || !s.hasher.Compare(dbUser.PasswordHash, oldPassword) {
+		s.logger.Warn("user[%d] presented invalid password on change", id)
+		return ErrInvalidPassword
+	}
+
+	return s.SetPassword(ctx, id, newPassword)
+}
@@ -5,42 +5,137 @@ import (
 	"fmt"
 	"medods/internal/model"
 	mock_repository "medods/internal/repository/mock"
+	"medods/internal/service/onetime"
+	mock_onetime "medods/internal/service/onetime/mock"
+	mock_user "medods/internal/service/user/mock"
 	mock_logger "medods/pkg/logger/mock"
+	mock_smtp "medods/pkg/smtp/mock"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
+var defaultConfig = &Config{
+	VerificationURL:           "http://localhost:8080/api/v1/user/verify",
+	VerificationTokenLifetime: 24 * time.Hour,
+}
+
 func TestUserCreate(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	logger := mock_logger.NewMockInterface(ctrl)
 	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
 
-	service := New(userRepo, logger)
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
 
 	defaultUser := model.User{
 		ID:    1,
 		Email: "2",
 	}
 
+	defaultToken := "token.signature"
+	defaultLink := defaultConfig.VerificationURL + "?token=" + defaultToken
+
 	unexpectedError := fmt.Errorf("unexpected error")
 
 	tc := []struct {
 		name        string
 		input       model.User
+		password    string
 		buildStubs  func()
-		checkResult func(t *testing.T, err error)
+		checkResult func(t *testing.T, db model.User, err error)
 	}{
 		{
 			name:  "OK",
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(nil)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(df, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(df.ID), gomock.Eq(defaultConfig.VerificationTokenLifetime)).Times(1).
+					Return(defaultToken, nil)
+				smtp.EXPECT().SendEmailVerification(gomock.Eq(defaultLink), gomock.Eq(df.Email)).Times(1).Return(nil)
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, db model.User, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultUser, db)
+			},
+		},
+		{
+			name:  "OK email is not sent",
+			input: defaultUser,
+			buildStubs: func() {
+				df := defaultUser
+				logger.EXPECT().Error(gomock.Any(), gomock.Any()).Times(1)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(df, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(df.ID), gomock.Any()).Times(1).
+					Return(defaultToken, nil)
+				smtp.EXPECT().SendEmailVerification(gomock.Eq(defaultLink), gomock.Eq(df.Email)).Times(1).Return(unexpectedError) // note: smtp is down
+			},
+			checkResult: func(t *testing.T, db model.User, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, defaultUser, db)
+			},
+		},
+		{
+			name:  "OK token is not issued",
+			input: defaultUser,
+			buildStubs: func() {
+				df := defaultUser
+				logger.EXPECT().Error(gomock.Any(), gomock.Any()).Times(1)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(df, nil)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+				smtp.EXPECT().SendEmailVerification(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, db model.User, err error) {
 				assert.NoError(t, err)
+				assert.Equal(t, defaultUser, db)
+			},
+		},
+		{
+			name:     "OK with password",
+			input:    defaultUser,
+			password: "password", // note
+			buildStubs: func() {
+				df := defaultUser
+				df.PasswordHash = "hash" // note: inserted together with user
+				hasher.EXPECT().Hash(gomock.Eq("password")).Times(1).Return("hash", nil)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(df, nil)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultToken, nil)
+				smtp.EXPECT().SendEmailVerification(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, db model.User, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "hash", db.PasswordHash)
+			},
+		},
+		{
+			name:     "error weak password",
+			input:    defaultUser,
+			password: "short", // note
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Any()).Times(0)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, db model.User, err error) {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			},
+		},
+		{
+			name:     "error hash password",
+			input:    defaultUser,
+			password: "password",
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Any()).Times(1).Return("", unexpectedError)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, db model.User, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
 			},
 		},
 		{
@@ -48,9 +143,10 @@ func TestUserCreate(t *testing.T) {
 			input: defaultUser,
 			buildStubs: func() {
 				df := defaultUser
-				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(unexpectedError)
+				userRepo.EXPECT().Create(gomock.Any(), gomock.Eq(df)).Times(1).Return(model.User{}, unexpectedError)
+				tokens.EXPECT().Issue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
-			checkResult: func(t *testing.T, err error) {
+			checkResult: func(t *testing.T, db model.User, err error) {
 				assert.Error(t, err)
 				assert.Equal(t, err, unexpectedError)
 			},
@@ -60,8 +156,8 @@ func TestUserCreate(t *testing.T) {
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
-			err := service.Create(context.Background(), test.input)
-			test.checkResult(t, err)
+			user, err := service.Create(context.Background(), test.input, test.password)
+			test.checkResult(t, user, err)
 		})
 	}
 }
@@ -70,8 +166,11 @@ func TestUserGetByID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	logger := mock_logger.NewMockInterface(ctrl)
 	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
 
-	service := New(userRepo, logger)
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
 
 	defaultUser := model.User{
 		ID:    1,
@@ -126,8 +225,11 @@ func TestUserList(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	logger := mock_logger.NewMockInterface(ctrl)
 	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
 
-	service := New(userRepo, logger)
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
 
 	defaultUser := model.User{
 		ID:    1,
@@ -179,3 +281,260 @@ func TestUserList(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
+
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
+
+	defaultPassword := "password"
+	defaultHash := "hash"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		input       string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:  "OK",
+			input: defaultPassword,
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Eq(defaultPassword)).Times(1).Return(defaultHash, nil)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Eq(1), gomock.Eq(defaultHash)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "error too short",
+			input: "short", // note: less than min
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Any()).Times(0)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			},
+		},
+		{
+			name:  "error too long",
+			input: strings.Repeat("a", MaxPasswordLength+1), // note: more than max
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Any()).Times(0)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrWeakPassword)
+			},
+		},
+		{
+			name:  "error unexpected hash",
+			input: defaultPassword,
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Eq(defaultPassword)).Times(1).Return("", unexpectedError)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultPassword,
+			buildStubs: func() {
+				hasher.EXPECT().Hash(gomock.Eq(defaultPassword)).Times(1).Return(defaultHash, nil)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Eq(1), gomock.Eq(defaultHash)).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := service.SetPassword(context.Background(), 1, test.input)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
+
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
+
+	oldPassword := "old_password"
+	newPassword := "new_password"
+	newHash := "new_hash"
+
+	defaultUser := model.User{
+		ID:           1,
+		Email:        "2",
+		PasswordHash: "old_hash",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				hasher.EXPECT().Compare(gomock.Eq(defaultUser.PasswordHash), gomock.Eq(oldPassword)).Times(1).Return(true)
+				hasher.EXPECT().Hash(gomock.Eq(newPassword)).Times(1).Return(newHash, nil)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Eq(defaultUser.ID), gomock.Eq(newHash)).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error invalid old password",
+			buildStubs: func() {
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				userRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(defaultUser, nil)
+				hasher.EXPECT().Compare(gomock.Eq(defaultUser.PasswordHash), gomock.Eq(oldPassword)).Times(1).Return(false)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidPassword)
+			},
+		},
+		{
+			name: "error password not set",
+			buildStubs: func() {
+				df := defaultUser
+				df.PasswordHash = "" // note: not set
+
+				logger.EXPECT().Warn(gomock.Any(), gomock.Any()).Times(1)
+				userRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(df, nil)
+				hasher.EXPECT().Compare(gomock.Any(), gomock.Any()).Times(0)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidPassword)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				userRepo.EXPECT().GetByID(gomock.Any(), gomock.Eq(defaultUser.ID)).Times(1).Return(model.User{}, unexpectedError)
+				userRepo.EXPECT().UpdatePasswordHash(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := service.ChangePassword(context.Background(), defaultUser.ID, oldPassword, newPassword)
+			test.checkResult(t, err)
+		})
+	}
+}
+
+func TestUserVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	logger := mock_logger.NewMockInterface(ctrl)
+	userRepo := mock_repository.NewMockUser(ctrl)
+	hasher := mock_user.NewMockHasher(ctrl)
+	tokens := mock_onetime.NewMockInterface(ctrl)
+	smtp := mock_smtp.NewMockInterface(ctrl)
+
+	service := New(userRepo, hasher, tokens, smtp, defaultConfig, logger)
+
+	defaultToken := "token.signature"
+	defaultOneTimeToken := model.OneTimeToken{
+		Purpose: onetime.PurposeEmailVerification,
+		UserID:  1,
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name        string
+		buildStubs  func()
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				logger.EXPECT().Info(gomock.Any(), gomock.Any()).Times(1)
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(defaultToken)).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userRepo.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Eq(defaultOneTimeToken.UserID), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "error invalid token",
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(defaultToken)).Times(1).
+					Return(model.OneTimeToken{}, onetime.ErrInvalidToken) // note: used, expired or forged
+				userRepo.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+			},
+		},
+		{
+			name: "error unexpected consume",
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(defaultToken)).Times(1).
+					Return(model.OneTimeToken{}, unexpectedError)
+				userRepo.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+				assert.NotErrorIs(t, err, ErrInvalidVerificationToken)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				tokens.EXPECT().Consume(gomock.Any(), gomock.Eq(onetime.PurposeEmailVerification), gomock.Eq(defaultToken)).Times(1).
+					Return(defaultOneTimeToken, nil)
+				userRepo.EXPECT().MarkEmailVerified(gomock.Any(), gomock.Eq(defaultOneTimeToken.UserID), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, unexpectedError)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+			err := service.VerifyEmail(context.Background(), defaultToken)
+			test.checkResult(t, err)
+		})
+	}
+}
@@ -5,29 +5,35 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"medods/internal/model"
 	"medods/internal/service"
 	"medods/internal/service/auth"
+	"medods/internal/service/client"
+	"medods/internal/service/mfa"
 	"medods/internal/service/user"
+	"medods/pkg/authmiddleware"
 	"medods/pkg/logger"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type authRoutes struct {
-	authService auth.Interface
-	userService user.Interface
-	logger      logger.Interface
+	authService   auth.Interface
+	userService   user.Interface
+	clientService client.Interface
+	secureCookies bool
+	logger        logger.Interface
 }
 
-func newAuthRoutes(l logger.Interface, s *service.Manager) *authRoutes {
+func newAuthRoutes(l logger.Interface, s *service.Manager, cfg *Config) *authRoutes {
 	return &authRoutes{
-		authService: s.Auth,
-		userService: s.User,
+		authService:   s.Auth,
+		userService:   s.User,
+		clientService: s.Client,
+		secureCookies: cfg.SecureCookies,
 
 		logger: l,
 	}
@@ -38,19 +44,85 @@ type loginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-// CreateSession godoc
+// returned instead of tokens when user has second factor enabled
+type mfaRequiredResponse struct {
+	Status string `json:"status" example:"mfa_required"`
+	// single-use, must be posted to /auth/login/mfa together with code of second factor
+	MFAToken string `json:"mfa_token"`
+}
+
+const mfaRequiredStatus = "mfa_required"
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required,email" example:"user@gmail.com"`
+	Password string `json:"password" binding:"required" example:"password"`
+}
+
+// Login godoc
 //
-//	@Summary		Create session
-//	@Description	Create session and return new pair access and refresh tokens.
+//	@Summary		Login
+//	@Description	Check email and password, create session and return new pair access and refresh tokens.
+//	@Description	If user has second factor enabled, returns 202 with challenge token for /auth/login/mfa instead.
 //	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			login_request	body		loginRequest	true	"email and password of user"
+//	@Success		200				{object}	loginResponse
+//	@Success		202				{object}	mfaRequiredResponse	"Second factor is required"
+//	@Failure		400				{object}	errMsg				"Invalid request parameters"
+//	@Failure		401				{object}	errMsg				"Invalid email or password"
+//	@Failure		403				{object}	errMsg				"Email is not verified"
+//	@Failure		500				{object}	errMsg				"Internal server error"
+//	@Router			/auth/login [post]
+func (h authRoutes) login(c *gin.Context) {
+	var req loginRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	aToken, rToken, err := h.authService.Login(ctx, req.Email, req.Password, c.ClientIP())
+	var challenge *auth.MFAChallengeError
+	if errors.As(err, &challenge) {
+		c.JSON(http.StatusAccepted, mfaRequiredResponse{
+			Status:   mfaRequiredStatus,
+			MFAToken: challenge.Token,
+		})
+		return
+	} else if errors.Is(err, auth.ErrInvalidCredentials) {
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if errors.Is(err, auth.ErrEmailNotVerified) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		AccessToken:  aToken,
+		RefreshToken: rToken,
+	})
+}
+
+// CreateSession godoc
+//
+//	@Summary		Create session by user id
+//	@Description	Create session and return new pair access and refresh tokens. Available only in dev mode.
+//	@Tags			test
 //	@Produce		json
 //	@Param			user_id	path	int	true	"user id"
 //	@Success		201
 //	@Failure		400	{object}	errMsg	"Invalid request parameters"
+//	@Failure		403	{object}	errMsg	"Email is not verified"
 //	@Failure		404	{object}	errMsg	"User not found"
 //	@Failure		500	{object}	errMsg	"Internal server error"
 //	@Router			/auth/login/{user_id} [get]
-func (h authRoutes) login(c *gin.Context) {
+func (h authRoutes) loginByID(c *gin.Context) {
 	stringID := c.Param("user_id")
 	userID, err := strconv.Atoi(stringID)
 	if err != nil {
@@ -71,7 +143,10 @@ func (h authRoutes) login(c *gin.Context) {
 	}
 
 	aToken, rToken, err := h.authService.CreateSession(ctx, user.ID, c.ClientIP())
-	if err != nil {
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if err != nil {
 		errorMsg(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -95,6 +170,7 @@ type refreshResponse struct {
 //
 //	@Summary		Refresh session
 //	@Description	Refresh session and return new pair access and refresh tokens.
+//	@Description	Access token may be omitted, it's required only for refresh tokens issued before format selector.secret.
 //	@Security		BearerAuth
 //	@Tags			auth
 //	@Accept			json
@@ -102,17 +178,14 @@ type refreshResponse struct {
 //	@Param			refresh_token	body	refreshRequest	true	"refresh token for refresh session"
 //	@Success		200
 //	@Failure		400	{object}	errMsg	"Invalid request parameters"
-//	@Failure		401	{object}	errMsg	"Unauthorized - invalid tokens"
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid tokens, or new IP address requires to log in again"
+//	@Failure		403	{object}	errMsg	"Refresh from new IP address is denied"
 //	@Failure		500	{object}	errMsg	"Internal server error"
 //	@Router			/auth/refresh [post]
 func (h authRoutes) refresh(c *gin.Context) {
-	// не сдела middleware потому что подумал что тут логично пропускать даже expire aToken
-	aToken := c.GetHeader("Authorization")
-	aToken = strings.TrimPrefix(aToken, "Bearer ")
-	if len(aToken) == 0 {
-		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
-		return
-	}
+	// не сдела middleware потому что подумал что тут логично пропускать даже expire aToken,
+	// refresh token with selector finds session by itself, so access token is optional
+	aToken := bearerToken(c)
 
 	var req refreshRequest
 	if err := c.BindJSON(&req); err != nil {
@@ -125,10 +198,15 @@ func (h authRoutes) refresh(c *gin.Context) {
 	defer cancel()
 
 	aToken, rToken, err := h.authService.RefreshSession(ctx, aToken, rToken, c.ClientIP())
-	if errors.Is(err, jwt.ErrTokenExpired) ||
-		errors.Is(err, jwt.ErrSignatureInvalid) ||
-		errors.Is(err, jwt.ErrTokenMalformed) ||
-		errors.Is(err, auth.ErrValidationFailed) {
+	if errors.Is(err, auth.ErrStepUpRequired) {
+		// RFC 9470, client should send user to login
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_user_authentication", error_description="log in again from new IP address"`)
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if errors.Is(err, auth.ErrIPDenied) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if isUnauthorized(err) {
 		errorMsg(c, http.StatusUnauthorized, err)
 		return
 	} else if err != nil {
@@ -141,3 +219,368 @@ func (h authRoutes) refresh(c *gin.Context) {
 		RefreshToken: rToken,
 	})
 }
+
+// Logout godoc
+//
+//	@Summary		Logout
+//	@Description	Revoke session of access token, refresh of it will fail after that.
+//	@Security		BearerAuth
+//	@Tags			auth
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/auth/logout [post]
+func (h authRoutes) logout(c *gin.Context) {
+	// token is verified by middleware
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, authmiddleware.ErrTokenMissing)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	if err := h.authService.RevokeSession(ctx, payload); err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+//
+//	@Summary		Logout everywhere
+//	@Description	Revoke all sessions of user which access token belongs to.
+//	@Security		BearerAuth
+//	@Tags			auth
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403	{object}	errMsg	"Token of OAuth client"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/auth/logout-all [post]
+func (h authRoutes) logoutAll(c *gin.Context) {
+	// token is verified by middleware
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, authmiddleware.ErrTokenMissing)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	if err := h.authService.RevokeAllSessions(ctx, payload.UserID); err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type introspectRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// Introspect godoc
+//
+//	@Summary		Introspect token
+//	@Description	Report state of access or refresh token by RFC 7662, caller authenticates with client credentials (basic auth or form).
+//	@Tags			auth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			token			formData	string	true	"token to introspect"
+//	@Param			token_type_hint	formData	string	false	"access_token or refresh_token"
+//	@Param			client_id		formData	string	false	"client id, if basic auth is not used"
+//	@Param			client_secret	formData	string	false	"client secret, if basic auth is not used"
+//	@Success		200				{object}	model.Introspection
+//	@Failure		400				{object}	errMsg	"Invalid request parameters"
+//	@Failure		401				{object}	errMsg	"Unauthorized - invalid client credentials"
+//	@Failure		500				{object}	errMsg	"Internal server error"
+//	@Router			/auth/introspect [post]
+func (h authRoutes) introspect(c *gin.Context) {
+	var req introspectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.ClientID, req.ClientSecret
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	if err := h.clientService.Authenticate(ctx, clientID, clientSecret); errors.Is(err, client.ErrInvalidClient) {
+		c.Header("WWW-Authenticate", `Basic realm="introspect"`)
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(req.Token) == 0 {
+		errorMsg(c, http.StatusBadRequest, fmt.Errorf("token is empty"))
+		return
+	}
+
+	res, err := h.authService.Introspect(ctx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// firstPartyOnly refuse access tokens of OAuth 2.0 clients, it goes after auth middleware on routes
+// which manage account or all its sessions, scope of client doesn't grant them
+func firstPartyOnly(c *gin.Context) {
+	if payload, ok := tokenPayload(c); ok && payload.ClientID != "" {
+		errorMsg(c, http.StatusForbidden, fmt.Errorf("token of oauth client can't be used here"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// tokenPayload return payload put to context by auth middleware
+func tokenPayload(c *gin.Context) (*model.Payload, bool) {
+	return authmiddleware.PayloadFromGin[*model.Payload](c)
+}
+
+func bearerToken(c *gin.Context) string {
+	return authmiddleware.BearerToken(c.Request)
+}
+
+func isUnauthorized(err error) bool {
+	return authmiddleware.IsTokenInvalid(err) || errors.Is(err, auth.ErrValidationFailed)
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@gmail.com"`
+}
+
+// ForgotPassword godoc
+//
+//	@Summary		Forgot password
+//	@Description	Send password reset link to email if account exists. Response is the same for unknown emails.
+//	@Tags			auth
+//	@Accept			json
+//	@Param			forgot_request	body	forgotPasswordRequest	true	"email of user"
+//	@Success		202
+//	@Failure		400	{object}	errMsg	"Invalid request parameters"
+//	@Router			/auth/password/forgot [post]
+func (h authRoutes) forgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	// failure is only logged, different status would reveal that account exists
+	if err := h.authService.ForgotPassword(ctx, req.Email, c.ClientIP()); err != nil {
+		h.logger.Error("failed to send password reset link: %s", err.Error())
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=72" example:"new_password"`
+}
+
+// ResetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	Set new password by token from reset link and close all sessions of user, link could be used only once.
+//	@Tags			auth
+//	@Accept			json
+//	@Param			reset_request	body	resetPasswordRequest	true	"token from reset link and new password"
+//	@Success		204
+//	@Failure		400	{object}	errMsg	"Invalid request parameters or invalid, expired or already used token"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/auth/password/reset [post]
+func (h authRoutes) resetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	err := h.authService.ResetPassword(ctx, req.Token, req.NewPassword)
+	if errors.Is(err, auth.ErrInvalidResetToken) || errors.Is(err, user.ErrWeakPassword) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+const (
+	// keeps nonce of login link in browser which requested it
+	magicLinkNonceCookie = "magic_link_nonce"
+	magicLinkCookiePath  = "/api/v1/auth/magic-link"
+)
+
+type magicLinkRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@gmail.com"`
+}
+
+// SendMagicLink godoc
+//
+//	@Summary		Send login link
+//	@Description	Send single-use login link to email if account exists and set nonce cookie, link works only in the same browser. Response is the same for unknown emails.
+//	@Tags			auth
+//	@Accept			json
+//	@Param			magic_link_request	body	magicLinkRequest	true	"email of user"
+//	@Success		202
+//	@Failure		400	{object}	errMsg	"Invalid request parameters"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/auth/magic-link [post]
+func (h authRoutes) sendMagicLink(c *gin.Context) {
+	var req magicLinkRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	nonce, err := h.authService.SendMagicLink(ctx, req.Email, c.ClientIP())
+	if len(nonce) == 0 {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	} else if err != nil {
+		// failure is only logged, different status would reveal that account exists
+		h.logger.Error("failed to send login link: %s", err.Error())
+	}
+
+	h.setNonceCookie(c, nonce, 0)
+	c.Status(http.StatusAccepted)
+}
+
+// setNonceCookie set nonce cookie of login link, negative max age deletes it
+func (h authRoutes) setNonceCookie(c *gin.Context, nonce string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(magicLinkNonceCookie, nonce, maxAge, magicLinkCookiePath, "", h.secureCookies, true)
+}
+
+// ConsumeMagicLink godoc
+//
+//	@Summary		Login by link
+//	@Description	Check login link and nonce cookie set when link was requested, create session and return new pair access and refresh tokens.
+//	@Tags			auth
+//	@Produce		json
+//	@Param			token	query		string	true	"token from login link"
+//	@Success		200		{object}	loginResponse
+//	@Failure		400		{object}	errMsg	"Invalid request parameters"
+//	@Failure		401		{object}	errMsg	"Invalid, expired or already used link, or link opened in other browser"
+//	@Failure		403		{object}	errMsg	"Email is not verified"
+//	@Failure		500		{object}	errMsg	"Internal server error"
+//	@Router			/auth/magic-link/consume [get]
+func (h authRoutes) consumeMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if len(token) == 0 {
+		errorMsg(c, http.StatusBadRequest, fmt.Errorf("token is required"))
+		return
+	}
+
+	// missing cookie is the same as wrong one
+	nonce, _ := c.Cookie(magicLinkNonceCookie)
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	aToken, rToken, err := h.authService.LoginByMagicLink(ctx, token, nonce, c.ClientIP())
+	var challenge *auth.MFAChallengeError
+	if errors.As(err, &challenge) {
+		// link is already used, so nonce is not needed anymore
+		h.setNonceCookie(c, "", -1)
+		c.JSON(http.StatusAccepted, mfaRequiredResponse{
+			Status:   mfaRequiredStatus,
+			MFAToken: challenge.Token,
+		})
+		return
+	} else if errors.Is(err, auth.ErrInvalidMagicLink) {
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if errors.Is(err, auth.ErrEmailNotVerified) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.setNonceCookie(c, "", -1)
+	c.JSON(http.StatusOK, loginResponse{
+		AccessToken:  aToken,
+		RefreshToken: rToken,
+	})
+}
+
+type verifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// code of authenticator app or one of recovery codes
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// VerifyMFA godoc
+//
+//	@Summary		Login with second factor
+//	@Description	Check code of authenticator app or recovery code for challenge returned by login, create session and return new pair access and refresh tokens.
+//	@Description	Challenge works once, after wrong code login must be repeated.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			mfa_request	body		verifyMFARequest	true	"challenge token and code"
+//	@Success		200			{object}	loginResponse
+//	@Failure		400			{object}	errMsg	"Invalid request parameters"
+//	@Failure		401			{object}	errMsg	"Invalid, expired or used challenge or wrong code"
+//	@Failure		500			{object}	errMsg	"Internal server error"
+//	@Router			/auth/login/mfa [post]
+func (h authRoutes) verifyMFA(c *gin.Context) {
+	var req verifyMFARequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	aToken, rToken, err := h.authService.VerifyMFA(ctx, req.MFAToken, req.Code, c.ClientIP())
+	if errors.Is(err, auth.ErrInvalidMFAChallenge) || errors.Is(err, mfa.ErrInvalidCode) {
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		AccessToken:  aToken,
+		RefreshToken: rToken,
+	})
+}
@@ -7,12 +7,19 @@ import (
 	"fmt"
 	"medods/internal/model"
 	"medods/internal/service"
+	"medods/internal/service/auth"
 	mock_auth "medods/internal/service/auth/mock"
+	"medods/internal/service/client"
+	mock_client "medods/internal/service/client/mock"
+	mock_jwt "medods/internal/service/jwt/mock"
+	"medods/internal/service/mfa"
 	mock_session "medods/internal/service/session/mock"
 	mock_user "medods/internal/service/user/mock"
 	"medods/pkg/logger"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -33,7 +40,7 @@ func TestAuthLogin(t *testing.T) {
 		Auth:    authService,
 		User:    userService,
 		Session: sessionService,
-	}, logger)
+	}, &Config{DevMode: true}, logger)
 
 	type args struct {
 		path string
@@ -46,7 +53,7 @@ func TestAuthLogin(t *testing.T) {
 	}
 
 	defaultAToken := "access_token"
-	defaultRToken := "rand_string"
+	defaultRToken := "selector.rand_string"
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
@@ -139,7 +146,7 @@ func TestAuthRefresh(t *testing.T) {
 		Auth:    authService,
 		User:    userService,
 		Session: sessionService,
-	}, logger)
+	}, &Config{DevMode: true}, logger)
 
 	type args struct {
 		aToken string
@@ -178,20 +185,16 @@ func TestAuthRefresh(t *testing.T) {
 			},
 		},
 		{
-			name: "error no access token in header",
+			name: "OK without access token",
 			input: args{
-				aToken: "",
+				aToken: "", // note: refresh token of new format is enough
 				rToken: defaultRToken,
 			},
-			buildStubs: func() {},
+			buildStubs: func() {
+				authService.EXPECT().RefreshSession(gomock.Any(), gomock.Eq(""), gomock.Eq(defaultRToken), gomock.Any()).Times(1).Return(defaultAToken, defaultRToken, nil)
+			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				j, err := json.Marshal(map[string]any{
-					"error": "authorization token is empty",
-				})
-				assert.NoError(t, err)
-
-				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
-				assert.Equal(t, string(j), recorder.Body.String())
+				assert.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
 		{
@@ -205,6 +208,36 @@ func TestAuthRefresh(t *testing.T) {
 				assert.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name: "error step-up required from new ip",
+			input: args{
+				aToken: defaultAToken,
+				rToken: defaultRToken,
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", &auth.IPPolicyError{Decision: auth.IPDecision{Outcome: auth.IPStepUp}})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), "insufficient_user_authentication")
+			},
+		},
+		{
+			name: "error refresh from new ip denied",
+			input: args{
+				aToken: defaultAToken,
+				rToken: defaultRToken,
+			},
+			buildStubs: func() {
+				authService.EXPECT().RefreshSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", &auth.IPPolicyError{Decision: auth.IPDecision{Outcome: auth.IPDeny}})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
 		{
 			name: "error refresh session token expired",
 			input: args{
@@ -276,3 +309,1057 @@ func TestAuthRefresh(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		JWT:     jwtMaker,
+	}, &Config{DevMode: true}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		aToken        string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				authService.EXPECT().RevokeSession(gomock.Any(), gomock.Eq(&defaultPayload)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:       "error no access token in header",
+			aToken:     "",
+			buildStubs: func() {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:   "error token expired",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, nil, jwt.ErrTokenExpired)
+				authService.EXPECT().RevokeSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:   "error unexpected revoke session",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				authService.EXPECT().RevokeSession(gomock.Any(), gomock.Eq(&defaultPayload)).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.aToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthLogoutAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		JWT:     jwtMaker,
+	}, &Config{DevMode: true}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		aToken        string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				authService.EXPECT().RevokeAllSessions(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:   "error token of oauth client",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ClientID, cpPayload.Scope = "spa", "openid" // note
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				authService.EXPECT().RevokeAllSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:       "error no access token in header",
+			aToken:     "",
+			buildStubs: func() {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:   "error token expired",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, nil, jwt.ErrTokenExpired)
+				authService.EXPECT().RevokeAllSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:   "error unexpected revoke all sessions",
+			aToken: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				authService.EXPECT().RevokeAllSessions(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/logout-all", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.aToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthIntrospect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	clientService := mock_client.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		Client:  clientService,
+	}, &Config{DevMode: true}, logger)
+
+	type args struct {
+		form      url.Values
+		basicAuth bool
+	}
+
+	defaultToken := "access_token"
+	defaultClientID := "billing"
+	defaultClientSecret := "secret"
+
+	defaultArgs := args{
+		form:      url.Values{"token": {defaultToken}},
+		basicAuth: true,
+	}
+
+	active := model.Introspection{
+		Active:    true,
+		TokenType: "access_token",
+		Sub:       "1",
+		Exp:       2,
+		Iat:       1,
+		JTI:       "jti",
+		IP:        "::1",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         args
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Eq(defaultClientID), gomock.Eq(defaultClientSecret)).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Eq(defaultToken), gomock.Any()).Times(1).Return(active, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res model.Introspection
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, active, res)
+			},
+		},
+		{
+			name: "OK credentials in form",
+			input: args{
+				form: url.Values{
+					"token":         {defaultToken},
+					"client_id":     {defaultClientID},
+					"client_secret": {defaultClientSecret},
+				},
+				basicAuth: false, // note: credentials in form
+			},
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Eq(defaultClientID), gomock.Eq(defaultClientSecret)).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Eq(defaultToken), gomock.Any()).Times(1).Return(active, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "OK token type hint",
+			input: args{
+				form:      url.Values{"token": {"selector.secret"}, "token_type_hint": {"refresh_token"}}, // note
+				basicAuth: true,
+			},
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Eq("selector.secret"), gomock.Eq("refresh_token")).Times(1).
+					Return(model.Introspection{Active: true, TokenType: "refresh_token"}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "OK inactive token",
+			input: defaultArgs,
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Eq(defaultToken), gomock.Any()).Times(1).Return(model.Introspection{Active: false}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.JSONEq(t, `{"active":false}`, recorder.Body.String())
+			},
+		},
+		{
+			name:  "error invalid client",
+			input: defaultArgs,
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(client.ErrInvalidClient)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name: "error empty token",
+			input: args{
+				form:      url.Values{}, // note: no token
+				basicAuth: true,
+			},
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error unexpected introspect",
+			input: defaultArgs,
+			buildStubs: func() {
+				clientService.EXPECT().Authenticate(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				authService.EXPECT().Introspect(gomock.Any(), gomock.Eq(defaultToken), gomock.Any()).Times(1).Return(model.Introspection{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/introspect", strings.NewReader(test.input.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.input.basicAuth {
+				req.SetBasicAuth(defaultClientID, defaultClientSecret)
+			}
+
+			router.ServeHTTP(rec, req)
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthPasswordLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{DevMode: false}, logger)
+
+	defaultRequest := loginRequest{
+		Email:    "user@gmail.com",
+		Password: "password",
+	}
+	defaultIP := "0.0.0.0"
+
+	defaultAToken := "access_token"
+	defaultRToken := "rand_string"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         loginRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().Login(gomock.Any(), gomock.Eq(defaultRequest.Email), gomock.Eq(defaultRequest.Password), gomock.Eq(defaultIP)).
+					Times(1).Return(defaultAToken, defaultRToken, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultAToken, res.AccessToken)
+				assert.Equal(t, defaultRToken, res.RefreshToken)
+			},
+		},
+		{
+			name:       "error incorrect email",
+			input:      loginRequest{Email: "incorrect", Password: defaultRequest.Password},
+			buildStubs: func() {}, // none expecting calls
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "mfa required",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().Login(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", &auth.MFAChallengeError{Token: "pwd.token.signature"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+				var res mfaRequiredResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, mfaRequiredStatus, res.Status)
+				assert.Equal(t, "pwd.token.signature", res.MFAToken)
+			},
+		},
+		{
+			name:  "error invalid credentials",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().Login(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", "", auth.ErrInvalidCredentials)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "error email not verified",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().Login(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", "", auth.ErrEmailNotVerified)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "error unexpected login",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().Login(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", "", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(j))
+			req.Header.Set("x-forwarded-for", defaultIP)
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+
+	t.Run("error login by id outside dev mode", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/login/1", nil)
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("error test routes outside dev mode", func(t *testing.T) {
+		for _, route := range []struct{ method, path string }{
+			{http.MethodGet, "/api/v1/user/list"},
+			{http.MethodGet, "/api/v1/session/list"},
+			{http.MethodPost, "/api/v1/session/update"},
+		} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(route.method, route.path, nil)
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, route.path)
+		}
+	})
+}
+
+func TestAuthForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{}, logger)
+
+	defaultRequest := forgotPasswordRequest{
+		Email: "user@gmail.com",
+	}
+	defaultIP := "0.0.0.0"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         forgotPasswordRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().ForgotPassword(gomock.Any(), gomock.Eq(defaultRequest.Email), gomock.Eq(defaultIP)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+			},
+		},
+		{
+			name:       "error incorrect email",
+			input:      forgotPasswordRequest{Email: "incorrect"},
+			buildStubs: func() {}, // none expecting calls
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error is hidden",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().ForgotPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/forgot", bytes.NewBuffer(j))
+			req.Header.Set("x-forwarded-for", defaultIP)
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{}, logger)
+
+	defaultRequest := resetPasswordRequest{
+		Token:       "token.signature",
+		NewPassword: "new_password",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         resetPasswordRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().ResetPassword(gomock.Any(), gomock.Eq(defaultRequest.Token), gomock.Eq(defaultRequest.NewPassword)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "error weak password",
+			input: resetPasswordRequest{
+				Token:       defaultRequest.Token,
+				NewPassword: "short", // note: too short
+			},
+			buildStubs: func() {
+				authService.EXPECT().ResetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid token",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().ResetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(auth.ErrInvalidResetToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().ResetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/reset", bytes.NewBuffer(j))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthMagicLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{SecureCookies: true}, logger)
+
+	defaultRequest := magicLinkRequest{
+		Email: "user@gmail.com",
+	}
+	defaultIP := "0.0.0.0"
+	defaultNonce := "nonce"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         magicLinkRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().SendMagicLink(gomock.Any(), gomock.Eq(defaultRequest.Email), gomock.Eq(defaultIP)).Times(1).Return(defaultNonce, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+				cookies := recorder.Result().Cookies()
+				if assert.Len(t, cookies, 1) {
+					assert.Equal(t, magicLinkNonceCookie, cookies[0].Name)
+					assert.Equal(t, defaultNonce, cookies[0].Value)
+					assert.Equal(t, magicLinkCookiePath, cookies[0].Path)
+					assert.True(t, cookies[0].HttpOnly)
+					assert.True(t, cookies[0].Secure)
+					assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+				}
+			},
+		},
+		{
+			name:       "error incorrect email",
+			input:      magicLinkRequest{Email: "incorrect"},
+			buildStubs: func() {}, // none expecting calls
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error after nonce is hidden",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(defaultNonce, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+				assert.Len(t, recorder.Result().Cookies(), 1)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/magic-link", bytes.NewBuffer(j))
+			req.Header.Set("x-forwarded-for", defaultIP)
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthMagicLinkPlainHTTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth: authService,
+	}, &Config{SecureCookies: false}, logger) // note: login link is http
+
+	authService.EXPECT().SendMagicLink(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("nonce", nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/magic-link", bytes.NewBufferString(`{"email":"user@gmail.com"}`))
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.False(t, cookies[0].Secure)
+		assert.True(t, cookies[0].HttpOnly)
+	}
+}
+
+func TestAuthConsumeMagicLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{}, logger)
+
+	defaultToken := "token.signature"
+	defaultNonce := "nonce"
+	defaultIP := "0.0.0.0"
+
+	defaultAToken := "access_token"
+	defaultRToken := "rand_string"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		token string
+		nonce string
+	}
+
+	defaultArgs := args{
+		token: defaultToken,
+		nonce: defaultNonce,
+	}
+
+	tc := []struct {
+		name          string
+		input         args
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Eq(defaultToken), gomock.Eq(defaultNonce), gomock.Eq(defaultIP)).Times(1).
+					Return(defaultAToken, defaultRToken, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultAToken, res.AccessToken)
+				assert.Equal(t, defaultRToken, res.RefreshToken)
+
+				// nonce is not needed anymore
+				cookies := recorder.Result().Cookies()
+				if assert.Len(t, cookies, 1) {
+					assert.Equal(t, magicLinkNonceCookie, cookies[0].Name)
+					assert.Negative(t, cookies[0].MaxAge)
+				}
+			},
+		},
+		{
+			name:  "mfa required",
+			input: defaultArgs,
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", &auth.MFAChallengeError{Token: "email.token.signature"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+				var res mfaRequiredResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, "email.token.signature", res.MFAToken)
+
+				// link is consumed, so nonce is cleared too
+				cookies := recorder.Result().Cookies()
+				if assert.Len(t, cookies, 1) {
+					assert.Negative(t, cookies[0].MaxAge)
+				}
+			},
+		},
+		{
+			name: "error without cookie",
+			input: args{
+				token: defaultToken,
+				nonce: "", // note: other browser
+			},
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Eq(defaultToken), gomock.Eq(""), gomock.Any()).Times(1).
+					Return("", "", auth.ErrInvalidMagicLink)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "error empty token",
+			input: args{
+				token: "", // note: empty
+				nonce: defaultNonce,
+			},
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error email not verified",
+			input: defaultArgs,
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", auth.ErrEmailNotVerified)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				authService.EXPECT().LoginByMagicLink(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/magic-link/consume?token="+test.input.token, nil)
+			req.Header.Set("x-forwarded-for", defaultIP)
+			if len(test.input.nonce) != 0 {
+				req.AddCookie(&http.Cookie{Name: magicLinkNonceCookie, Value: test.input.nonce})
+			}
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestAuthVerifyMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{}, logger)
+
+	defaultRequest := verifyMFARequest{
+		MFAToken: "pwd.token.signature",
+		Code:     "123456",
+	}
+	defaultIP := "0.0.0.0"
+
+	defaultAToken := "access_token"
+	defaultRToken := "rand_string"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         verifyMFARequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().VerifyMFA(gomock.Any(), gomock.Eq(defaultRequest.MFAToken), gomock.Eq(defaultRequest.Code), gomock.Eq(defaultIP)).Times(1).
+					Return(defaultAToken, defaultRToken, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultAToken, res.AccessToken)
+				assert.Equal(t, defaultRToken, res.RefreshToken)
+			},
+		},
+		{
+			name:  "error without code",
+			input: verifyMFARequest{MFAToken: defaultRequest.MFAToken}, // note: code is missing
+			buildStubs: func() {
+				authService.EXPECT().VerifyMFA(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid challenge",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().VerifyMFA(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", auth.ErrInvalidMFAChallenge)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid code",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().VerifyMFA(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", mfa.ErrInvalidCode)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				authService.EXPECT().VerifyMFA(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", "", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login/mfa", bytes.NewBuffer(j))
+			req.Header.Set("x-forwarded-for", defaultIP)
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package http
+
+type Config struct {
+	// register routes for testing, which must not be available in production
+	DevMode bool
+	// public url of server, endpoints in OpenID Connect discovery are built from it
+	Issuer string
+	// send cookies only over https, browsers drop secure cookies set by plain http server
+	SecureCookies bool
+}
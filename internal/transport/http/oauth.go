@@ -0,0 +1,292 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods/internal/service"
+	"medods/internal/service/jwt"
+	"medods/internal/service/oauth"
+	"medods/pkg/logger"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type oauthRoutes struct {
+	oauthService oauth.Interface
+	jwtMaker     jwt.Interface
+	issuer       string
+	logger       logger.Interface
+}
+
+func newOAuthRoutes(l logger.Interface, s *service.Manager, cfg *Config) *oauthRoutes {
+	return &oauthRoutes{
+		oauthService: s.OAuth,
+		jwtMaker:     s.JWT,
+		issuer:       cfg.Issuer,
+
+		logger: l,
+	}
+}
+
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+}
+
+type authorizeResponse struct {
+	// uri of client with code or error, page navigates to it
+	RedirectURI string `json:"redirect_uri" example:"https://app.example.com/callback?code=code&state=xyz"`
+}
+
+// Authorize godoc
+//
+//	@Summary		OAuth 2.0 authorization endpoint
+//	@Description	Issue authorization code to client on behalf of user which access token belongs to (RFC 6749 section 4.1).
+//	@Description	PKCE with S256 is required. Parameters are taken from query or form.
+//	@Description	Errors of request are sent to redirect uri, unknown client or redirect uri get 400 without redirect.
+//	@Description	Browser doesn't send bearer token on navigation, so client sends user to login page of first-party frontend
+//	@Description	with these parameters, and the page calls this endpoint by XHR with Accept: application/json
+//	@Description	and navigates to redirect_uri of response. Request without Accept: application/json gets 302.
+//	@Security		BearerAuth
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			response_type			query		string				true	"code"
+//	@Param			client_id				query		string				true	"registered client id"
+//	@Param			redirect_uri			query		string				false	"registered redirect uri, may be omitted if client has only one"
+//	@Param			scope					query		string				false	"space-delimited scopes, all scopes of client by default"
+//	@Param			state					query		string				false	"opaque value returned to client"
+//	@Param			code_challenge			query		string				true	"base64url of sha256 of code verifier"
+//	@Param			code_challenge_method	query		string				true	"S256"
+//	@Param			nonce					query		string				false	"OpenID Connect nonce, returned in id token"
+//	@Success		200						{object}	authorizeResponse	"Accept: application/json"
+//	@Success		302
+//	@Failure		400	{object}	oauth.Error	"Unknown client or redirect uri"
+//	@Failure		401	{object}	errMsg		"Unauthorized - invalid token"
+//	@Failure		500	{object}	oauth.Error	"Internal server error"
+//	@Router			/oauth/authorize [get]
+//	@Router			/oauth/authorize [post]
+func (h oauthRoutes) authorize(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	var req authorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oauth.Error{Code: oauth.ErrorInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	redirectURI, err := h.oauthService.Authorize(ctx, oauth.AuthorizeRequest{
+		ResponseType:        req.ResponseType,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+	}, payload)
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) {
+		c.JSON(http.StatusBadRequest, oauthErr)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, oauth.Error{Code: oauth.ErrorServerError, Description: err.Error()})
+		return
+	}
+
+	// XHR can't read location of redirect, it follows it to client
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, authorizeResponse{RedirectURI: redirectURI})
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURI)
+}
+
+type tokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// Token godoc
+//
+//	@Summary		OAuth 2.0 token endpoint
+//	@Description	Exchange authorization code or refresh token for tokens (RFC 6749 section 4.1.3 and 6).
+//	@Description	Confidential client authenticates with basic auth or form, public client sends only client_id.
+//	@Description	Refresh token is bound to client, refresh rotates it the same way as /auth/refresh.
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			grant_type		formData	string	true	"authorization_code or refresh_token"
+//	@Param			client_id		formData	string	false	"client id, if basic auth is not used"
+//	@Param			client_secret	formData	string	false	"secret of confidential client, if basic auth is not used"
+//	@Param			code			formData	string	false	"authorization code"
+//	@Param			redirect_uri	formData	string	false	"the same as in authorization request"
+//	@Param			code_verifier	formData	string	false	"PKCE code verifier"
+//	@Param			refresh_token	formData	string	false	"refresh token"
+//	@Param			scope			formData	string	false	"scope of refresh, it can't exceed granted one"
+//	@Success		200				{object}	oauth.TokenResponse
+//	@Failure		400				{object}	oauth.Error	"Invalid request or grant"
+//	@Failure		401				{object}	oauth.Error	"Invalid client credentials"
+//	@Failure		500				{object}	oauth.Error	"Internal server error"
+//	@Router			/oauth/token [post]
+func (h oauthRoutes) token(c *gin.Context) {
+	// tokens must not be cached, RFC 6749 section 5.1, errors included
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req tokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oauth.Error{Code: oauth.ErrorInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	// credentials of basic auth are form-encoded, RFC 6749 section 2.3.1
+	clientID, clientSecret, basic := c.Request.BasicAuth()
+	if basic {
+		if req.ClientSecret != "" || (req.ClientID != "" && req.ClientID != clientID) {
+			c.JSON(http.StatusBadRequest, oauth.Error{Code: oauth.ErrorInvalidRequest, Description: "client must use only one authentication method"})
+			return
+		}
+
+		var idErr, secretErr error
+		clientID, idErr = url.QueryUnescape(clientID)
+		clientSecret, secretErr = url.QueryUnescape(clientSecret)
+		if idErr != nil || secretErr != nil {
+			c.JSON(http.StatusBadRequest, oauth.Error{Code: oauth.ErrorInvalidRequest, Description: "client credentials are malformed"})
+			return
+		}
+	} else {
+		clientID, clientSecret = req.ClientID, req.ClientSecret
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	res, err := h.oauthService.Token(ctx, oauth.TokenRequest{
+		GrantType:    req.GrantType,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Code:         req.Code,
+		RedirectURI:  req.RedirectURI,
+		CodeVerifier: req.CodeVerifier,
+		RefreshToken: req.RefreshToken,
+		Scope:        req.Scope,
+	}, c.ClientIP())
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) && oauthErr.Code == oauth.ErrorInvalidClient {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		c.JSON(http.StatusUnauthorized, oauthErr)
+		return
+	} else if errors.As(err, &oauthErr) {
+		c.JSON(http.StatusBadRequest, oauthErr)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, oauth.Error{Code: oauth.ErrorServerError, Description: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// UserInfo godoc
+//
+//	@Summary		OpenID Connect userinfo endpoint
+//	@Description	Return claims of user which access token was issued to client with openid scope.
+//	@Description	Email claims are returned only if email scope is granted.
+//	@Security		BearerAuth
+//	@Tags			oauth
+//	@Produce		json
+//	@Success		200	{object}	model.UserInfo
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403	{object}	errMsg	"Token has no openid scope"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/oauth/userinfo [get]
+//	@Router			/oauth/userinfo [post]
+func (h oauthRoutes) userInfo(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	info, err := h.oauthService.UserInfo(ctx, payload)
+	if errors.Is(err, oauth.ErrInsufficientScope) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+type discoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// discovery serves OpenID Provider Metadata (OpenID Connect Discovery 1.0 section 3).
+// It lives outside of /api/v1, because its path is fixed by the spec relative to issuer.
+func (h oauthRoutes) discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, discoveryResponse{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             h.issuer + "/api/v1/oauth/authorize",
+		TokenEndpoint:                     h.issuer + "/api/v1/oauth/token",
+		UserInfoEndpoint:                  h.issuer + "/api/v1/oauth/userinfo",
+		JWKSURI:                           h.issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{oauth.ResponseTypeCode},
+		GrantTypesSupported:               []string{oauth.GrantTypeAuthorizationCode, oauth.GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.jwtMaker.Algorithm()},
+		ScopesSupported:                   []string{oauth.ScopeOpenID, oauth.ScopeEmail},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oauth.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "email", "email_verified"},
+	})
+}
+
+// jwks publish public keys which tokens are signed with (RFC 7517 section 5), set is empty for HS512
+func (h oauthRoutes) jwks(c *gin.Context) {
+	c.JSON(http.StatusOK, h.jwtMaker.JWKS())
+}
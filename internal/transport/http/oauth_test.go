@@ -0,0 +1,538 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/service"
+	mock_jwt "medods/internal/service/jwt/mock"
+	"medods/internal/service/oauth"
+	mock_oauth "medods/internal/service/oauth/mock"
+	"medods/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	oauthService := mock_oauth.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		JWT:   jwtMaker,
+		OAuth: oauthService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1", AMR: []string{model.AMRPassword}}
+
+	defaultQuery := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+		"nonce":                 {"nonce"},
+	}
+	defaultRequest := oauth.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "spa",
+		RedirectURI:         "https://app.example.com/callback",
+		State:               "xyz",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+		Nonce:               "nonce",
+	}
+	defaultRedirect := "https://app.example.com/callback?code=code&state=xyz"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		method string
+		query  url.Values
+		token  string
+		accept string
+	}
+
+	defaultArgs := args{
+		method: http.MethodGet,
+		query:  defaultQuery,
+		token:  defaultAToken,
+	}
+
+	tc := []struct {
+		name          string
+		input         args
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultArgs,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Eq(defaultRequest), gomock.Eq(&defaultPayload)).Times(1).
+					Return(defaultRedirect, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusFound, recorder.Code)
+				assert.Equal(t, defaultRedirect, recorder.Header().Get("Location"))
+			},
+		},
+		{
+			name: "OK form",
+			input: args{
+				method: http.MethodPost, // note: parameters in body
+				query:  defaultQuery,
+				token:  defaultAToken,
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Eq(defaultRequest), gomock.Any()).Times(1).
+					Return(defaultRedirect, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusFound, recorder.Code)
+			},
+		},
+		{
+			name: "OK xhr",
+			input: args{
+				method: http.MethodGet,
+				query:  defaultQuery,
+				token:  defaultAToken,
+				accept: "application/json", // note
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Eq(defaultRequest), gomock.Any()).Times(1).
+					Return(defaultRedirect, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("Location"))
+
+				var res authorizeResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultRedirect, res.RedirectURI)
+			},
+		},
+		{
+			name: "OK browser navigation",
+			input: args{
+				method: http.MethodGet,
+				query:  defaultQuery,
+				token:  defaultAToken,
+				accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", // note
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Eq(defaultRequest), gomock.Any()).Times(1).
+					Return(defaultRedirect, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusFound, recorder.Code)
+				assert.Equal(t, defaultRedirect, recorder.Header().Get("Location"))
+			},
+		},
+		{
+			name: "error without token",
+			input: args{
+				method: http.MethodGet,
+				query:  defaultQuery,
+			},
+			buildStubs: func() {
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "error unknown client",
+			input: defaultArgs,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return("", &oauth.Error{Code: oauth.ErrorInvalidRequest, Description: "client_id is unknown"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// note: no redirect to uri which is not registered
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("Location"))
+
+				var res oauth.Error
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, oauth.ErrorInvalidRequest, res.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultArgs,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().Authorize(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return("", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			var req *http.Request
+			if test.input.method == http.MethodGet {
+				req = httptest.NewRequest(http.MethodGet, "/api/v1/oauth/authorize?"+test.input.query.Encode(), nil)
+			} else {
+				req = httptest.NewRequest(http.MethodPost, "/api/v1/oauth/authorize", strings.NewReader(test.input.query.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if test.input.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.input.token))
+			}
+			if test.input.accept != "" {
+				req.Header.Set("Accept", test.input.accept)
+			}
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestOAuthToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	oauthService := mock_oauth.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		OAuth: oauthService,
+	}, &Config{}, logger)
+
+	defaultForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"spa"},
+		"code":          {"code"},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {"verifier"},
+	}
+	defaultRequest := oauth.TokenRequest{
+		GrantType:    "authorization_code",
+		ClientID:     "spa",
+		Code:         "code",
+		RedirectURI:  "https://app.example.com/callback",
+		CodeVerifier: "verifier",
+	}
+	defaultResponse := oauth.TokenResponse{
+		AccessToken:  "access_token",
+		TokenType:    "Bearer",
+		ExpiresIn:    900,
+		RefreshToken: "access_token~refresh_token",
+		Scope:        "openid",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	type args struct {
+		form      url.Values
+		basicAuth []string
+	}
+
+	tc := []struct {
+		name          string
+		input         args
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK public client",
+			input: args{form: defaultForm},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Eq(defaultRequest), gomock.Any()).Times(1).Return(defaultResponse, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+				assert.Equal(t, "no-cache", recorder.Header().Get("Pragma"))
+
+				var res oauth.TokenResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultResponse, res)
+			},
+		},
+		{
+			name: "OK basic auth",
+			input: args{
+				form: url.Values{
+					"grant_type":    {"refresh_token"},
+					"refresh_token": {"access_token~refresh_token"},
+				},
+				basicAuth: []string{"backend%3A1", "p%40ss"}, // note: credentials are form-encoded
+			},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Eq(oauth.TokenRequest{
+					GrantType:    "refresh_token",
+					ClientID:     "backend:1",
+					ClientSecret: "p@ss",
+					RefreshToken: "access_token~refresh_token",
+				}), gomock.Any()).Times(1).Return(defaultResponse, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "error two authentication methods",
+			input: args{
+				form: url.Values{
+					"grant_type":    {"refresh_token"},
+					"client_secret": {"secret"},
+				},
+				basicAuth: []string{"backend", "secret"},
+			},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "error invalid client with basic auth",
+			input: args{
+				form:      defaultForm,
+				basicAuth: []string{"spa", "wrong"},
+			},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(oauth.TokenResponse{}, &oauth.Error{Code: oauth.ErrorInvalidClient})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+
+				var res oauth.Error
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, oauth.ErrorInvalidClient, res.Code)
+			},
+		},
+		{
+			name:  "error invalid client",
+			input: args{form: defaultForm},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(oauth.TokenResponse{}, &oauth.Error{Code: oauth.ErrorInvalidClient})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:  "error invalid grant",
+			input: args{form: defaultForm},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(oauth.TokenResponse{}, &oauth.Error{Code: oauth.ErrorInvalidGrant, Description: "code is used"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var res oauth.Error
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, oauth.Error{Code: oauth.ErrorInvalidGrant, Description: "code is used"}, res)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: args{form: defaultForm},
+			buildStubs: func() {
+				oauthService.EXPECT().Token(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(oauth.TokenResponse{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+				var res oauth.Error
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, oauth.ErrorServerError, res.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/token", strings.NewReader(test.input.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.input.basicAuth != nil {
+				req.SetBasicAuth(test.input.basicAuth[0], test.input.basicAuth[1])
+			}
+
+			router.ServeHTTP(rec, req)
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestOAuthUserInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	oauthService := mock_oauth.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		JWT:   jwtMaker,
+		OAuth: oauthService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, ClientID: "spa", Scope: "openid email"}
+	verified := true
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().UserInfo(gomock.Any(), gomock.Eq(&defaultPayload)).Times(1).
+					Return(model.UserInfo{Subject: "1", Email: "user@gmail.com", EmailVerified: &verified}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.JSONEq(t, `{"sub":"1","email":"user@gmail.com","email_verified":true}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "error without token",
+			buildStubs: func() {
+				oauthService.EXPECT().UserInfo(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "error insufficient scope",
+			input: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().UserInfo(gomock.Any(), gomock.Any()).Times(1).Return(model.UserInfo{}, oauth.ErrInsufficientScope)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+				assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultAToken,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				oauthService.EXPECT().UserInfo(gomock.Any(), gomock.Any()).Times(1).Return(model.UserInfo{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/oauth/userinfo", nil)
+			if test.input != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", test.input))
+			}
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	jwtMaker.EXPECT().Algorithm().Times(1).Return("EdDSA")
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{JWT: jwtMaker}, &Config{Issuer: "https://auth.example.com"}, logger)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res discoveryResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "https://auth.example.com", res.Issuer)
+	assert.Equal(t, "https://auth.example.com/api/v1/oauth/authorize", res.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/api/v1/oauth/token", res.TokenEndpoint)
+	assert.Equal(t, "https://auth.example.com/api/v1/oauth/userinfo", res.UserInfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", res.JWKSURI)
+	assert.Equal(t, []string{"EdDSA"}, res.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, res.CodeChallengeMethodsSupported)
+	assert.Contains(t, res.ScopesSupported, "openid")
+}
+
+func TestJWKS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	jwtMaker.EXPECT().JWKS().Times(1).Return(model.JSONWebKeySet{Keys: []model.JSONWebKey{{
+		KeyType:   "OKP",
+		KeyID:     "kid",
+		Use:       "sig",
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		X:         "x",
+	}}})
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{JWT: jwtMaker}, &Config{}, logger)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"keys":[{"kty":"OKP","kid":"kid","use":"sig","alg":"EdDSA","crv":"Ed25519","x":"x"}]}`, rec.Body.String())
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"medods/internal/model"
 	"medods/internal/service"
+	"medods/pkg/authmiddleware"
 	"medods/pkg/logger"
 
 	_ "medods/docs"
@@ -12,10 +14,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(servise *service.Manager, l logger.Interface) *gin.Engine {
-	authRoutes := newAuthRoutes(l, servise)
+func NewRouter(servise *service.Manager, cfg *Config, l logger.Interface) *gin.Engine {
+	authRoutes := newAuthRoutes(l, servise, cfg)
 	userRoutes := newUserRoutes(l, servise)
 	sessionRoutes := newSessionRoutes(l, servise)
+	webAuthnRoutes := newWebAuthnRoutes(l, servise)
+	oauthRoutes := newOAuthRoutes(l, servise, cfg)
+
+	authMiddleware := authmiddleware.New[*model.Payload](servise.JWT, &authmiddleware.Config{
+		Realm: "medods",
+	})
 
 	r := gin.New()
 	r.Use(gin.Recovery())
@@ -23,17 +31,53 @@ func NewRouter(servise *service.Manager, l logger.Interface) *gin.Engine {
 	api := r.Group("/api/v1")
 
 	auth := api.Group("/auth")
-	auth.GET("/login/:user_id", authRoutes.login)
+	auth.POST("/login", authRoutes.login)
+	if cfg.DevMode {
+		auth.GET("/login/:user_id", authRoutes.loginByID)
+	}
+	auth.POST("/login/mfa", authRoutes.verifyMFA)
 	auth.POST("/refresh", authRoutes.refresh)
+	auth.POST("/logout", authMiddleware.Gin(), authRoutes.logout)
+	// tokens of oauth clients may only log out of their own session, not manage account
+	auth.POST("/logout-all", authMiddleware.Gin(), firstPartyOnly, authRoutes.logoutAll)
+	auth.POST("/introspect", authRoutes.introspect)
+	auth.POST("/password/forgot", authRoutes.forgotPassword)
+	auth.POST("/password/reset", authRoutes.resetPassword)
+	auth.POST("/magic-link", authRoutes.sendMagicLink)
+	auth.GET("/magic-link/consume", authRoutes.consumeMagicLink)
+
+	webAuthn := auth.Group("/webauthn")
+	webAuthn.POST("/register/begin", authMiddleware.Gin(), firstPartyOnly, webAuthnRoutes.beginRegistration)
+	webAuthn.POST("/register/finish", authMiddleware.Gin(), firstPartyOnly, webAuthnRoutes.finishRegistration)
+	webAuthn.POST("/login/begin", webAuthnRoutes.beginLogin)
+	webAuthn.POST("/login/finish", webAuthnRoutes.finishLogin)
 
 	user := api.Group("/user")
 	user.POST("/create", userRoutes.createUser)
-	user.GET("/list", userRoutes.listUser)
+	user.GET("/verify", userRoutes.verifyEmail)
+	user.POST("/password", authMiddleware.Gin(), firstPartyOnly, userRoutes.changePassword)
+	user.POST("/2fa/enroll", authMiddleware.Gin(), firstPartyOnly, userRoutes.enrollTOTP)
+	user.POST("/2fa/confirm", authMiddleware.Gin(), firstPartyOnly, userRoutes.confirmTOTP)
+
+	oauth := api.Group("/oauth")
+	oauth.GET("/authorize", authMiddleware.Gin(), oauthRoutes.authorize)
+	oauth.POST("/authorize", authMiddleware.Gin(), oauthRoutes.authorize)
+	oauth.POST("/token", oauthRoutes.token)
+	oauth.GET("/userinfo", authMiddleware.Gin(), oauthRoutes.userInfo)
+	oauth.POST("/userinfo", authMiddleware.Gin(), oauthRoutes.userInfo)
+
+	r.GET("/.well-known/openid-configuration", oauthRoutes.discovery)
+	r.GET("/.well-known/jwks.json", oauthRoutes.jwks)
+
+	// routes for testing read and rewrite every user and session without authentication
+	if cfg.DevMode {
+		user.GET("/list", userRoutes.listUser)
 
-	session := api.Group("/session")
-	session.GET("/list", sessionRoutes.listSession)
-	// вообще по хорошему /:id/update но ручка просто для теста
-	session.POST("/update", sessionRoutes.updateSession)
+		session := api.Group("/session")
+		session.GET("/list", sessionRoutes.listSession)
+		// вообще по хорошему /:id/update но ручка просто для теста
+		session.POST("/update", sessionRoutes.updateSession)
+	}
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	return r
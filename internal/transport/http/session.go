@@ -39,7 +39,7 @@ type updateSessionRequest struct {
 // UpdateSession godoc
 //
 //	@Summary		Update session
-//	@Description	Update session in database. Needed for testing.
+//	@Description	Update session in database. Needed for testing. Available only in dev mode.
 //	@Tags			test
 //	@Accept			json
 //	@Produce		json
@@ -84,7 +84,7 @@ func (h sessionRoutes) updateSession(c *gin.Context) {
 // ListSession godoc
 //
 //	@Summary		List session
-//	@Description	Show rows of session table from database.
+//	@Description	Show rows of session table from database. Available only in dev mode.
 //	@Tags			test
 //	@Success		200
 //	@Success		204
@@ -30,7 +30,7 @@ func TestSessionList(t *testing.T) {
 		Auth:    authService,
 		User:    userService,
 		Session: sessionService,
-	}, logger)
+	}, &Config{DevMode: true}, logger)
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"medods/internal/model"
 	"medods/internal/service"
+	"medods/internal/service/mfa"
 	"medods/internal/service/user"
 	"medods/pkg/logger"
 	"net/http"
@@ -16,28 +18,32 @@ import (
 
 type userRoutes struct {
 	userService user.Interface
+	mfaService  mfa.Interface
 	logger      logger.Interface
 }
 
 func newUserRoutes(l logger.Interface, s *service.Manager) *userRoutes {
 	return &userRoutes{
 		userService: s.User,
+		mfaService:  s.MFA,
 		logger:      l,
 	}
 }
 
 type createUserRequest struct {
 	Email string `json:"email" binding:"required,email" example:"mock@gmail.com"`
+	// optional, without password user can't login by email
+	Password string `json:"password" binding:"omitempty,min=8,max=72" example:"password"`
 }
 
 // CreateUser godoc
 //
 //	@Summary		Create user
-//	@Description	Create user, needed for testing with few users
+//	@Description	Create user with unverified email and send verification link to it, needed for testing with few users
 //	@Tags			test
 //	@Accept			json
 //	@Produce		json
-//	@Param			create_request	body	createUserRequest	true	"create user request, email of user" Format(email)
+//	@Param			create_request	body	createUserRequest	true	"create user request, email and optional password of user"
 //	@Success		201
 //	@Failure		400	{object}	errMsg	"Invalid request parameters"
 //	@Failure		500	{object}	errMsg	"Internal server error"
@@ -52,9 +58,13 @@ func (h userRoutes) createUser(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
 	defer cancel()
 
-	if err := h.userService.Create(ctx, model.User{
+	_, err := h.userService.Create(ctx, model.User{
 		Email: req.Email,
-	}); err != nil {
+	}, req.Password)
+	if errors.Is(err, user.ErrWeakPassword) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
 		errorMsg(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -62,10 +72,42 @@ func (h userRoutes) createUser(c *gin.Context) {
 	c.Status(http.StatusCreated)
 }
 
+// VerifyEmail godoc
+//
+//	@Summary		Verify email
+//	@Description	Mark email of user verified by token from link sent on creation, link could be used only once.
+//	@Tags			user
+//	@Param			token	query	string	true	"token from verification link"
+//	@Success		204
+//	@Failure		400	{object}	errMsg	"Invalid, expired or already used token"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/user/verify [get]
+func (h userRoutes) verifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if len(token) == 0 {
+		errorMsg(c, http.StatusBadRequest, fmt.Errorf("token is required"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	err := h.userService.VerifyEmail(ctx, token)
+	if errors.Is(err, user.ErrInvalidVerificationToken) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // ListUsers godoc
 //
 //	@Summary		List user
-//	@Description	Show rows of users table from database.
+//	@Description	Show rows of users table from database. Available only in dev mode.
 //	@Tags			test
 //	@Success		200
 //	@Success		204
@@ -86,3 +128,159 @@ func (h userRoutes) listUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, users)
 }
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required" example:"password"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=72" example:"new_password"`
+}
+
+// ChangePassword godoc
+//
+//	@Summary		Change password
+//	@Description	Change password of user which access token belongs to, old password is required.
+//	@Security		BearerAuth
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			change_request	body	changePasswordRequest	true	"old and new password"
+//	@Success		204
+//	@Failure		400	{object}	errMsg	"Invalid request parameters"
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403	{object}	errMsg	"Invalid old password or token of OAuth client"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/user/password [post]
+func (h userRoutes) changePassword(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	var req changePasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	err := h.userService.ChangePassword(ctx, payload.UserID, req.OldPassword, req.NewPassword)
+	if errors.Is(err, user.ErrInvalidPassword) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if errors.Is(err, user.ErrWeakPassword) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type enrollTOTPResponse struct {
+	// base32, for manual entry in authenticator app
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	// key uri, usually shown as QR code
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/medods:user@gmail.com?secret=JBSWY3DPEHPK3PXP&issuer=medods"`
+}
+
+// EnrollTOTP godoc
+//
+//	@Summary		Start two-factor enrollment
+//	@Description	Generate secret of authenticator app for user which access token belongs to.
+//	@Description	Second factor is enabled only after confirmation with first code, repeated call replaces pending secret.
+//	@Security		BearerAuth
+//	@Tags			user
+//	@Produce		json
+//	@Success		200	{object}	enrollTOTPResponse
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403	{object}	errMsg	"Token of OAuth client"
+//	@Failure		409	{object}	errMsg	"Second factor is already enabled"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/user/2fa/enroll [post]
+func (h userRoutes) enrollTOTP(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	secret, uri, err := h.mfaService.Enroll(ctx, payload.UserID)
+	if errors.Is(err, mfa.ErrAlreadyEnabled) {
+		errorMsg(c, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric" example:"123456"`
+}
+
+type confirmTOTPResponse struct {
+	// single-use codes for login without authenticator app, they are shown only once
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmTOTP godoc
+//
+//	@Summary		Confirm two-factor enrollment
+//	@Description	Enable second factor of user which access token belongs to, if code of pending secret is valid.
+//	@Description	Returns recovery codes, previous recovery codes stop working.
+//	@Security		BearerAuth
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			confirm_request	body		confirmTOTPRequest	true	"code of authenticator app"
+//	@Success		200				{object}	confirmTOTPResponse
+//	@Failure		400				{object}	errMsg	"Invalid request parameters or wrong code"
+//	@Failure		401				{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403				{object}	errMsg	"Token of OAuth client"
+//	@Failure		409				{object}	errMsg	"Enrollment is not started or second factor is already enabled"
+//	@Failure		500				{object}	errMsg	"Internal server error"
+//	@Router			/user/2fa/confirm [post]
+func (h userRoutes) confirmTOTP(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	var req confirmTOTPRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	codes, err := h.mfaService.Confirm(ctx, payload.UserID, req.Code)
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if errors.Is(err, mfa.ErrAlreadyEnabled) || errors.Is(err, mfa.ErrNotEnrolled) {
+		errorMsg(c, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, confirmTOTPResponse{
+		RecoveryCodes: codes,
+	})
+}
@@ -8,13 +8,18 @@ import (
 	"medods/internal/model"
 	"medods/internal/service"
 	mock_auth "medods/internal/service/auth/mock"
+	mock_jwt "medods/internal/service/jwt/mock"
+	"medods/internal/service/mfa"
+	mock_mfa "medods/internal/service/mfa/mock"
 	mock_session "medods/internal/service/session/mock"
+	"medods/internal/service/user"
 	mock_user "medods/internal/service/user/mock"
 	"medods/pkg/logger"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
@@ -32,23 +37,38 @@ func TestUserCreate(t *testing.T) {
 		Auth:    authService,
 		User:    userService,
 		Session: sessionService,
-	}, logger)
+	}, &Config{DevMode: true}, logger)
 
 	defaultEmail := "mock@gmail.com"
+	defaultPassword := "password"
+
+	defaultUser := model.User{ID: 1, Email: defaultEmail}
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
 	tc := []struct {
 		name          string
-		input         string
+		input         createUserRequest
 		buildStubs    func()
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name:  "OK",
-			input: defaultEmail,
+			input: createUserRequest{Email: defaultEmail},
+			buildStubs: func() {
+				userService.EXPECT().Create(gomock.Any(), gomock.Eq(model.User{Email: defaultEmail}), gomock.Eq("")).Times(1).Return(defaultUser, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name:  "OK with password",
+			input: createUserRequest{Email: defaultEmail, Password: defaultPassword},
 			buildStubs: func() {
-				userService.EXPECT().Create(gomock.Any(), gomock.Eq(model.User{Email: defaultEmail})).Times(1).Return(nil)
+				// note: password is set by the same insert
+				userService.EXPECT().Create(gomock.Any(), gomock.Eq(model.User{Email: defaultEmail}), gomock.Eq(defaultPassword)).Times(1).Return(defaultUser, nil)
+				userService.EXPECT().SetPassword(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				assert.Equal(t, http.StatusCreated, recorder.Code)
@@ -56,7 +76,7 @@ func TestUserCreate(t *testing.T) {
 		},
 		{
 			name:       "error empty required field in request",
-			input:      "",
+			input:      createUserRequest{},
 			buildStubs: func() {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				assert.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -64,7 +84,15 @@ func TestUserCreate(t *testing.T) {
 		},
 		{
 			name:       "error incorrect email field format in request",
-			input:      "incorrect",
+			input:      createUserRequest{Email: "incorrect"},
+			buildStubs: func() {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:       "error too short password",
+			input:      createUserRequest{Email: defaultEmail, Password: "short"},
 			buildStubs: func() {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				assert.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -72,21 +100,31 @@ func TestUserCreate(t *testing.T) {
 		},
 		{
 			name:  "error unexpected user create",
-			input: defaultEmail,
+			input: createUserRequest{Email: defaultEmail},
 			buildStubs: func() {
-				userService.EXPECT().Create(gomock.Any(), gomock.Eq(model.User{Email: defaultEmail})).Times(1).Return(unexpectedError)
+				userService.EXPECT().Create(gomock.Any(), gomock.Eq(model.User{Email: defaultEmail}), gomock.Any()).Times(1).Return(model.User{}, unexpectedError)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
+		{
+			name:  "error weak password",
+			input: createUserRequest{Email: defaultEmail, Password: defaultPassword},
+			buildStubs: func() {
+				userService.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(model.User{}, user.ErrWeakPassword)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
 	}
 
 	for _, test := range tc {
 		t.Run(test.name, func(t *testing.T) {
 			test.buildStubs()
 
-			j, err := json.Marshal(createUserRequest{test.input})
+			j, err := json.Marshal(test.input)
 			assert.NoError(t, err)
 
 			rec := httptest.NewRecorder()
@@ -112,7 +150,7 @@ func TestUserList(t *testing.T) {
 		Auth:    authService,
 		User:    userService,
 		Session: sessionService,
-	}, logger)
+	}, &Config{DevMode: true}, logger)
 
 	unexpectedError := fmt.Errorf("unexpected error")
 
@@ -163,3 +201,429 @@ func TestUserList(t *testing.T) {
 		})
 	}
 }
+
+func TestUserChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		JWT:     jwtMaker,
+	}, &Config{DevMode: true}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	defaultRequest := changePasswordRequest{
+		OldPassword: "old_password",
+		NewPassword: "new_password",
+	}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         changePasswordRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Eq(defaultRequest.OldPassword), gomock.Eq(defaultRequest.NewPassword)).
+					Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:  "error token of oauth client",
+			input: defaultRequest,
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ClientID, cpPayload.Scope = "spa", "openid" // note
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid token",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, nil, jwt.ErrTokenExpired)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "error weak new password",
+			input: changePasswordRequest{
+				OldPassword: defaultRequest.OldPassword,
+				NewPassword: "short", // note: too short
+			},
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid old password",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(user.ErrInvalidPassword)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "error unexpected change password",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				userService.EXPECT().ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/user/password", bytes.NewBuffer(j))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultAToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestUserVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+	}, &Config{}, logger)
+
+	defaultToken := "token.signature"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultToken,
+			buildStubs: func() {
+				userService.EXPECT().VerifyEmail(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:  "error empty token",
+			input: "", // note: empty
+			buildStubs: func() {
+				userService.EXPECT().VerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid token",
+			input: defaultToken,
+			buildStubs: func() {
+				userService.EXPECT().VerifyEmail(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(user.ErrInvalidVerificationToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultToken,
+			buildStubs: func() {
+				userService.EXPECT().VerifyEmail(gomock.Any(), gomock.Eq(defaultToken)).Times(1).Return(unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/user/verify?token="+test.input, nil)
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestUserEnrollTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		JWT:     jwtMaker,
+		MFA:     mfaService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	defaultSecret := "JBSWY3DPEHPK3PXP"
+	defaultURI := "otpauth://totp/medods:user@gmail.com?secret=JBSWY3DPEHPK3PXP"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Enroll(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultSecret, defaultURI, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res enrollTOTPResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultSecret, res.Secret)
+				assert.Equal(t, defaultURI, res.OTPAuthURI)
+			},
+		},
+		{
+			name: "error token of oauth client",
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ClientID, cpPayload.Scope = "spa", "openid" // note
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				mfaService.EXPECT().Enroll(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "error invalid token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, nil, jwt.ErrTokenExpired)
+				mfaService.EXPECT().Enroll(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "error already enabled",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Enroll(gomock.Any(), gomock.Any()).Times(1).Return("", "", mfa.ErrAlreadyEnabled)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Enroll(gomock.Any(), gomock.Any()).Times(1).Return("", "", unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/user/2fa/enroll", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultAToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestUserConfirmTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	userService := mock_user.NewMockInterface(ctrl)
+	sessionService := mock_session.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	mfaService := mock_mfa.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		User:    userService,
+		Session: sessionService,
+		JWT:     jwtMaker,
+		MFA:     mfaService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	defaultRequest := confirmTOTPRequest{Code: "123456"}
+	defaultCodes := []string{"ABCD-EFGH-IJKL-MNOP"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         confirmTOTPRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Confirm(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Eq(defaultRequest.Code)).Times(1).Return(defaultCodes, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res confirmTOTPResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultCodes, res.RecoveryCodes)
+			},
+		},
+		{
+			name:  "error malformed code",
+			input: confirmTOTPRequest{Code: "12ab"}, // note: not 6 digits
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Confirm(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error wrong code",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Confirm(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, mfa.ErrInvalidCode)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error not enrolled",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Confirm(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, mfa.ErrNotEnrolled)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				mfaService.EXPECT().Confirm(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			j, err := json.Marshal(test.input)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/user/2fa/confirm", bytes.NewBuffer(j))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultAToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/service"
+	"medods/internal/service/auth"
+	"medods/internal/service/passkey"
+	"medods/pkg/logger"
+	"medods/pkg/webauthn"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webAuthnRoutes struct {
+	authService    auth.Interface
+	passkeyService passkey.Interface
+	logger         logger.Interface
+}
+
+func newWebAuthnRoutes(l logger.Interface, s *service.Manager) *webAuthnRoutes {
+	return &webAuthnRoutes{
+		authService:    s.Auth,
+		passkeyService: s.Passkey,
+
+		logger: l,
+	}
+}
+
+// options are wrapped as in argument of navigator.credentials.create() and get()
+type creationOptionsResponse struct {
+	PublicKey webauthn.CreationOptions `json:"publicKey"`
+}
+
+type requestOptionsResponse struct {
+	PublicKey webauthn.RequestOptions `json:"publicKey"`
+}
+
+// BeginPasskeyRegistration godoc
+//
+//	@Summary		Start passkey registration
+//	@Description	Return options of navigator.credentials.create() for user which access token belongs to.
+//	@Description	Challenge works once, registration must be finished before it expires.
+//	@Security		BearerAuth
+//	@Tags			webauthn
+//	@Produce		json
+//	@Success		200	{object}	creationOptionsResponse
+//	@Failure		401	{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403	{object}	errMsg	"Token of OAuth client"
+//	@Failure		500	{object}	errMsg	"Internal server error"
+//	@Router			/auth/webauthn/register/begin [post]
+func (h webAuthnRoutes) beginRegistration(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	options, err := h.passkeyService.BeginRegistration(ctx, payload.UserID)
+	if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, creationOptionsResponse{PublicKey: options})
+}
+
+// FinishPasskeyRegistration godoc
+//
+//	@Summary		Finish passkey registration
+//	@Description	Verify credential created by authenticator and save it as passkey of user which access token belongs to.
+//	@Security		BearerAuth
+//	@Tags			webauthn
+//	@Accept			json
+//	@Produce		json
+//	@Param			credential	body		webauthn.AttestationResponse	true	"PublicKeyCredential returned by navigator.credentials.create(), binary fields are base64url"
+//	@Success		201			{object}	model.WebAuthnCredential
+//	@Failure		400			{object}	errMsg	"Invalid request parameters, challenge or credential"
+//	@Failure		401			{object}	errMsg	"Unauthorized - invalid token"
+//	@Failure		403			{object}	errMsg	"Token of OAuth client"
+//	@Failure		409			{object}	errMsg	"Passkey is already registered"
+//	@Failure		500			{object}	errMsg	"Internal server error"
+//	@Router			/auth/webauthn/register/finish [post]
+func (h webAuthnRoutes) finishRegistration(c *gin.Context) {
+	payload, ok := tokenPayload(c)
+	if !ok {
+		errorMsg(c, http.StatusUnauthorized, fmt.Errorf("authorization token is empty"))
+		return
+	}
+
+	var req webauthn.AttestationResponse
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	credential, err := h.passkeyService.FinishRegistration(ctx, payload.UserID, req)
+	if errors.Is(err, passkey.ErrInvalidChallenge) || errors.Is(err, passkey.ErrInvalidCredential) {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	} else if errors.Is(err, passkey.ErrCredentialExists) {
+		errorMsg(c, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+type beginPasskeyLoginRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@gmail.com"`
+}
+
+// BeginPasskeyLogin godoc
+//
+//	@Summary		Start login with passkey
+//	@Description	Return options of navigator.credentials.get() with passkeys of user.
+//	@Description	Unknown email gets the same response, so it can't be used to check whether user exists.
+//	@Tags			webauthn
+//	@Accept			json
+//	@Produce		json
+//	@Param			login_request	body		beginPasskeyLoginRequest	true	"email of user"
+//	@Success		200				{object}	requestOptionsResponse
+//	@Failure		400				{object}	errMsg	"Invalid request parameters"
+//	@Failure		500				{object}	errMsg	"Internal server error"
+//	@Router			/auth/webauthn/login/begin [post]
+func (h webAuthnRoutes) beginLogin(c *gin.Context) {
+	var req beginPasskeyLoginRequest
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	options, err := h.passkeyService.BeginLogin(ctx, req.Email)
+	if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requestOptionsResponse{PublicKey: options})
+}
+
+// FinishPasskeyLogin godoc
+//
+//	@Summary		Login with passkey
+//	@Description	Verify assertion of passkey, create session and return new pair access and refresh tokens.
+//	@Description	Authenticator verifies user itself, so second factor is not asked.
+//	@Tags			webauthn
+//	@Accept			json
+//	@Produce		json
+//	@Param			credential	body		webauthn.AssertionResponse	true	"PublicKeyCredential returned by navigator.credentials.get(), binary fields are base64url"
+//	@Success		200			{object}	loginResponse
+//	@Failure		400			{object}	errMsg	"Invalid request parameters"
+//	@Failure		401			{object}	errMsg	"Invalid, expired or used challenge or invalid passkey"
+//	@Failure		403			{object}	errMsg	"Email is not verified"
+//	@Failure		500			{object}	errMsg	"Internal server error"
+//	@Router			/auth/webauthn/login/finish [post]
+func (h webAuthnRoutes) finishLogin(c *gin.Context) {
+	var req webauthn.AssertionResponse
+	if err := c.BindJSON(&req); err != nil {
+		errorMsg(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Copy(), 3*time.Second)
+	defer cancel()
+
+	uid, err := h.passkeyService.FinishLogin(ctx, req)
+	if errors.Is(err, passkey.ErrInvalidChallenge) || errors.Is(err, passkey.ErrInvalidCredential) {
+		errorMsg(c, http.StatusUnauthorized, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	aToken, rToken, err := h.authService.CreateSession(ctx, uid, c.ClientIP(), model.AMRHardwareKey, model.AMRMFA)
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		errorMsg(c, http.StatusForbidden, err)
+		return
+	} else if err != nil {
+		errorMsg(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{
+		AccessToken:  aToken,
+		RefreshToken: rToken,
+	})
+}
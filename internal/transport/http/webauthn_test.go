@@ -0,0 +1,418 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"medods/internal/model"
+	"medods/internal/service"
+	"medods/internal/service/auth"
+	mock_auth "medods/internal/service/auth/mock"
+	mock_jwt "medods/internal/service/jwt/mock"
+	"medods/internal/service/passkey"
+	mock_passkey "medods/internal/service/passkey/mock"
+	"medods/pkg/logger"
+	"medods/pkg/webauthn"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultAttestation = webauthn.AttestationResponse{
+	RawID: []byte{1},
+	Type:  "public-key",
+	Response: webauthn.AuthenticatorAttestationResponse{
+		ClientDataJSON:    []byte("{}"),
+		AttestationObject: []byte{0xa0},
+	},
+}
+
+var defaultAssertion = webauthn.AssertionResponse{
+	RawID: []byte{1},
+	Type:  "public-key",
+	Response: webauthn.AuthenticatorAssertionResponse{
+		ClientDataJSON:    []byte("{}"),
+		AuthenticatorData: []byte{2},
+		Signature:         []byte{3},
+	},
+}
+
+func TestWebAuthnBeginRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	passkeyService := mock_passkey.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		JWT:     jwtMaker,
+		Passkey: passkeyService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	defaultOptions := webauthn.CreationOptions{Challenge: []byte("challenge")}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().BeginRegistration(gomock.Any(), gomock.Eq(defaultPayload.UserID)).Times(1).Return(defaultOptions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res map[string]map[string]interface{}
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, "Y2hhbGxlbmdl", res["publicKey"]["challenge"]) // note: base64url
+			},
+		},
+		{
+			name: "error token of oauth client",
+			buildStubs: func() {
+				cpPayload := defaultPayload
+				cpPayload.ClientID, cpPayload.Scope = "spa", "openid" // note
+
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &cpPayload, nil)
+				passkeyService.EXPECT().BeginRegistration(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "error invalid token",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, nil, jwt.ErrTokenExpired)
+				passkeyService.EXPECT().BeginRegistration(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().BeginRegistration(gomock.Any(), gomock.Any()).Times(1).Return(webauthn.CreationOptions{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/webauthn/register/begin", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultAToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestWebAuthnFinishRegistration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	jwtMaker := mock_jwt.NewMockInterface(ctrl)
+	passkeyService := mock_passkey.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		JWT:     jwtMaker,
+		Passkey: passkeyService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultPayload := model.Payload{UserID: 1, SessionID: 2, IP: "::1"}
+
+	defaultCredential := model.WebAuthnCredential{ID: 3, UserID: 1, CredentialID: "AQ"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: string(mustMarshal(t, defaultAttestation)),
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().FinishRegistration(gomock.Any(), gomock.Eq(defaultPayload.UserID), gomock.Eq(defaultAttestation)).
+					Times(1).Return(defaultCredential, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res model.WebAuthnCredential
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultCredential, res)
+			},
+		},
+		{
+			name:  "error not base64url",
+			input: `{"rawId":"+/8=","type":"public-key","response":{"clientDataJSON":"e30","attestationObject":"oA"}}`, // note
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().FinishRegistration(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error invalid credential",
+			input: string(mustMarshal(t, defaultAttestation)),
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().FinishRegistration(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{}, passkey.ErrInvalidCredential)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "error already registered",
+			input: string(mustMarshal(t, defaultAttestation)),
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().FinishRegistration(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{}, passkey.ErrCredentialExists)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: string(mustMarshal(t, defaultAttestation)),
+			buildStubs: func() {
+				jwtMaker.EXPECT().VerifyToken(gomock.Any(), gomock.Eq(defaultAToken)).Times(1).Return(nil, &defaultPayload, nil)
+				passkeyService.EXPECT().FinishRegistration(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(model.WebAuthnCredential{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/webauthn/register/finish", bytes.NewBufferString(test.input))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", defaultAToken))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestWebAuthnBeginLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	passkeyService := mock_passkey.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		Passkey: passkeyService,
+	}, &Config{}, logger)
+
+	defaultRequest := beginPasskeyLoginRequest{Email: "user@gmail.com"}
+	defaultOptions := webauthn.RequestOptions{Challenge: []byte("challenge"), RPID: "localhost"}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		input         beginPasskeyLoginRequest
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			input: defaultRequest,
+			buildStubs: func() {
+				passkeyService.EXPECT().BeginLogin(gomock.Any(), gomock.Eq(defaultRequest.Email)).Times(1).Return(defaultOptions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res requestOptionsResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultOptions.Challenge, res.PublicKey.Challenge)
+				assert.Equal(t, "localhost", res.PublicKey.RPID)
+			},
+		},
+		{
+			name:  "error invalid email",
+			input: beginPasskeyLoginRequest{Email: "user"}, // note
+			buildStubs: func() {
+				passkeyService.EXPECT().BeginLogin(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "unexpected error",
+			input: defaultRequest,
+			buildStubs: func() {
+				passkeyService.EXPECT().BeginLogin(gomock.Any(), gomock.Any()).Times(1).Return(webauthn.RequestOptions{}, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/webauthn/login/begin", bytes.NewBuffer(mustMarshal(t, test.input)))
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestWebAuthnFinishLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	authService := mock_auth.NewMockInterface(ctrl)
+	passkeyService := mock_passkey.NewMockInterface(ctrl)
+
+	logger := logger.New("debug", true)
+
+	router := NewRouter(&service.Manager{
+		Auth:    authService,
+		Passkey: passkeyService,
+	}, &Config{}, logger)
+
+	defaultAToken := "access_token"
+	defaultRToken := "refresh_token"
+	defaultIP := "192.0.2.1"
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	tc := []struct {
+		name          string
+		buildStubs    func()
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func() {
+				passkeyService.EXPECT().FinishLogin(gomock.Any(), gomock.Eq(defaultAssertion)).Times(1).Return(1, nil)
+				authService.EXPECT().CreateSession(gomock.Any(), gomock.Eq(1), gomock.Eq(defaultIP), model.AMRHardwareKey, model.AMRMFA).
+					Times(1).Return(defaultAToken, defaultRToken, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+
+				var res loginResponse
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				assert.Equal(t, defaultAToken, res.AccessToken)
+				assert.Equal(t, defaultRToken, res.RefreshToken)
+			},
+		},
+		{
+			name: "error invalid challenge",
+			buildStubs: func() {
+				passkeyService.EXPECT().FinishLogin(gomock.Any(), gomock.Any()).Times(1).Return(0, passkey.ErrInvalidChallenge)
+				authService.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "error invalid passkey",
+			buildStubs: func() {
+				passkeyService.EXPECT().FinishLogin(gomock.Any(), gomock.Any()).Times(1).Return(0, passkey.ErrInvalidCredential)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "error email not verified",
+			buildStubs: func() {
+				passkeyService.EXPECT().FinishLogin(gomock.Any(), gomock.Any()).Times(1).Return(1, nil)
+				authService.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return("", "", auth.ErrEmailNotVerified)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "unexpected error",
+			buildStubs: func() {
+				passkeyService.EXPECT().FinishLogin(gomock.Any(), gomock.Any()).Times(1).Return(0, unexpectedError)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			test.buildStubs()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/webauthn/login/finish", bytes.NewBuffer(mustMarshal(t, defaultAssertion)))
+			req.RemoteAddr = defaultIP + ":1234"
+
+			router.ServeHTTP(rec, req)
+
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	j, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return j
+}
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON "sessions"(user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "session_rotations";
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS revoked_at;
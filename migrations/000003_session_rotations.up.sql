@@ -0,0 +1,13 @@
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS revoked_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "session_rotations" (
+    id SERIAL PRIMARY KEY,
+    session_id INT NOT NULL,
+    access_token_id VARCHAR NOT NULL,
+    refresh_token_hash VARCHAR NOT NULL,
+    rotated_at BIGINT NOT NULL,
+
+    FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS session_rotations_session_jti_idx ON "session_rotations"(session_id, access_token_id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+    jti VARCHAR PRIMARY KEY,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON "revoked_tokens"(expires_at);
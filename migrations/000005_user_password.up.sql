@@ -0,0 +1,2 @@
+-- empty hash means that password is not set and password login is impossible
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS password_hash VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+-- 0 means that email is not verified yet, users created before verification flow are trusted
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS email_verified_at BIGINT NOT NULL DEFAULT 0;
+UPDATE "users" SET email_verified_at = EXTRACT(EPOCH FROM NOW())::BIGINT;
+
+-- single-use tokens sent to user by email, only sha256 of token is stored
+CREATE TABLE IF NOT EXISTS "one_time_tokens" (
+    token_hash VARCHAR PRIMARY KEY,
+    purpose VARCHAR NOT NULL,
+    user_id INT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS one_time_tokens_user_id_idx ON "one_time_tokens"(user_id);
@@ -0,0 +1,17 @@
+-- secret is encrypted by application key, 0 in totp_enabled_at means that enrollment is not confirmed yet,
+-- last step keeps the period of the last accepted code, so the same code can't be replayed
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS totp_secret VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS totp_enabled_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- single-use codes for login without authenticator, only sha256 of code is stored
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash VARCHAR NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON "recovery_codes"(user_id);
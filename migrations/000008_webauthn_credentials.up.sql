@@ -0,0 +1,15 @@
+-- passkeys of users, credential id is base64url of id given by authenticator,
+-- public key is COSE_Key, sign count grows on every login if authenticator supports counter
+CREATE TABLE IF NOT EXISTS "webauthn_credentials" (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    credential_id VARCHAR NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT NOT NULL DEFAULT 0,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON "webauthn_credentials"(user_id);
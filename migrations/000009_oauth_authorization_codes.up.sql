@@ -0,0 +1,17 @@
+-- authorization codes of OAuth 2.0 clients, only sha256 of code is stored,
+-- code_challenge is S256 of PKCE verifier, redirect_uri is empty when client omitted it in authorization request
+CREATE TABLE IF NOT EXISTS "oauth_authorization_codes" (
+    code_hash VARCHAR PRIMARY KEY,
+    client_id VARCHAR NOT NULL,
+    user_id INT NOT NULL,
+    redirect_uri VARCHAR NOT NULL DEFAULT '',
+    code_challenge VARCHAR NOT NULL,
+    scope VARCHAR NOT NULL DEFAULT '',
+    amr VARCHAR NOT NULL DEFAULT '',
+    expires_at BIGINT NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS oauth_authorization_codes_user_id_idx ON "oauth_authorization_codes"(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE "oauth_authorization_codes" DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE "oauth_authorization_codes" DROP COLUMN IF EXISTS nonce;
@@ -0,0 +1,3 @@
+-- nonce of OpenID Connect request and time of login of user are put into id token issued for code
+ALTER TABLE "oauth_authorization_codes" ADD COLUMN IF NOT EXISTS nonce VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "oauth_authorization_codes" ADD COLUMN IF NOT EXISTS auth_time BIGINT NOT NULL DEFAULT 0;
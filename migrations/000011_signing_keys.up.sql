@@ -0,0 +1,11 @@
+-- keyring of jwt signing keys shared by all instances, material is private key or secret
+-- sealed with JWT_MASTER_KEY, state is active for the only signing key and verify for others
+CREATE TABLE IF NOT EXISTS "signing_keys" (
+    kid VARCHAR PRIMARY KEY,
+    alg VARCHAR NOT NULL,
+    material TEXT NOT NULL,
+    state VARCHAR NOT NULL,
+    created_at BIGINT NOT NULL,
+    promoted_at BIGINT NOT NULL DEFAULT 0,
+    demoted_at BIGINT NOT NULL DEFAULT 0
+);
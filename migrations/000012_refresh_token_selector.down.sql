@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS session_rotations_refresh_token_selector_idx;
+ALTER TABLE "session_rotations" DROP COLUMN IF EXISTS refresh_token_selector;
+
+DROP INDEX IF EXISTS sessions_refresh_token_selector_idx;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS scope;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS client_id;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS amr;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS ip;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS refresh_token_selector;
@@ -0,0 +1,18 @@
+-- refresh token is <selector>.<secret>, session is found by selector without access token,
+-- so session keeps ip and grant which were put into access token
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS refresh_token_selector VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS ip VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS amr VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS client_id VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS scope VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS auth_time BIGINT NOT NULL DEFAULT 0;
+
+-- sessions opened before have no selector until their first refresh
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_refresh_token_selector_idx ON "sessions"(refresh_token_selector)
+    WHERE refresh_token_selector <> '';
+
+-- selector of rotated refresh token finds session when the token is reused
+ALTER TABLE "session_rotations" ADD COLUMN IF NOT EXISTS refresh_token_selector VARCHAR NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS session_rotations_refresh_token_selector_idx ON "session_rotations"(refresh_token_selector)
+    WHERE refresh_token_selector <> '';
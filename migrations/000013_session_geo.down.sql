@@ -0,0 +1,4 @@
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS as_org;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS asn;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS city;
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS country;
@@ -0,0 +1,5 @@
+-- location of session ip resolved by geoip database, empty without it
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS country VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS city VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS asn BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS as_org VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS one_time_tokens_expires_at_idx;
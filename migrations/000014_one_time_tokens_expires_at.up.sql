@@ -0,0 +1,2 @@
+-- expired tokens are deleted on schedule
+CREATE INDEX IF NOT EXISTS one_time_tokens_expires_at_idx ON "one_time_tokens"(expires_at);
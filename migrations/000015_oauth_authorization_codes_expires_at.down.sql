@@ -0,0 +1 @@
+DROP INDEX IF EXISTS oauth_authorization_codes_expires_at_idx;
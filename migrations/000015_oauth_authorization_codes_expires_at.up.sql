@@ -0,0 +1,2 @@
+-- expired codes are deleted on schedule
+CREATE INDEX IF NOT EXISTS oauth_authorization_codes_expires_at_idx ON "oauth_authorization_codes"(expires_at);
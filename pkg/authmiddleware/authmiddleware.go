@@ -0,0 +1,156 @@
+package authmiddleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenMissing = fmt.Errorf("authorization token is empty")
+)
+
+// Verifier check token and return its claims, e.g. jwt.Interface of auth service
+type Verifier[T any] interface {
+	VerifyToken(ctx context.Context, tokenString string) (token *jwt.Token, payload T, err error)
+}
+
+// Middleware authenticate requests by bearer access token (RFC 6750)
+// and put payload of token to request context
+type Middleware[T any] struct {
+	verifier Verifier[T]
+	realm    string
+}
+
+func New[T any](verifier Verifier[T], cfg *Config) *Middleware[T] {
+	m := &Middleware[T]{
+		verifier: verifier,
+		realm:    "api",
+	}
+
+	if cfg != nil && cfg.Realm != "" {
+		m.realm = cfg.Realm
+	}
+
+	return m
+}
+
+type ctxKey struct{}
+
+const ginKey = "authmiddleware.payload"
+
+type errMsg struct {
+	Error string `json:"error"`
+}
+
+// Gin middleware, payload is available by PayloadFromGin and PayloadFromContext of c.Request
+func (m *Middleware[T]) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, code, err := m.authenticate(c.Request)
+		if err != nil {
+			m.challenge(c.Writer.Header(), err)
+			c.AbortWithStatusJSON(code, errMsg{err.Error()})
+			return
+		}
+
+		c.Set(ginKey, payload)
+		c.Request = c.Request.WithContext(WithPayload(c.Request.Context(), payload))
+		c.Next()
+	}
+}
+
+// Handler is net/http middleware, payload is available by PayloadFromContext
+func (m *Middleware[T]) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, code, err := m.authenticate(r)
+		if err != nil {
+			m.challenge(w.Header(), err)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(code)
+			_ = json.NewEncoder(w).Encode(errMsg{err.Error()})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPayload(r.Context(), payload)))
+	})
+}
+
+func WithPayload[T any](ctx context.Context, payload T) context.Context {
+	return context.WithValue(ctx, ctxKey{}, payload)
+}
+
+func PayloadFromContext[T any](ctx context.Context) (T, bool) {
+	payload, ok := ctx.Value(ctxKey{}).(T)
+	return payload, ok
+}
+
+func PayloadFromGin[T any](c *gin.Context) (T, bool) {
+	v, ok := c.Get(ginKey)
+	if !ok {
+		var empty T
+		return empty, false
+	}
+
+	payload, ok := v.(T)
+	return payload, ok
+}
+
+// authenticate return payload of token, or status code which request must be rejected with
+func (m *Middleware[T]) authenticate(r *http.Request) (payload T, code int, err error) {
+	token := BearerToken(r)
+	if len(token) == 0 {
+		return payload, http.StatusUnauthorized, ErrTokenMissing
+	}
+
+	_, payload, err = m.verifier.VerifyToken(r.Context(), token)
+	if IsTokenInvalid(err) {
+		return payload, http.StatusUnauthorized, err
+	} else if err != nil {
+		return payload, http.StatusInternalServerError, err
+	}
+
+	return payload, http.StatusOK, nil
+}
+
+// challenge set WWW-Authenticate header, see RFC 6750 section 3
+func (m *Middleware[T]) challenge(h http.Header, err error) {
+	switch {
+	case errors.Is(err, ErrTokenMissing):
+		h.Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q`, m.realm))
+	case errors.Is(err, jwt.ErrTokenExpired):
+		h.Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="invalid_token", error_description="token is expired"`, m.realm))
+	case errors.Is(err, jwt.ErrTokenInvalidId):
+		h.Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="invalid_token", error_description="token is revoked"`, m.realm))
+	case IsTokenInvalid(err):
+		h.Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="invalid_token", error_description="token is invalid"`, m.realm))
+	}
+}
+
+// BearerToken return token from Authorization header, empty if scheme is not Bearer
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// IsTokenInvalid tells that token was rejected by validation, not by failure of verifier or its storage
+func IsTokenInvalid(err error) bool {
+	return errors.Is(err, jwt.ErrTokenMalformed) ||
+		errors.Is(err, jwt.ErrTokenUnverifiable) ||
+		errors.Is(err, jwt.ErrTokenSignatureInvalid) ||
+		errors.Is(err, jwt.ErrSignatureInvalid) ||
+		errors.Is(err, jwt.ErrTokenExpired) ||
+		errors.Is(err, jwt.ErrTokenNotValidYet) ||
+		errors.Is(err, jwt.ErrTokenUsedBeforeIssued) ||
+		errors.Is(err, jwt.ErrTokenRequiredClaimMissing) ||
+		errors.Is(err, jwt.ErrTokenInvalidClaims) ||
+		errors.Is(err, jwt.ErrTokenInvalidId)
+}
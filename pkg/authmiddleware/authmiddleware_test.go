@@ -0,0 +1,179 @@
+package authmiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	UserID int
+}
+
+type verifierFunc func(tokenString string) (*jwt.Token, *payload, error)
+
+func (f verifierFunc) VerifyToken(_ context.Context, tokenString string) (*jwt.Token, *payload, error) {
+	return f(tokenString)
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	defaultToken := "access_token"
+	defaultPayload := &payload{UserID: 1}
+
+	unexpectedError := fmt.Errorf("unexpected error")
+
+	verifier := func(err error) verifierFunc {
+		return func(tokenString string) (*jwt.Token, *payload, error) {
+			assert.Equal(t, defaultToken, tokenString)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, defaultPayload, nil
+		}
+	}
+
+	tc := []struct {
+		name          string
+		header        string
+		verifier      verifierFunc
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			header:   "Bearer " + defaultToken,
+			verifier: verifier(nil),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "1", recorder.Body.String())
+			},
+		},
+		{
+			name:     "OK lowercase scheme",
+			header:   "bearer " + defaultToken,
+			verifier: verifier(nil),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "error no token",
+			header:   "",
+			verifier: verifier(unexpectedError), // note: must not be called
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, `Bearer realm="test"`, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:     "error other scheme",
+			header:   "Basic " + defaultToken,
+			verifier: verifier(unexpectedError),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, `Bearer realm="test"`, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:     "error expired token",
+			header:   "Bearer " + defaultToken,
+			verifier: verifier(jwt.ErrTokenExpired),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, `Bearer realm="test", error="invalid_token", error_description="token is expired"`, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:     "error revoked token",
+			header:   "Bearer " + defaultToken,
+			verifier: verifier(fmt.Errorf("%w: token is revoked", jwt.ErrTokenInvalidId)),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, `Bearer realm="test", error="invalid_token", error_description="token is revoked"`, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:     "error invalid signature",
+			header:   "Bearer " + defaultToken,
+			verifier: verifier(jwt.ErrTokenSignatureInvalid),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+				assert.Equal(t, `Bearer realm="test", error="invalid_token", error_description="token is invalid"`, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name:     "error unexpected verify",
+			header:   "Bearer " + defaultToken,
+			verifier: verifier(unexpectedError),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+	}
+
+	for _, test := range tc {
+		m := New[*payload](test.verifier, &Config{Realm: "test"})
+
+		t.Run("gin "+test.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", m.Gin(), func(c *gin.Context) {
+				p, ok := PayloadFromGin[*payload](c)
+				assert.True(t, ok)
+
+				fromCtx, ok := PayloadFromContext[*payload](c.Request.Context())
+				assert.True(t, ok)
+				assert.Equal(t, p, fromCtx)
+
+				c.String(http.StatusOK, "%d", p.UserID)
+			})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+
+			r.ServeHTTP(rec, req)
+			test.checkResponse(t, rec)
+		})
+
+		t.Run("http "+test.name, func(t *testing.T) {
+			h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p, ok := PayloadFromContext[*payload](r.Context())
+				assert.True(t, ok)
+
+				fmt.Fprintf(w, "%d", p.UserID)
+			}))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+
+			h.ServeHTTP(rec, req)
+			test.checkResponse(t, rec)
+		})
+	}
+}
+
+func TestPayloadFromContextMissing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	_, ok := PayloadFromGin[*payload](c)
+	assert.False(t, ok)
+
+	_, ok = PayloadFromContext[*payload](c.Request.Context())
+	assert.False(t, ok)
+}
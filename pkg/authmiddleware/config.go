@@ -0,0 +1,6 @@
+package authmiddleware
+
+type Config struct {
+	// realm reported in WWW-Authenticate header
+	Realm string
+}
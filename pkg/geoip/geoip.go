@@ -0,0 +1,154 @@
+// Package geoip resolves ip addresses to location and autonomous system by local MaxMind DB file,
+// e.g. GeoLite2-City, GeoLite2-ASN or database which has fields of both.
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"medods/pkg/logger"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is what is known about ip, fields which database doesn't have are empty
+type Record struct {
+	// ISO 3166-1 alpha-2 code, e.g. RU
+	Country string
+	// english name
+	City string
+	// number and organization of autonomous system
+	ASN          uint
+	Organization string
+	// nil if database has no coordinates of ip
+	Location *Location
+}
+
+// Location is approximate coordinates of ip
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// km, ip is somewhere within radius around coordinates
+	AccuracyRadius uint
+}
+
+// DB is database file which is reloaded when it's replaced, e.g. by geoipupdate
+type DB struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *Reader
+	modTime time.Time
+	size    int64
+}
+
+// Open load database file, file is required to exist from the start
+func Open(path string) (*DB, error) {
+	db := &DB{path: path}
+	if _, err := db.Reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Reload read file again if its modification time or size changed since last load.
+// Database which failed to load doesn't replace loaded one.
+func (db *DB) Reload() (reloaded bool, err error) {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat geoip database: %w", err)
+	}
+
+	db.mu.RLock()
+	unchanged := db.reader != nil && info.ModTime().Equal(db.modTime) && info.Size() == db.size
+	db.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	buf, err := os.ReadFile(db.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read geoip database: %w", err)
+	}
+	reader, err := NewReader(buf)
+	if err != nil {
+		return false, fmt.Errorf("failed to load geoip database %s: %w", db.path, err)
+	}
+
+	db.mu.Lock()
+	db.reader, db.modTime, db.size = reader, info.ModTime(), info.Size()
+	db.mu.Unlock()
+	return true, nil
+}
+
+// Lookup return record of ip, ok is false if ip isn't in database
+func (db *DB) Lookup(ip net.IP) (Record, bool, error) {
+	db.mu.RLock()
+	reader := db.reader
+	db.mu.RUnlock()
+
+	value, ok, err := reader.Lookup(ip)
+	if err != nil || !ok {
+		return Record{}, false, err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return Record{}, false, fmt.Errorf("%w: record must be map", ErrInvalidDatabase)
+	}
+
+	r := Record{
+		Country:      lookupString(m, "country", "iso_code"),
+		City:         lookupString(m, "city", "names", "en"),
+		Organization: lookupString(m, "autonomous_system_organization"),
+	}
+	// country of ip may be unknown while country of registration isn't, e.g. for anycast networks
+	if r.Country == "" {
+		r.Country = lookupString(m, "registered_country", "iso_code")
+	}
+	if asn, ok := m["autonomous_system_number"].(uint64); ok {
+		r.ASN = uint(asn)
+	}
+	if location, ok := m["location"].(map[string]interface{}); ok {
+		latitude, okLat := location["latitude"].(float64)
+		longitude, okLong := location["longitude"].(float64)
+		if okLat && okLong {
+			radius, _ := location["accuracy_radius"].(uint64)
+			r.Location = &Location{Latitude: latitude, Longitude: longitude, AccuracyRadius: uint(radius)}
+		}
+	}
+	return r, true, nil
+}
+
+// lookupString return string at path of nested maps, empty if there is none
+func lookupString(m map[string]interface{}, path ...string) string {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		m = next
+	}
+	s, _ := m[path[len(path)-1]].(string)
+	return s
+}
+
+// RunReload check database file for changes until ctx is done
+func RunReload(ctx context.Context, db *DB, interval time.Duration, l logger.Interface) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := db.Reload()
+			if err != nil {
+				l.Error("failed to reload geoip database: %s", err.Error())
+			} else if reloaded {
+				l.Info("geoip database %s reloaded", db.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package geoip_test
+
+import (
+	"flag"
+	"medods/pkg/geoip"
+	"medods/pkg/geoip/geoiptest"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/test.mmdb")
+
+const fixturePath = "testdata/test.mmdb"
+
+// networks of testdata/test.mmdb, documentation ranges and private ASNs
+var fixtureNetworks = []geoiptest.Network{
+	{CIDR: "198.51.100.0/24", Record: geoip.Record{Country: "RU", City: "Moscow", ASN: 64500, Organization: "Example Telecom",
+		Location: &geoip.Location{Latitude: 55.7558, Longitude: 37.6173, AccuracyRadius: 20}}},
+	{CIDR: "192.0.2.0/25", Record: geoip.Record{Country: "DE", City: "Berlin", ASN: 64501, Organization: "Example Hosting",
+		Location: &geoip.Location{Latitude: 52.52, Longitude: 13.405, AccuracyRadius: 50}}},
+	{CIDR: "2001:db8:1::/48", Record: geoip.Record{Country: "US", City: "New York", ASN: 64502, Organization: "Example Cloud",
+		Location: &geoip.Location{Latitude: 40.7128, Longitude: -74.006, AccuracyRadius: 100}}},
+	// anycast network, country isn't known
+	{CIDR: "203.0.113.0/24", Record: geoip.Record{ASN: 64503, Organization: "Example Anycast"}},
+}
+
+func TestFixture(t *testing.T) {
+	buf, err := geoiptest.Build(fixtureNetworks)
+	require.NoError(t, err)
+
+	if *update {
+		require.NoError(t, os.WriteFile(fixturePath, buf, 0o644))
+	}
+
+	fixture, err := os.ReadFile(fixturePath)
+	require.NoError(t, err)
+	assert.Equal(t, buf, fixture, "fixture is outdated, run go test ./pkg/geoip -update")
+}
+
+func TestLookup(t *testing.T) {
+	db, err := geoip.Open(fixturePath)
+	require.NoError(t, err)
+
+	tc := []struct {
+		name     string
+		ip       string
+		expected geoip.Record
+		ok       bool
+	}{
+		{
+			name:     "OK ipv4",
+			ip:       "198.51.100.7",
+			expected: fixtureNetworks[0].Record,
+			ok:       true,
+		},
+		{
+			name:     "OK ipv4-mapped ipv6",
+			ip:       "::ffff:198.51.100.7",
+			expected: fixtureNetworks[0].Record,
+			ok:       true,
+		},
+		{
+			name:     "OK ipv6",
+			ip:       "2001:db8:1:ff::1",
+			expected: fixtureNetworks[2].Record,
+			ok:       true,
+		},
+		{
+			name:     "OK only asn",
+			ip:       "203.0.113.1",
+			expected: fixtureNetworks[3].Record,
+			ok:       true,
+		},
+		{
+			name: "other half of network",
+			ip:   "192.0.2.200", // note: 192.0.2.0/25
+		},
+		{
+			name: "unknown ipv4",
+			ip:   "10.0.0.1",
+		},
+		{
+			name: "unknown ipv6",
+			ip:   "2001:db8:2::1",
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			record, ok, err := db.Lookup(net.ParseIP(test.ip))
+			assert.NoError(t, err)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, record)
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.mmdb")
+	ip := net.ParseIP("198.51.100.7")
+
+	write := func(country string, modTime time.Time) {
+		buf, err := geoiptest.Build([]geoiptest.Network{{CIDR: "198.51.100.0/24", Record: geoip.Record{Country: country}}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, buf, 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("RU", start)
+
+	db, err := geoip.Open(path)
+	require.NoError(t, err)
+
+	reloaded, err := db.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	write("DE", start.Add(time.Minute))
+	reloaded, err = db.Reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	record, _, _ := db.Lookup(ip)
+	assert.Equal(t, "DE", record.Country)
+
+	// broken file doesn't replace loaded database
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o644))
+	reloaded, err = db.Reload()
+	assert.ErrorIs(t, err, geoip.ErrInvalidDatabase)
+	assert.False(t, reloaded)
+	record, _, _ = db.Lookup(ip)
+	assert.Equal(t, "DE", record.Country)
+
+	require.NoError(t, os.Remove(path))
+	_, err = db.Reload()
+	assert.Error(t, err)
+
+	_, err = geoip.Open(path)
+	assert.Error(t, err)
+}
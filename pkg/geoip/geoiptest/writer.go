@@ -0,0 +1,219 @@
+// Package geoiptest writes small MaxMind DB files for tests of geoip lookups.
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"medods/pkg/geoip"
+	"net"
+	"sort"
+)
+
+// Network is record of geoip database and network it belongs to
+type Network struct {
+	CIDR   string
+	Record geoip.Record
+}
+
+// DatabaseType is written to metadata of built databases
+const DatabaseType = "medods-test"
+
+// records of search tree before node count is known: node index if positive, as root is never a child,
+// -(offset+1) of data if negative, empty record otherwise
+const emptyRecord = 0
+
+// Build return IPv6 database with 24 bit records where every network has its record,
+// IPv4 networks are put into ::/96 like MaxMind does. Networks must not overlap.
+// Output depends only on networks, so it can be compared with fixture file.
+func Build(networks []Network) ([]byte, error) {
+	nodes := [][2]int{{emptyRecord, emptyRecord}}
+	var data bytes.Buffer
+	// networks of the same record share it
+	offsets := map[string]int{}
+
+	for _, n := range networks {
+		ip, network, err := net.ParseCIDR(n.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		ones, _ := network.Mask.Size()
+		bits := ip.Mask(network.Mask).To16()
+		if ip.To4() != nil {
+			bits = append(make(net.IP, 12), ip.Mask(network.Mask).To4()...)
+			ones += 96
+		}
+		if ones == 0 {
+			return nil, fmt.Errorf("network %s covers every ip", n.CIDR)
+		}
+
+		record := encode(recordData(n.Record))
+		offset, ok := offsets[string(record)]
+		if !ok {
+			offset = data.Len()
+			offsets[string(record)] = offset
+			data.Write(record)
+		}
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := (bits[i/8] >> (7 - i%8)) & 1
+			next := nodes[node][bit]
+			if next < 0 || (i == ones-1 && next != emptyRecord) {
+				return nil, fmt.Errorf("network %s overlaps other network", n.CIDR)
+			}
+			if i == ones-1 {
+				nodes[node][bit] = -(offset + 1)
+				break
+			}
+			if next == emptyRecord {
+				next = len(nodes)
+				nodes = append(nodes, [2]int{emptyRecord, emptyRecord})
+				nodes[node][bit] = next
+			}
+			node = next
+		}
+	}
+
+	nodeCount := len(nodes)
+	if nodeCount+16+data.Len() >= 1<<24 {
+		return nil, fmt.Errorf("database is too big for 24 bit records")
+	}
+
+	var out bytes.Buffer
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			switch {
+			case record == emptyRecord:
+				value = nodeCount
+			case record < 0:
+				value = nodeCount + 16 + (-record - 1)
+			}
+			out.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	out.Write(encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"database_type":               DatabaseType,
+		"description":                 map[string]interface{}{"en": "database for tests"},
+		"ip_version":                  uint16(6),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	}))
+
+	return out.Bytes(), nil
+}
+
+// recordData lay record out like GeoIP2 City and ASN databases do
+func recordData(r geoip.Record) map[string]interface{} {
+	m := map[string]interface{}{}
+	if r.Country != "" {
+		m["country"] = map[string]interface{}{"iso_code": r.Country}
+	}
+	if r.City != "" {
+		m["city"] = map[string]interface{}{"names": map[string]interface{}{"en": r.City}}
+	}
+	if r.ASN != 0 {
+		m["autonomous_system_number"] = uint32(r.ASN)
+	}
+	if r.Organization != "" {
+		m["autonomous_system_organization"] = r.Organization
+	}
+	if r.Location != nil {
+		m["location"] = map[string]interface{}{
+			"accuracy_radius": uint16(r.Location.AccuracyRadius),
+			"latitude":        r.Location.Latitude,
+			"longitude":       r.Location.Longitude,
+		}
+	}
+	return m
+}
+
+// encode write value of data section, map keys are sorted so output is deterministic
+func encode(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(encodeHead(2, len(v)), v...)
+	case uint16:
+		return appendUint(encodeHead(5, uintSize(uint64(v))), uint64(v))
+	case uint32:
+		return appendUint(encodeHead(6, uintSize(uint64(v))), uint64(v))
+	case uint64:
+		return appendUint(encodeHead(9, uintSize(v)), v)
+	case float64:
+		return binary.BigEndian.AppendUint64(encodeHead(3, 8), math.Float64bits(v))
+	case bool:
+		if v {
+			return encodeHead(14, 1)
+		}
+		return encodeHead(14, 0)
+	case []interface{}:
+		out := encodeHead(11, len(v))
+		for _, item := range v {
+			out = append(out, encode(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		out := encodeHead(7, len(v))
+		for _, key := range keys {
+			out = append(out, encode(key)...)
+			out = append(out, encode(v[key])...)
+		}
+		return out
+	default:
+		panic(fmt.Sprintf("geoiptest: unsupported type %T", value))
+	}
+}
+
+// encodeHead write control byte of type, types after 7 are extended, and size
+func encodeHead(typ, size int) []byte {
+	var out []byte
+	var ext []byte
+	switch {
+	case size < 29:
+		out = []byte{byte(size)}
+	case size < 285:
+		out, ext = []byte{29}, []byte{byte(size - 29)}
+	case size < 65821:
+		out, ext = []byte{30}, binary.BigEndian.AppendUint16(nil, uint16(size-285))
+	default:
+		size -= 65821
+		out, ext = []byte{31}, []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+
+	if typ > 7 {
+		out = append(out, byte(typ-7))
+	} else {
+		out[0] |= byte(typ << 5)
+	}
+	return append(out, ext...)
+}
+
+func uintSize(v uint64) int {
+	size := 0
+	for ; v > 0; v >>= 8 {
+		size++
+	}
+	return size
+}
+
+func appendUint(out []byte, v uint64) []byte {
+	for i := uintSize(v) - 1; i >= 0; i-- {
+		out = append(out, byte(v>>(8*i)))
+	}
+	return out
+}
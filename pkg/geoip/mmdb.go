@@ -0,0 +1,338 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// Minimal reader of MaxMind DB format (https://maxmind.github.io/MaxMind-DB/):
+// binary search tree of ip bits followed by data section with records.
+// Data cache containers and end markers are never written to records, so they are rejected.
+
+// nesting limit protects decoder from stack exhaustion by crafted file
+const maxDataDepth = 16
+
+var ErrInvalidDatabase = fmt.Errorf("invalid maxmind database")
+
+// metadata is at the end of file after this marker, marker may be found in last 128KiB only
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+const maxMetadataSize = 128 * 1024
+
+// 16 zero bytes separate search tree from data section
+const dataSectionSeparator = 16
+
+// Reader look up records of ip in database loaded into memory
+type Reader struct {
+	buf          []byte
+	data         []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	// node where IPv4 addresses start in IPv6 tree, they are stored as ::a.b.c.d
+	ipv4Start uint
+}
+
+// NewReader parse database, buf must not be changed after that
+func NewReader(buf []byte) (*Reader, error) {
+	from := 0
+	if len(buf) > maxMetadataSize {
+		from = len(buf) - maxMetadataSize
+	}
+	i := bytes.LastIndex(buf[from:], metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	metaStart := from + i + len(metadataMarker)
+
+	value, _, err := decodeData(buf[metaStart:], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	meta, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata must be map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{buf: buf}
+	r.nodeCount, _ = metaUint(meta, "node_count")
+	r.recordSize, _ = metaUint(meta, "record_size")
+	r.ipVersion, _ = metaUint(meta, "ip_version")
+	r.databaseType, _ = meta["database_type"].(string)
+	if major, _ := metaUint(meta, "binary_format_major_version"); major != 2 {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidDatabase, major)
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported ip version %d", ErrInvalidDatabase, r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(metaStart-len(metadataMarker)) {
+		return nil, fmt.Errorf("%w: search tree is out of file", ErrInvalidDatabase)
+	}
+	r.data = buf[treeSize+dataSectionSeparator : metaStart-len(metadataMarker)]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// DatabaseType is type of database from metadata, e.g. GeoLite2-City
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup return decoded record of network which ip belongs to, ok is false if ip isn't in database.
+// Maps are map[string]interface{}, unsigned integers are uint64, uint128 is *big.Int.
+func (r *Reader) Lookup(ip net.IP) (record interface{}, ok bool, err error) {
+	node, bits := uint(0), ip.To16()
+	if bits == nil {
+		return nil, false, fmt.Errorf("invalid ip %q", ip)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		bits, node = ip4, r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, false, nil
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - i%8)) & 1
+		node = r.readNode(node, uint(bit))
+	}
+	if node == r.nodeCount {
+		return nil, false, nil
+	}
+	if node < r.nodeCount {
+		return nil, false, fmt.Errorf("%w: search tree is deeper than ip", ErrInvalidDatabase)
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, false, fmt.Errorf("%w: record is out of data section", ErrInvalidDatabase)
+	}
+	record, _, err = decodeData(r.data, offset, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, true, nil
+}
+
+// readNode return left (bit 0) or right (bit 1) record of node
+func (r *Reader) readNode(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.buf[node*8+bit*4:]))
+	}
+}
+
+func metaUint(meta map[string]interface{}, key string) (uint, bool) {
+	v, ok := meta[key].(uint64)
+	return uint(v), ok
+}
+
+// types of data section fields
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// max size in bytes of unsigned integer types, leading zeros are omitted
+var uintSizes = map[int]uint{typeUint16: 2, typeUint32: 4, typeUint64: 8}
+
+// decodeData decode field at offset of data section and return offset after it.
+// Pointers are offsets in the same section, field after pointer goes on after pointer itself.
+func decodeData(data []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDataDepth {
+		return nil, 0, fmt.Errorf("%w: nesting is too deep", ErrInvalidDatabase)
+	}
+	if offset >= uint(len(data)) {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidDatabase)
+	}
+
+	ctrl := data[offset]
+	offset++
+	typ := int(ctrl >> 5)
+
+	if typ == typePointer {
+		pointer, next, err := decodePointer(data, ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := decodeData(data, pointer, depth+1)
+		return value, next, err
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(data)) {
+			return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidDatabase)
+		}
+		typ = 7 + int(data[offset])
+		offset++
+	}
+
+	size, offset, err := decodeSize(data, ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, min(size, uint(len(data))))
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			key, offset, err = decodeData(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key must be string, got %T", ErrInvalidDatabase, key)
+			}
+			value, offset, err = decodeData(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		items := make([]interface{}, 0, min(size, uint(len(data))))
+		for i := uint(0); i < size; i++ {
+			var item interface{}
+			item, offset, err = decodeData(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+		}
+		return items, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("%w: invalid boolean size %d", ErrInvalidDatabase, size)
+		}
+		return size == 1, offset, nil
+	}
+
+	if size > uint(len(data))-offset {
+		return nil, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidDatabase)
+	}
+	b, next := data[offset:offset+size], offset+size
+
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: invalid double size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: invalid float size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > uintSizes[typ] {
+			return nil, 0, fmt.Errorf("%w: invalid unsigned integer size %d", ErrInvalidDatabase, size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: invalid int32 size %d", ErrInvalidDatabase, size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("%w: invalid uint128 size %d", ErrInvalidDatabase, size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported data type %d", ErrInvalidDatabase, typ)
+	}
+}
+
+// decodePointer return offset which pointer refers to and offset after pointer
+func decodePointer(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl>>3)&0x3 + 1
+	if size > uint(len(data))-offset {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidDatabase)
+	}
+	b := data[offset : offset+size]
+
+	var pointer uint
+	if size < 4 {
+		pointer = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		pointer = pointer<<8 | uint(c)
+	}
+	pointer += [...]uint{0, 2048, 526336, 0}[size-1]
+
+	return pointer, offset + size, nil
+}
+
+// decodeSize return size of field of control byte, long sizes take next bytes
+func decodeSize(data []byte, ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if n > uint(len(data))-offset {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", ErrInvalidDatabase)
+	}
+	var v uint
+	for _, c := range data[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	return [...]uint{29, 285, 65821}[n-1] + v, offset + n, nil
+}
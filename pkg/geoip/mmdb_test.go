@@ -0,0 +1,184 @@
+package geoip
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeData(t *testing.T) {
+	tc := []struct {
+		name        string
+		input       []byte
+		offset      uint
+		checkResult func(t *testing.T, value interface{}, next uint, err error)
+	}{
+		{
+			name: "OK map",
+			// {"a": uint16(300), "b": [true, int32(-1)]}
+			input: []byte{0xe2, 0x41, 'a', 0xa2, 0x01, 0x2c, 0x41, 'b', 0x02, 0x04, 0x01, 0x07, 0x04, 0x01, 0xff, 0xff, 0xff, 0xff},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(18), next)
+				assert.Equal(t, map[string]interface{}{
+					"a": uint64(300),
+					"b": []interface{}{true, int64(-1)},
+				}, value)
+			},
+		},
+		{
+			name: "OK pointer",
+			// "ab", then map {"k": pointer to "ab"}
+			input:  []byte{0x42, 'a', 'b', 0xe1, 0x41, 'k', 0x20, 0x00},
+			offset: 3,
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(8), next) // note: after pointer, not after "ab"
+				assert.Equal(t, map[string]interface{}{"k": "ab"}, value)
+			},
+		},
+		{
+			name:  "OK long string",
+			input: append([]byte{0x5d, 0x01}, []byte("123456789012345678901234567890")...),
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "123456789012345678901234567890", value)
+			},
+		},
+		{
+			name:  "OK uint128",
+			input: []byte{0x02, 0x03, 0x01, 0x00},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, big.NewInt(256), value)
+			},
+		},
+		{
+			name:  "OK double",
+			input: []byte{0x68, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 1.5, value)
+			},
+		},
+		{
+			name:  "truncated string",
+			input: []byte{0x45, 'a', 'b'},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "pointer out of data",
+			input: []byte{0x20, 0xff},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "pointer loop",
+			input: []byte{0x20, 0x00},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "key isn't string",
+			input: []byte{0xe1, 0xa1, 0x01, 0x41, 'a'},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "oversized uint16",
+			input: []byte{0xa3, 0x01, 0x02, 0x03},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "huge array",
+			input: []byte{0x1f, 0x04, 0xff, 0xff, 0xff},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+		{
+			name:  "data cache container",
+			input: []byte{0x00, 0x05},
+			checkResult: func(t *testing.T, value interface{}, next uint, err error) {
+				assert.ErrorIs(t, err, ErrInvalidDatabase)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			value, next, err := decodeData(test.input, test.offset, 0)
+			test.checkResult(t, value, next, err)
+		})
+	}
+}
+
+func TestReadNode(t *testing.T) {
+	tc := []struct {
+		name        string
+		recordSize  uint
+		buf         []byte
+		left, right uint
+	}{
+		{
+			name:       "24 bit",
+			recordSize: 24,
+			buf:        []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			left:       0x010203,
+			right:      0x040506,
+		},
+		{
+			name:       "28 bit",
+			recordSize: 28,
+			buf:        []byte{0x01, 0x02, 0x03, 0xab, 0x04, 0x05, 0x06},
+			left:       0xa010203,
+			right:      0xb040506,
+		},
+		{
+			name:       "32 bit",
+			recordSize: 32,
+			buf:        []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			left:       0x01020304,
+			right:      0x05060708,
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Reader{buf: test.buf, recordSize: test.recordSize, nodeCount: 1}
+			assert.Equal(t, test.left, r.readNode(0, 0))
+			assert.Equal(t, test.right, r.readNode(0, 1))
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	_, err := NewReader([]byte("no metadata"))
+	assert.ErrorIs(t, err, ErrInvalidDatabase)
+
+	// {"binary_format_major_version": uint16(2), "ip_version": uint16(6), "node_count": uint32(1000), "record_size": uint16(24)}
+	meta := []byte{0xe4,
+		0x5b}
+	meta = append(meta, "binary_format_major_version"...)
+	meta = append(meta, 0xa1, 0x02)
+	meta = append(meta, 0x4a)
+	meta = append(meta, "ip_version"...)
+	meta = append(meta, 0xa1, 0x06)
+	meta = append(meta, 0x4a)
+	meta = append(meta, "node_count"...)
+	meta = append(meta, 0xc2, 0x03, 0xe8)
+	meta = append(meta, 0x4b)
+	meta = append(meta, "record_size"...)
+	meta = append(meta, 0xa1, 0x18)
+
+	_, err = NewReader(append(append(make([]byte, 32), metadataMarker...), meta...))
+	assert.ErrorIs(t, err, ErrInvalidDatabase, "search tree of 1000 nodes is out of file")
+}
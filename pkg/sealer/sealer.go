@@ -0,0 +1,62 @@
+package sealer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+var (
+	ErrInvalidKey    = fmt.Errorf("key of sealer must be 32 bytes long")
+	ErrInvalidSealed = fmt.Errorf("sealed value is malformed or was encrypted by other key")
+)
+
+// Sealer encrypt secrets stored in database with AES-256-GCM,
+// sealed value is base64 of nonce followed by ciphertext and tag.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+func New(key []byte) (*Sealer, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+func (s Sealer) Seal(plain []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, plain, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s Sealer) Open(sealed string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return nil, ErrInvalidSealed
+	}
+
+	nonce, ciphertext := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidSealed
+	}
+
+	return plain, nil
+}
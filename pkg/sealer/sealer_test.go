@@ -0,0 +1,90 @@
+package sealer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealer(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	s, err := New(key)
+	require.NoError(t, err)
+	other, err := New(otherKey)
+	require.NoError(t, err)
+
+	plain := []byte("secret")
+	sealed, err := s.Seal(plain)
+	require.NoError(t, err)
+
+	tc := []struct {
+		name        string
+		sealer      *Sealer
+		sealed      string
+		checkResult func(t *testing.T, opened []byte, err error)
+	}{
+		{
+			name:   "OK",
+			sealer: s,
+			sealed: sealed,
+			checkResult: func(t *testing.T, opened []byte, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, plain, opened)
+			},
+		},
+		{
+			name:   "other key",
+			sealer: other,
+			sealed: sealed,
+			checkResult: func(t *testing.T, opened []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidSealed)
+			},
+		},
+		{
+			name:   "tampered",
+			sealer: s,
+			sealed: sealed[:len(sealed)-4] + "AAAA",
+			checkResult: func(t *testing.T, opened []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidSealed)
+			},
+		},
+		{
+			name:   "not base64",
+			sealer: s,
+			sealed: "!!!",
+			checkResult: func(t *testing.T, opened []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidSealed)
+			},
+		},
+		{
+			name:   "too short",
+			sealer: s,
+			sealed: "AAAA",
+			checkResult: func(t *testing.T, opened []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidSealed)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			opened, err := test.sealer.Open(test.sealed)
+			test.checkResult(t, opened, err)
+		})
+	}
+
+	t.Run("nonce is random", func(t *testing.T) {
+		again, err := s.Seal(plain)
+		assert.NoError(t, err)
+		assert.NotEqual(t, sealed, again)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := New([]byte("short"))
+		assert.ErrorIs(t, err, ErrInvalidKey)
+	})
+}
@@ -33,18 +33,60 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// SendEmailVerification mocks base method.
+func (m *MockInterface) SendEmailVerification(link, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmailVerification", link, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendEmailVerification indicates an expected call of SendEmailVerification.
+func (mr *MockInterfaceMockRecorder) SendEmailVerification(link, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmailVerification", reflect.TypeOf((*MockInterface)(nil).SendEmailVerification), link, to)
+}
+
+// SendImpossibleTravel mocks base method.
+func (m *MockInterface) SendImpossibleTravel(ip, location, previousLocation, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendImpossibleTravel", ip, location, previousLocation, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendImpossibleTravel indicates an expected call of SendImpossibleTravel.
+func (mr *MockInterfaceMockRecorder) SendImpossibleTravel(ip, location, previousLocation, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendImpossibleTravel", reflect.TypeOf((*MockInterface)(nil).SendImpossibleTravel), ip, location, previousLocation, to)
+}
+
 // SendLoginFromNewIP mocks base method.
-func (m *MockInterface) SendLoginFromNewIP(ip, to string) error {
+func (m *MockInterface) SendLoginFromNewIP(ip, location, to string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendLoginFromNewIP", ip, to)
+	ret := m.ctrl.Call(m, "SendLoginFromNewIP", ip, location, to)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendLoginFromNewIP indicates an expected call of SendLoginFromNewIP.
-func (mr *MockInterfaceMockRecorder) SendLoginFromNewIP(ip, to interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) SendLoginFromNewIP(ip, location, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendLoginFromNewIP", reflect.TypeOf((*MockInterface)(nil).SendLoginFromNewIP), ip, location, to)
+}
+
+// SendMagicLink mocks base method.
+func (m *MockInterface) SendMagicLink(link, ip, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMagicLink", link, ip, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMagicLink indicates an expected call of SendMagicLink.
+func (mr *MockInterfaceMockRecorder) SendMagicLink(link, ip, to interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendLoginFromNewIP", reflect.TypeOf((*MockInterface)(nil).SendLoginFromNewIP), ip, to)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMagicLink", reflect.TypeOf((*MockInterface)(nil).SendMagicLink), link, ip, to)
 }
 
 // SendMail mocks base method.
@@ -65,3 +107,31 @@ func (mr *MockInterfaceMockRecorder) SendMail(subject, body interface{}, to ...i
 	varargs := append([]interface{}{subject, body}, to...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMail", reflect.TypeOf((*MockInterface)(nil).SendMail), varargs...)
 }
+
+// SendPasswordReset mocks base method.
+func (m *MockInterface) SendPasswordReset(link, ip, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendPasswordReset", link, ip, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendPasswordReset indicates an expected call of SendPasswordReset.
+func (mr *MockInterfaceMockRecorder) SendPasswordReset(link, ip, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendPasswordReset", reflect.TypeOf((*MockInterface)(nil).SendPasswordReset), link, ip, to)
+}
+
+// SendRefreshTokenReuse mocks base method.
+func (m *MockInterface) SendRefreshTokenReuse(ip, to string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendRefreshTokenReuse", ip, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendRefreshTokenReuse indicates an expected call of SendRefreshTokenReuse.
+func (mr *MockInterfaceMockRecorder) SendRefreshTokenReuse(ip, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendRefreshTokenReuse", reflect.TypeOf((*MockInterface)(nil).SendRefreshTokenReuse), ip, to)
+}
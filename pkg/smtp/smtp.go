@@ -9,7 +9,12 @@ import (
 
 type Interface interface {
 	SendMail(subject, body string, to ...string) error
-	SendLoginFromNewIP(ip string, to string) error
+	SendLoginFromNewIP(ip string, location string, to string) error
+	SendRefreshTokenReuse(ip string, to string) error
+	SendImpossibleTravel(ip string, location string, previousLocation string, to string) error
+	SendEmailVerification(link string, to string) error
+	SendPasswordReset(link string, ip string, to string) error
+	SendMagicLink(link string, ip string, to string) error
 }
 
 var _ Interface = (*smtp)(nil)
@@ -29,13 +34,43 @@ func New(cfg *Config) *smtp {
 	}
 }
 
-func (s smtp) SendLoginFromNewIP(ip, to string) error {
+// SendLoginFromNewIP email about new ip, location is empty if it's unknown
+func (s smtp) SendLoginFromNewIP(ip, location, to string) error {
 	msg := fmt.Sprintf("Login from new IP address: %s", ip)
+	if location != "" {
+		msg = fmt.Sprintf("Login from new IP address: %s (%s)", ip, location)
+	}
 	return s.SendMail("Login from new IP.", msg, to)
 }
 
+func (s smtp) SendRefreshTokenReuse(ip, to string) error {
+	msg := fmt.Sprintf("Your session was closed because an already used refresh token was presented from IP address: %s. Please log in again.", ip)
+	return s.SendMail("Session closed for security reasons.", msg, to)
+}
+
+func (s smtp) SendImpossibleTravel(ip, location, previousLocation, to string) error {
+	msg := fmt.Sprintf("Your session was used from IP address %s (%s) too soon after it was used in %s to travel in between. The session can not be continued, please log in again. If it was not you, change your password.", ip, location, previousLocation)
+	return s.SendMail("Suspicious use of your session.", msg, to)
+}
+
+func (s smtp) SendEmailVerification(link, to string) error {
+	msg := fmt.Sprintf("Please confirm your email address by following the link: <a href=\"%s\">%s</a>. If you did not sign up, ignore this message.", link, link)
+	return s.SendMail("Confirm your email.", msg, to)
+}
+
+func (s smtp) SendPasswordReset(link, ip, to string) error {
+	msg := fmt.Sprintf("Password reset was requested from IP address: %s. To set new password follow the link: <a href=\"%s\">%s</a>. The link expires soon and works once. If you did not request it, ignore this message.", ip, link, link)
+	return s.SendMail("Reset your password.", msg, to)
+}
+
+func (s smtp) SendMagicLink(link, ip, to string) error {
+	msg := fmt.Sprintf("Login link was requested from IP address: %s. To log in follow the link in the same browser: <a href=\"%s\">%s</a>. The link expires in a few minutes and works once. If you did not request it, ignore this message.", ip, link, link)
+	return s.SendMail("Your login link.", msg, to)
+}
+
 func (s smtp) SendMail(subject, body string, to ...string) error {
-	log.Printf("[SENDING MAIL] from[%s] to[%s] body[%s]\n", s.from, to[0], body)
+	// body isn't logged, it contains links which log in or reset password
+	log.Printf("[SENDING MAIL] from[%s] to[%s] subject[%s]\n", s.from, to[0], subject)
 
 	m := mail.NewMessage()
 	m.SetHeader("From", s.from)
@@ -0,0 +1,14 @@
+package totp
+
+import "time"
+
+type Config struct {
+	// name of service shown by authenticator app
+	Issuer string
+	// length of code, 6 by default
+	Digits int
+	// life time of code, 30s by default
+	Period time.Duration
+	// count of periods before and after current one which codes are accepted, covers clock drift of device, 1 by default
+	Skew int
+}
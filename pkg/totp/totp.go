@@ -0,0 +1,153 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // HMAC-SHA1 is the algorithm supported by every authenticator app
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// count of random bytes in secret, RFC 4226 recommends 160 bits
+const secretBytes = 20
+
+var (
+	ErrInvalidSecret = fmt.Errorf("totp secret is not valid base32")
+
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// TOTP generate and validate time-based one-time passwords (RFC 6238)
+// compatible with authenticator apps: HMAC-SHA1, secret is base32 without padding.
+type TOTP struct {
+	issuer string
+	digits int
+	period time.Duration
+	skew   int
+}
+
+func New(cfg *Config) *TOTP {
+	t := &TOTP{
+		issuer: "",
+		digits: 6,
+		period: 30 * time.Second,
+		skew:   1,
+	}
+
+	if cfg == nil {
+		return t
+	}
+	t.issuer = cfg.Issuer
+	if cfg.Digits > 0 {
+		t.digits = cfg.Digits
+	}
+	if cfg.Period > 0 {
+		t.period = cfg.Period
+	}
+	if cfg.Skew > 0 {
+		t.skew = cfg.Skew
+	}
+
+	return t
+}
+
+// GenerateSecret return new random secret encoded by base32
+func (t TOTP) GenerateSecret() (string, error) {
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI return otpauth:// key uri, which is usually shown to user as QR code
+func (t TOTP) URI(account, secret string) string {
+	label := account
+	if t.issuer != "" {
+		label = t.issuer + ":" + account
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	if t.issuer != "" {
+		query.Set("issuer", t.issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(t.digits))
+	query.Set("period", strconv.Itoa(int(t.period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Step return number of period which contains moment
+func (t TOTP) Step(moment time.Time) int64 {
+	return moment.Unix() / int64(t.period/time.Second)
+}
+
+// Code return code of secret for period which contains moment
+func (t TOTP) Code(secret string, moment time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, t.Step(moment)), nil
+}
+
+// Validate check code against periods around moment and return step of matched one,
+// caller should keep the step and refuse codes of the same or earlier steps to prevent replay.
+func (t TOTP) Validate(secret, code string, moment time.Time) (step int64, ok bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != t.digits {
+		return 0, false
+	}
+
+	current := t.Step(moment)
+	for i := -t.skew; i <= t.skew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(t.code(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// code is HOTP of step (RFC 4226)
+func (t TOTP) code(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", t.digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
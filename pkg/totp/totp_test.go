@@ -0,0 +1,144 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// secret of test vectors from RFC 6238 appendix B
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	totp := New(&Config{Digits: 8})
+
+	tc := []struct {
+		name   string
+		moment int64
+		code   string
+	}{
+		{name: "59", moment: 59, code: "94287082"},
+		{name: "1111111109", moment: 1111111109, code: "07081804"},
+		{name: "1111111111", moment: 1111111111, code: "14050471"},
+		{name: "1234567890", moment: 1234567890, code: "89005924"},
+		{name: "2000000000", moment: 2000000000, code: "69279037"},
+		{name: "20000000000", moment: 20000000000, code: "65353130"},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := totp.Code(rfcSecret, time.Unix(test.moment, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, test.code, code)
+		})
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		_, err := totp.Code("not base32!", time.Unix(59, 0))
+		assert.ErrorIs(t, err, ErrInvalidSecret)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	totp := New(nil)
+
+	now := time.Unix(1111111111, 0)
+	current := totp.Step(now)
+	code := func(step int64) string {
+		c, err := totp.Code(rfcSecret, time.Unix(step*30, 0))
+		require.NoError(t, err)
+		return c
+	}
+
+	tc := []struct {
+		name   string
+		secret string
+		code   string
+		step   int64
+		ok     bool
+	}{
+		{
+			name:   "current period",
+			secret: rfcSecret,
+			code:   code(current),
+			step:   current,
+			ok:     true,
+		},
+		{
+			name:   "previous period",
+			secret: rfcSecret,
+			code:   code(current - 1),
+			step:   current - 1,
+			ok:     true,
+		},
+		{
+			name:   "next period",
+			secret: rfcSecret,
+			code:   code(current + 1),
+			step:   current + 1,
+			ok:     true,
+		},
+		{
+			name:   "outside of skew",
+			secret: rfcSecret,
+			code:   code(current - 2),
+			ok:     false,
+		},
+		{
+			name:   "wrong length",
+			secret: rfcSecret,
+			code:   code(current)[1:],
+			ok:     false,
+		},
+		{
+			name:   "invalid secret",
+			secret: "not base32!",
+			code:   code(current),
+			ok:     false,
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := totp.Validate(test.secret, test.code, now)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.step, step)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	totp := New(nil)
+
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	key, err := decodeSecret(secret)
+	assert.NoError(t, err)
+	assert.Len(t, key, secretBytes)
+
+	other, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
+
+func TestURI(t *testing.T) {
+	totp := New(&Config{Issuer: "medods"})
+
+	uri, err := url.Parse(totp.URI("user@mail.com", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/medods:user@mail.com", uri.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {"JBSWY3DPEHPK3PXP"},
+		"issuer":    {"medods"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
@@ -0,0 +1,228 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Minimal CBOR (RFC 8949) needed by WebAuthn: attestation object and COSE keys.
+// Indefinite lengths, tags and floats are never produced by authenticators for these structures, so they are rejected.
+
+// nesting limit protects decoder from stack exhaustion by crafted input
+const maxCBORDepth = 16
+
+var ErrInvalidCBOR = fmt.Errorf("invalid cbor")
+
+// decodeCBOR decode first item of data and return rest of input.
+// Maps are map[interface{}]interface{} with int64 or string keys, unsigned and negative integers are int64.
+func decodeCBOR(data []byte) (value interface{}, rest []byte, err error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("%w: nesting is too deep", ErrInvalidCBOR)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOR)
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22:
+			return nil, data[1:], nil
+		default:
+			return nil, nil, fmt.Errorf("%w: unsupported simple value %d", ErrInvalidCBOR, info)
+		}
+	}
+
+	arg, data, err := decodeArgument(info, data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOR)
+		}
+		if major == 2 {
+			return append([]byte(nil), data[:arg]...), data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+	case 4:
+		// every item takes at least one byte
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOR)
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			item, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOR)
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			key, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key %T", ErrInvalidCBOR, key)
+			}
+			if _, ok := m[key]; ok {
+				return nil, nil, fmt.Errorf("%w: duplicate map key %v", ErrInvalidCBOR, key)
+			}
+			value, data, err = decodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, data, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported major type %d", ErrInvalidCBOR, major)
+	}
+}
+
+func decodeArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	case info >= 24 && info <= 27:
+		return 0, nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidCBOR)
+	default:
+		return 0, nil, fmt.Errorf("%w: indefinite length is not supported", ErrInvalidCBOR)
+	}
+}
+
+// MarshalCBOR encode value of types produced by decoder, plus int, map[int]interface{} and map[string]interface{}.
+// Map keys are sorted, so output is deterministic. Used by software authenticator of tests.
+func MarshalCBOR(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{0xf6}, nil
+	case bool:
+		if v {
+			return []byte{0xf5}, nil
+		}
+		return []byte{0xf4}, nil
+	case int:
+		return MarshalCBOR(int64(v))
+	case int64:
+		if v >= 0 {
+			return encodeHead(0, uint64(v)), nil
+		}
+		return encodeHead(1, uint64(-1-v)), nil
+	case []byte:
+		return append(encodeHead(2, uint64(len(v))), v...), nil
+	case string:
+		return append(encodeHead(3, uint64(len(v))), v...), nil
+	case []interface{}:
+		out := encodeHead(4, uint64(len(v)))
+		for _, item := range v {
+			b, err := MarshalCBOR(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	case map[int]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for key, value := range v {
+			m[int64(key)] = value
+		}
+		return MarshalCBOR(m)
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		return MarshalCBOR(m)
+	case map[interface{}]interface{}:
+		type pair struct{ key, value []byte }
+		pairs := make([]pair, 0, len(v))
+		for key, value := range v {
+			k, err := MarshalCBOR(key)
+			if err != nil {
+				return nil, err
+			}
+			val, err := MarshalCBOR(value)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, pair{k, val})
+		}
+		// canonical order of RFC 7049: shorter keys first, then bytewise
+		sort.Slice(pairs, func(i, j int) bool {
+			if len(pairs[i].key) != len(pairs[j].key) {
+				return len(pairs[i].key) < len(pairs[j].key)
+			}
+			return string(pairs[i].key) < string(pairs[j].key)
+		})
+
+		out := encodeHead(5, uint64(len(v)))
+		for _, p := range pairs {
+			out = append(out, p.key...)
+			out = append(out, p.value...)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %T", ErrInvalidCBOR, value)
+	}
+}
+
+func encodeHead(major byte, arg uint64) []byte {
+	m := major << 5
+	switch {
+	case arg < 24:
+		return []byte{m | byte(arg)}
+	case arg <= math.MaxUint8:
+		return []byte{m | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{m | 25}, uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{m | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{m | 27}, arg)
+	}
+}
@@ -0,0 +1,105 @@
+package webauthn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBOR(t *testing.T) {
+	tc := []struct {
+		name        string
+		input       []byte
+		checkResult func(t *testing.T, value interface{}, rest []byte, err error)
+	}{
+		{
+			name:  "OK",
+			input: mustMarshalCBOR(t, map[int]interface{}{1: 2, -1: []byte{1}, 3: "a", 4: []interface{}{true, nil, int64(-300)}}),
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, rest)
+				assert.Equal(t, map[interface{}]interface{}{
+					int64(1):  int64(2),
+					int64(-1): []byte{1},
+					int64(3):  "a",
+					int64(4):  []interface{}{true, nil, int64(-300)},
+				}, value)
+			},
+		},
+		{
+			name:  "rest of input",
+			input: []byte{0x01, 0x02},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), value)
+				assert.Equal(t, []byte{0x02}, rest)
+			},
+		},
+		{
+			name:  "truncated byte string",
+			input: []byte{0x45, 0x01},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+		{
+			name:  "huge array",
+			input: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+		{
+			name:  "indefinite length",
+			input: []byte{0x9f, 0x01, 0xff},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+		{
+			name:  "duplicate key",
+			input: []byte{0xa2, 0x01, 0x01, 0x01, 0x02},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+		{
+			name:  "too deep",
+			input: append(repeat(0x81, maxCBORDepth+1), 0x01),
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+		{
+			name:  "tag",
+			input: []byte{0xc1, 0x01},
+			checkResult: func(t *testing.T, value interface{}, rest []byte, err error) {
+				assert.ErrorIs(t, err, ErrInvalidCBOR)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			value, rest, err := decodeCBOR(test.input)
+			test.checkResult(t, value, rest, err)
+		})
+	}
+}
+
+func mustMarshalCBOR(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	b, err := MarshalCBOR(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func repeat(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
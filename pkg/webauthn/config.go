@@ -0,0 +1,14 @@
+package webauthn
+
+import "time"
+
+type Config struct {
+	// relying party id, registrable domain of site, e.g. example.com
+	RPID string
+	// name of site shown by authenticator
+	RPName string
+	// allowed origins of client data, e.g. https://example.com
+	Origins []string
+	// time given to user for ceremony, passed to browser
+	Timeout time.Duration
+}
@@ -0,0 +1,130 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms (RFC 9053) accepted for credentials, in order of preference
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// labels of COSE key parameters (RFC 9052)
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseEC2Crv = -1
+	coseEC2X   = -2
+	coseEC2Y   = -3
+
+	coseOKPCrv = -1
+	coseOKPX   = -2
+
+	coseRSAN = -1
+	coseRSAE = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+const minRSABits = 2048
+
+var ErrUnsupportedKey = fmt.Errorf("unsupported credential public key")
+
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey decode COSE_Key of credential
+func parsePublicKey(cose []byte) (publicKey, error) {
+	value, rest, err := decodeCBOR(cose)
+	if err != nil {
+		return publicKey{}, err
+	}
+	if len(rest) != 0 {
+		return publicKey{}, fmt.Errorf("%w: trailing bytes after key", ErrInvalidCBOR)
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return publicKey{}, fmt.Errorf("%w: key is not a map", ErrUnsupportedKey)
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseEC2Crv)].(int64)
+		x, _ := m[int64(coseEC2X)].([]byte)
+		y, _ := m[int64(coseEC2Y)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, fmt.Errorf("%w: invalid P-256 key", ErrUnsupportedKey)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, fmt.Errorf("%w: point is not on curve", ErrUnsupportedKey)
+		}
+		return publicKey{alg: alg, key: key}, nil
+
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseOKPCrv)].(int64)
+		x, _ := m[int64(coseOKPX)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSABits || len(e) == 0 || len(e) > 4 || key.E < 3 {
+			return publicKey{}, fmt.Errorf("%w: invalid RSA key", ErrUnsupportedKey)
+		}
+		return publicKey{alg: alg, key: key}, nil
+
+	default:
+		return publicKey{}, fmt.Errorf("%w: kty %d alg %d", ErrUnsupportedKey, kty, alg)
+	}
+}
+
+func (k publicKey) verify(data, signature []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+// MarshalES256Key encode P-256 public key as COSE_Key, used by software authenticator of tests
+func MarshalES256Key(key *ecdsa.PublicKey) ([]byte, error) {
+	return MarshalCBOR(map[int]interface{}{
+		coseKty:    coseKtyEC2,
+		coseAlg:    AlgES256,
+		coseEC2Crv: coseCrvP256,
+		coseEC2X:   key.X.FillBytes(make([]byte, 32)),
+		coseEC2Y:   key.Y.FillBytes(make([]byte, 32)),
+	})
+}
@@ -0,0 +1,427 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	credentialType = "public-key"
+
+	// passkey replaces password and second factor, so user must be verified by authenticator
+	userVerificationRequired = "required"
+	residentKeyPreferred     = "preferred"
+	// attestation is not verified, authenticator model is not restricted
+	attestationNone = "none"
+)
+
+// flags of authenticator data
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+	flagExtensionData    = 0x80
+)
+
+const (
+	rpIDHashLength    = 32
+	authDataMinLength = rpIDHashLength + 1 + 4
+	aaguidLength      = 16
+	// limit of WebAuthn Level 3
+	maxCredentialIDLength = 1023
+)
+
+var (
+	ErrInvalidResponse   = fmt.Errorf("invalid authenticator response")
+	ErrChallengeMismatch = fmt.Errorf("challenge mismatch")
+	ErrOriginMismatch    = fmt.Errorf("origin is not allowed")
+	ErrRPIDMismatch      = fmt.Errorf("relying party id mismatch")
+	ErrUserNotVerified   = fmt.Errorf("user is not verified by authenticator")
+	ErrInvalidSignature  = fmt.Errorf("invalid assertion signature")
+	// sign count didn't grow, authenticator is probably cloned
+	ErrSignCount = fmt.Errorf("sign count of authenticator is not increased")
+)
+
+// URLEncodedBytes is encoded in JSON as base64url without padding, as WebAuthn clients do
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	*b = decoded
+	return nil
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id" swaggertype:"string"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string          `json:"type"`
+	ID   URLEncodedBytes `json:"id" swaggertype:"string"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is argument of navigator.credentials.create() in publicKey field
+type CreationOptions struct {
+	Challenge              URLEncodedBytes        `json:"challenge" swaggertype:"string"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is argument of navigator.credentials.get() in publicKey field
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge" swaggertype:"string"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    URLEncodedBytes `json:"clientDataJSON" binding:"required" swaggertype:"string"`
+	AttestationObject URLEncodedBytes `json:"attestationObject" binding:"required" swaggertype:"string"`
+}
+
+// AttestationResponse is PublicKeyCredential returned by navigator.credentials.create()
+type AttestationResponse struct {
+	RawID    URLEncodedBytes                  `json:"rawId" binding:"required" swaggertype:"string"`
+	Type     string                           `json:"type" binding:"required"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    URLEncodedBytes `json:"clientDataJSON" binding:"required" swaggertype:"string"`
+	AuthenticatorData URLEncodedBytes `json:"authenticatorData" binding:"required" swaggertype:"string"`
+	Signature         URLEncodedBytes `json:"signature" binding:"required" swaggertype:"string"`
+	UserHandle        URLEncodedBytes `json:"userHandle,omitempty" swaggertype:"string"`
+}
+
+// AssertionResponse is PublicKeyCredential returned by navigator.credentials.get()
+type AssertionResponse struct {
+	RawID    URLEncodedBytes                `json:"rawId" binding:"required" swaggertype:"string"`
+	Type     string                         `json:"type" binding:"required"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+// Credential is public key credential created by authenticator on registration
+type Credential struct {
+	ID []byte
+	// COSE_Key
+	PublicKey []byte
+	SignCount uint32
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// set only if attested credential data is included
+	credentialID []byte
+	publicKey    []byte
+}
+
+// WebAuthn is relying party of WebAuthn Level 2 ceremonies for passkeys.
+// Attestation is not requested, so any authenticator is accepted, its key proves possession on every login.
+type WebAuthn struct {
+	rp      RelyingParty
+	rpIDSum [sha256.Size]byte
+	origins []string
+	timeout time.Duration
+}
+
+func New(cfg *Config) *WebAuthn {
+	return &WebAuthn{
+		rp:      RelyingParty{ID: cfg.RPID, Name: cfg.RPName},
+		rpIDSum: sha256.Sum256([]byte(cfg.RPID)),
+		origins: cfg.Origins,
+		timeout: cfg.Timeout,
+	}
+}
+
+// CreationOptions build options of registration, existing credentials of user are excluded,
+// so the same authenticator isn't registered twice
+func (w WebAuthn) CreationOptions(challenge []byte, user UserEntity, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        w.rp,
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: credentialType, Alg: AlgES256},
+			{Type: credentialType, Alg: AlgEdDSA},
+			{Type: credentialType, Alg: AlgRS256},
+		},
+		Timeout:            w.timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      residentKeyPreferred,
+			UserVerification: userVerificationRequired,
+		},
+		Attestation: attestationNone,
+	}
+}
+
+// RequestOptions build options of login with one of allowed credentials
+func (w WebAuthn) RequestOptions(challenge []byte, allow [][]byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          w.timeout.Milliseconds(),
+		RPID:             w.rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerificationRequired,
+	}
+}
+
+// Challenge return challenge claimed by client data, caller must check that it was issued and not used yet,
+// then pass it to verification
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	return challenge, nil
+}
+
+// VerifyRegistration verify response of registration ceremony (WebAuthn Level 2, 7.1) and return new credential
+func (w WebAuthn) VerifyRegistration(resp AttestationResponse, challenge []byte) (Credential, error) {
+	if resp.Type != credentialType {
+		return Credential{}, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, resp.Type)
+	}
+	if err := w.verifyClientData(resp.Response.ClientDataJSON, ceremonyCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+
+	rawAuthData, err := parseAttestationObject(resp.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := w.verifyAuthenticatorData(authData); err != nil {
+		return Credential{}, err
+	}
+	if authData.flags&flagAttestedCredData == 0 {
+		return Credential{}, fmt.Errorf("%w: attested credential data is missing", ErrInvalidResponse)
+	}
+	if !bytes.Equal(authData.credentialID, resp.RawID) {
+		return Credential{}, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+	if _, err := parsePublicKey(authData.publicKey); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion verify response of authentication ceremony (WebAuthn Level 2, 7.2) by stored credential
+// and return new sign count of authenticator which should be saved
+func (w WebAuthn) VerifyAssertion(resp AssertionResponse, challenge []byte, cred Credential) (signCount uint32, err error) {
+	if resp.Type != credentialType {
+		return 0, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, resp.Type)
+	}
+	if !bytes.Equal(resp.RawID, cred.ID) {
+		return 0, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+	if err := w.verifyClientData(resp.Response.ClientDataJSON, ceremonyGet, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, resp.Response.Signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	// authenticators without counter always report 0
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.signCount, nil
+}
+
+func (w WebAuthn) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidResponse, err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q", ErrInvalidResponse, cd.Type)
+	}
+
+	claimed, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(claimed, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	if cd.CrossOrigin || !w.allowedOrigin(cd.Origin) {
+		return fmt.Errorf("%w: %q", ErrOriginMismatch, cd.Origin)
+	}
+
+	return nil
+}
+
+func (w WebAuthn) verifyAuthenticatorData(authData authenticatorData) error {
+	if subtle.ConstantTimeCompare(authData.rpIDHash, w.rpIDSum[:]) != 1 {
+		return ErrRPIDMismatch
+	}
+	if authData.flags&flagUserPresent == 0 || authData.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func (w WebAuthn) allowedOrigin(origin string) bool {
+	for _, o := range w.origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAttestationObject return authenticator data of attestation object, statement is ignored
+// because attestation "none" is requested
+func parseAttestationObject(raw []byte) ([]byte, error) {
+	value, rest, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing bytes after attestation object", ErrInvalidCBOR)
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrInvalidResponse)
+	}
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: authData is missing", ErrInvalidResponse)
+	}
+	return authData, nil
+}
+
+func parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < authDataMinLength {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data is too short", ErrInvalidResponse)
+	}
+
+	authData := authenticatorData{
+		rpIDHash:  raw[:rpIDHashLength],
+		flags:     raw[rpIDHashLength],
+		signCount: binary.BigEndian.Uint32(raw[rpIDHashLength+1:]),
+	}
+	rest := raw[authDataMinLength:]
+
+	if authData.flags&flagAttestedCredData != 0 {
+		if len(rest) < aaguidLength+2 {
+			return authenticatorData{}, fmt.Errorf("%w: attested credential data is too short", ErrInvalidResponse)
+		}
+		rest = rest[aaguidLength:]
+		idLength := int(binary.BigEndian.Uint16(rest))
+		rest = rest[2:]
+		if idLength == 0 || idLength > maxCredentialIDLength || idLength > len(rest) {
+			return authenticatorData{}, fmt.Errorf("%w: invalid credential id length", ErrInvalidResponse)
+		}
+		authData.credentialID = append([]byte(nil), rest[:idLength]...)
+		rest = rest[idLength:]
+
+		// key is CBOR item of unknown length, its end is found by decoding
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, err
+		}
+		authData.publicKey = append([]byte(nil), rest[:len(rest)-len(afterKey)]...)
+		rest = afterKey
+	}
+
+	if authData.flags&flagExtensionData != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return authenticatorData{}, err
+		}
+	}
+
+	if len(rest) != 0 {
+		return authenticatorData{}, fmt.Errorf("%w: trailing bytes after authenticator data", ErrInvalidResponse)
+	}
+
+	return authData, nil
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	result := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, CredentialDescriptor{Type: credentialType, ID: id})
+	}
+	return result
+}
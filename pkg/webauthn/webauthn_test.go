@@ -0,0 +1,273 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"medods/pkg/webauthn"
+	"medods/pkg/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rpID   = "example.com"
+	origin = "https://example.com"
+)
+
+var defaultConfig = &webauthn.Config{
+	RPID:    rpID,
+	RPName:  "Example",
+	Origins: []string{origin},
+	Timeout: time.Minute,
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	w := webauthn.New(defaultConfig)
+	challenge := []byte("challenge")
+	options := w.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("1"), Name: "user@example.com"}, nil)
+
+	tc := []struct {
+		name          string
+		authenticator func(t *testing.T) *webauthntest.Authenticator
+		modify        func(resp *webauthn.AttestationResponse)
+		challenge     []byte
+		checkResult   func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error)
+	}{
+		{
+			name:          "OK",
+			authenticator: newAuthenticator(rpID, origin),
+			modify:        func(resp *webauthn.AttestationResponse) {},
+			challenge:     challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, a.CredentialID, cred.ID)
+				assert.NotEmpty(t, cred.PublicKey)
+				assert.Equal(t, uint32(0), cred.SignCount)
+			},
+		},
+		{
+			name:          "other challenge",
+			authenticator: newAuthenticator(rpID, origin),
+			modify:        func(resp *webauthn.AttestationResponse) {},
+			challenge:     []byte("other"), // note
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrChallengeMismatch)
+			},
+		},
+		{
+			name:          "other origin",
+			authenticator: newAuthenticator(rpID, "https://evil.com"), // note
+			modify:        func(resp *webauthn.AttestationResponse) {},
+			challenge:     challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrOriginMismatch)
+			},
+		},
+		{
+			name:          "other rp id",
+			authenticator: newAuthenticator("evil.com", origin), // note
+			modify:        func(resp *webauthn.AttestationResponse) {},
+			challenge:     challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrRPIDMismatch)
+			},
+		},
+		{
+			name:          "assertion instead of attestation",
+			authenticator: newAuthenticator(rpID, origin),
+			modify: func(resp *webauthn.AttestationResponse) {
+				resp.Response.ClientDataJSON = mustJSON(t, map[string]interface{}{
+					"type":      "webauthn.get",
+					"challenge": "Y2hhbGxlbmdl",
+					"origin":    origin,
+				})
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrInvalidResponse)
+			},
+		},
+		{
+			name:          "other raw id",
+			authenticator: newAuthenticator(rpID, origin),
+			modify: func(resp *webauthn.AttestationResponse) {
+				resp.RawID = []byte("other")
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrInvalidResponse)
+			},
+		},
+		{
+			name:          "malformed attestation object",
+			authenticator: newAuthenticator(rpID, origin),
+			modify: func(resp *webauthn.AttestationResponse) {
+				resp.Response.AttestationObject = resp.Response.AttestationObject[:50]
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, a *webauthntest.Authenticator, cred webauthn.Credential, err error) {
+				assert.Error(t, err)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			a := test.authenticator(t)
+			resp, err := a.Create(options)
+			require.NoError(t, err)
+			test.modify(&resp)
+
+			cred, err := w.VerifyRegistration(resp, test.challenge)
+			test.checkResult(t, a, cred, err)
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	w := webauthn.New(defaultConfig)
+	challenge := []byte("challenge")
+
+	tc := []struct {
+		name        string
+		prepare     func(a *webauthntest.Authenticator, cred *webauthn.Credential)
+		modify      func(resp *webauthn.AssertionResponse)
+		challenge   []byte
+		checkResult func(t *testing.T, signCount uint32, err error)
+	}{
+		{
+			name:      "OK",
+			prepare:   func(a *webauthntest.Authenticator, cred *webauthn.Credential) {},
+			modify:    func(resp *webauthn.AssertionResponse) {},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, uint32(1), signCount)
+			},
+		},
+		{
+			name: "authenticator without counter",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {
+				a.NoCounter = true
+			},
+			modify:    func(resp *webauthn.AssertionResponse) {},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, uint32(0), signCount)
+			},
+		},
+		{
+			name: "cloned authenticator",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {
+				cred.SignCount = 5
+				a.SignCount = 4
+			},
+			modify:    func(resp *webauthn.AssertionResponse) {},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrSignCount)
+			},
+		},
+		{
+			name: "counter reset to 0",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {
+				cred.SignCount = 5
+				a.NoCounter = true
+			},
+			modify:    func(resp *webauthn.AssertionResponse) {},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrSignCount)
+			},
+		},
+		{
+			name:      "other challenge",
+			prepare:   func(a *webauthntest.Authenticator, cred *webauthn.Credential) {},
+			modify:    func(resp *webauthn.AssertionResponse) {},
+			challenge: []byte("other"), // note
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrChallengeMismatch)
+			},
+		},
+		{
+			name:    "tampered authenticator data",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {},
+			modify: func(resp *webauthn.AssertionResponse) {
+				resp.Response.AuthenticatorData[len(resp.Response.AuthenticatorData)-1]++
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrInvalidSignature)
+			},
+		},
+		{
+			name:    "user not verified",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {},
+			modify: func(resp *webauthn.AssertionResponse) {
+				resp.Response.AuthenticatorData[32] &^= 0x04
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrUserNotVerified)
+			},
+		},
+		{
+			name:    "other credential",
+			prepare: func(a *webauthntest.Authenticator, cred *webauthn.Credential) {},
+			modify: func(resp *webauthn.AssertionResponse) {
+				resp.RawID = []byte("other")
+			},
+			challenge: challenge,
+			checkResult: func(t *testing.T, signCount uint32, err error) {
+				assert.ErrorIs(t, err, webauthn.ErrInvalidResponse)
+			},
+		},
+	}
+
+	for _, test := range tc {
+		t.Run(test.name, func(t *testing.T) {
+			a := newAuthenticator(rpID, origin)(t)
+			attestation, err := a.Create(w.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("1")}, nil))
+			require.NoError(t, err)
+			cred, err := w.VerifyRegistration(attestation, challenge)
+			require.NoError(t, err)
+
+			test.prepare(a, &cred)
+			resp, err := a.Get(w.RequestOptions(challenge, [][]byte{cred.ID}))
+			require.NoError(t, err)
+			test.modify(&resp)
+
+			signCount, err := w.VerifyAssertion(resp, test.challenge, cred)
+			test.checkResult(t, signCount, err)
+		})
+	}
+}
+
+func TestURLEncodedBytes(t *testing.T) {
+	b, err := json.Marshal(webauthn.URLEncodedBytes{0xfb, 0xff})
+	require.NoError(t, err)
+	assert.Equal(t, `"-_8"`, string(b))
+
+	var decoded webauthn.URLEncodedBytes
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, webauthn.URLEncodedBytes{0xfb, 0xff}, decoded)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`"+/8="`), &decoded), webauthn.ErrInvalidResponse)
+}
+
+func newAuthenticator(rpID, origin string) func(t *testing.T) *webauthntest.Authenticator {
+	return func(t *testing.T) *webauthntest.Authenticator {
+		a, err := webauthntest.New(rpID, origin)
+		require.NoError(t, err)
+		return a
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,142 @@
+// Package webauthntest provides software authenticator for tests of WebAuthn ceremonies.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"medods/pkg/webauthn"
+)
+
+const credentialIDBytes = 16
+
+// Authenticator is software authenticator with one ES256 credential,
+// it always reports user as present and verified.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// counter of assertions, reported in authenticator data
+	SignCount uint32
+	// keep counter at 0, like some platform authenticators do
+	NoCounter bool
+
+	CredentialID []byte
+	key          *ecdsa.PrivateKey
+}
+
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, credentialIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		CredentialID: id,
+		key:          key,
+	}, nil
+}
+
+// Create answer navigator.credentials.create() with attestation "none"
+func (a *Authenticator) Create(options webauthn.CreationOptions) (webauthn.AttestationResponse, error) {
+	clientDataJSON, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return webauthn.AttestationResponse{}, err
+	}
+
+	publicKey, err := webauthn.MarshalES256Key(&a.key.PublicKey)
+	if err != nil {
+		return webauthn.AttestationResponse{}, err
+	}
+
+	// flags: user present, user verified, attested credential data
+	authData := a.authData(0x01 | 0x04 | 0x40)
+	authData = append(authData, make([]byte, 16)...) // aaguid of "none" attestation is zero
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, publicKey...)
+
+	attestationObject, err := webauthn.MarshalCBOR(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		return webauthn.AttestationResponse{}, err
+	}
+
+	return webauthn.AttestationResponse{
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+		},
+	}, nil
+}
+
+// Get answer navigator.credentials.get() if credential of authenticator is allowed
+func (a *Authenticator) Get(options webauthn.RequestOptions) (webauthn.AssertionResponse, error) {
+	allowed := len(options.AllowCredentials) == 0
+	for _, c := range options.AllowCredentials {
+		if string(c.ID) == string(a.CredentialID) {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return webauthn.AssertionResponse{}, fmt.Errorf("credential is not allowed")
+	}
+
+	clientDataJSON, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return webauthn.AssertionResponse{}, err
+	}
+
+	if !a.NoCounter {
+		a.SignCount++
+	}
+	authData := a.authData(0x01 | 0x04)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return webauthn.AssertionResponse{}, err
+	}
+
+	return webauthn.AssertionResponse{
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+		},
+	}, nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.SignCount)
+}